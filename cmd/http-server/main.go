@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"video-crawler/internal/app"
 	"video-crawler/internal/config"
 )
 
+// shutdownTimeout 收到退出信号后等待进行中请求结束的最长时间
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// 加载配置
 	cfg, err := config.Load(true)
@@ -19,7 +27,29 @@ func main() {
 
 	// 启动HTTP服务
 	log.Printf("启动HTTP服务在端口 %d", cfg.Server.Port)
-	if err := app.Run(); err != nil {
-		log.Fatalf("HTTP服务启动失败: %v", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.Run()
+	}()
+
+	// 收到 SIGINT / SIGTERM 时先停止 HTTP 服务，再写入尚未持久化的数据（如脚本键值存储）
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		app.Close()
+		if err != nil {
+			log.Fatalf("HTTP服务启动失败: %v", err)
+		}
+		return
+	case sig := <-quit:
+		log.Printf("收到信号 %s，正在关闭HTTP服务", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != nil {
+		log.Printf("关闭HTTP服务失败: %v", err)
 	}
+	app.Close()
 }
@@ -91,8 +91,9 @@ export const videoSourceAPI = {
     return result
   },
   
-  saveVideoSource: async (data: any) => {
-    const response = await makeRequest('/api/video-source/save', {
+  // force 为 true 时即使脚本检查有错误也保存
+  saveVideoSource: async (data: any, force = false) => {
+    const response = await makeRequest(`/api/video-source/save${force ? '?force=1' : ''}`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
@@ -103,6 +104,59 @@ export const videoSourceAPI = {
     return result
   },
   
+  // 站点脚本版本列表（不含脚本内容）
+  getRevisions: async (id: string) => {
+    const response = await makeRequest(`/api/video-source/revisions?id=${encodeURIComponent(id)}`)
+    const result = await response.json()
+    return result
+  },
+
+  // 比较两个版本，to 省略时与当前版本比较
+  diffRevisions: async (id: string, from: number, to = 0) => {
+    const response = await makeRequest(`/api/video-source/revision-diff?id=${encodeURIComponent(id)}&from=${from}${to ? `&to=${to}` : ''}`)
+    const result = await response.json()
+    return result
+  },
+
+  // 回滚到指定版本（生成新版本）
+  rollbackRevision: async (id: string, revision: number, comment = '') => {
+    const response = await makeRequest('/api/video-source/rollback', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ id, revision, comment }),
+    })
+    const result = await response.json()
+    return result
+  },
+
+  // 固定稳定版本，revision 为 0 时取消固定
+  pinStableRevision: async (id: string, revision: number) => {
+    const response = await makeRequest('/api/video-source/pin-stable', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ id, revision }),
+    })
+    const result = await response.json()
+    return result
+  },
+
+  // 静态检查脚本，不保存也不执行
+  lintScript: async (engineType: number, script: string) => {
+    const response = await makeRequest('/api/video-source/lint', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ engine_type: engineType, script }),
+    })
+    const result = await response.json()
+    return result
+  },
+
   exportVideoSources: async () => {
     const response = await makeRequest('/api/video-source/export')
     if (!response.ok) {
@@ -178,5 +232,18 @@ export const scriptAPI = {
     })
     const result = await response.json()
     return result
+  },
+
+  // Lua 单步调试命令：continue / step_over / step_into / step_out / pause / abort / breakpoints / variables / evaluate
+  luaDebugCommand: async (payload: { session_id: string; command: string; frame?: number; expression?: string; breakpoints?: number[] }) => {
+    const response = await makeRequest('/api/lua/debug/command', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(payload),
+    })
+    const result = await response.json()
+    return result
   }
 }
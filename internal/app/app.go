@@ -1,11 +1,14 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,7 +27,11 @@ type App struct {
 	config      *config.Config
 	httpHandler *handler.Handler
 	userService services.UserServiceInterface
+	scriptStore services.ScriptStoreService
 	engine      *gin.Engine
+
+	serverMutex sync.Mutex
+	server      *http.Server
 }
 
 var (
@@ -52,13 +59,19 @@ func New(cfg *config.Config) *App {
 
 	jwtManager := utils.NewJWTManager(cfg.Server.JwtSecret, time.Duration(cfg.Server.JwtExpire)*time.Hour)
 	userService := services.NewUserService(jwtManager)
-	videoSourceService := services.NewVideoSourceService()
+	scriptStoreService := services.NewScriptStoreService()
+	videoSourceService := services.NewVideoSourceService(scriptStoreService)
 	historyService := services.GetHistoryService()
 	luaTestService := services.NewLuaTestService()
+	downloadService := services.NewDownloadService(cfg.Download)
+	scriptModuleService := services.NewScriptModuleService()
+	playURLCache := services.NewPlayURLCacheService(scriptModuleService)
+	luaDebugService := services.NewLuaDebugService()
 	return &App{
 		config:      cfg,
-		httpHandler: handler.New(cfg, userService, videoSourceService, historyService, luaTestService),
+		httpHandler: handler.New(cfg, userService, videoSourceService, historyService, luaTestService, downloadService, playURLCache, scriptModuleService, scriptStoreService, luaDebugService),
 		userService: userService,
+		scriptStore: scriptStoreService,
 		engine:      engine,
 	}
 }
@@ -71,7 +84,36 @@ func (a *App) Run() error {
 	addr := fmt.Sprintf("%s:%d", a.config.Server.Host, a.config.Server.Port)
 	log.Printf("Starting gin server on %s", addr)
 
-	return a.engine.Run(addr)
+	server := &http.Server{Addr: addr, Handler: a.engine}
+	a.serverMutex.Lock()
+	a.server = server
+	a.serverMutex.Unlock()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接收新请求并等待进行中的请求结束，ctx 到期后强制关闭；之后 Run 返回 nil
+func (a *App) Shutdown(ctx context.Context) error {
+	a.serverMutex.Lock()
+	server := a.server
+	a.serverMutex.Unlock()
+	if server == nil {
+		return nil
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return err
+	}
+	return nil
+}
+
+// Close 退出前写入尚未持久化的数据
+func (a *App) Close() {
+	if err := a.scriptStore.Flush(); err != nil {
+		log.Printf("保存脚本键值存储失败: %v", err)
+	}
 }
 
 // registerRoutes 注册所有路由
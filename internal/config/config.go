@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"video-crawler/internal/entities"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -10,9 +11,12 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	Env    string       `yaml:"env"` // 运行环境: dev, prod
-	Auth   AuthConfig   `yaml:"auth"`
+	Server   ServerConfig   `yaml:"server"`
+	Env      string         `yaml:"env"` // 运行环境: dev, prod
+	Auth     AuthConfig     `yaml:"auth"`
+	HLS      HLSConfig      `yaml:"hls"`
+	Download DownloadConfig `yaml:"download"`
+	Script   ScriptConfig   `yaml:"script"`
 }
 
 // ServerConfig 服务器配置
@@ -28,6 +32,26 @@ type AuthConfig struct {
 	RequireLogin bool `yaml:"require_login"` // 是否需要登录注册，默认为false
 }
 
+// HLSConfig HLS 代理配置
+type HLSConfig struct {
+	AdFilter entities.AdFilterRule `yaml:"ad_filter"` // 广告分片过滤的全局默认规则
+}
+
+// DownloadConfig 离线下载配置
+type DownloadConfig struct {
+	MaxConcurrent      int   `yaml:"max_concurrent"`      // 同时进行的下载任务数，默认 2
+	SegmentConcurrency int   `yaml:"segment_concurrency"` // 单个 HLS 任务并发下载的分片数，默认 4
+	UserQuotaMB        int64 `yaml:"user_quota_mb"`       // 每个用户可占用的磁盘空间（MB），0 表示不限制
+	MaxTasksPerUser    int   `yaml:"max_tasks_per_user"`  // 每个用户未完成的任务数上限，0 表示不限制
+}
+
+// ScriptConfig 站点脚本配置
+type ScriptConfig struct {
+	// StrictValidation 严格校验脚本结果：缺少必填字段、类型错误等问题直接返回错误，而不是静默丢弃。
+	// 视频接口也可以通过 strict=1 / strict=0 参数按请求覆盖
+	StrictValidation bool `yaml:"strict_validation"`
+}
+
 var conf *Config
 
 // Load 从 YAML 文件加载配置。默认从 configs/config.yaml 读取，也可通过环境变量 CONFIG_PATH 指定路径
@@ -65,6 +89,25 @@ func Load(force bool) (*Config, error) {
 	if conf.Auth.RequireLogin == false {
 		conf.Auth.RequireLogin = false // 默认为false
 	}
+	if conf.HLS.AdFilter.MaxAdDuration <= 0 {
+		conf.HLS.AdFilter.MaxAdDuration = 120
+	}
+	if conf.HLS.AdFilter.DurationTolerance <= 0 {
+		conf.HLS.AdFilter.DurationTolerance = 0.5
+	}
+	if conf.Download.MaxConcurrent <= 0 {
+		conf.Download.MaxConcurrent = 2
+	}
+	if conf.Download.SegmentConcurrency <= 0 {
+		conf.Download.SegmentConcurrency = 4
+	}
+	if conf.HLS.AdFilter.HostMismatch == nil {
+		hostMismatch := true
+		conf.HLS.AdFilter.HostMismatch = &hostMismatch
+	}
+	if err := conf.HLS.AdFilter.Validate(); err != nil {
+		return nil, fmt.Errorf("hls.ad_filter 配置无效: %w", err)
+	}
 
 	return &conf, nil
 }
@@ -68,3 +68,20 @@ func TestConfigYAMLTags(t *testing.T) {
 	// 粗略保证字段不会被未来重命名导致 YAML 解析异常
 	_ = time.Second // 引用标准库，避免空文件
 }
+
+func TestLoad_InvalidAdFilterPattern(t *testing.T) {
+	content := testYAML + `
+hls:
+  ad_filter:
+    url_patterns:
+      - "(unclosed"
+`
+	withTempConfig(t, content, func(path string) {
+		os.Setenv("CONFIG_PATH", path)
+		defer os.Unsetenv("CONFIG_PATH")
+
+		if _, err := Load(true); err == nil {
+			t.Fatalf("Load() should reject invalid hls.ad_filter.url_patterns")
+		}
+	})
+}
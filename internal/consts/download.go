@@ -0,0 +1,17 @@
+package consts
+
+// 离线下载任务状态
+const (
+	DownloadStatusPending   = "pending"   // 排队中
+	DownloadStatusRunning   = "running"   // 下载中
+	DownloadStatusPaused    = "paused"    // 已暂停
+	DownloadStatusCompleted = "completed" // 已完成
+	DownloadStatusFailed    = "failed"    // 失败
+	DownloadStatusCanceled  = "canceled"  // 已取消
+)
+
+// 离线下载媒体类型
+const (
+	DownloadMediaMP4 = "mp4" // 直链文件
+	DownloadMediaHLS = "hls" // m3u8 播放列表
+)
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"video-crawler/internal/consts"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/services"
+	"video-crawler/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadController 离线下载管理
+type DownloadController struct {
+	downloadService    services.DownloadService
+	videoSourceService services.VideoSourceService
+	videoController    *VideoController
+}
+
+func NewDownloadController(downloadService services.DownloadService, videoSourceService services.VideoSourceService, videoController *VideoController) *DownloadController {
+	return &DownloadController{downloadService: downloadService, videoSourceService: videoSourceService, videoController: videoController}
+}
+
+// Create 创建下载任务：通过站点脚本解析播放地址后加入队列
+// POST /api/download/create {"source_id":"xxx","url":"剧集页面地址","title":"影片名","episode":"第1集"}
+func (c *DownloadController) Create(ctx *gin.Context) {
+	var request struct {
+		SourceID string `json:"source_id" binding:"required"`
+		URL      string `json:"url" binding:"required"`
+		Title    string `json:"title"`
+		Episode  string `json:"episode"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+
+	videoSource, err := c.videoController.runtimeSource(request.SourceID)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "获取视频源失败: "+err.Error(), nil)
+		return
+	}
+	playDetail, _, _, err := c.videoController.resolvePlayURL(ctx, &videoSource, request.URL, false)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, err.Error(), nil)
+		return
+	}
+
+	headers := playHeaders(&videoSource)
+	if _, ok := headers["User-Agent"]; !ok {
+		if ua := ctx.GetHeader("User-Agent"); ua != "" {
+			headers["User-Agent"] = ua
+		}
+	}
+	task, err := c.downloadService.Create(entities.DownloadTask{
+		UserID:     ctx.GetString("user_id"),
+		SourceID:   videoSource.Id,
+		SourceName: videoSource.Name,
+		Title:      request.Title,
+		Episode:    request.Episode,
+		PageURL:    request.URL,
+		MediaURL:   playDetail.VideoURL,
+		Headers:    headers,
+	})
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, "创建下载任务失败: "+err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, task)
+}
+
+// List 下载任务列表：管理员可通过 all=1 查看所有用户的任务
+// GET /api/download/list[?all=1]
+func (c *DownloadController) List(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	if ctx.Query("all") == "1" && ctx.GetBool("is_admin") {
+		userID = ""
+	}
+	utils.SuccessResponse(ctx, c.downloadService.List(userID))
+}
+
+// Pause 暂停下载
+// POST /api/download/pause {"id":"xxx"}
+func (c *DownloadController) Pause(ctx *gin.Context) {
+	c.operate(ctx, c.downloadService.Pause)
+}
+
+// Resume 继续下载（也用于重试失败的任务）
+// POST /api/download/resume {"id":"xxx"}
+func (c *DownloadController) Resume(ctx *gin.Context) {
+	c.operate(ctx, c.downloadService.Resume)
+}
+
+// Cancel 取消下载并清理已下载的部分
+// POST /api/download/cancel {"id":"xxx"}
+func (c *DownloadController) Cancel(ctx *gin.Context) {
+	c.operate(ctx, c.downloadService.Cancel)
+}
+
+// Delete 删除任务及已下载的文件
+// POST /api/download/delete {"id":"xxx"}
+func (c *DownloadController) Delete(ctx *gin.Context) {
+	c.operate(ctx, c.downloadService.Delete)
+}
+
+// File 获取已完成任务的视频文件，支持 Range 以便直接播放
+// GET /api/download/file?id=xxx[&attachment=1]
+func (c *DownloadController) File(ctx *gin.Context) {
+	task, ok := c.ownedTask(ctx, ctx.Query("id"))
+	if !ok {
+		return
+	}
+	path, err := c.downloadService.FilePath(task.ID)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, err.Error(), nil)
+		return
+	}
+	if ctx.Query("attachment") == "1" {
+		ctx.FileAttachment(path, task.FileName)
+		return
+	}
+	ctx.File(path)
+}
+
+// Events 以 SSE 推送当前用户任务的状态与进度变化
+// GET /api/download/events
+func (c *DownloadController) Events(ctx *gin.Context) {
+	userID := ctx.GetString("user_id")
+	isAdmin := ctx.GetBool("is_admin")
+
+	writer := ctx.Writer
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		utils.SendResponse(ctx, http.StatusInternalServerError, "服务器不支持流式响应", nil)
+		return
+	}
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+
+	events, unsubscribe := c.downloadService.Subscribe()
+	defer unsubscribe()
+
+	writer.Write([]byte("event: connected\ndata: {\"message\":\"连接已建立\"}\n\n"))
+	flusher.Flush()
+
+	// 定期发送注释行，避免代理因空闲断开连接
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev := <-events:
+			if ev.Task.UserID != userID && !isAdmin {
+				continue
+			}
+			data, err := json.Marshal(ev.Task)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", ev.Type, data)
+			flusher.Flush()
+		case <-heartbeat.C:
+			writer.Write([]byte(": ping\n\n"))
+			flusher.Flush()
+		case <-ctx.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// operate 校验任务归属后执行操作
+func (c *DownloadController) operate(ctx *gin.Context, op func(id string) error) {
+	var request struct {
+		ID string `json:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	if _, ok := c.ownedTask(ctx, request.ID); !ok {
+		return
+	}
+	if err := op(request.ID); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, nil)
+}
+
+// ownedTask 获取任务，只有任务所属用户或管理员可以访问
+func (c *DownloadController) ownedTask(ctx *gin.Context, id string) (entities.DownloadTask, bool) {
+	task, ok := c.downloadService.Get(id)
+	if !ok {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, services.ErrDownloadNotFound.Error(), nil)
+		return task, false
+	}
+	if task.UserID != ctx.GetString("user_id") && !ctx.GetBool("is_admin") {
+		utils.SendResponse(ctx, consts.ResponseCodeNoPermission, "no permission", nil)
+		return task, false
+	}
+	return task, true
+}
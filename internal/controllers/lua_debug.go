@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"video-crawler/internal/entities"
+	lua "video-crawler/internal/luaengine"
+	"video-crawler/internal/services"
+	"video-crawler/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LuaDebugController struct {
+	luaDebugService services.LuaDebugService
+}
+
+func NewLuaDebugController(luaDebugService services.LuaDebugService) *LuaDebugController {
+	return &LuaDebugController{luaDebugService: luaDebugService}
+}
+
+// Start 启动 Lua 单步调试会话(SSE)。
+// 事件：session（会话ID）、log、paused（暂停位置与调用栈）、resumed、result、error、complete
+func (c *LuaDebugController) Start(ctx *gin.Context) {
+	if ctx.Request.Method != "POST" {
+		utils.SendResponse(ctx, http.StatusMethodNotAllowed, "只支持POST方法", nil)
+		return
+	}
+
+	var request struct {
+		Script      string                 `json:"script" binding:"required"`
+		Method      string                 `json:"method" binding:"required"`
+		Params      map[string]interface{} `json:"params" binding:"required"`
+		Breakpoints []int                  `json:"breakpoints"`   // 入口脚本的断点行号
+		StopOnEntry bool                   `json:"stop_on_entry"` // 在第一行暂停
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: "+err.Error(), nil)
+		return
+	}
+
+	// 验证方法类型
+	validMethods := map[string]bool{
+		"search_video":          true,
+		"get_video_detail":      true,
+		"get_play_video_detail": true,
+	}
+	if !validMethods[request.Method] {
+		utils.SendResponse(ctx, http.StatusBadRequest, "不支持的方法类型: "+request.Method, nil)
+		return
+	}
+
+	// 设置上下文：客户端断开时脚本随之终止
+	reqCtx := ctx.Request.Context()
+	if ua := ctx.GetHeader("User-Agent"); ua != "" {
+		reqCtx = context.WithValue(reqCtx, services.CtxKeyRequestUA, ua)
+	}
+
+	_, outputChan, err := c.luaDebugService.Start(reqCtx, request.Script, request.Method, request.Params, request.Breakpoints, request.StopOnEntry)
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusBadRequest, "启动调试失败: "+err.Error(), nil)
+		return
+	}
+
+	writer := ctx.Writer
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		go func() {
+			for range outputChan {
+			}
+		}()
+		ctx.String(http.StatusInternalServerError, "event: error\ndata: {\"message\":\"服务器不支持流式响应\"}\n\n")
+		return
+	}
+
+	// 设置SSE响应头
+	ctx.Status(http.StatusOK)
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("Access-Control-Allow-Origin", "*")
+	ctx.Header("Access-Control-Allow-Headers", "Cache-Control")
+
+	writer.Write([]byte("event: connected\ndata: {\"message\":\"连接已建立\"}\n\n"))
+	flusher.Flush()
+
+	for {
+		select {
+		case msg, ok := <-outputChan:
+			if !ok {
+				writer.Write([]byte("event: complete\ndata: {\"message\":\"调试结束\"}\n\n"))
+				flusher.Flush()
+				return
+			}
+			writer.Write([]byte(msg))
+			flusher.Flush()
+		case <-ctx.Request.Context().Done():
+			// 客户端断开连接：脚本会随请求上下文终止，继续读完剩余输出让会话正常清理
+			go func() {
+				for range outputChan {
+				}
+			}()
+			return
+		}
+	}
+}
+
+// Command 向调试会话发送命令：continue / step_over / step_into / step_out / pause / abort / breakpoints / variables / evaluate
+func (c *LuaDebugController) Command(ctx *gin.Context) {
+	if ctx.Request.Method != "POST" {
+		utils.SendResponse(ctx, http.StatusMethodNotAllowed, "只支持POST方法", nil)
+		return
+	}
+
+	var request entities.LuaDebugCommand
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: "+err.Error(), nil)
+		return
+	}
+	if request.SessionID == "" || request.Command == "" {
+		utils.SendResponse(ctx, http.StatusBadRequest, "session_id 与 command 不能为空", nil)
+		return
+	}
+
+	result, err := c.luaDebugService.Command(request)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrLuaDebugSessionNotFound) || errors.Is(err, lua.ErrDebugAborted) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, lua.ErrDebugNotPaused) {
+			status = http.StatusConflict
+		}
+		utils.SendResponse(ctx, status, err.Error(), nil)
+		return
+	}
+
+	utils.SuccessResponse(ctx, result)
+}
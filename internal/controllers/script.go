@@ -243,6 +243,7 @@ func (c *LuaTestController) AdvancedTestJSScript(ctx *gin.Context) {
 		Script string                 `json:"script" binding:"required"`
 		Method string                 `json:"method" binding:"required"`
 		Params map[string]interface{} `json:"params" binding:"required"`
+		Trace  bool                   `json:"trace"` // 记录请求与解析的执行时间线
 	}
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -266,11 +267,14 @@ func (c *LuaTestController) AdvancedTestJSScript(ctx *gin.Context) {
 	if ua := ctx.GetHeader("User-Agent"); ua != "" {
 		reqCtx = context.WithValue(reqCtx, services.CtxKeyRequestUA, ua)
 	}
+	if request.Trace {
+		reqCtx = context.WithValue(reqCtx, services.CtxKeyTrace, true)
+	}
 
 	// 执行高级调试
 	result, consoleOutput, err := c.jsTestService.ExecuteAdvancedTest(reqCtx, request.Script, request.Method, request.Params)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, "执行失败: "+err.Error(), nil)
+		utils.SendResponse(ctx, http.StatusInternalServerError, "执行失败: "+err.Error(), scriptErrorData(err))
 		return
 	}
 
@@ -278,8 +282,15 @@ func (c *LuaTestController) AdvancedTestJSScript(ctx *gin.Context) {
 	response := map[string]interface{}{
 		"original":  result.Original,
 		"converted": result.Converted,
+		"report":    result.Report,
 		"console":   consoleOutput,
 	}
+	if result.ScriptError != nil {
+		response["script_error"] = result.ScriptError
+	}
+	if result.Trace != nil {
+		response["trace"] = result.Trace
+	}
 
 	utils.SendResponse(ctx, http.StatusOK, "执行成功", response)
 }
@@ -295,6 +306,7 @@ func (c *LuaTestController) AdvancedTestLuaScript(ctx *gin.Context) {
 		Script string                 `json:"script" binding:"required"`
 		Method string                 `json:"method" binding:"required"`
 		Params map[string]interface{} `json:"params" binding:"required"`
+		Trace  bool                   `json:"trace"` // 记录请求与解析的执行时间线
 	}
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -318,11 +330,14 @@ func (c *LuaTestController) AdvancedTestLuaScript(ctx *gin.Context) {
 	if ua := ctx.GetHeader("User-Agent"); ua != "" {
 		reqCtx = context.WithValue(reqCtx, services.CtxKeyRequestUA, ua)
 	}
+	if request.Trace {
+		reqCtx = context.WithValue(reqCtx, services.CtxKeyTrace, true)
+	}
 
 	// 执行高级调试
 	result, consoleOutput, err := c.luaTestService.ExecuteAdvancedTest(reqCtx, request.Script, request.Method, request.Params)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, "执行失败: "+err.Error(), nil)
+		utils.SendResponse(ctx, http.StatusInternalServerError, "执行失败: "+err.Error(), scriptErrorData(err))
 		return
 	}
 
@@ -330,8 +345,15 @@ func (c *LuaTestController) AdvancedTestLuaScript(ctx *gin.Context) {
 	response := map[string]interface{}{
 		"original":  result.Original,
 		"converted": result.Converted,
+		"report":    result.Report,
 		"console":   consoleOutput,
 	}
+	if result.ScriptError != nil {
+		response["script_error"] = result.ScriptError
+	}
+	if result.Trace != nil {
+		response["trace"] = result.Trace
+	}
 
 	utils.SendResponse(ctx, http.StatusOK, "执行成功", response)
 }
@@ -355,6 +377,7 @@ func (c *LuaTestController) AdvancedTestJSScriptSSE(ctx *gin.Context) {
 		Script string                 `json:"script" binding:"required"`
 		Method string                 `json:"method" binding:"required"`
 		Params map[string]interface{} `json:"params" binding:"required"`
+		Trace  bool                   `json:"trace"` // 记录请求与解析的执行时间线
 	}
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -389,6 +412,9 @@ func (c *LuaTestController) AdvancedTestJSScriptSSE(ctx *gin.Context) {
 	if ua := ctx.GetHeader("User-Agent"); ua != "" {
 		reqCtx = context.WithValue(reqCtx, services.CtxKeyRequestUA, ua)
 	}
+	if request.Trace {
+		reqCtx = context.WithValue(reqCtx, services.CtxKeyTrace, true)
+	}
 
 	// 获取输出通道
 	outputChan, err := c.jsTestService.ExecuteAdvancedTestSSE(reqCtx, script, method, params)
@@ -443,6 +469,7 @@ func (c *LuaTestController) AdvancedTestLuaScriptSSE(ctx *gin.Context) {
 		Script string                 `json:"script" binding:"required"`
 		Method string                 `json:"method" binding:"required"`
 		Params map[string]interface{} `json:"params" binding:"required"`
+		Trace  bool                   `json:"trace"` // 记录请求与解析的执行时间线
 	}
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -478,6 +505,9 @@ func (c *LuaTestController) AdvancedTestLuaScriptSSE(ctx *gin.Context) {
 	if ua := ctx.GetHeader("User-Agent"); ua != "" {
 		reqCtx = context.WithValue(reqCtx, services.CtxKeyRequestUA, ua)
 	}
+	if request.Trace {
+		reqCtx = context.WithValue(reqCtx, services.CtxKeyTrace, true)
+	}
 
 	// 获取输出通道
 	outputChan, err := c.luaTestService.ExecuteAdvancedTestSSE(reqCtx, script, method, params)
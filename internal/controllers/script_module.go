@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"strconv"
+	"video-crawler/internal/consts"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/services"
+	"video-crawler/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScriptModuleController 共享脚本模块管理（管理员或站点管理员可操作）
+type ScriptModuleController struct {
+	scriptModuleService services.ScriptModuleService
+	videoSourceService  services.VideoSourceService
+}
+
+func NewScriptModuleController(scriptModuleService services.ScriptModuleService, videoSourceService services.VideoSourceService) *ScriptModuleController {
+	return &ScriptModuleController{scriptModuleService: scriptModuleService, videoSourceService: videoSourceService}
+}
+
+// scriptModuleDependent 依赖某个模块的站点
+type scriptModuleDependent struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// List 模块列表（不含代码历史）
+// GET /api/script-module/list[?engine_type=0]
+func (c *ScriptModuleController) List(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	engineType := -1
+	if v := ctx.Query("engine_type"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: engine_type", nil)
+			return
+		}
+		engineType = n
+	}
+	utils.SuccessResponse(ctx, c.scriptModuleService.List(engineType))
+}
+
+// Detail 模块详情（含历史版本与依赖它的站点）
+// GET /api/script-module/detail?engine_type=0&name=utils
+func (c *ScriptModuleController) Detail(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	engineType, err := strconv.Atoi(ctx.Query("engine_type"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: engine_type", nil)
+		return
+	}
+	module, err := c.scriptModuleService.Get(engineType, ctx.Query("name"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, gin.H{
+		"module":     module,
+		"dependents": c.dependents(module.EngineType, module.Name),
+	})
+}
+
+// Save 创建或更新模块；依赖它的站点会在下次执行时加载新版本，其播放地址缓存随之失效
+// POST /api/script-module/save {"name":"utils","engine_type":0,"description":"","code":"..."}
+func (c *ScriptModuleController) Save(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	var request entities.ScriptModule
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	module, err := c.scriptModuleService.Save(request, ctx.GetString("user_id"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, gin.H{
+		"module":     module,
+		"dependents": c.dependents(module.EngineType, module.Name),
+	})
+}
+
+// Rollback 回滚到指定历史版本（生成新版本）
+// POST /api/script-module/rollback {"name":"utils","engine_type":0,"version":3}
+func (c *ScriptModuleController) Rollback(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	var request struct {
+		Name       string `json:"name" binding:"required"`
+		EngineType int    `json:"engine_type"`
+		Version    int    `json:"version" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	module, err := c.scriptModuleService.Rollback(request.EngineType, request.Name, request.Version, ctx.GetString("user_id"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, gin.H{
+		"module":     module,
+		"dependents": c.dependents(module.EngineType, module.Name),
+	})
+}
+
+// Delete 删除模块；仍被站点引用时需要 force=true
+// POST /api/script-module/delete {"name":"utils","engine_type":0,"force":false}
+func (c *ScriptModuleController) Delete(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	var request struct {
+		Name       string `json:"name" binding:"required"`
+		EngineType int    `json:"engine_type"`
+		Force      bool   `json:"force"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	if dependents := c.dependents(request.EngineType, request.Name); len(dependents) > 0 && !request.Force {
+		utils.SendResponse(ctx, consts.ResponseCodeError, "模块仍被站点引用", gin.H{"dependents": dependents})
+		return
+	}
+	if err := c.scriptModuleService.Delete(request.EngineType, request.Name); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeError, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, gin.H{"message": "删除成功"})
+}
+
+// dependents 找出直接或间接引用模块的站点
+func (c *ScriptModuleController) dependents(engineType int, name string) []scriptModuleDependent {
+	result := []scriptModuleDependent{}
+	sources, err := c.videoSourceService.List()
+	if err != nil {
+		return result
+	}
+	for _, src := range sources {
+		if src.EngineType != engineType {
+			continue
+		}
+		script := src.LuaScript
+		if src.EngineType == 1 {
+			script = src.JsScript
+		}
+		for _, dep := range c.scriptModuleService.Dependencies(engineType, script) {
+			if dep == name {
+				result = append(result, scriptModuleDependent{ID: src.Id, Name: src.Name})
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (c *ScriptModuleController) checkPermission(ctx *gin.Context) bool {
+	if !(ctx.GetBool("is_admin") || ctx.GetBool("is_site_admin")) {
+		utils.SendResponse(ctx, consts.ResponseCodeNoPermission, "no permission", nil)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"strings"
+	"video-crawler/internal/consts"
+	"video-crawler/internal/services"
+	"video-crawler/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScriptStoreController 站点脚本键值存储的查看与清理（管理员或站点管理员可操作）
+type ScriptStoreController struct {
+	scriptStoreService services.ScriptStoreService
+	videoSourceService services.VideoSourceService
+}
+
+func NewScriptStoreController(scriptStoreService services.ScriptStoreService, videoSourceService services.VideoSourceService) *ScriptStoreController {
+	return &ScriptStoreController{scriptStoreService: scriptStoreService, videoSourceService: videoSourceService}
+}
+
+// List 有数据的站点及其键数量与占用
+// GET /api/script-store/list
+func (c *ScriptStoreController) List(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	summaries := c.scriptStoreService.Summaries()
+	for i := range summaries {
+		if src, err := c.videoSourceService.Detail(summaries[i].SourceID); err == nil {
+			summaries[i].SourceName = src.Name
+		}
+	}
+	utils.SuccessResponse(ctx, summaries)
+}
+
+// Detail 站点的所有未过期键值
+// GET /api/script-store/detail?source_id=xxx
+func (c *ScriptStoreController) Detail(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	sourceID := strings.TrimSpace(ctx.Query("source_id"))
+	if sourceID == "" {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "站点ID不能为空", nil)
+		return
+	}
+	utils.SuccessResponse(ctx, c.scriptStoreService.Entries(sourceID))
+}
+
+// Clear 删除站点的指定键，不指定 key 时清空整个站点
+// POST /api/script-store/clear {"source_id":"xxx","key":""}
+func (c *ScriptStoreController) Clear(ctx *gin.Context) {
+	if !c.checkPermission(ctx) {
+		return
+	}
+	var request struct {
+		SourceID string `json:"source_id"`
+		Key      string `json:"key"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	removed, err := c.scriptStoreService.Clear(strings.TrimSpace(request.SourceID), request.Key)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, gin.H{"removed": removed})
+}
+
+func (c *ScriptStoreController) checkPermission(ctx *gin.Context) bool {
+	if !(ctx.GetBool("is_admin") || ctx.GetBool("is_site_admin")) {
+		utils.SendResponse(ctx, consts.ResponseCodeNoPermission, "no permission", nil)
+		return false
+	}
+	return true
+}
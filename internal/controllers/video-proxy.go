@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"video-crawler/internal/config"
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/hls"
+	"video-crawler/internal/logger"
+	"video-crawler/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPlaylistSize 代理播放列表的最大体积，防止误把大文件当作 m3u8 读入内存
+const maxPlaylistSize = 8 << 20
+
+// proxyURLTTL 签名代理地址的有效期，播放器在此期间无需登录凭证即可拉取列表与分片
+const proxyURLTTL = 12 * time.Hour
+
+// ProxyHLS 代理 m3u8 播放列表：使用站点网络配置拉取，并将子列表、分片、密钥地址改写回代理。
+// ad_filter=1/0 按请求开启或关闭广告分片过滤（默认取站点与全局配置），
+// ad_debug=1 时不返回播放列表，而是返回被剔除分片的报告。
+// 携带 expires 与 sig 的签名地址无需登录凭证（由 PlayURL 与本接口生成），未签名的请求需登录
+// GET /api/video/proxy/hls?source_id=xxx&url=yyy[&ad_filter=1][&ad_debug=1][&expires=xxx&sig=xxx]
+func (c *VideoController) ProxyHLS(ctx *gin.Context) {
+	src, target, ok := c.proxyTarget(ctx)
+	if !ok {
+		return
+	}
+
+	browser, err := newProxyBrowser(ctx, src)
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusInternalServerError, "创建浏览器实例失败: "+err.Error(), nil)
+		return
+	}
+	defer browser.Close()
+
+	resp, err := browser.Do(http.MethodGet, target, nil, map[string]string{"Accept-Encoding": "identity"})
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusBadGateway, "拉取播放列表失败: "+err.Error(), nil)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		utils.SendResponse(ctx, http.StatusBadGateway, "拉取播放列表失败: "+resp.Status, nil)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize+1))
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusBadGateway, "读取播放列表失败: "+err.Error(), nil)
+		return
+	}
+	if len(body) > maxPlaylistSize || !hls.IsPlaylist(resp.Header.Get("Content-Type"), resp.Request.URL.String(), body) {
+		utils.SendResponse(ctx, http.StatusBadRequest, "目标地址不是 m3u8 播放列表", nil)
+		return
+	}
+
+	rule := c.adFilterRule(ctx, src)
+	adDebug := ctx.Query("ad_debug") == "1"
+	if rule.IsEnabled() || adDebug {
+		filtered, report, err := hls.FilterAds(body, resp.Request.URL, rule)
+		switch {
+		case err != nil && adDebug:
+			utils.SendResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			return
+		case err != nil:
+			// 规则无效时不影响播放，记录日志后返回未过滤的播放列表
+			logger.CtxLogger(ctx).WithError(err).WithField("source_id", src.Id).Warn("广告过滤规则无效，跳过过滤")
+		case adDebug:
+			utils.SuccessResponse(ctx, report)
+			return
+		default:
+			body = filtered
+		}
+	}
+
+	// 子播放列表继续携带过滤参数，使码率变体同样生效
+	extra := url.Values{}
+	if v := ctx.Query("ad_filter"); v != "" {
+		extra.Set("ad_filter", v)
+	}
+	rewritten := hls.RewritePlaylist(body, resp.Request.URL, func(absURI string, kind hls.URIKind) string {
+		if kind == hls.URIKindPlaylist {
+			return c.proxyURL("/api/video/proxy/hls", src.Id, absURI, extra)
+		}
+		return c.proxyURL("/api/video/proxy/segment", src.Id, absURI, nil)
+	})
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Data(http.StatusOK, "application/vnd.apple.mpegurl", rewritten)
+}
+
+// ProxySegment 代理分片、密钥等二进制资源，透传 Range 以支持拖动与断点
+// GET /api/video/proxy/segment?source_id=xxx&url=yyy[&expires=xxx&sig=xxx]
+func (c *VideoController) ProxySegment(ctx *gin.Context) {
+	src, target, ok := c.proxyTarget(ctx)
+	if !ok {
+		return
+	}
+
+	browser, err := newProxyBrowser(ctx, src)
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusInternalServerError, "创建浏览器实例失败: "+err.Error(), nil)
+		return
+	}
+	defer browser.Close()
+	// 流式转发大文件时不限制整体耗时，由客户端断开来结束
+	browser.SetTimeout(0)
+
+	headers := map[string]string{"Accept-Encoding": "identity", "Accept": "*/*"}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := ctx.GetHeader(h); v != "" {
+			headers[h] = v
+		}
+	}
+	resp, err := browser.Do(http.MethodGet, target, nil, headers)
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusBadGateway, "拉取资源失败: "+err.Error(), nil)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag", "Cache-Control"} {
+		if v := resp.Header.Get(h); v != "" {
+			ctx.Header(h, v)
+		}
+	}
+	if resp.Header.Get("Accept-Ranges") == "" && resp.StatusCode == http.StatusOK {
+		ctx.Header("Accept-Ranges", "bytes")
+	}
+	ctx.Status(resp.StatusCode)
+	if _, err := io.Copy(ctx.Writer, resp.Body); err != nil {
+		logger.CtxLogger(ctx).WithError(err).Debug("proxy segment copy interrupted")
+	}
+}
+
+// adFilterRule 合并全局配置、站点规则与请求参数得到本次使用的广告过滤规则
+func (c *VideoController) adFilterRule(ctx *gin.Context, src *entities.VideoSourceEntity) entities.AdFilterRule {
+	var rule entities.AdFilterRule
+	if c.config != nil {
+		rule = c.config.HLS.AdFilter
+	}
+	rule = rule.Merge(src.AdFilter)
+	switch ctx.Query("ad_filter") {
+	case "1", "true":
+		enabled := true
+		rule.Enabled = &enabled
+	case "0", "false":
+		enabled := false
+		rule.Enabled = &enabled
+	}
+	return rule
+}
+
+// proxyTarget 解析代理请求的站点与目标地址；携带签名时校验签名与有效期，
+// 只代理服务端生成过的地址
+func (c *VideoController) proxyTarget(ctx *gin.Context) (*entities.VideoSourceEntity, string, bool) {
+	if ctx.Query("sig") != "" {
+		if err := c.verifyProxyURL(ctx.Request.URL.Path, ctx.Request.URL.Query()); err != nil {
+			utils.SendResponse(ctx, http.StatusForbidden, err.Error(), nil)
+			return nil, "", false
+		}
+	}
+	sourceID := ctx.Query("source_id")
+	target := ctx.Query("url")
+	if sourceID == "" || target == "" {
+		utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: source_id 与 url 不能为空", nil)
+		return nil, "", false
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: url 必须为 http(s) 绝对地址", nil)
+		return nil, "", false
+	}
+	videoSource, err := c.videoSourceService.Detail(sourceID)
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusBadRequest, "获取视频源失败: "+err.Error(), nil)
+		return nil, "", false
+	}
+	return &videoSource, target, true
+}
+
+// newProxyBrowser 按站点网络配置创建请求实例：沿用前端 UA，以站点域名作为 Referer，
+// 并应用站点配置的 play_headers
+func newProxyBrowser(ctx *gin.Context, src *entities.VideoSourceEntity) (crawler.BrowserRequest, error) {
+	// 目标地址来自站点脚本与播放列表，拒绝访问内网地址（含重定向）
+	browser, err := crawler.NewPublicBrowser()
+	if err != nil {
+		return nil, err
+	}
+	if ua := ctx.GetHeader("User-Agent"); ua != "" {
+		browser.SetUserAgent(ua)
+	}
+	browser.SetTimeout(30 * time.Second)
+	headers := playHeaders(src)
+	if ua, ok := headers["User-Agent"]; ok {
+		browser.SetUserAgent(ua)
+	}
+	browser.SetHeaders(headers)
+	return browser, nil
+}
+
+// playHeaders 拉取播放资源时使用的请求头：以站点域名作为 Referer/Origin，再应用站点配置的 play_headers
+func playHeaders(src *entities.VideoSourceEntity) map[string]string {
+	headers := map[string]string{}
+	if domain := strings.TrimSpace(src.Domain); domain != "" {
+		if u, err := url.Parse(domain); err == nil && u.Host != "" {
+			headers["Referer"] = strings.TrimRight(domain, "/") + "/"
+			headers["Origin"] = u.Scheme + "://" + u.Host
+		}
+	}
+	for k, v := range src.PlayHeaders {
+		headers[k] = v
+	}
+	return headers
+}
+
+// proxyURL 生成指向代理接口的相对签名地址，extra 为需要一并签名的附加参数
+func (c *VideoController) proxyURL(path, sourceID, target string, extra url.Values) string {
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("source_id", sourceID)
+	q.Set("url", target)
+	q.Set("expires", strconv.FormatInt(time.Now().Add(proxyURLTTL).Unix(), 10))
+	q.Set("sig", c.proxySignature(path, q))
+	return path + "?" + q.Encode()
+}
+
+// verifyProxyURL 校验代理地址的签名与有效期，签名覆盖路径与除 sig 外的全部参数
+func (c *VideoController) verifyProxyURL(path string, q url.Values) error {
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return errProxyURLExpired
+	}
+	sig := q.Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(c.proxySignature(path, q))) {
+		return errProxyURLSignature
+	}
+	return nil
+}
+
+var (
+	errProxyURLExpired   = errors.New("代理地址已过期")
+	errProxyURLSignature = errors.New("代理地址签名无效")
+)
+
+func (c *VideoController) proxySignature(path string, q url.Values) string {
+	signed := url.Values{}
+	for k, v := range q {
+		if k != "sig" {
+			signed[k] = v
+		}
+	}
+	mac := hmac.New(sha256.New, proxySigningKey(c.config))
+	mac.Write([]byte(path + "?" + signed.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	randomProxyKey     []byte
+	randomProxyKeyOnce sync.Once
+)
+
+// proxySigningKey 由 JWT 密钥派生签名密钥；未配置时使用进程内随机密钥，重启后旧地址失效
+func proxySigningKey(cfg *config.Config) []byte {
+	if cfg != nil && cfg.Server.JwtSecret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.Server.JwtSecret))
+		mac.Write([]byte("video-proxy"))
+		return mac.Sum(nil)
+	}
+	randomProxyKeyOnce.Do(func() {
+		randomProxyKey = make([]byte, 32)
+		rand.Read(randomProxyKey)
+	})
+	return randomProxyKey
+}
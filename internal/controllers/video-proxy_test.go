@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"video-crawler/internal/config"
+)
+
+func TestProxyURLSignature(t *testing.T) {
+	c := &VideoController{config: &config.Config{Server: config.ServerConfig{JwtSecret: "secret"}}}
+	extra := url.Values{"ad_filter": {"1"}}
+	signed := c.proxyURL("/api/video/proxy/hls", "src", "https://example.com/a.m3u8", extra)
+	path, rawQuery, _ := strings.Cut(signed, "?")
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.verifyProxyURL(path, q); err != nil {
+		t.Fatalf("valid url rejected: %v", err)
+	}
+
+	// 签名不能用于其他路径
+	if err := c.verifyProxyURL("/api/video/proxy/segment", q); err != errProxyURLSignature {
+		t.Fatalf("expected signature error for other path, got %v", err)
+	}
+	for _, key := range []string{"url", "source_id", "ad_filter"} {
+		tampered := url.Values{}
+		for k, v := range q {
+			tampered[k] = v
+		}
+		tampered.Set(key, "http://127.0.0.1/")
+		if err := c.verifyProxyURL(path, tampered); err != errProxyURLSignature {
+			t.Fatalf("tampered %s: expected signature error, got %v", key, err)
+		}
+	}
+
+	// 其他密钥签名的地址无效
+	other := &VideoController{config: &config.Config{Server: config.ServerConfig{JwtSecret: "other"}}}
+	if err := other.verifyProxyURL(path, q); err != errProxyURLSignature {
+		t.Fatalf("expected signature error for other key, got %v", err)
+	}
+
+	q.Set("expires", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+	q.Set("sig", c.proxySignature(path, q))
+	if err := c.verifyProxyURL(path, q); err != errProxyURLExpired {
+		t.Fatalf("expected expired error, got %v", err)
+	}
+}
@@ -2,11 +2,15 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 	"video-crawler/internal/consts"
 	"video-crawler/internal/crawler"
 	"video-crawler/internal/entities"
+	"video-crawler/internal/logger"
 	"video-crawler/internal/services"
 	"video-crawler/internal/utils"
 
@@ -48,24 +52,173 @@ func (c *VideoSourceController) Save(ctx *gin.Context) {
 		utils.SendResponse(ctx, consts.ResponseCodeNoPermission, "no permission", nil)
 		return
 	}
-	var videoSource entities.VideoSourceEntity
-	if err := ctx.ShouldBindJSON(&videoSource); err != nil {
+	var req struct {
+		entities.VideoSourceEntity
+		Comment string `json:"comment"` // 版本说明
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
 		return
 	}
+	// 广告过滤规则在代理播放时才使用，保存时先校验，避免无效的正则导致播放失败
+	if req.AdFilter != nil {
+		if err := req.AdFilter.Validate(); err != nil {
+			utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+			return
+		}
+	}
+
+	// 脚本有 error 级别的诊断时拒绝保存，force=1 强制保存
+	lint := services.LintVideoSourceScript(req.VideoSourceEntity)
+	if !lint.Valid && ctx.Query("force") != "1" {
+		utils.SendResponse(ctx, consts.ResponseCodeSaveVideoSourceFailed, "脚本检查未通过", gin.H{"lint": lint})
+		return
+	}
 
-	err := c.videoSourceService.Save(videoSource)
+	videoSource, err := c.videoSourceService.Save(req.VideoSourceEntity, ctx.GetString("user_id"), strings.TrimSpace(req.Comment))
 	if err != nil {
 		utils.SendResponse(ctx, consts.ResponseCodeSaveVideoSourceFailed, err.Error(), nil)
 		return
 	}
 
 	utils.SuccessResponse(ctx, gin.H{
-		"id":      videoSource.Id,
-		"message": "保存成功",
+		"id":       videoSource.Id,
+		"message":  "保存成功",
+		"lint":     lint,
+		"revision": videoSource.Revision,
 	})
 }
 
+// Lint 静态检查脚本：语法错误、入口函数、禁用或未定义的全局变量、不可达代码，不保存也不执行
+func (c *VideoSourceController) Lint(ctx *gin.Context) {
+	var req struct {
+		EngineType int    `json:"engine_type"`
+		Script     string `json:"script"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, services.LintScript(req.EngineType, req.Script))
+}
+
+// Revisions 站点脚本的版本列表（不含脚本内容），同时返回当前版本与固定的稳定版本
+// GET /api/video-source/revisions?id=xxx
+func (c *VideoSourceController) Revisions(ctx *gin.Context) {
+	sourceID := ctx.Query("id")
+	videoSource, err := c.videoSourceService.Detail(sourceID)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeGetVideoSourceDetailFailed, err.Error(), nil)
+		return
+	}
+	revisions, err := c.videoSourceService.Revisions(sourceID)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeGetVideoSourceDetailFailed, err.Error(), nil)
+		return
+	}
+	stable := 0
+	if videoSource.Stable != nil {
+		stable = videoSource.Stable.Revision
+	}
+	utils.SuccessResponse(ctx, gin.H{
+		"revision":        videoSource.Revision,
+		"stable_revision": stable,
+		"revisions":       revisions,
+	})
+}
+
+// Revision 指定版本的详情（含脚本）
+// GET /api/video-source/revision?id=xxx&revision=3
+func (c *VideoSourceController) Revision(ctx *gin.Context) {
+	revision, err := strconv.Atoi(ctx.Query("revision"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: revision", nil)
+		return
+	}
+	rev, err := c.videoSourceService.Revision(ctx.Query("id"), revision)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeGetVideoSourceDetailFailed, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, rev)
+}
+
+// RevisionDiff 比较两个版本的脚本，省略 to 时与当前版本比较
+// GET /api/video-source/revision-diff?id=xxx&from=2&to=5
+func (c *VideoSourceController) RevisionDiff(ctx *gin.Context) {
+	from, err := strconv.Atoi(ctx.Query("from"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: from", nil)
+		return
+	}
+	to := 0
+	if v := ctx.Query("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: to", nil)
+			return
+		}
+	}
+	diff, err := c.videoSourceService.DiffRevisions(ctx.Query("id"), from, to)
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeGetVideoSourceDetailFailed, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, diff)
+}
+
+// Rollback 回滚到指定版本（生成新版本），不影响固定的稳定版本
+// POST /api/video-source/rollback {"id":"xxx","revision":3,"comment":""}
+func (c *VideoSourceController) Rollback(ctx *gin.Context) {
+	// 站点管理：管理员或站点管理员可操作
+	isAdmin := ctx.GetBool("is_admin")
+	isSiteAdmin := ctx.GetBool("is_site_admin")
+	if !(isAdmin || isSiteAdmin) {
+		utils.SendResponse(ctx, consts.ResponseCodeNoPermission, "no permission", nil)
+		return
+	}
+	var req struct {
+		Id       string `json:"id" binding:"required"`
+		Revision int    `json:"revision" binding:"required"`
+		Comment  string `json:"comment"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	videoSource, err := c.videoSourceService.RollbackRevision(req.Id, req.Revision, ctx.GetString("user_id"), strings.TrimSpace(req.Comment))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeSaveVideoSourceFailed, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, videoSource)
+}
+
+// PinStable 固定稳定版本：视频接口执行该版本的脚本，编辑与调试继续使用最新版本；revision 为 0 时取消固定
+// POST /api/video-source/pin-stable {"id":"xxx","revision":3}
+func (c *VideoSourceController) PinStable(ctx *gin.Context) {
+	// 站点管理：管理员或站点管理员可操作
+	isAdmin := ctx.GetBool("is_admin")
+	isSiteAdmin := ctx.GetBool("is_site_admin")
+	if !(isAdmin || isSiteAdmin) {
+		utils.SendResponse(ctx, consts.ResponseCodeNoPermission, "no permission", nil)
+		return
+	}
+	var req struct {
+		Id       string `json:"id" binding:"required"`
+		Revision int    `json:"revision"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
+		return
+	}
+	videoSource, err := c.videoSourceService.PinStable(req.Id, req.Revision, ctx.GetString("user_id"))
+	if err != nil {
+		utils.SendResponse(ctx, consts.ResponseCodeSaveVideoSourceFailed, err.Error(), nil)
+		return
+	}
+	utils.SuccessResponse(ctx, videoSource)
+}
+
 func (c *VideoSourceController) Delete(ctx *gin.Context) {
 	// 站点管理：管理员或站点管理员可操作
 	isAdmin := ctx.GetBool("is_admin")
@@ -147,31 +300,72 @@ func (c *VideoSourceController) CheckStatus(ctx *gin.Context) {
 	}
 
 	// 使用爬虫请求域名，如果返回200，则站点正常，否则站点不可用
+	check := &entities.VideoSourceHealthCheck{CheckedAt: time.Now(), Status: consts.VideoSourceStatusUnavailable}
 	resp, err := browser.Get(videoSource.Domain)
 	if err != nil {
+		check.Error = err.Error()
+		c.saveHealthCheck(ctx, videoSource, check)
 		utils.SendResponse(ctx, consts.ResponseCodeCheckVideoSourceStatusFailed, err.Error(), nil)
 		return
 	}
 	defer resp.Body.Close()
+	check.HTTPStatus = resp.StatusCode
 
 	// 读取响应体以确保连接正常
 	_, err = io.ReadAll(resp.Body)
 	if err != nil {
-		utils.SendResponse(ctx, consts.ResponseCodeCheckVideoSourceStatusFailed, "读取响应失败: "+err.Error(), nil)
+		check.Error = "读取响应失败: " + err.Error()
+		c.saveHealthCheck(ctx, videoSource, check)
+		utils.SendResponse(ctx, consts.ResponseCodeCheckVideoSourceStatusFailed, check.Error, nil)
 		return
 	}
 
 	if resp.StatusCode == 200 {
-		videoSource.Status = consts.VideoSourceStatusNormal
-	} else {
-		videoSource.Status = consts.VideoSourceStatusUnavailable
+		check.Status = consts.VideoSourceStatusNormal
 	}
-	err = c.videoSourceService.Save(videoSource)
-	if err != nil {
+	// 指定 keyword 时执行一次试搜，并按严格模式校验结果，存在 error 级别的问题视为不可用
+	if keyword := strings.TrimSpace(ctx.Query("keyword")); keyword != "" && check.Status == consts.VideoSourceStatusNormal {
+		check.Keyword = keyword
+		checkSearchResult(ctx, &videoSource, check)
+	}
+
+	if err := c.videoSourceService.UpdateHealthCheck(videoSource.Id, check.Status, check); err != nil {
 		utils.SendResponse(ctx, consts.ResponseCodeSaveVideoSourceFailed, err.Error(), nil)
 		return
 	}
-	utils.SuccessResponse(ctx, videoSource.Status)
+	if len(check.Issues) > 0 {
+		utils.SuccessResponseWithWarnings(ctx, check.Status, check.Issues)
+		return
+	}
+	utils.SuccessResponse(ctx, check.Status)
+}
+
+// checkSearchResult 执行 search_video 并把结果校验情况写入检查记录
+func checkSearchResult(ctx *gin.Context, src *entities.VideoSourceEntity, check *entities.VideoSourceHealthCheck) {
+	// 与视频接口一致，固定了稳定版本时检查稳定版本
+	runtime := src.Runtime()
+	data, err := executeByEngine(ctx, &runtime, "search_video", check.Keyword)
+	if err != nil {
+		check.Status = consts.VideoSourceStatusUnavailable
+		check.Error = err.Error()
+		return
+	}
+	converted, report, err := entities.ValidateScriptResult("search_video", data, true)
+	check.Errors, check.Warnings = report.Errors, report.Warnings
+	check.Issues = report.Problems()
+	if err != nil {
+		check.Status = consts.VideoSourceStatusUnavailable
+		check.Error = err.Error()
+		return
+	}
+	check.Results = len(converted.([]entities.SearchVideoResult))
+}
+
+// saveHealthCheck 保存失败的检查记录，保存出错只记录日志
+func (c *VideoSourceController) saveHealthCheck(ctx *gin.Context, videoSource entities.VideoSourceEntity, check *entities.VideoSourceHealthCheck) {
+	if err := c.videoSourceService.UpdateHealthCheck(videoSource.Id, check.Status, check); err != nil {
+		logger.CtxLogger(ctx).WithError(err).WithField("source_id", videoSource.Id).Error("save video source health check failed")
+	}
 }
 
 // SetStatus 手动设置站点状态
@@ -251,6 +445,16 @@ func (c *VideoSourceController) Import(ctx *gin.Context) {
 		utils.SendResponse(ctx, consts.ResponseCodeParamError, "参数错误: "+err.Error(), nil)
 		return
 	}
+	// 与 Save 一致，广告过滤规则无效的站点整体拒绝导入，并指明是哪个站点
+	for i, source := range importData {
+		if source.AdFilter == nil {
+			continue
+		}
+		if err := source.AdFilter.Validate(); err != nil {
+			utils.SendResponse(ctx, consts.ResponseCodeParamError, fmt.Sprintf("参数错误: 第 %d 个站点 %q: %s", i+1, source.Name, err.Error()), nil)
+			return
+		}
+	}
 
 	// 调用服务层进行导入
 	importedCount, err := c.videoSourceService.Import(importData)
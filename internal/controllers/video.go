@@ -1,15 +1,26 @@
 package controllers
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"video-crawler/internal/config"
+	"video-crawler/internal/consts"
 	"video-crawler/internal/crawler"
 	"video-crawler/internal/entities"
+	"video-crawler/internal/hls"
 	"video-crawler/internal/jsengine"
+	"video-crawler/internal/logger"
 	"video-crawler/internal/lua"
+	"video-crawler/internal/scriptlib"
 	"video-crawler/internal/services"
 	"video-crawler/internal/utils"
 
@@ -18,13 +29,24 @@ import (
 
 // VideoController 提供视频搜索 / 详情 / 播放地址能力
 type VideoController struct {
+	config             *config.Config
 	videoSourceService services.VideoSourceService
 	historyService     services.HistoryService
 	userService        services.UserServiceInterface
+	playURLCache       services.PlayURLCacheService
 }
 
-func NewVideoController(videoSourceService services.VideoSourceService, historyService services.HistoryService, userService services.UserServiceInterface) *VideoController {
-	return &VideoController{videoSourceService: videoSourceService, historyService: historyService, userService: userService}
+func NewVideoController(cfg *config.Config, videoSourceService services.VideoSourceService, historyService services.HistoryService, userService services.UserServiceInterface, playURLCache services.PlayURLCacheService) *VideoController {
+	return &VideoController{config: cfg, videoSourceService: videoSourceService, historyService: historyService, userService: userService, playURLCache: playURLCache}
+}
+
+// runtimeSource 读取站点配置，固定了稳定版本时使用稳定版本的脚本
+func (c *VideoController) runtimeSource(sourceID string) (entities.VideoSourceEntity, error) {
+	src, err := c.videoSourceService.Detail(sourceID)
+	if err != nil {
+		return src, err
+	}
+	return src.Runtime(), nil
 }
 
 // Search 视频搜索
@@ -37,26 +59,117 @@ func (c *VideoController) Search(ctx *gin.Context) {
 		return
 	}
 
-	videoSource, err := c.videoSourceService.Detail(sourceID)
+	videoSource, err := c.runtimeSource(sourceID)
 	if err != nil {
 		utils.SendResponse(ctx, http.StatusBadRequest, "获取视频源失败: "+err.Error(), nil)
 		return
 	}
 
-	data, err := c.executeByEngine(ctx, &videoSource, "search_video", keyword)
+	data, err := executeByEngine(ctx, &videoSource, "search_video", keyword)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+		c.sendResultError(ctx, "", err)
 		return
 	}
 
 	// 验证并规范化搜索结果
-	validResults, err := entities.ValidateSearchVideoResult(data)
+	converted, report, err := c.validateResult(ctx, "search_video", data)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, "搜索结果格式错误: "+err.Error(), nil)
+		c.sendResultError(ctx, "搜索结果格式错误", err)
 		return
 	}
 
-	utils.SuccessResponse(ctx, validResults)
+	utils.SuccessResponseWithWarnings(ctx, converted.([]entities.SearchVideoResult), resultWarnings(report))
+}
+
+// aggregateSearchConcurrency 聚合搜索时同时执行的站点脚本数量
+const aggregateSearchConcurrency = 5
+
+// SearchAggregate 跨站点聚合搜索，同一影片合并为一条并列出所有站点入口
+// GET /api/video/search/aggregate?keyword=yyy[&source_type=n]
+func (c *VideoController) SearchAggregate(ctx *gin.Context) {
+	keyword := ctx.Query("keyword")
+	if keyword == "" {
+		utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: keyword 不能为空", nil)
+		return
+	}
+	sourceType := -1
+	if v := ctx.Query("source_type"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: source_type 必须为数字", nil)
+			return
+		}
+		sourceType = n
+	}
+
+	sourceList, err := c.videoSourceService.List()
+	if err != nil {
+		utils.SendResponse(ctx, http.StatusInternalServerError, "获取视频源列表失败: "+err.Error(), nil)
+		return
+	}
+	// 按排序值从大到小执行，保证合并后主条目优先取排序靠前的站点
+	sort.SliceStable(sourceList, func(i, j int) bool { return sourceList[i].Sort > sourceList[j].Sort })
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, aggregateSearchConcurrency)
+		results  = make([][]entities.SourcedSearchVideoResult, len(sourceList))
+		problems = make([][]entities.ScriptResultIssue, len(sourceList))
+	)
+	for i, item := range sourceList {
+		if item.Status != consts.VideoSourceStatusNormal {
+			continue
+		}
+		if sourceType >= 0 && item.SourceType != sourceType {
+			continue
+		}
+		videoSource, err := c.runtimeSource(item.Id)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		// 每个协程使用独立的请求副本，gin.Context 不能在多个协程间共享
+		go func(ctx *gin.Context, idx int, src entities.VideoSourceEntity) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			data, err := executeByEngine(ctx, &src, "search_video", keyword)
+			if err != nil {
+				logger.CtxLogger(ctx).WithError(err).WithField("source_id", src.Id).Warn("aggregate search failed")
+				return
+			}
+			converted, report, err := c.validateResult(ctx, "search_video", data)
+			if err != nil {
+				logger.CtxLogger(ctx).WithError(err).WithField("source_id", src.Id).Warn("aggregate search result invalid")
+				problems[idx] = report.Problems()
+				return
+			}
+			problems[idx] = report.Problems()
+			validResults := converted.([]entities.SearchVideoResult)
+			sourced := make([]entities.SourcedSearchVideoResult, 0, len(validResults))
+			for _, r := range validResults {
+				sourced = append(sourced, entities.SourcedSearchVideoResult{SourceID: src.Id, Result: r})
+			}
+			results[idx] = sourced
+		}(ctx.Copy(), i, videoSource)
+	}
+	wg.Wait()
+
+	all := make([]entities.SourcedSearchVideoResult, 0)
+	// 按站点列出结果校验问题
+	warnings := map[string][]entities.ScriptResultIssue{}
+	for i, r := range results {
+		all = append(all, r...)
+		if len(problems[i]) > 0 {
+			warnings[sourceList[i].Id] = problems[i]
+		}
+	}
+	if len(warnings) == 0 {
+		utils.SuccessResponse(ctx, services.MergeSearchVideoResults(all))
+		return
+	}
+	utils.SuccessResponseWithWarnings(ctx, services.MergeSearchVideoResults(all), warnings)
 }
 
 // Detail 视频详情
@@ -69,26 +182,27 @@ func (c *VideoController) Detail(ctx *gin.Context) {
 		return
 	}
 
-	videoSource, err := c.videoSourceService.Detail(sourceID)
+	videoSource, err := c.runtimeSource(sourceID)
 	if err != nil {
 		utils.SendResponse(ctx, http.StatusBadRequest, "获取视频源失败: "+err.Error(), nil)
 		return
 	}
 
-	data, err := c.executeByEngine(ctx, &videoSource, "get_video_detail", url)
+	data, err := executeByEngine(ctx, &videoSource, "get_video_detail", url)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+		c.sendResultError(ctx, "", err)
 		return
 	}
 
 	// 验证并规范化视频详情结果
-	validResult, err := entities.ValidateVideoDetailResult(data)
+	converted, report, err := c.validateResult(ctx, "get_video_detail", data)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, "视频详情格式错误: "+err.Error(), nil)
+		c.sendResultError(ctx, "视频详情格式错误", err)
 		return
 	}
+	validResult := converted.(*entities.VideoDetailResult)
 	// 使用验证后的结果
-	utils.SuccessResponse(ctx, validResult)
+	utils.SuccessResponseWithWarnings(ctx, validResult, resultWarnings(report))
 
 	// 异步记录观看历史，失败不影响接口返回
 	go func(sourceIDCopy, urlCopy string, dataCopy interface{}) {
@@ -128,8 +242,14 @@ func (c *VideoController) Detail(ctx *gin.Context) {
 	}(sourceID, url, validResult)
 }
 
-// PlayURL 获取可播放地址
-// GET /api/video/url?source_id=xxx&url=yyy
+// playURLResponse 播放地址；m3u8 地址同时返回签名的代理地址，播放器通过代理拉取以应用站点请求头与广告过滤
+type playURLResponse struct {
+	entities.PlayVideoDetailResult
+	ProxyURL string `json:"proxy_url,omitempty"`
+}
+
+// PlayURL 获取可播放地址，refresh=1 时忽略缓存重新解析
+// GET /api/video/url?source_id=xxx&url=yyy[&refresh=1]
 func (c *VideoController) PlayURL(ctx *gin.Context) {
 	sourceID := ctx.Query("source_id")
 	url := ctx.Query("url")
@@ -138,30 +258,324 @@ func (c *VideoController) PlayURL(ctx *gin.Context) {
 		return
 	}
 
-	videoSource, err := c.videoSourceService.Detail(sourceID)
+	videoSource, err := c.runtimeSource(sourceID)
 	if err != nil {
 		utils.SendResponse(ctx, http.StatusBadRequest, "获取视频源失败: "+err.Error(), nil)
 		return
 	}
 
-	data, err := c.executeByEngine(ctx, &videoSource, "get_play_video_detail", url)
+	validResult, report, _, err := c.resolvePlayURL(ctx, &videoSource, url, ctx.Query("refresh") == "1")
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+		c.sendResultError(ctx, "", err)
 		return
 	}
 
+	result := playURLResponse{PlayVideoDetailResult: *validResult}
+	if hls.IsPlaylist("", validResult.VideoURL, nil) {
+		result.ProxyURL = c.proxyURL("/api/video/proxy/hls", videoSource.Id, validResult.VideoURL, nil)
+	}
+	utils.SuccessResponseWithWarnings(ctx, result, resultWarnings(report))
+}
+
+// resolvePlayURL 执行 get_play_video_detail 并校验结果，优先使用缓存（命中缓存时 report 为 nil）
+func (c *VideoController) resolvePlayURL(ctx *gin.Context, src *entities.VideoSourceEntity, episodeURL string, refresh bool) (*entities.PlayVideoDetailResult, *entities.ScriptResultReport, bool, error) {
+	// 与脚本收到的 ctx 一致，不同请求参数的结果分开缓存
+	cc := newCallContext(ctx, src, "get_play_video_detail")
+	if c.playURLCache != nil && !refresh {
+		if cached, ok := c.playURLCache.Get(src, episodeURL, cc); ok {
+			return &cached, nil, true, nil
+		}
+	}
+	data, err := executeByEngine(ctx, src, "get_play_video_detail", episodeURL)
+	if err != nil {
+		return nil, nil, false, err
+	}
 	// 验证并规范化播放详情结果
-	validResult, err := entities.ValidatePlayVideoDetailResult(data)
+	converted, report, err := c.validateResult(ctx, "get_play_video_detail", data)
+	if err != nil {
+		return nil, report, false, fmt.Errorf("播放详情格式错误: %w", err)
+	}
+	validResult := converted.(*entities.PlayVideoDetailResult)
+	if validResult == nil {
+		return nil, report, false, fmt.Errorf("播放详情格式错误: 脚本没有返回对象")
+	}
+	if c.playURLCache != nil {
+		c.playURLCache.Set(src, episodeURL, cc, *validResult)
+	}
+	return validResult, report, false, nil
+}
+
+// strictValidation 是否严格校验脚本结果：strict 参数优先，否则使用配置
+func (c *VideoController) strictValidation(ctx *gin.Context) bool {
+	switch ctx.Query("strict") {
+	case "1", "true":
+		return true
+	case "0", "false":
+		return false
+	}
+	return c.config != nil && c.config.Script.StrictValidation
+}
+
+// validateResult 校验并转换脚本结果，严格模式下存在 error 级别的问题时返回 *entities.ScriptResultError
+func (c *VideoController) validateResult(ctx *gin.Context, method string, data interface{}) (interface{}, *entities.ScriptResultReport, error) {
+	return entities.ValidateScriptResult(method, data, c.strictValidation(ctx))
+}
+
+// resultWarnings 返回报告中的 error 与 warning，没有问题时返回 nil 以省略响应中的 warnings 字段
+func resultWarnings(report *entities.ScriptResultReport) any {
+	if report == nil {
+		return nil
+	}
+	if problems := report.Problems(); len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// sendResultError 返回脚本执行或结果校验失败；校验失败时 data 为完整的校验报告，
+// 开发环境下脚本出错时 data 为结构化脚本错误（行号、调用栈与源码片段）
+func (c *VideoController) sendResultError(ctx *gin.Context, prefix string, err error) {
+	var resultErr *entities.ScriptResultError
+	if errors.As(err, &resultErr) {
+		utils.SendResponse(ctx, http.StatusUnprocessableEntity, err.Error(), resultErr.Report)
+		return
+	}
+	if prefix != "" {
+		utils.SendResponse(ctx, http.StatusInternalServerError, prefix+": "+err.Error(), c.devScriptError(err))
+		return
+	}
+	utils.SendResponse(ctx, http.StatusInternalServerError, err.Error(), c.devScriptError(err))
+}
+
+// devScriptError 开发环境下返回错误链中的结构化脚本错误，生产环境不暴露脚本源码，返回 nil
+func (c *VideoController) devScriptError(err error) any {
+	if c.config == nil || c.config.Env != "dev" {
+		return nil
+	}
+	return scriptErrorData(err)
+}
+
+// scriptErrorData 错误链中的结构化脚本错误，没有时返回 nil
+func scriptErrorData(err error) any {
+	if se, ok := scriptlib.AsScriptError(err); ok {
+		return se
+	}
+	return nil
+}
+
+// batchResolveConcurrency 批量解析剧集播放地址时默认同时执行的脚本数量
+const (
+	batchResolveConcurrency    = 4
+	maxBatchResolveConcurrency = 8
+)
+
+// batchEpisodeResult 批量解析中单集的结果
+type batchEpisodeResult struct {
+	Index    int    `json:"index"`     // 剧集在线路中的序号
+	Name     string `json:"name"`      // 剧集名称
+	URL      string `json:"url"`       // 剧集页面地址
+	VideoURL string `json:"video_url"` // 解析出的播放地址
+	Cached   bool   `json:"cached"`    // 是否命中缓存
+	Error    string `json:"error"`     // 解析失败原因
+	// Warnings 播放地址结果校验中的问题
+	Warnings []entities.ScriptResultIssue `json:"warnings,omitempty"`
+}
+
+// PlayURLBatch 解析整条线路所有剧集的播放地址，通过 SSE 逐集返回结果
+// 事件：meta（线路信息）、episode（单集结果，按完成顺序）、done（汇总）、error（整体失败）
+// GET /api/video/url/batch?source_id=xxx&url=详情页地址[&line=线路名][&concurrency=n][&refresh=1]
+func (c *VideoController) PlayURLBatch(ctx *gin.Context) {
+	sourceID := ctx.Query("source_id")
+	detailURL := ctx.Query("url")
+	if sourceID == "" || detailURL == "" {
+		utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: source_id 与 url 不能为空", nil)
+		return
+	}
+	concurrency := batchResolveConcurrency
+	if v := ctx.Query("concurrency"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			utils.SendResponse(ctx, http.StatusBadRequest, "参数错误: concurrency 必须为正整数", nil)
+			return
+		}
+		concurrency = min(n, maxBatchResolveConcurrency)
+	}
+	refresh := ctx.Query("refresh") == "1"
+
+	videoSource, err := c.runtimeSource(sourceID)
 	if err != nil {
-		utils.SendResponse(ctx, http.StatusInternalServerError, "播放详情格式错误: "+err.Error(), nil)
+		utils.SendResponse(ctx, http.StatusBadRequest, "获取视频源失败: "+err.Error(), nil)
 		return
 	}
 
-	utils.SuccessResponse(ctx, validResult)
+	writer := ctx.Writer
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		utils.SendResponse(ctx, http.StatusInternalServerError, "服务器不支持流式响应", nil)
+		return
+	}
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	sendEvent := func(event string, payload interface{}) {
+		data, _ := json.Marshal(payload)
+		fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	data, err := executeByEngine(ctx, &videoSource, "get_video_detail", detailURL)
+	if err != nil {
+		sendEvent("error", gin.H{"message": err.Error(), "script_error": c.devScriptError(err)})
+		return
+	}
+	converted, report, err := c.validateResult(ctx, "get_video_detail", data)
+	if err != nil {
+		sendEvent("error", gin.H{"message": "视频详情格式错误: " + err.Error(), "report": report})
+		return
+	}
+	detail := converted.(*entities.VideoDetailResult)
+	if detail == nil {
+		sendEvent("error", gin.H{"message": "视频详情格式错误: 脚本没有返回对象", "report": report})
+		return
+	}
+	line, err := pickSourceLine(detail.Source, ctx.Query("line"))
+	if err != nil {
+		sendEvent("error", gin.H{"message": err.Error()})
+		return
+	}
+	sendEvent("meta", gin.H{"name": detail.Name, "line": line.Name, "total": len(line.Episodes), "warnings": report.Problems()})
+
+	// 客户端断开后 gin.Context 会被回收，解析协程使用请求的副本；断开时取消尚未开始的剧集
+	reqCtx := ctx.Copy()
+	runCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+	results := resolveEpisodes(runCtx, line.Episodes, concurrency, func(idx int, ep entities.EpisodeItem) batchEpisodeResult {
+		r := batchEpisodeResult{Index: idx, Name: ep.Name, URL: ep.URL}
+		play, report, cached, err := c.resolvePlayURL(episodeRequest(reqCtx, ep.URL), &videoSource, ep.URL, refresh)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.VideoURL, r.Cached = play.VideoURL, cached
+		}
+		if report != nil {
+			r.Warnings = report.Problems()
+		}
+		return r
+	})
+
+	success, failed := 0, 0
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				sendEvent("done", gin.H{"total": len(line.Episodes), "success": success, "failed": failed})
+				return
+			}
+			if r.Error != "" {
+				failed++
+			} else {
+				success++
+			}
+			sendEvent("episode", r)
+		case <-runCtx.Done():
+			// 客户端断开，未开始的剧集不再解析
+			return
+		}
+	}
+}
+
+// batchOnlyParams 批量解析接口自身的请求参数，单集解析时去掉
+var batchOnlyParams = []string{"line", "concurrency", "refresh"}
+
+// episodeRequest 构造单集解析使用的请求副本：去掉批量接口自身的参数并以剧集地址作为 url，
+// 与播放器请求 /api/video/url 时脚本收到的 ctx 及播放地址缓存键一致，预解析的结果可以直接命中
+func episodeRequest(base *gin.Context, episodeURL string) *gin.Context {
+	ep := base.Copy()
+	req := base.Request.Clone(base.Request.Context())
+	q := req.URL.Query()
+	for _, k := range batchOnlyParams {
+		q.Del(k)
+	}
+	q.Set("url", episodeURL)
+	req.URL.RawQuery = q.Encode()
+	ep.Request = req
+	return ep
 }
 
-// executeLuaFunction 组合 Lua 脚本并执行指定函数，返回其返回的数据表
-func executeLuaFunction(ctx *gin.Context, baseScript string, funcName string, arg string) (interface{}, error) {
+// resolveEpisodes 以最多 concurrency 个协程并发解析剧集，结果按完成顺序写入返回的通道，全部结束后关闭通道。
+// runCtx 取消后不再开始新的剧集，已完成的结果也不再等待读取
+func resolveEpisodes(runCtx context.Context, episodes []entities.EpisodeItem, concurrency int, resolve func(idx int, ep entities.EpisodeItem) batchEpisodeResult) <-chan batchEpisodeResult {
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, concurrency)
+		results = make(chan batchEpisodeResult)
+	)
+	for i, ep := range episodes {
+		wg.Add(1)
+		go func(idx int, ep entities.EpisodeItem) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-runCtx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			if runCtx.Err() != nil {
+				return
+			}
+			r := resolve(idx, ep)
+			select {
+			case results <- r:
+			case <-runCtx.Done():
+			}
+		}(i, ep)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// pickSourceLine 按名称选择播放线路，未指定时使用第一条
+func pickSourceLine(lines []entities.SourceItem, name string) (entities.SourceItem, error) {
+	if len(lines) == 0 {
+		return entities.SourceItem{}, fmt.Errorf("视频详情中没有播放线路")
+	}
+	if name == "" {
+		return lines[0], nil
+	}
+	names := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l.Name == name {
+			return l, nil
+		}
+		names = append(names, l.Name)
+	}
+	return entities.SourceItem{}, fmt.Errorf("线路 %q 不存在，可选线路: %s", name, strings.Join(names, ", "))
+}
+
+// newCallContext 由视频接口请求构造入口函数的 ctx 参数
+func newCallContext(ctx *gin.Context, src *entities.VideoSourceEntity, funcName string) *scriptlib.CallContext {
+	params := map[string]string{}
+	for k, v := range ctx.Request.URL.Query() {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+	cc := scriptlib.NewCallContext(funcName, params)
+	if cc.Locale == "" {
+		// Accept-Language 的首选语言，如 "zh-CN,zh;q=0.9" -> "zh-CN"
+		lang := strings.SplitN(ctx.GetHeader("Accept-Language"), ",", 2)[0]
+		cc.Locale = strings.TrimSpace(strings.SplitN(lang, ";", 2)[0])
+	}
+	cc.Source = scriptlib.SourceInfo{ID: src.Id, Name: src.Name, Domain: src.Domain, Settings: src.Settings}
+	cc.Store = scriptlib.SourceStore(src.Id)
+	return cc
+}
+
+// executeLuaFunction 执行 Lua 脚本并调用指定函数，返回其返回的数据表
+func executeLuaFunction(ctx *gin.Context, baseScript string, funcName string, arg string, cc *scriptlib.CallContext) (interface{}, error) {
 	// 创建浏览器
 	browser, err := crawler.NewDefaultBrowser()
 	if err != nil {
@@ -193,15 +607,10 @@ func executeLuaFunction(ctx *gin.Context, baseScript string, funcName string, ar
 		"DNT":                       "1",
 	})
 
-	// 组合脚本：原脚本 + 调用指定函数并 return { data=..., err=... }
-	argLiteral := strconv.Quote(arg) // 作为 Lua 字面量字符串使用
-	wrapped := baseScript + "\n\n" +
-		fmt.Sprintf("local __data, __err = %s(%s)\nreturn { data = __data, err = __err }\n", funcName, argLiteral)
-
-	// 执行
+	// 执行脚本并按名称调用入口函数，参数以原生值传入
 	engine := lua.NewLuaEngineWithContext(browser, ctx)
 	defer engine.Close()
-	ret, execErr := engine.Execute(wrapped)
+	ret, execErr := engine.CallFunction(baseScript, funcName, arg, cc)
 	if execErr != nil {
 		return nil, fmt.Errorf("脚本执行失败: %w", execErr)
 	}
@@ -214,7 +623,7 @@ func executeLuaFunction(ctx *gin.Context, baseScript string, funcName string, ar
 }
 
 // executeByEngine 根据站点 engine_type 调用 Lua 或 JS 引擎
-func (c *VideoController) executeByEngine(ctx *gin.Context, src *entities.VideoSourceEntity, funcName string, arg string) (interface{}, error) {
+func executeByEngine(ctx *gin.Context, src *entities.VideoSourceEntity, funcName string, arg string) (interface{}, error) {
 	if src.EngineType == 1 {
 		// JS 引擎
 		browser, err := crawler.NewDefaultBrowser()
@@ -228,18 +637,19 @@ func (c *VideoController) executeByEngine(ctx *gin.Context, src *entities.VideoS
 			browser.SetRandomUserAgent()
 		}
 		e := jsengine.NewWithContext(browser, ctx)
-		argLiteral := strconv.Quote(arg)
-		wrapped := src.JsScript + "\n\n" +
-			fmt.Sprintf("var __ret = (function(){ try { var r = %s(%s); return {data: r, err: null}; } catch(e){ return {data:null, err: String(e)} } })(); __ret;", funcName, argLiteral)
-		m, err := e.ExecuteWrapped(wrapped)
+		m, err := e.CallFunction(src.JsScript, funcName, arg, newCallContext(ctx, src, funcName))
 		if err != nil {
 			return nil, err
 		}
 		if v, ok := m["err"]; ok && v != nil && fmt.Sprint(v) != "" {
+			// 抛出的异常带有结构化错误，错误文本与 err 相同
+			if se, ok := m["error"].(*scriptlib.ScriptError); ok {
+				return nil, fmt.Errorf("脚本返回错误: %w", se)
+			}
 			return nil, fmt.Errorf("脚本返回错误: %v", v)
 		}
 		return m["data"], nil
 	}
 	// 默认 Lua
-	return executeLuaFunction(ctx, src.LuaScript, funcName, arg)
+	return executeLuaFunction(ctx, src.LuaScript, funcName, arg, newCallContext(ctx, src, funcName))
 }
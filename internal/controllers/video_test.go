@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"video-crawler/internal/entities"
+	"video-crawler/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPickSourceLine(t *testing.T) {
+	lines := []entities.SourceItem{{Name: "线路1"}, {Name: "线路2"}}
+	if l, err := pickSourceLine(lines, ""); err != nil || l.Name != "线路1" {
+		t.Fatalf("default line = %v, %v", l.Name, err)
+	}
+	if l, err := pickSourceLine(lines, "线路2"); err != nil || l.Name != "线路2" {
+		t.Fatalf("named line = %v, %v", l.Name, err)
+	}
+	if _, err := pickSourceLine(lines, "线路3"); err == nil {
+		t.Fatal("unknown line should fail")
+	}
+	if _, err := pickSourceLine(nil, ""); err == nil {
+		t.Fatal("empty lines should fail")
+	}
+}
+
+func TestResolveEpisodes(t *testing.T) {
+	episodes := make([]entities.EpisodeItem, 10)
+	for i := range episodes {
+		episodes[i] = entities.EpisodeItem{Name: fmt.Sprintf("第%d集", i+1), URL: fmt.Sprintf("/play/%d", i)}
+	}
+	var running, peak int32
+	results := resolveEpisodes(context.Background(), episodes, 3, func(idx int, ep entities.EpisodeItem) batchEpisodeResult {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		// 序号越小越晚完成，结果按完成顺序返回
+		time.Sleep(time.Duration(len(episodes)-idx) * 3 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return batchEpisodeResult{Index: idx, Name: ep.Name, URL: ep.URL}
+	})
+
+	seen := map[int]bool{}
+	for r := range results {
+		if seen[r.Index] {
+			t.Fatalf("episode %d reported twice", r.Index)
+		}
+		seen[r.Index] = true
+		if r.Name != episodes[r.Index].Name || r.URL != episodes[r.Index].URL {
+			t.Fatalf("result %d does not match its episode: %+v", r.Index, r)
+		}
+	}
+	if len(seen) != len(episodes) {
+		t.Fatalf("got %d results, want %d", len(seen), len(episodes))
+	}
+	if peak > 3 {
+		t.Fatalf("peak concurrency = %d, want <= 3", peak)
+	}
+
+	// 取消后不再开始新的剧集，通道最终关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	var started int32
+	results = resolveEpisodes(ctx, episodes, 1, func(idx int, ep entities.EpisodeItem) batchEpisodeResult {
+		atomic.AddInt32(&started, 1)
+		cancel()
+		return batchEpisodeResult{Index: idx}
+	})
+	for range results {
+	}
+	if started != 1 {
+		t.Fatalf("started %d episodes after cancel, want 1", started)
+	}
+}
+
+func TestEpisodeRequestCacheKey(t *testing.T) {
+	src := &entities.VideoSourceEntity{Id: "src", LuaScript: "-- script"}
+	cache := services.NewPlayURLCacheService(nil)
+	newRequest := func(target string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+		return ctx
+	}
+	episodeURL := "https://example.com/play/1"
+
+	// 批量解析带有线路、并发等自身参数，单集的 ctx 与播放器请求单集地址时一致
+	batch := newRequest("/api/video/url/batch?source_id=src&url=" + url.QueryEscape("https://example.com/detail") + "&line=" + url.QueryEscape("线路2") + "&concurrency=3&refresh=1&quality=hd")
+	warm := newCallContext(episodeRequest(batch, episodeURL), src, "get_play_video_detail")
+	if warm.Params["url"] != episodeURL || warm.Params["line"] != "" || warm.Params["quality"] != "hd" {
+		t.Fatalf("episode params = %v", warm.Params)
+	}
+	if batch.Query("line") != "线路2" {
+		t.Fatal("episode request must not modify the batch request")
+	}
+	cache.Set(src, episodeURL, warm, entities.PlayVideoDetailResult{VideoURL: "https://cdn.example.com/1.m3u8"})
+
+	play := newRequest("/api/video/url?source_id=src&url=" + url.QueryEscape(episodeURL) + "&quality=hd")
+	if got, ok := cache.Get(src, episodeURL, newCallContext(play, src, "get_play_video_detail")); !ok || got.VideoURL != "https://cdn.example.com/1.m3u8" {
+		t.Fatalf("batch-warmed entry should be a cache hit for the player request: %v, %v", got, ok)
+	}
+}
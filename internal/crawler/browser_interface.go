@@ -2,6 +2,7 @@ package crawler
 
 import (
 	"net/http"
+	"net/url"
 	"time"
 
 	fakeUserAgent "github.com/lib4u/fake-useragent"
@@ -15,9 +16,18 @@ type BrowserRequest interface {
 	// Post 发送POST请求
 	Post(url string, data map[string]interface{}) (*http.Response, error)
 
+	// PostForm 以 application/x-www-form-urlencoded 提交表单（同名键重复编码）
+	PostForm(url string, data url.Values) (*http.Response, error)
+
+	// PostMultipart 以 multipart/form-data 提交字段与文件
+	PostMultipart(url string, fields url.Values, files []MultipartFile) (*http.Response, error)
+
 	// Do 发送任意方法请求（headers 将覆盖全局同名 header；body 为原始字节）
 	Do(method string, url string, body []byte, headers map[string]string) (*http.Response, error)
 
+	// DoWithOptions 发送任意方法请求，超时、重定向、代理等选项只作用于本次请求
+	DoWithOptions(method string, url string, body []byte, options RequestOptions) (*http.Response, error)
+
 	// SetHeaders 设置请求头
 	SetHeaders(headers map[string]string)
 
@@ -52,6 +62,18 @@ type BrowserRequest interface {
 	Close() error
 }
 
+// RequestOptions 单次请求选项，零值表示沿用浏览器的全局配置
+type RequestOptions struct {
+	// Headers 覆盖全局同名 header
+	Headers map[string]string
+	// Timeout 大于 0 时覆盖全局超时
+	Timeout time.Duration
+	// FollowRedirects 非 nil 时覆盖全局重定向策略
+	FollowRedirects *bool
+	// Proxy 非空时本次请求经该代理发送
+	Proxy string
+}
+
 // BrowserConfig 浏览器配置
 type BrowserConfig struct {
 	Timeout         time.Duration
@@ -62,6 +84,8 @@ type BrowserConfig struct {
 	MaxRetries      int
 	RetryDelay      time.Duration
 	FollowRedirects bool
+	// BlockPrivateNetwork 拒绝连接回环、内网、链路本地等地址（含重定向），用于代为访问用户提供的地址
+	BlockPrivateNetwork bool
 }
 
 // DefaultConfig 默认配置
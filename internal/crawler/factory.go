@@ -32,3 +32,15 @@ func NewDefaultBrowser() (BrowserRequest, error) {
 	browser.SetRandomUserAgent()
 	return browser, nil
 }
+
+// NewPublicBrowser 创建只允许访问公网地址的浏览器实例，用于代为请求用户或站点提供的地址
+func NewPublicBrowser() (BrowserRequest, error) {
+	config := DefaultConfig()
+	config.BlockPrivateNetwork = true
+	browser, err := NewHTTPBrowser(config)
+	if err != nil {
+		return nil, err
+	}
+	browser.SetRandomUserAgent()
+	return browser, nil
+}
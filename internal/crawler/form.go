@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MultipartFile multipart/form-data 中的文件部分
+type MultipartFile struct {
+	// Field 表单字段名
+	Field string
+	// FileName 文件名，为空时使用字段名
+	FileName string
+	// ContentType 为空时使用 application/octet-stream
+	ContentType string
+	Data        []byte
+}
+
+// FormValues 将任意 map 转换为 url.Values，切片值展开为重复的键
+func FormValues(data map[string]interface{}) url.Values {
+	values := url.Values{}
+	for key, value := range data {
+		switch v := value.(type) {
+		case []string:
+			for _, item := range v {
+				values.Add(key, item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				values.Add(key, formString(item))
+			}
+		default:
+			values.Add(key, formString(v))
+		}
+	}
+	return values
+}
+
+func formString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// EncodeMultipart 编码 multipart/form-data 请求体，返回请求体与带 boundary 的 Content-Type。
+// 普通字段按键名排序、同名字段保持原顺序，文件部分按传入顺序写在字段之后。
+func EncodeMultipart(fields url.Values, files []MultipartFile) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range fields[key] {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, "", fmt.Errorf("failed to write field %s: %w", key, err)
+			}
+		}
+	}
+
+	for _, file := range files {
+		if file.Field == "" {
+			return nil, "", fmt.Errorf("multipart file field name is required")
+		}
+		fileName := file.FileName
+		if fileName == "" {
+			fileName = file.Field
+		}
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(file.Field), escapeQuotes(fileName)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create file part %s: %w", file.Field, err)
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return nil, "", fmt.Errorf("failed to write file part %s: %w", file.Field, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// escapeQuotes 与 mime/multipart 内部的转义规则一致
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
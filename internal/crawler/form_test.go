@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// parsedForm 测试服务端实际解析到的表单
+type parsedForm struct {
+	ContentType string                `json:"content_type"`
+	Form        url.Values            `json:"form"`
+	Files       map[string]parsedFile `json:"files"`
+}
+
+type parsedFile struct {
+	FileName    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+func formEchoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		result := parsedForm{ContentType: mediaType, Files: map[string]parsedFile{}}
+		if mediaType == "multipart/form-data" {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for field, headers := range r.MultipartForm.File {
+				f, _ := headers[0].Open()
+				data, _ := io.ReadAll(f)
+				f.Close()
+				result.Files[field] = parsedFile{FileName: headers[0].Filename, ContentType: headers[0].Header.Get("Content-Type"), Content: string(data)}
+			}
+		} else if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result.Form = r.PostForm
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func decodeParsedForm(t *testing.T, resp *http.Response) parsedForm {
+	t.Helper()
+	defer resp.Body.Close()
+	var result parsedForm
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestPostFormAndMultipart(t *testing.T) {
+	server := formEchoServer(t)
+	browser, err := NewHTTPBrowser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+
+	fields := FormValues(map[string]interface{}{
+		"tag": []interface{}{"a", "b", 3},
+		"ids": []string{"1", "2"},
+		"q":   "x y&z",
+		"n":   nil,
+	})
+	want := url.Values{"tag": {"a", "b", "3"}, "ids": {"1", "2"}, "q": {"x y&z"}, "n": {""}}
+
+	resp, err := browser.PostForm(server.URL, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeParsedForm(t, resp)
+	if got.ContentType != "application/x-www-form-urlencoded" || !reflect.DeepEqual(got.Form, want) {
+		t.Fatalf("form = %+v", got)
+	}
+
+	resp, err = browser.PostMultipart(server.URL, fields, []MultipartFile{
+		{Field: "cover", FileName: `a "b".png`, ContentType: "image/png", Data: []byte("\x00PNG\r\n")},
+		{Field: "raw", Data: []byte("text")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = decodeParsedForm(t, resp)
+	if got.ContentType != "multipart/form-data" || !reflect.DeepEqual(got.Form, want) {
+		t.Fatalf("multipart form = %+v", got)
+	}
+	if f := got.Files["cover"]; f != (parsedFile{FileName: `a "b".png`, ContentType: "image/png", Content: "\x00PNG\r\n"}) {
+		t.Fatalf("cover = %+v", f)
+	}
+	// 未指定时文件名取字段名，类型为 application/octet-stream
+	if f := got.Files["raw"]; f != (parsedFile{FileName: "raw", ContentType: "application/octet-stream", Content: "text"}) {
+		t.Fatalf("raw = %+v", f)
+	}
+
+	if _, _, err := EncodeMultipart(nil, []MultipartFile{{Data: []byte("x")}}); err == nil {
+		t.Fatal("file without field name should fail")
+	}
+}
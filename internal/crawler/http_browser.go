@@ -2,7 +2,6 @@ package crawler
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -17,6 +16,7 @@ import (
 type HTTPBrowser struct {
 	client *http.Client
 	config *BrowserConfig
+	tracer HTTPTracer // 非 nil 时记录每次请求往返（调试时间线）
 }
 
 // NewHTTPBrowser 创建新的HTTP浏览器实例
@@ -27,12 +27,8 @@ func NewHTTPBrowser(config *BrowserConfig) (*HTTPBrowser, error) {
 
 	// 创建HTTP客户端
 	client := &http.Client{
-		Timeout: config.Timeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // 跳过TLS证书验证
-			},
-		},
+		Timeout:   config.Timeout,
+		Transport: newTransport(config.BlockPrivateNetwork),
 	}
 
 	// 设置重定向策略
@@ -56,12 +52,9 @@ func NewHTTPBrowser(config *BrowserConfig) (*HTTPBrowser, error) {
 		if transport, ok := client.Transport.(*http.Transport); ok {
 			transport.Proxy = http.ProxyURL(proxyURL)
 		} else {
-			client.Transport = &http.Transport{
-				Proxy: http.ProxyURL(proxyURL),
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
-			}
+			transport := newTransport(config.BlockPrivateNetwork)
+			transport.Proxy = http.ProxyURL(proxyURL)
+			client.Transport = transport
 		}
 	}
 
@@ -73,6 +66,67 @@ func NewHTTPBrowser(config *BrowserConfig) (*HTTPBrowser, error) {
 
 // Do 发送任意方法请求（headers 将覆盖全局；body 为原始字节）
 func (c *HTTPBrowser) Do(method string, rawURL string, body []byte, headers map[string]string) (*http.Response, error) {
+	return c.do(method, rawURL, body, headers, 0)
+}
+
+// do 发送请求，retry 为第几次重试（用于请求记录）
+func (c *HTTPBrowser) do(method string, rawURL string, body []byte, headers map[string]string, retry int) (*http.Response, error) {
+	req, err := c.newRequest(method, rawURL, body, headers)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(c.client, req, retry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	return resp, nil
+}
+
+// DoWithOptions 按单次请求选项发送请求，不修改全局配置
+func (c *HTTPBrowser) DoWithOptions(method string, rawURL string, body []byte, options RequestOptions) (*http.Response, error) {
+	req, err := c.newRequest(method, rawURL, body, options.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	// 浅拷贝客户端，覆盖本次请求的选项
+	client := *c.client
+	if options.Timeout > 0 {
+		client.Timeout = options.Timeout
+	}
+	if options.FollowRedirects != nil {
+		if *options.FollowRedirects {
+			client.CheckRedirect = func(req *http.Request, via []*http.Request) error { return nil }
+		} else {
+			client.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+		}
+	}
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		var transport *http.Transport
+		if t, ok := c.client.Transport.(*http.Transport); ok {
+			transport = t.Clone()
+		} else {
+			transport = newTransport(c.config.BlockPrivateNetwork)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		// 临时 Transport 不复用连接，避免请求结束后遗留空闲连接
+		transport.DisableKeepAlives = true
+		client.Transport = transport
+	}
+
+	resp, err := c.send(&client, req, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	return resp, nil
+}
+
+// newRequest 创建请求并写入 User-Agent、全局 header 与 Cookie
+func (c *HTTPBrowser) newRequest(method string, rawURL string, body []byte, headers map[string]string) (*http.Request, error) {
 	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -99,12 +153,7 @@ func (c *HTTPBrowser) Do(method string, rawURL string, body []byte, headers map[
 		}
 		req.Header.Set("Cookie", strings.Join(cookieStrings, "; "))
 	}
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	return resp, nil
+	return req, nil
 }
 
 // Get 发送GET请求
@@ -113,7 +162,7 @@ func (c *HTTPBrowser) Get(url string) (*http.Response, error) {
 	var err error
 
 	for i := 0; i <= c.config.MaxRetries; i++ {
-		response, err = c.doGet(url)
+		response, err = c.doGet(url, i)
 		if err == nil {
 			break
 		}
@@ -126,9 +175,9 @@ func (c *HTTPBrowser) Get(url string) (*http.Response, error) {
 	return response, err
 }
 
-// doGet 执行GET请求
-func (c *HTTPBrowser) doGet(url string) (*http.Response, error) {
-	return c.Do("GET", url, nil, nil)
+// doGet 执行GET请求，retry 为第几次重试
+func (c *HTTPBrowser) doGet(url string, retry int) (*http.Response, error) {
+	return c.do("GET", url, nil, nil, retry)
 }
 
 // Post 发送POST请求
@@ -140,6 +189,20 @@ func (c *HTTPBrowser) Post(url string, data map[string]interface{}) (*http.Respo
 	return c.Do("POST", url, jsonData, map[string]string{"Content-Type": "application/json"})
 }
 
+// PostForm 以 application/x-www-form-urlencoded 提交表单
+func (c *HTTPBrowser) PostForm(url string, data url.Values) (*http.Response, error) {
+	return c.Do("POST", url, []byte(data.Encode()), map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+}
+
+// PostMultipart 以 multipart/form-data 提交字段与文件
+func (c *HTTPBrowser) PostMultipart(url string, fields url.Values, files []MultipartFile) (*http.Response, error) {
+	body, contentType, err := EncodeMultipart(fields, files)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do("POST", url, body, map[string]string{"Content-Type": contentType})
+}
+
 // SetHeaders 设置请求头
 func (c *HTTPBrowser) SetHeaders(headers map[string]string) {
 	// 合并新的请求头到现有配置中
@@ -173,12 +236,12 @@ func (c *HTTPBrowser) SetProxy(proxy string) {
 	if proxy != "" {
 		proxyURL, err := url.Parse(proxy)
 		if err == nil {
-			c.client.Transport = &http.Transport{
-				Proxy: http.ProxyURL(proxyURL),
-			}
+			transport := newTransport(c.config.BlockPrivateNetwork)
+			transport.Proxy = http.ProxyURL(proxyURL)
+			c.client.Transport = transport
 		}
 	} else {
-		c.client.Transport = &http.Transport{}
+		c.client.Transport = newTransport(c.config.BlockPrivateNetwork)
 	}
 }
 
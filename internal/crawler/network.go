@@ -0,0 +1,51 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateNetwork 目标地址位于内网，拒绝由服务端代为访问
+var ErrPrivateNetwork = errors.New("禁止访问内网地址")
+
+// cgnatNetwork 运营商级 NAT 地址段（100.64.0.0/10），同样不应从公网访问
+var cgnatNetwork = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPrivateIP 回环、私有、链路本地（含云主机元数据地址）、未指定与组播地址
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnatNetwork.Contains(ip)
+}
+
+// publicOnlyControl 在建立连接前检查实际连接的 IP：域名解析结果与每次重定向都会经过这里，
+// 不受 DNS 重绑定影响
+func publicOnlyControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateNetwork, host)
+	}
+	return nil
+}
+
+// newTransport 创建请求使用的 Transport；blockPrivate 为 true 时拒绝连接内网地址（配置了代理时检查的是代理地址）
+func newTransport(blockPrivate bool) *http.Transport {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true, // 跳过TLS证书验证
+		},
+	}
+	if blockPrivate {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnlyControl}
+		transport.DialContext = dialer.DialContext
+	}
+	return transport
+}
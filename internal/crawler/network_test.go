@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"8.8.8.8":         false,
+		"2001:4860::8888": false,
+	}
+	for addr, want := range cases {
+		if got := IsPrivateIP(net.ParseIP(addr)); got != want {
+			t.Errorf("IsPrivateIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPublicBrowserBlocksPrivateNetwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	browser, err := NewPublicBrowser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+	if _, err := browser.Do(http.MethodGet, server.URL, nil, nil); !errors.Is(err, ErrPrivateNetwork) {
+		t.Fatalf("expected ErrPrivateNetwork, got %v", err)
+	}
+
+	// 默认浏览器不受限制
+	browser, err = NewDefaultBrowser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+	resp, err := browser.Do(http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TracePreviewSize 请求记录中响应体预览的最大字节数（解压后）
+	TracePreviewSize = 2048
+	// tracePreviewRaw 为生成预览保留的原始响应体字节数，压缩内容需要多保留一些
+	tracePreviewRaw = 64 * 1024
+)
+
+// HTTPTrace 一次请求往返的记录；重试的每一次尝试单独记录，重定向经过的地址记录在 Redirects 中
+type HTTPTrace struct {
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	FinalURL        string            `json:"final_url,omitempty"` // 跟随重定向后的地址
+	Redirects       []string          `json:"redirects,omitempty"`
+	Retry           int               `json:"retry"` // 第几次重试，0 为首次请求
+	RequestHeaders  map[string]string `json:"request_headers"`
+	Status          int               `json:"status"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	BodySize        int64             `json:"body_size"`              // 脚本读取的响应体字节数（压缩时为压缩后大小）
+	BodyPreview     string            `json:"body_preview,omitempty"` // 解压后的响应体开头
+	StartedAt       time.Time         `json:"started_at"`
+	WaitMs          float64           `json:"wait_ms"`     // 发出请求到收到响应头
+	DurationMs      float64           `json:"duration_ms"` // 含读取响应体
+	Error           string            `json:"error,omitempty"`
+}
+
+// HTTPTracer 接收请求记录；异步请求会在其它协程中调用
+type HTTPTracer func(HTTPTrace)
+
+// TraceableBrowser 支持记录请求时间线的浏览器
+type TraceableBrowser interface {
+	// SetTracer 设置请求记录回调，nil 关闭记录
+	SetTracer(tracer HTTPTracer)
+}
+
+// SetTracer 设置请求记录回调，nil 关闭记录
+func (c *HTTPBrowser) SetTracer(tracer HTTPTracer) {
+	c.tracer = tracer
+}
+
+// send 发送请求；设置了 tracer 时记录本次往返，响应体读完或关闭时输出记录
+func (c *HTTPBrowser) send(client *http.Client, req *http.Request, retry int) (*http.Response, error) {
+	tracer := c.tracer
+	if tracer == nil {
+		return client.Do(req)
+	}
+
+	trace := &HTTPTrace{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		Retry:          retry,
+		RequestHeaders: flattenHeader(req.Header),
+		StartedAt:      time.Now(),
+	}
+	// 浅拷贝客户端，在重定向检查中记录经过的地址
+	traced := *client
+	checkRedirect := client.CheckRedirect
+	traced.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if checkRedirect != nil {
+			if err := checkRedirect(r, via); err != nil {
+				return err
+			}
+		}
+		trace.Redirects = append(trace.Redirects, r.URL.String())
+		return nil
+	}
+
+	resp, err := traced.Do(req)
+	trace.WaitMs = elapsedMs(trace.StartedAt)
+	if err != nil {
+		trace.Error = err.Error()
+		trace.DurationMs = trace.WaitMs
+		tracer(*trace)
+		return nil, err
+	}
+	trace.Status = resp.StatusCode
+	trace.ResponseHeaders = flattenHeader(resp.Header)
+	if u := resp.Request.URL.String(); u != trace.URL {
+		trace.FinalURL = u
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, trace: trace, encoding: resp.Header.Get("Content-Encoding"), tracer: tracer}
+	return resp, nil
+}
+
+// tracedBody 统计脚本读取的响应体并保留开头用于预览
+type tracedBody struct {
+	io.ReadCloser
+	trace    *HTTPTrace
+	encoding string
+	tracer   HTTPTracer
+	raw      bytes.Buffer
+	once     sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.trace.BodySize += int64(n)
+	if keep := tracePreviewRaw - b.raw.Len(); keep > 0 {
+		b.raw.Write(p[:min(n, keep)])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+// finish 响应体读完或关闭时输出记录，只输出一次
+func (b *tracedBody) finish() {
+	b.once.Do(func() {
+		b.trace.DurationMs = elapsedMs(b.trace.StartedAt)
+		b.trace.BodyPreview = bodyPreview(b.encoding, b.raw.Bytes())
+		b.tracer(*b.trace)
+	})
+}
+
+// bodyPreview 解压（gzip/deflate）响应体开头并截取 TracePreviewSize 字节；只有部分数据时尽量解出已有内容
+func bodyPreview(encoding string, raw []byte) string {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(strings.Split(encoding, ",")[0])) {
+	case "", "identity":
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return ""
+		}
+		r = gr
+	case "deflate":
+		if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			r = zr
+		} else {
+			r = flate.NewReader(bytes.NewReader(raw))
+		}
+	default:
+		// 其他编码（如 br/zstd）与引擎一致不解压，预览没有意义
+		return ""
+	}
+	if r != nil {
+		decoded, _ := io.ReadAll(io.LimitReader(r, TracePreviewSize))
+		raw = decoded
+	}
+	if len(raw) > TracePreviewSize {
+		raw = raw[:TracePreviewSize]
+	}
+	return strings.ToValidUTF8(string(raw), "")
+}
+
+// flattenHeader 将 header 转换为单值映射，同名多值以逗号连接
+func flattenHeader(h http.Header) map[string]string {
+	m := make(map[string]string, len(h))
+	for k, v := range h {
+		m[k] = strings.Join(v, ", ")
+	}
+	return m
+}
+
+func elapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
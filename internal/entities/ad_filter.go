@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// AdFilterRule HLS 广告分片过滤规则（全局默认值来自配置文件，站点可单独覆盖）
+type AdFilterRule struct {
+	Enabled           *bool    `json:"enabled,omitempty" yaml:"enabled"`                       // 是否启用过滤
+	MaxAdDuration     float64  `json:"max_ad_duration,omitempty" yaml:"max_ad_duration"`       // 可判定为广告的块最长时长（秒）
+	HostMismatch      *bool    `json:"host_mismatch,omitempty" yaml:"host_mismatch"`           // 块内分片域名与正片不同视为广告
+	DurationTolerance float64  `json:"duration_tolerance,omitempty" yaml:"duration_tolerance"` // 分片时长偏离正片中位数的比例阈值
+	URLPatterns       []string `json:"url_patterns,omitempty" yaml:"url_patterns"`             // 分片地址命中任一正则即视为广告
+}
+
+// Merge 以 override 中已设置的字段覆盖当前规则，返回新规则
+func (r AdFilterRule) Merge(override *AdFilterRule) AdFilterRule {
+	if override == nil {
+		return r
+	}
+	if override.Enabled != nil {
+		r.Enabled = override.Enabled
+	}
+	if override.MaxAdDuration > 0 {
+		r.MaxAdDuration = override.MaxAdDuration
+	}
+	if override.HostMismatch != nil {
+		r.HostMismatch = override.HostMismatch
+	}
+	if override.DurationTolerance > 0 {
+		r.DurationTolerance = override.DurationTolerance
+	}
+	if len(override.URLPatterns) > 0 {
+		r.URLPatterns = append(append([]string{}, r.URLPatterns...), override.URLPatterns...)
+	}
+	return r
+}
+
+// Validate 校验规则：地址正则必须能够编译，阈值不能为负数
+func (r AdFilterRule) Validate() error {
+	if r.MaxAdDuration < 0 || r.DurationTolerance < 0 {
+		return fmt.Errorf("广告过滤规则的时长与偏离阈值不能为负数")
+	}
+	for _, p := range r.URLPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("广告过滤规则 %q 无效: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// IsEnabled 规则是否启用
+func (r AdFilterRule) IsEnabled() bool {
+	return r.Enabled != nil && *r.Enabled
+}
+
+// AdSegmentReport 被过滤掉的分片
+type AdSegmentReport struct {
+	Index    int     `json:"index"`    // 分片在原播放列表中的序号（从 0 开始）
+	URI      string  `json:"uri"`      // 分片绝对地址
+	Duration float64 `json:"duration"` // 分片时长（秒）
+	Reason   string  `json:"reason"`   // 判定原因
+}
+
+// AdFilterReport 广告过滤调试报告
+type AdFilterReport struct {
+	TotalSegments   int               `json:"total_segments"`
+	KeptSegments    int               `json:"kept_segments"`
+	DroppedDuration float64           `json:"dropped_duration"`
+	Dropped         []AdSegmentReport `json:"dropped"`
+}
@@ -0,0 +1,18 @@
+package entities
+
+import "testing"
+
+func TestAdFilterRuleValidate(t *testing.T) {
+	if err := (AdFilterRule{URLPatterns: []string{`/ad/`, `\.ts\?ad=1$`}}).Validate(); err != nil {
+		t.Fatalf("valid rule rejected: %v", err)
+	}
+	for _, rule := range []AdFilterRule{
+		{URLPatterns: []string{`/ad/`, `(unclosed`}},
+		{MaxAdDuration: -1},
+		{DurationTolerance: -0.5},
+	} {
+		if err := rule.Validate(); err == nil {
+			t.Fatalf("invalid rule %+v accepted", rule)
+		}
+	}
+}
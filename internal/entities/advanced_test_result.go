@@ -1,9 +1,14 @@
 package entities
 
+import "video-crawler/internal/scriptlib"
+
 // AdvancedTestResult 高级调试结果
 type AdvancedTestResult struct {
-	Original  interface{} `json:"original"`  // 原始结果
-	Converted interface{} `json:"converted"` // 转换后的结果
+	Original    interface{}            `json:"original"`               // 原始结果
+	Converted   interface{}            `json:"converted"`              // 转换后的结果（与视频接口相同的 Validate*Result 转换）
+	Report      *ScriptResultReport    `json:"report"`                 // 转换报告：丢弃的元素、类型错误、缺失的必填字段、非绝对地址等
+	ScriptError *scriptlib.ScriptError `json:"script_error,omitempty"` // 入口函数抛出异常时的结构化错误（JS）
+	Trace       []ScriptTraceEvent     `json:"trace,omitempty"`        // 请求开启 trace 时的执行时间线
 }
 
 // AdvancedTestRequest 高级调试请求
@@ -11,4 +16,5 @@ type AdvancedTestRequest struct {
 	Script string                 `json:"script"` // 脚本内容
 	Method string                 `json:"method"` // 方法名称
 	Params map[string]interface{} `json:"params"` // 参数
+	Trace  bool                   `json:"trace"`  // 记录请求与解析的执行时间线
 }
@@ -0,0 +1,30 @@
+package entities
+
+// DownloadTask 离线下载任务
+type DownloadTask struct {
+	ID              string            `json:"id"`
+	UserID          string            `json:"user_id"`
+	SourceID        string            `json:"source_id"`
+	SourceName      string            `json:"source_name"`
+	Title           string            `json:"title"`            // 影片名称
+	Episode         string            `json:"episode"`          // 剧集名称
+	PageURL         string            `json:"page_url"`         // 剧集页面地址（脚本入参）
+	MediaURL        string            `json:"media_url"`        // 脚本解析出的播放地址
+	MediaType       string            `json:"media_type"`       // mp4 / hls
+	Headers         map[string]string `json:"headers"`          // 下载时附加的请求头
+	Status          string            `json:"status"`           // 任务状态
+	Error           string            `json:"error"`            // 失败原因
+	FileName        string            `json:"file_name"`        // 下载完成后的文件名
+	TotalBytes      int64             `json:"total_bytes"`      // 总大小（HLS 为已知分片估算值，未知为 0）
+	DownloadedBytes int64             `json:"downloaded_bytes"` // 已下载字节数
+	TotalSegments   int               `json:"total_segments"`   // HLS 分片总数
+	DoneSegments    int               `json:"done_segments"`    // HLS 已完成分片数
+	CreatedAt       int64             `json:"created_at"`
+	UpdatedAt       int64             `json:"updated_at"`
+}
+
+// DownloadEvent 下载进度事件（通过 SSE 推送）
+type DownloadEvent struct {
+	Type string       `json:"type"` // progress / status / removed
+	Task DownloadTask `json:"task"`
+}
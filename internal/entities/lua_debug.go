@@ -0,0 +1,23 @@
+package entities
+
+// Lua 单步调试命令
+const (
+	LuaDebugContinue    = "continue"    // 继续执行到下一个断点
+	LuaDebugStepOver    = "step_over"   // 单步跳过
+	LuaDebugStepInto    = "step_into"   // 单步进入
+	LuaDebugStepOut     = "step_out"    // 单步跳出
+	LuaDebugPause       = "pause"       // 运行中暂停
+	LuaDebugAbort       = "abort"       // 终止脚本
+	LuaDebugBreakpoints = "breakpoints" // 替换断点，运行中也可以使用
+	LuaDebugVariables   = "variables"   // 查看某层调用栈的局部变量、上值与全局变量
+	LuaDebugEvaluate    = "evaluate"    // 在某层调用栈中对表达式求值
+)
+
+// LuaDebugCommand 调试命令请求
+type LuaDebugCommand struct {
+	SessionID   string `json:"session_id"`
+	Command     string `json:"command"`
+	Frame       int    `json:"frame"`       // 调用栈层级，0 为当前函数（variables / evaluate）
+	Expression  string `json:"expression"`  // evaluate 的表达式
+	Breakpoints []int  `json:"breakpoints"` // breakpoints 的行号列表
+}
@@ -0,0 +1,21 @@
+package entities
+
+// ScriptModule 站点脚本可通过 require 引用的共享模块
+type ScriptModule struct {
+	Name        string                `json:"name"`        // 模块名，即 require 的参数
+	EngineType  int                   `json:"engine_type"` // 0: Lua 1: JavaScript，与站点一致
+	Description string                `json:"description"`
+	Code        string                `json:"code"`
+	Version     int                   `json:"version"` // 每次修改递增
+	UpdatedAt   int64                 `json:"updated_at"`
+	UpdatedBy   string                `json:"updated_by"`
+	History     []ScriptModuleVersion `json:"history,omitempty"` // 历史版本（不含当前版本），按版本号升序
+}
+
+// ScriptModuleVersion 模块的历史版本
+type ScriptModuleVersion struct {
+	Version   int    `json:"version"`
+	Code      string `json:"code"`
+	UpdatedAt int64  `json:"updated_at"`
+	UpdatedBy string `json:"updated_by"`
+}
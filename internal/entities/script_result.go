@@ -7,49 +7,49 @@ import (
 
 // SearchVideoResult 搜索视频结果结构体
 type SearchVideoResult struct {
-	Cover       string `json:"cover"`        // 视频封面
-	Name        string `json:"name"`         // 视频名称
-	Type        string `json:"type"`         // 视频类型
-	URL         string `json:"url"`          // 视频链接
-	Actor       string `json:"actor"`        // 演员
-	Director    string `json:"director"`     // 导演
-	ReleaseDate string `json:"release_date"` // 上映日期
-	Region      string `json:"region"`       // 地区
-	Language    string `json:"language"`     // 语言
-	Description string `json:"description"`  // 描述
-	Score       string `json:"score"`        // 评分
+	Cover       string `json:"cover" script:"url"`        // 视频封面
+	Name        string `json:"name" script:"required"`    // 视频名称
+	Type        string `json:"type"`                      // 视频类型
+	URL         string `json:"url" script:"required,url"` // 视频链接
+	Actor       string `json:"actor"`                     // 演员
+	Director    string `json:"director"`                  // 导演
+	ReleaseDate string `json:"release_date"`              // 上映日期
+	Region      string `json:"region"`                    // 地区
+	Language    string `json:"language"`                  // 语言
+	Description string `json:"description"`               // 描述
+	Score       string `json:"score"`                     // 评分
 }
 
 // EpisodeItem 剧集对象结构体
 type EpisodeItem struct {
-	Name string `json:"name"` // 剧集名称（如：'第1集'、'第2集'、'大结局'等）
-	URL  string `json:"url"`  // 剧集播放链接
+	Name string `json:"name" script:"required"` // 剧集名称（如：'第1集'、'第2集'、'大结局'等）
+	URL  string `json:"url" script:"required"`  // 剧集播放链接（交给 get_play_video_detail 解析，可以是相对地址）
 }
 
 // SourceItem 来源站点对象结构体
 type SourceItem struct {
-	Name     string        `json:"name"`     // 来源站点名称（如：'线路1'、'线路2'、'备用线路'等）
-	Episodes []EpisodeItem `json:"episodes"` // 剧集列表数组
+	Name     string        `json:"name"`                       // 来源站点名称（如：'线路1'、'线路2'、'备用线路'等）
+	Episodes []EpisodeItem `json:"episodes" script:"required"` // 剧集列表数组
 }
 
 // VideoDetailResult 视频详情结果结构体
 type VideoDetailResult struct {
-	Cover       string       `json:"cover"`        // 视频封面
-	Name        string       `json:"name"`         // 视频名称
-	URL         string       `json:"url"`          // 视频链接
-	Score       string       `json:"score"`        // 评分
-	ReleaseDate string       `json:"release_date"` // 上映日期
-	Region      string       `json:"region"`       // 地区
-	Actor       string       `json:"actor"`        // 演员
-	Director    string       `json:"director"`     // 导演
-	Description string       `json:"description"`  // 描述
-	Language    string       `json:"language"`     // 语言
-	Source      []SourceItem `json:"source"`       // 数组：来源站点及剧集列表
+	Cover       string       `json:"cover" script:"url"`       // 视频封面
+	Name        string       `json:"name" script:"required"`   // 视频名称
+	URL         string       `json:"url" script:"url"`         // 视频链接
+	Score       string       `json:"score"`                    // 评分
+	ReleaseDate string       `json:"release_date"`             // 上映日期
+	Region      string       `json:"region"`                   // 地区
+	Actor       string       `json:"actor"`                    // 演员
+	Director    string       `json:"director"`                 // 导演
+	Description string       `json:"description"`              // 描述
+	Language    string       `json:"language"`                 // 语言
+	Source      []SourceItem `json:"source" script:"required"` // 数组：来源站点及剧集列表
 }
 
 // PlayVideoDetailResult 播放详情结果结构体
 type PlayVideoDetailResult struct {
-	VideoURL string `json:"video_url"` // 视频链接
+	VideoURL string `json:"video_url" script:"required,url"` // 视频链接
 }
 
 // ScriptResult 脚本执行结果结构体
@@ -58,6 +58,24 @@ type ScriptResult struct {
 	Err  string      `json:"err"`  // 错误信息，为空表示成功
 }
 
+// SourcedSearchVideoResult 带来源站点的搜索结果（跨站点聚合的输入）
+type SourcedSearchVideoResult struct {
+	SourceID string            `json:"source_id"`
+	Result   SearchVideoResult `json:"result"`
+}
+
+// SearchVideoAlternative 聚合结果中同一影片在某个站点的入口
+type SearchVideoAlternative struct {
+	SourceID string `json:"source_id"` // 站点ID
+	URL      string `json:"url"`       // 该站点的视频链接
+}
+
+// MergedSearchVideoResult 跨站点去重合并后的搜索结果
+type MergedSearchVideoResult struct {
+	SearchVideoResult
+	Alternatives []SearchVideoAlternative `json:"alternatives"` // 所有站点入口，首个与 url 对应
+}
+
 // ValidateSearchVideoResult 验证搜索视频结果
 func ValidateSearchVideoResult(data interface{}) ([]SearchVideoResult, error) {
 	if data == nil {
@@ -0,0 +1,302 @@
+package entities
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 校验问题的级别
+const (
+	IssueLevelError   = "error"   // 数据被丢弃或缺少必填字段，前端无法正常展示
+	IssueLevelWarning = "warning" // 数据被强制转换或格式可疑
+	IssueLevelInfo    = "info"    // 不影响使用，例如未定义的字段被忽略
+)
+
+// 校验问题的类型
+const (
+	IssueEmptyResult     = "empty_result"     // 脚本没有返回数据
+	IssueWrongType       = "wrong_type"       // 类型与约定不符（字符串字段会被强制转换，数组/对象字段会被丢弃）
+	IssueDroppedItem     = "dropped_item"     // 数组中的元素不是对象，被跳过
+	IssueMissingRequired = "missing_required" // 必填字段缺失或为空
+	IssueRelativeURL     = "relative_url"     // 链接不是 http(s) 绝对地址
+	IssueUnknownField    = "unknown_field"    // 约定之外的字段，转换时被忽略
+)
+
+// ScriptResultIssue 脚本结果中的一个问题
+type ScriptResultIssue struct {
+	Level   string      `json:"level"`
+	Kind    string      `json:"kind"`
+	Path    string      `json:"path"` // 字段路径，如 [2].url、source[0].episodes[3].name
+	Message string      `json:"message"`
+	Value   interface{} `json:"value,omitempty"` // 原始值（仅类型错误时给出）
+}
+
+// ScriptResultReport 脚本结果转换报告：与 VideoController 使用同一套 Validate*Result 转换，
+// 并逐字段列出转换过程中丢弃、强制转换或缺失的数据
+type ScriptResultReport struct {
+	Method      string              `json:"method"`
+	Valid       bool                `json:"valid"` // 没有 error 级别的问题
+	InputItems  int                 `json:"input_items"`
+	OutputItems int                 `json:"output_items"`
+	Errors      int                 `json:"errors"`
+	Warnings    int                 `json:"warnings"`
+	Issues      []ScriptResultIssue `json:"issues"`
+}
+
+func (r *ScriptResultReport) add(level, kind, path, message string, value interface{}) {
+	r.Issues = append(r.Issues, ScriptResultIssue{Level: level, Kind: kind, Path: path, Message: message, Value: value})
+	switch level {
+	case IssueLevelError:
+		r.Errors++
+	case IssueLevelWarning:
+		r.Warnings++
+	}
+}
+
+// Problems 返回 error 与 warning 级别的问题，用于在接口响应与健康检查记录中展示
+func (r *ScriptResultReport) Problems() []ScriptResultIssue {
+	problems := []ScriptResultIssue{}
+	for _, issue := range r.Issues {
+		if issue.Level == IssueLevelError || issue.Level == IssueLevelWarning {
+			problems = append(problems, issue)
+		}
+	}
+	return problems
+}
+
+// ScriptResultError 严格模式下脚本结果未通过校验，Report 中列出所有问题
+type ScriptResultError struct {
+	Report *ScriptResultReport
+}
+
+func (e *ScriptResultError) Error() string {
+	for _, issue := range e.Report.Issues {
+		if issue.Level == IssueLevelError {
+			path := issue.Path
+			if path == "" {
+				path = "(根)"
+			}
+			return fmt.Sprintf("%s 结果未通过校验（%d 个错误）: %s %s", e.Report.Method, e.Report.Errors, path, issue.Message)
+		}
+	}
+	return fmt.Sprintf("%s 结果未通过校验", e.Report.Method)
+}
+
+// ValidateScriptResult 校验并转换脚本结果。
+// 宽松模式与视频接口原有行为一致，只生成报告；严格模式下存在 error 级别的问题时返回 *ScriptResultError
+func ValidateScriptResult(method string, data interface{}, strict bool) (interface{}, *ScriptResultReport, error) {
+	converted, report, err := ConvertScriptResult(method, data)
+	if err != nil {
+		return converted, report, err
+	}
+	if strict && !report.Valid {
+		return nil, report, &ScriptResultError{Report: report}
+	}
+	return converted, report, nil
+}
+
+// ConvertScriptResult 按方法名执行与视频接口相同的转换，并生成逐字段的校验报告
+func ConvertScriptResult(method string, data interface{}) (interface{}, *ScriptResultReport, error) {
+	report := &ScriptResultReport{Method: method, Issues: []ScriptResultIssue{}}
+	var converted interface{}
+	var err error
+	switch method {
+	case "search_video":
+		var results []SearchVideoResult
+		results, err = ValidateSearchVideoResult(data)
+		converted, report.OutputItems = results, len(results)
+		checkScriptResultArray(report, "", data, reflect.TypeOf(SearchVideoResult{}))
+	case "get_video_detail":
+		var result *VideoDetailResult
+		result, err = ValidateVideoDetailResult(data)
+		converted = result
+		if result != nil {
+			report.OutputItems = 1
+		}
+		checkScriptResultObject(report, "", data, reflect.TypeOf(VideoDetailResult{}))
+	case "get_play_video_detail":
+		var result *PlayVideoDetailResult
+		result, err = ValidatePlayVideoDetailResult(data)
+		converted = result
+		if result != nil {
+			report.OutputItems = 1
+		}
+		checkScriptResultObject(report, "", data, reflect.TypeOf(PlayVideoDetailResult{}))
+	default:
+		return nil, nil, fmt.Errorf("不支持的方法: %s", method)
+	}
+	report.Valid = report.Errors == 0
+	return converted, report, err
+}
+
+// checkScriptResultArray 检查顶层数组（搜索结果）
+func checkScriptResultArray(r *ScriptResultReport, path string, data interface{}, elem reflect.Type) {
+	if data == nil {
+		r.add(IssueLevelWarning, IssueEmptyResult, path, "脚本没有返回数据", nil)
+		return
+	}
+	items, ok := asScriptArray(data)
+	if !ok {
+		r.add(IssueLevelError, IssueWrongType, path, fmt.Sprintf("应为数组，实际为 %s，结果被整体丢弃", scriptTypeName(data)), data)
+		return
+	}
+	r.InputItems = len(items)
+	if len(items) == 0 {
+		r.add(IssueLevelWarning, IssueEmptyResult, path, "结果数组为空", nil)
+	}
+	for i, item := range items {
+		checkScriptResultItem(r, fmt.Sprintf("%s[%d]", path, i), item, elem)
+	}
+}
+
+// checkScriptResultObject 检查顶层对象（详情、播放地址）
+func checkScriptResultObject(r *ScriptResultReport, path string, data interface{}, t reflect.Type) {
+	if data == nil {
+		r.add(IssueLevelError, IssueEmptyResult, path, "脚本没有返回数据", nil)
+		return
+	}
+	if _, ok := data.(map[string]interface{}); !ok {
+		r.add(IssueLevelError, IssueWrongType, path, fmt.Sprintf("应为对象，实际为 %s，结果被整体丢弃", scriptTypeName(data)), data)
+		return
+	}
+	r.InputItems = 1
+	checkScriptResultFields(r, path, data.(map[string]interface{}), t)
+}
+
+// checkScriptResultItem 检查数组元素，非对象元素会被转换逻辑跳过
+func checkScriptResultItem(r *ScriptResultReport, path string, item interface{}, t reflect.Type) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		r.add(IssueLevelError, IssueDroppedItem, path, fmt.Sprintf("元素应为对象，实际为 %s，已被跳过", scriptTypeName(item)), item)
+		return
+	}
+	checkScriptResultFields(r, path, m, t)
+}
+
+// checkScriptResultFields 按结构体的 json 与 script 标签检查对象字段
+func checkScriptResultFields(r *ScriptResultReport, path string, m map[string]interface{}, t reflect.Type) {
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		known[name] = true
+		opts := scriptTagOptions(field.Tag.Get("script"))
+		fieldPath := joinScriptPath(path, name)
+		value, exists := m[name]
+
+		switch field.Type.Kind() {
+		case reflect.String:
+			checkScriptString(r, fieldPath, value, exists, opts)
+		case reflect.Slice:
+			if !exists || value == nil {
+				if opts["required"] {
+					r.add(IssueLevelError, IssueMissingRequired, fieldPath, "缺少必填字段", nil)
+				}
+				continue
+			}
+			items, ok := asScriptArray(value)
+			if !ok {
+				r.add(IssueLevelError, IssueWrongType, fieldPath, fmt.Sprintf("应为数组，实际为 %s，字段被丢弃", scriptTypeName(value)), value)
+				continue
+			}
+			if len(items) == 0 && opts["required"] {
+				r.add(IssueLevelError, IssueMissingRequired, fieldPath, "必填数组为空", nil)
+			}
+			for j, item := range items {
+				checkScriptResultItem(r, fmt.Sprintf("%s[%d]", fieldPath, j), item, field.Type.Elem())
+			}
+		}
+	}
+
+	var unknown []string
+	for key := range m {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		r.add(IssueLevelInfo, IssueUnknownField, joinScriptPath(path, key), "约定之外的字段，转换时被忽略", nil)
+	}
+}
+
+func checkScriptString(r *ScriptResultReport, path string, value interface{}, exists bool, opts map[string]bool) {
+	if !exists || value == nil {
+		if opts["required"] {
+			r.add(IssueLevelError, IssueMissingRequired, path, "缺少必填字段", nil)
+		}
+		return
+	}
+	str, ok := value.(string)
+	if !ok {
+		str = toString(value)
+		r.add(IssueLevelWarning, IssueWrongType, path, fmt.Sprintf("应为字符串，实际为 %s，已转换为 %q", scriptTypeName(value), str), value)
+	}
+	if strings.TrimSpace(str) == "" {
+		if opts["required"] {
+			r.add(IssueLevelError, IssueMissingRequired, path, "必填字段为空", nil)
+		}
+		return
+	}
+	if opts["url"] && !isAbsoluteHTTPURL(str) {
+		r.add(IssueLevelWarning, IssueRelativeURL, path, fmt.Sprintf("不是 http(s) 绝对地址: %s", str), nil)
+	}
+}
+
+// asScriptArray 识别数组；Lua 的空表会被转换为空对象，按空数组处理
+func asScriptArray(v interface{}) ([]interface{}, bool) {
+	switch val := v.(type) {
+	case []interface{}:
+		return val, true
+	case map[string]interface{}:
+		if len(val) == 0 {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+func scriptTagOptions(tag string) map[string]bool {
+	opts := map[string]bool{}
+	for _, opt := range strings.Split(tag, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			opts[opt] = true
+		}
+	}
+	return opts
+}
+
+func joinScriptPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isAbsoluteHTTPURL(s string) bool {
+	lower := strings.ToLower(strings.TrimSpace(s))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func scriptTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "字符串"
+	case bool:
+		return "布尔值"
+	case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "数字"
+	case []interface{}:
+		return "数组"
+	case map[string]interface{}:
+		return "对象"
+	}
+	return fmt.Sprintf("%T", v)
+}
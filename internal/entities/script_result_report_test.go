@@ -0,0 +1,62 @@
+package entities
+
+import "testing"
+
+func TestConvertScriptResultReport(t *testing.T) {
+	data := []interface{}{
+		map[string]interface{}{"name": "A", "url": "https://a.com/1", "score": 8.5, "extra": 1},
+		"bad",
+		map[string]interface{}{"url": "/v/2", "cover": "//img.com/c.jpg"},
+	}
+	converted, report, err := ConvertScriptResult("search_video", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results := converted.([]SearchVideoResult); len(results) != 2 || results[0].Score != "8.500000" {
+		t.Fatalf("converted = %+v", converted)
+	}
+	if report.Valid || report.InputItems != 3 || report.OutputItems != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	kinds := map[string]string{}
+	for _, issue := range report.Issues {
+		kinds[issue.Path] = issue.Kind
+	}
+	want := map[string]string{
+		"[0].score": IssueWrongType,
+		"[0].extra": IssueUnknownField,
+		"[1]":       IssueDroppedItem,
+		"[2].name":  IssueMissingRequired,
+		"[2].url":   IssueRelativeURL,
+		"[2].cover": IssueRelativeURL,
+	}
+	for path, kind := range want {
+		if kinds[path] != kind {
+			t.Errorf("%s: got %q, want %q (issues: %+v)", path, kinds[path], kind, report.Issues)
+		}
+	}
+
+	_, report, _ = ConvertScriptResult("get_video_detail", map[string]interface{}{
+		"name":   "A",
+		"source": []interface{}{map[string]interface{}{"name": "线路1", "episodes": map[string]interface{}{}}},
+	})
+	if len(report.Issues) != 1 || report.Issues[0].Path != "source[0].episodes" || report.Issues[0].Kind != IssueMissingRequired {
+		t.Fatalf("detail issues = %+v", report.Issues)
+	}
+}
+
+func TestValidateScriptResultStrict(t *testing.T) {
+	data := []interface{}{map[string]interface{}{"name": "A"}}
+	if _, report, err := ValidateScriptResult("search_video", data, false); err != nil || report.Errors != 1 {
+		t.Fatalf("lenient: report = %+v, err = %v", report, err)
+	}
+	converted, report, err := ValidateScriptResult("search_video", data, true)
+	resultErr, ok := err.(*ScriptResultError)
+	if !ok || converted != nil || resultErr.Report != report {
+		t.Fatalf("strict: converted = %v, err = %v", converted, err)
+	}
+	if problems := report.Problems(); len(problems) != 1 || problems[0].Path != "[0].url" {
+		t.Fatalf("problems = %+v", problems)
+	}
+}
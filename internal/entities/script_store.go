@@ -0,0 +1,18 @@
+package entities
+
+// ScriptStoreEntry 站点脚本键值存储中的一项
+type ScriptStoreEntry struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	Size      int         `json:"size"`                 // 值 JSON 序列化后的字节数
+	ExpiresAt int64       `json:"expires_at,omitempty"` // 过期时间（Unix 秒），0 表示不过期
+	UpdatedAt int64       `json:"updated_at"`
+}
+
+// ScriptStoreSummary 某个站点键值存储的概况
+type ScriptStoreSummary struct {
+	SourceID   string `json:"source_id"`
+	SourceName string `json:"source_name"` // 站点已删除时为空
+	Keys       int    `json:"keys"`
+	Size       int    `json:"size"` // 所有值的字节数之和
+}
@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/scriptlib"
+)
+
+// 执行时间线事件类型
+const (
+	ScriptTraceHTTP  = "http"  // 一次请求往返
+	ScriptTraceParse = "parse" // 一次 HTML 解析或选择器调用
+)
+
+// ScriptTraceEvent 高级调试执行时间线中的一条记录，按发生顺序编号
+type ScriptTraceEvent struct {
+	Seq       int                   `json:"seq"`
+	Type      string                `json:"type"`       // http 或 parse
+	ElapsedMs float64               `json:"elapsed_ms"` // 自调试开始经过的毫秒数
+	HTTP      *crawler.HTTPTrace    `json:"http,omitempty"`
+	Parse     *scriptlib.ParseTrace `json:"parse,omitempty"`
+}
@@ -1,5 +1,7 @@
 package entities
 
+import "time"
+
 type (
 	VideoSourceEntity struct {
 		Id         string `json:"id"`
@@ -11,9 +13,42 @@ type (
 		EngineType int    `json:"engine_type"` // 0: Lua 1: JavaScript
 		LuaScript  string `json:"lua_script"`  // Lua脚本内容
 		JsScript   string `json:"js_script"`   // JavaScript脚本内容
+		// PlayHeaders 代理播放地址时附加的请求头（如 Referer、Origin），覆盖默认网络配置
+		PlayHeaders map[string]string `json:"play_headers,omitempty"`
+		// AdFilter 站点级广告分片过滤规则，未设置的字段沿用全局配置
+		AdFilter *AdFilterRule `json:"ad_filter,omitempty"`
+		// Settings 站点自定义配置，脚本通过 ctx.source.settings 读取
+		Settings map[string]interface{} `json:"settings,omitempty"`
+		// LastCheck 最近一次站点检查记录
+		LastCheck *VideoSourceHealthCheck `json:"last_check,omitempty"`
+		// Revision 当前脚本对应的版本号，由服务端在保存时维护
+		Revision int `json:"revision,omitempty"`
+		// Stable 固定的稳定版本，视频接口执行该版本的脚本
+		Stable *VideoSourceStable `json:"stable,omitempty"`
 	}
 )
 
+// Runtime 视频接口实际执行的站点配置：固定了稳定版本时使用稳定版本的引擎与脚本
+func (v VideoSourceEntity) Runtime() VideoSourceEntity {
+	if v.Stable != nil {
+		v.EngineType, v.LuaScript, v.JsScript = v.Stable.EngineType, v.Stable.LuaScript, v.Stable.JsScript
+	}
+	return v
+}
+
+// VideoSourceHealthCheck 站点检查记录：首页可访问性，以及可选的试搜结果校验
+type VideoSourceHealthCheck struct {
+	CheckedAt  time.Time           `json:"checked_at"`
+	Status     int                 `json:"status"`            // 检查后的站点状态
+	HTTPStatus int                 `json:"http_status"`       // 首页响应状态码，请求失败时为 0
+	Keyword    string              `json:"keyword,omitempty"` // 试搜关键词，为空表示只检查首页
+	Results    int                 `json:"results"`           // 试搜转换后的结果数
+	Errors     int                 `json:"errors"`            // 结果校验 error 数
+	Warnings   int                 `json:"warnings"`          // 结果校验 warning 数
+	Issues     []ScriptResultIssue `json:"issues,omitempty"`  // 结果校验中的 error 与 warning
+	Error      string              `json:"error,omitempty"`   // 请求或脚本失败原因
+}
+
 type VideoSourceListResponse struct {
 	Id         string `json:"id"`
 	Name       string `json:"name"`
@@ -24,4 +59,10 @@ type VideoSourceListResponse struct {
 	EngineType int    `json:"engine_type"`
 	LuaScript  string `json:"lua_script"` // Lua脚本内容
 	JsScript   string `json:"js_script"`  // JavaScript脚本
+	// LastCheck 最近一次站点检查记录
+	LastCheck *VideoSourceHealthCheck `json:"last_check,omitempty"`
+	// Revision 当前版本号
+	Revision int `json:"revision,omitempty"`
+	// StableRevision 固定的稳定版本号，0 表示未固定
+	StableRevision int `json:"stable_revision,omitempty"`
 }
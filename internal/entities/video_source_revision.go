@@ -0,0 +1,41 @@
+package entities
+
+// VideoSourceRevision 站点脚本的一个版本，创建后不再修改
+type VideoSourceRevision struct {
+	SourceID     string `json:"source_id"`
+	Revision     int    `json:"revision"` // 站点内从 1 开始递增
+	EngineType   int    `json:"engine_type"`
+	LuaScript    string `json:"lua_script,omitempty"`
+	JsScript     string `json:"js_script,omitempty"`
+	Comment      string `json:"comment,omitempty"`
+	CreatedAt    int64  `json:"created_at"`
+	CreatedBy    string `json:"created_by"`
+	RollbackFrom int    `json:"rollback_from,omitempty"` // 由回滚生成时为回滚到的版本号
+}
+
+// Script 版本中当前引擎使用的脚本
+func (r VideoSourceRevision) Script() string {
+	if r.EngineType == 1 {
+		return r.JsScript
+	}
+	return r.LuaScript
+}
+
+// VideoSourceStable 站点固定的稳定版本：视频接口执行该版本的脚本，编辑与调试使用最新版本
+type VideoSourceStable struct {
+	Revision   int    `json:"revision"`
+	EngineType int    `json:"engine_type"`
+	LuaScript  string `json:"lua_script,omitempty"`
+	JsScript   string `json:"js_script,omitempty"`
+	PinnedAt   int64  `json:"pinned_at"`
+	PinnedBy   string `json:"pinned_by"`
+}
+
+// VideoSourceRevisionDiff 两个版本之间的脚本差异
+type VideoSourceRevisionDiff struct {
+	From    VideoSourceRevision `json:"from"`
+	To      VideoSourceRevision `json:"to"`
+	Diff    string              `json:"diff"` // 当前引擎脚本的 unified diff，引擎不同时比较各自使用的脚本
+	Added   int                 `json:"added"`
+	Removed int                 `json:"removed"`
+}
@@ -10,21 +10,31 @@ import (
 
 // Handler HTTP处理器
 type Handler struct {
-	config             *config.Config
-	userService        services.UserServiceInterface
-	videoSourceService services.VideoSourceService
-	historyService     services.HistoryService
-	luaTestService     services.LuaTestService
+	config              *config.Config
+	userService         services.UserServiceInterface
+	videoSourceService  services.VideoSourceService
+	historyService      services.HistoryService
+	luaTestService      services.LuaTestService
+	downloadService     services.DownloadService
+	playURLCache        services.PlayURLCacheService
+	scriptModuleService services.ScriptModuleService
+	scriptStoreService  services.ScriptStoreService
+	luaDebugService     services.LuaDebugService
 }
 
 // New 创建新的处理器实例
-func New(cfg *config.Config, userService services.UserServiceInterface, videoSourceService services.VideoSourceService, historyService services.HistoryService, luaTestService services.LuaTestService) *Handler {
+func New(cfg *config.Config, userService services.UserServiceInterface, videoSourceService services.VideoSourceService, historyService services.HistoryService, luaTestService services.LuaTestService, downloadService services.DownloadService, playURLCache services.PlayURLCacheService, scriptModuleService services.ScriptModuleService, scriptStoreService services.ScriptStoreService, luaDebugService services.LuaDebugService) *Handler {
 	return &Handler{
-		config:             cfg,
-		userService:        userService,
-		videoSourceService: videoSourceService,
-		historyService:     historyService,
-		luaTestService:     luaTestService,
+		config:              cfg,
+		userService:         userService,
+		videoSourceService:  videoSourceService,
+		historyService:      historyService,
+		luaTestService:      luaTestService,
+		downloadService:     downloadService,
+		playURLCache:        playURLCache,
+		scriptModuleService: scriptModuleService,
+		scriptStoreService:  scriptStoreService,
+		luaDebugService:     luaDebugService,
 	}
 }
 
@@ -32,7 +42,7 @@ func New(cfg *config.Config, userService services.UserServiceInterface, videoSou
 func (h *Handler) HandleApi(c *gin.Context) {
 	userController := controllers.NewUserController(h.userService, h.historyService)
 	videoSourceController := controllers.NewVideoSourceController(h.videoSourceService)
-	videoController := controllers.NewVideoController(h.videoSourceService, h.historyService, h.userService)
+	videoController := controllers.NewVideoController(h.config, h.videoSourceService, h.historyService, h.userService, h.playURLCache)
 	historyController := controllers.NewHistoryController(h.historyService, h.userService)
 	switch c.Request.URL.Path {
 	case "/api":
@@ -46,14 +56,40 @@ func (h *Handler) HandleApi(c *gin.Context) {
 				"GET /api/video-source/list - 站点列表",
 				"GET /api/video-source/detail - 站点详情",
 				"POST /api/video-source/save - 保存站点",
+				"POST /api/video-source/lint - 检查站点脚本",
+				"GET /api/video-source/revisions - 站点脚本版本列表",
+				"GET /api/video-source/revision - 站点脚本版本详情",
+				"GET /api/video-source/revision-diff - 比较站点脚本版本",
+				"POST /api/video-source/rollback - 回滚站点脚本版本",
+				"POST /api/video-source/pin-stable - 固定站点稳定版本",
 				"POST /api/video-source/delete - 删除站点",
 				"POST /api/video-source/set-status - 设置站点状态",
 				"GET /api/video-source/export - 导出站点配置",
 				"POST /api/video-source/import - 导入站点配置",
+				"GET /api/script-module/list - 共享脚本模块列表",
+				"GET /api/script-module/detail - 共享脚本模块详情",
+				"POST /api/script-module/save - 保存共享脚本模块",
+				"POST /api/script-module/rollback - 回滚共享脚本模块",
+				"POST /api/script-module/delete - 删除共享脚本模块",
+				"GET /api/script-store/list - 站点脚本键值存储概况",
+				"GET /api/script-store/detail - 站点脚本键值存储内容",
+				"POST /api/script-store/clear - 清理站点脚本键值存储",
 				"GET /api/video/home/list - 视频首页推荐",
 				"GET /api/video/search - 视频搜索",
+				"GET /api/video/search/aggregate - 跨站点聚合搜索",
 				"GET /api/video/detail - 视频详情",
 				"GET /api/video/url - 视频URL",
+				"GET /api/video/url/batch - 批量解析整条线路的播放地址(SSE)",
+				"GET /api/video/proxy/hls - HLS播放列表代理",
+				"GET /api/video/proxy/segment - HLS分片/密钥代理",
+				"POST /api/download/create - 创建离线下载任务",
+				"GET /api/download/list - 下载任务列表",
+				"POST /api/download/pause - 暂停下载",
+				"POST /api/download/resume - 继续下载",
+				"POST /api/download/cancel - 取消下载",
+				"POST /api/download/delete - 删除下载任务",
+				"GET /api/download/file - 获取已下载文件",
+				"GET /api/download/events - 下载进度(SSE)",
 				"GET /api/history/search - 历史搜索",
 				"GET /api/history/video - 视频观看历史",
 				"GET /api/history/login - 登录历史",
@@ -64,6 +100,8 @@ func (h *Handler) HandleApi(c *gin.Context) {
 				"GET /api/user/list - 用户列表",
 				"POST /api/lua/test - Lua脚本测试(流式)",
 				"POST /api/lua/test-sse - Lua脚本测试(SSE)",
+				"POST /api/lua/debug/start - Lua单步调试(SSE)",
+				"POST /api/lua/debug/command - Lua单步调试命令",
 			},
 		})
 	case "/api/config":
@@ -85,6 +123,24 @@ func (h *Handler) HandleApi(c *gin.Context) {
 	case "/api/video-source/save":
 		// 保存站点
 		videoSourceController.Save(c)
+	case "/api/video-source/lint":
+		// 检查站点脚本
+		videoSourceController.Lint(c)
+	case "/api/video-source/revisions":
+		// 站点脚本版本列表
+		videoSourceController.Revisions(c)
+	case "/api/video-source/revision":
+		// 站点脚本版本详情
+		videoSourceController.Revision(c)
+	case "/api/video-source/revision-diff":
+		// 比较站点脚本版本
+		videoSourceController.RevisionDiff(c)
+	case "/api/video-source/rollback":
+		// 回滚站点脚本版本
+		videoSourceController.Rollback(c)
+	case "/api/video-source/pin-stable":
+		// 固定站点稳定版本
+		videoSourceController.PinStable(c)
 	case "/api/video-source/delete":
 		// 删除站点
 		videoSourceController.Delete(c)
@@ -100,15 +156,75 @@ func (h *Handler) HandleApi(c *gin.Context) {
 	case "/api/video-source/import":
 		// 导入站点配置
 		videoSourceController.Import(c)
+	case "/api/script-module/list":
+		// 共享脚本模块列表
+		controllers.NewScriptModuleController(h.scriptModuleService, h.videoSourceService).List(c)
+	case "/api/script-module/detail":
+		// 共享脚本模块详情
+		controllers.NewScriptModuleController(h.scriptModuleService, h.videoSourceService).Detail(c)
+	case "/api/script-module/save":
+		// 保存共享脚本模块
+		controllers.NewScriptModuleController(h.scriptModuleService, h.videoSourceService).Save(c)
+	case "/api/script-module/rollback":
+		// 回滚共享脚本模块
+		controllers.NewScriptModuleController(h.scriptModuleService, h.videoSourceService).Rollback(c)
+	case "/api/script-module/delete":
+		// 删除共享脚本模块
+		controllers.NewScriptModuleController(h.scriptModuleService, h.videoSourceService).Delete(c)
+	case "/api/script-store/list":
+		// 站点脚本键值存储概况
+		controllers.NewScriptStoreController(h.scriptStoreService, h.videoSourceService).List(c)
+	case "/api/script-store/detail":
+		// 站点脚本键值存储内容
+		controllers.NewScriptStoreController(h.scriptStoreService, h.videoSourceService).Detail(c)
+	case "/api/script-store/clear":
+		// 清理站点脚本键值存储
+		controllers.NewScriptStoreController(h.scriptStoreService, h.videoSourceService).Clear(c)
 	case "/api/video/search":
 		// 视频搜索
 		videoController.Search(c)
+	case "/api/video/search/aggregate":
+		// 跨站点聚合搜索
+		videoController.SearchAggregate(c)
 	case "/api/video/detail":
 		// 视频详情
 		videoController.Detail(c)
 	case "/api/video/url":
 		// 视频URL
 		videoController.PlayURL(c)
+	case "/api/video/url/batch":
+		// 批量解析整条线路的播放地址(SSE)
+		videoController.PlayURLBatch(c)
+	case "/api/video/proxy/hls":
+		// HLS播放列表代理
+		videoController.ProxyHLS(c)
+	case "/api/video/proxy/segment":
+		// HLS分片/密钥代理
+		videoController.ProxySegment(c)
+	case "/api/download/create":
+		// 创建离线下载任务
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).Create(c)
+	case "/api/download/list":
+		// 下载任务列表
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).List(c)
+	case "/api/download/pause":
+		// 暂停下载
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).Pause(c)
+	case "/api/download/resume":
+		// 继续下载
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).Resume(c)
+	case "/api/download/cancel":
+		// 取消下载
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).Cancel(c)
+	case "/api/download/delete":
+		// 删除下载任务
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).Delete(c)
+	case "/api/download/file":
+		// 获取已下载文件
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).File(c)
+	case "/api/download/events":
+		// 下载进度(SSE)
+		controllers.NewDownloadController(h.downloadService, h.videoSourceService, videoController).Events(c)
 	case "/api/history/search":
 		// 历史搜索
 		historyController.GetSearchHistory(c)
@@ -170,6 +286,12 @@ func (h *Handler) HandleApi(c *gin.Context) {
 		// Lua高级调试(SSE)
 		luaTestController := controllers.NewLuaTestController(h.luaTestService)
 		luaTestController.AdvancedTestLuaScriptSSE(c)
+	case "/api/lua/debug/start":
+		// Lua单步调试(SSE)
+		controllers.NewLuaDebugController(h.luaDebugService).Start(c)
+	case "/api/lua/debug/command":
+		// Lua单步调试命令
+		controllers.NewLuaDebugController(h.luaDebugService).Command(c)
 	}
 }
 
@@ -0,0 +1,314 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"video-crawler/internal/entities"
+)
+
+// 广告判定原因
+const (
+	AdReasonURLPattern      = "url_pattern"
+	AdReasonHostMismatch    = "host_mismatch"
+	AdReasonDurationAnomaly = "duration_anomaly"
+)
+
+// mediaSegment 媒体播放列表中的一个分片及其前置标签
+type mediaSegment struct {
+	lines         []string // 分片前的标签行与 URI 行，原样输出
+	uri           string   // 解析后的绝对地址
+	host          string
+	duration      float64
+	discontinuity bool
+}
+
+// FilterAds 过滤媒体播放列表中的广告分片。
+// 以 #EXT-X-DISCONTINUITY 将分片切分为若干块，时长不超过 MaxAdDuration 的非主体块
+// 若全部分片来自其它域名（HostMismatch）或分片时长明显偏离正片（DurationTolerance）则整块剔除；
+// 地址命中 URLPatterns 的分片无论位置均剔除。主播放列表与未命中规则的内容原样返回。
+func FilterAds(body []byte, base *url.URL, rule entities.AdFilterRule) ([]byte, entities.AdFilterReport, error) {
+	report := entities.AdFilterReport{Dropped: []entities.AdSegmentReport{}}
+	patterns := make([]*regexp.Regexp, 0, len(rule.URLPatterns))
+	for _, p := range rule.URLPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, report, fmt.Errorf("广告过滤规则 %q 无效: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	if IsMasterPlaylist(body) {
+		return body, report, nil
+	}
+
+	header, segments, footer := parseMediaPlaylist(body, base)
+	report.TotalSegments = len(segments)
+	if len(segments) == 0 {
+		return body, report, nil
+	}
+
+	reasons := make([]string, len(segments))
+	for i, seg := range segments {
+		for _, re := range patterns {
+			if re.MatchString(seg.uri) {
+				reasons[i] = AdReasonURLPattern
+				break
+			}
+		}
+	}
+	markAdBlocks(segments, reasons, rule)
+
+	kept := 0
+	for _, r := range reasons {
+		if r == "" {
+			kept++
+		}
+	}
+	// 全部被判定为广告时说明规则不适用于该片源，保持原样
+	if kept == 0 {
+		report.KeptSegments = len(segments)
+		return body, report, nil
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(body))
+	for _, l := range header {
+		out.WriteString(l)
+		out.WriteByte('\n')
+	}
+	// 被剔除分片上的 EXT-X-KEY / EXT-X-MAP 状态会延续到后续分片，需要补到下一个保留的分片前
+	var pendingKey, pendingMap string
+	for i, seg := range segments {
+		if reasons[i] != "" {
+			report.Dropped = append(report.Dropped, entities.AdSegmentReport{
+				Index:    i,
+				URI:      seg.uri,
+				Duration: seg.duration,
+				Reason:   reasons[i],
+			})
+			report.DroppedDuration += seg.duration
+			for _, l := range seg.lines {
+				t := strings.TrimSpace(l)
+				switch {
+				case strings.HasPrefix(t, "#EXT-X-KEY"):
+					pendingKey = l
+				case strings.HasPrefix(t, "#EXT-X-MAP"):
+					pendingMap = l
+				}
+			}
+			continue
+		}
+		hasKey, hasMap := false, false
+		for _, l := range seg.lines {
+			t := strings.TrimSpace(l)
+			hasKey = hasKey || strings.HasPrefix(t, "#EXT-X-KEY")
+			hasMap = hasMap || strings.HasPrefix(t, "#EXT-X-MAP")
+		}
+		if pendingKey != "" && !hasKey {
+			out.WriteString(pendingKey)
+			out.WriteByte('\n')
+		}
+		if pendingMap != "" && !hasMap {
+			out.WriteString(pendingMap)
+			out.WriteByte('\n')
+		}
+		pendingKey, pendingMap = "", ""
+		for _, l := range seg.lines {
+			out.WriteString(l)
+			out.WriteByte('\n')
+		}
+	}
+	for _, l := range footer {
+		out.WriteString(l)
+		out.WriteByte('\n')
+	}
+	report.KeptSegments = kept
+	report.DroppedDuration = math.Round(report.DroppedDuration*1000) / 1000
+	return out.Bytes(), report, nil
+}
+
+// markAdBlocks 按不连续块执行域名与时长启发式判定
+func markAdBlocks(segments []mediaSegment, reasons []string, rule entities.AdFilterRule) {
+	blocks := splitBlocks(segments)
+	if len(blocks) < 2 {
+		return
+	}
+
+	// 正片域名：累计时长最长的域名；最长的块视为正片，永不剔除
+	hostDuration := map[string]float64{}
+	for _, seg := range segments {
+		hostDuration[seg.host] += seg.duration
+	}
+	mainHost, best := "", -1.0
+	for h, d := range hostDuration {
+		if d > best || (d == best && h < mainHost) {
+			mainHost, best = h, d
+		}
+	}
+	mainBlock, mainDuration := 0, -1.0
+	for i, b := range blocks {
+		if d := blockDuration(segments[b[0]:b[1]]); d > mainDuration {
+			mainBlock, mainDuration = i, d
+		}
+	}
+	median := medianDuration(segments[blocks[mainBlock][0]:blocks[mainBlock][1]])
+	checkHost := rule.HostMismatch == nil || *rule.HostMismatch
+
+	for i, b := range blocks {
+		if i == mainBlock {
+			continue
+		}
+		segs := segments[b[0]:b[1]]
+		if rule.MaxAdDuration > 0 && blockDuration(segs) > rule.MaxAdDuration {
+			continue
+		}
+		reason := ""
+		switch {
+		case checkHost && mainHost != "" && allHostsDiffer(segs, mainHost):
+			reason = AdReasonHostMismatch
+		case rule.DurationTolerance > 0 && durationsAnomalous(segs, median, rule.DurationTolerance):
+			reason = AdReasonDurationAnomaly
+		}
+		if reason == "" {
+			continue
+		}
+		for j := b[0]; j < b[1]; j++ {
+			if reasons[j] == "" {
+				reasons[j] = reason
+			}
+		}
+	}
+}
+
+// splitBlocks 以不连续标记切分分片，返回每块的 [start, end) 下标
+func splitBlocks(segments []mediaSegment) [][2]int {
+	var blocks [][2]int
+	start := 0
+	for i := 1; i < len(segments); i++ {
+		if segments[i].discontinuity {
+			blocks = append(blocks, [2]int{start, i})
+			start = i
+		}
+	}
+	return append(blocks, [2]int{start, len(segments)})
+}
+
+func blockDuration(segs []mediaSegment) float64 {
+	total := 0.0
+	for _, s := range segs {
+		total += s.duration
+	}
+	return total
+}
+
+// medianDuration 正片分片时长中位数（末尾分片通常较短，不参与统计）
+func medianDuration(segs []mediaSegment) float64 {
+	if len(segs) > 1 {
+		segs = segs[:len(segs)-1]
+	}
+	ds := make([]float64, 0, len(segs))
+	for _, s := range segs {
+		if s.duration > 0 {
+			ds = append(ds, s.duration)
+		}
+	}
+	if len(ds) == 0 {
+		return 0
+	}
+	sort.Float64s(ds)
+	return ds[len(ds)/2]
+}
+
+func allHostsDiffer(segs []mediaSegment, mainHost string) bool {
+	for _, s := range segs {
+		if s.host == mainHost || s.host == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// durationsAnomalous 块内（除末尾外）过半分片的时长偏离正片中位数超过容差
+func durationsAnomalous(segs []mediaSegment, median, tolerance float64) bool {
+	if median <= 0 {
+		return false
+	}
+	if len(segs) > 1 {
+		segs = segs[:len(segs)-1]
+	}
+	anomalous := 0
+	for _, s := range segs {
+		if math.Abs(s.duration-median)/median > tolerance {
+			anomalous++
+		}
+	}
+	return anomalous*2 > len(segs)
+}
+
+// parseMediaPlaylist 将媒体播放列表拆分为头部标签、分片和尾部标签（如 #EXT-X-ENDLIST）
+func parseMediaPlaylist(body []byte, base *url.URL) (header []string, segments []mediaSegment, footer []string) {
+	var pending []string
+	inSegments := false
+	discontinuity := false
+	duration := 0.0
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			if !inSegments && !isSegmentTag(trimmed) {
+				header = append(header, line)
+				continue
+			}
+			inSegments = true
+			pending = append(pending, line)
+			if strings.HasPrefix(trimmed, "#EXT-X-DISCONTINUITY") && !strings.HasPrefix(trimmed, "#EXT-X-DISCONTINUITY-SEQUENCE") {
+				discontinuity = true
+			}
+			if strings.HasPrefix(trimmed, "#EXTINF:") {
+				v := strings.TrimPrefix(trimmed, "#EXTINF:")
+				if i := strings.IndexByte(v, ','); i >= 0 {
+					v = v[:i]
+				}
+				duration, _ = strconv.ParseFloat(strings.TrimSpace(v), 64)
+			}
+		default:
+			inSegments = true
+			abs := ResolveURI(base, trimmed)
+			host := ""
+			if u, err := url.Parse(abs); err == nil {
+				host = strings.ToLower(u.Hostname())
+			}
+			segments = append(segments, mediaSegment{
+				lines:         append(pending, line),
+				uri:           abs,
+				host:          host,
+				duration:      duration,
+				discontinuity: discontinuity,
+			})
+			pending, discontinuity, duration = nil, false, 0
+		}
+	}
+	return header, segments, pending
+}
+
+// isSegmentTag 判断标签是否作用于分片（出现后即进入分片区域）
+func isSegmentTag(tag string) bool {
+	for _, p := range []string{"#EXTINF", "#EXT-X-DISCONTINUITY", "#EXT-X-KEY", "#EXT-X-MAP", "#EXT-X-BYTERANGE", "#EXT-X-PROGRAM-DATE-TIME", "#EXT-X-GAP", "#EXT-X-CUE", "#EXT-X-DATERANGE"} {
+		if strings.HasPrefix(tag, p) && !strings.HasPrefix(tag, "#EXT-X-DISCONTINUITY-SEQUENCE") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"video-crawler/internal/entities"
+)
+
+func TestFilterAds(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/v/index.m3u8")
+	enabled := true
+	rule := entities.AdFilterRule{Enabled: &enabled, MaxAdDuration: 60, HostMismatch: &enabled, DurationTolerance: 0.5}
+
+	playlist := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:10",
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"",
+		"#EXTINF:10.0,", "a0.ts",
+		"#EXTINF:10.0,", "a1.ts",
+		"#EXTINF:10.0,", "a2.ts",
+		"#EXT-X-DISCONTINUITY",
+		"#EXT-X-KEY:METHOD=NONE",
+		"#EXTINF:5.0,", "https://ads.example.net/x0.ts",
+		"#EXTINF:5.0,", "https://ads.example.net/x1.ts",
+		"#EXT-X-DISCONTINUITY",
+		"#EXTINF:3.0,", "b0.ts",
+		"#EXTINF:3.0,", "b1.ts",
+		"#EXTINF:2.0,", "b2.ts",
+		"#EXT-X-DISCONTINUITY",
+		"#EXTINF:10.0,", "c0.ts",
+		"#EXTINF:10.0,", "c1.ts",
+		"#EXT-X-ENDLIST",
+	}, "\n")
+
+	out, report, err := FilterAds([]byte(playlist), base, rule)
+	if err != nil {
+		t.Fatalf("FilterAds error: %v", err)
+	}
+	got := string(out)
+	if strings.Contains(got, "ads.example.net") || strings.Contains(got, "b0.ts") {
+		t.Errorf("ad segments not removed:\n%s", got)
+	}
+	// 被剔除块中的 EXT-X-KEY 需要延续到后续分片
+	if !strings.Contains(got, "#EXT-X-KEY:METHOD=NONE\n#EXT-X-DISCONTINUITY\n#EXTINF:10.0,\nc0.ts") {
+		t.Errorf("key state not carried over:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "#EXT-X-ENDLIST\n") {
+		t.Errorf("footer lost:\n%s", got)
+	}
+	if report.TotalSegments != 10 || report.KeptSegments != 5 || len(report.Dropped) != 5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Dropped[0].Reason != AdReasonHostMismatch || report.Dropped[2].Reason != AdReasonDurationAnomaly {
+		t.Errorf("unexpected reasons: %+v", report.Dropped)
+	}
+	if report.DroppedDuration != 18 {
+		t.Errorf("dropped duration = %v, want 18", report.DroppedDuration)
+	}
+
+	// URL 规则按分片剔除，不依赖不连续标记
+	rule.URLPatterns = []string{`/a1\.ts$`}
+	out, _, _ = FilterAds([]byte("#EXTM3U\n#EXTINF:10,\na0.ts\n#EXTINF:10,\na1.ts\n#EXT-X-ENDLIST\n"), base, rule)
+	if strings.Contains(string(out), "a1.ts") || !strings.Contains(string(out), "a0.ts") {
+		t.Errorf("url pattern not applied:\n%s", out)
+	}
+}
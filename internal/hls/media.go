@@ -0,0 +1,272 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Key EXT-X-KEY 描述的分片加密方式
+type Key struct {
+	Method string // NONE / AES-128 / SAMPLE-AES
+	URI    string // 密钥绝对地址
+	IV     []byte // 显式 IV，为空时使用分片序号
+}
+
+// ByteRange 分片在资源中的字节范围
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// Header 返回对应的 HTTP Range 请求头值
+func (r ByteRange) Header() string {
+	return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+}
+
+// Segment 媒体分片
+type Segment struct {
+	URI      string     // 分片绝对地址
+	Duration float64    // 时长（秒）
+	Sequence int64      // 媒体序号
+	Range    *ByteRange // EXT-X-BYTERANGE
+	Key      *Key       // 加密信息，nil 表示未加密
+	MapURI   string     // EXT-X-MAP 初始化分片地址（fMP4）
+	MapRange *ByteRange
+}
+
+// IV 返回解密分片使用的初始向量：优先显式 IV，否则按规范使用 128 位大端媒体序号
+func (s Segment) IV() []byte {
+	if s.Key != nil && len(s.Key.IV) == aes.BlockSize {
+		return s.Key.IV
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(s.Sequence))
+	return iv
+}
+
+// MediaPlaylist 解析后的媒体播放列表
+type MediaPlaylist struct {
+	Segments []Segment
+	Ended    bool // 是否包含 #EXT-X-ENDLIST（点播）
+}
+
+// Variant 主播放列表中的码率变体
+type Variant struct {
+	URI        string
+	Bandwidth  int64
+	Resolution string
+}
+
+// ParseMasterPlaylist 解析主播放列表中的码率变体
+func ParseMasterPlaylist(body []byte, base *url.URL) []Variant {
+	var variants []Variant
+	var pending *Variant
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := ParseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bw, _ := strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			pending = &Variant{Bandwidth: bw, Resolution: attrs["RESOLUTION"]}
+		case strings.HasPrefix(line, "#"):
+		default:
+			if pending != nil {
+				pending.URI = ResolveURI(base, line)
+				variants = append(variants, *pending)
+				pending = nil
+			}
+		}
+	}
+	return variants
+}
+
+// BestVariant 选择码率最高的变体，没有变体时返回 false
+func BestVariant(variants []Variant) (Variant, bool) {
+	if len(variants) == 0 {
+		return Variant{}, false
+	}
+	best := variants[0]
+	for _, v := range variants[1:] {
+		if v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	return best, true
+}
+
+// ParseMediaPlaylist 解析媒体播放列表，展开每个分片对应的加密、初始化分片与字节范围
+func ParseMediaPlaylist(body []byte, base *url.URL) (*MediaPlaylist, error) {
+	if IsMasterPlaylist(body) {
+		return nil, errors.New("不是媒体播放列表")
+	}
+	pl := &MediaPlaylist{}
+	var (
+		seq          int64
+		duration     float64
+		key          *Key
+		mapURI       string
+		mapRange     *ByteRange
+		segRange     *ByteRange
+		lastRangeEnd = map[string]int64{}
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			seq, _ = strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.IndexByte(v, ','); i >= 0 {
+				v = v[:i]
+			}
+			duration, _ = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := ParseAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			if attrs["METHOD"] == "" || attrs["METHOD"] == "NONE" {
+				key = nil
+				continue
+			}
+			k := &Key{Method: attrs["METHOD"], URI: ResolveURI(base, attrs["URI"])}
+			if iv := attrs["IV"]; iv != "" {
+				raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+				if err != nil || len(raw) != aes.BlockSize {
+					return nil, fmt.Errorf("无效的 IV: %s", iv)
+				}
+				k.IV = raw
+			}
+			key = k
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			attrs := ParseAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			mapURI = ResolveURI(base, attrs["URI"])
+			mapRange = nil
+			if br := attrs["BYTERANGE"]; br != "" {
+				r, err := parseByteRange(br, 0)
+				if err != nil {
+					return nil, err
+				}
+				mapRange = &r
+			}
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			r, err := parseByteRange(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"), -1)
+			if err != nil {
+				return nil, err
+			}
+			segRange = &r
+		case strings.HasPrefix(line, "#EXT-X-ENDLIST"):
+			pl.Ended = true
+		case strings.HasPrefix(line, "#"):
+		default:
+			uri := ResolveURI(base, line)
+			if segRange != nil && segRange.Offset < 0 {
+				// 未指定偏移时紧接同一资源上一个范围之后
+				segRange.Offset = lastRangeEnd[uri]
+			}
+			if segRange != nil {
+				lastRangeEnd[uri] = segRange.Offset + segRange.Length
+			}
+			pl.Segments = append(pl.Segments, Segment{
+				URI:      uri,
+				Duration: duration,
+				Sequence: seq,
+				Range:    segRange,
+				Key:      key,
+				MapURI:   mapURI,
+				MapRange: mapRange,
+			})
+			seq++
+			duration, segRange = 0, nil
+		}
+	}
+	return pl, nil
+}
+
+// ParseAttributes 解析标签属性列表（KEY=VALUE,KEY="VALUE,WITH,COMMA"）
+func ParseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+			if i := strings.IndexByte(s, ','); i >= 0 {
+				s = s[i+1:]
+			} else {
+				s = ""
+			}
+		} else if i := strings.IndexByte(s, ','); i >= 0 {
+			value, s = s[:i], s[i+1:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[strings.ToUpper(name)] = strings.TrimSpace(value)
+	}
+	return attrs
+}
+
+// parseByteRange 解析 n[@o]，未指定偏移时使用 defaultOffset
+func parseByteRange(s string, defaultOffset int64) (ByteRange, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "@", 2)
+	length, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ByteRange{}, fmt.Errorf("无效的字节范围: %s", s)
+	}
+	r := ByteRange{Length: length, Offset: defaultOffset}
+	if len(parts) == 2 {
+		if r.Offset, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return ByteRange{}, fmt.Errorf("无效的字节范围: %s", s)
+		}
+	}
+	return r, nil
+}
+
+// DecryptAES128 使用 AES-128-CBC 解密分片并去除 PKCS#7 填充
+func DecryptAES128(data, key, iv []byte) ([]byte, error) {
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("密钥长度错误: %d", len(key))
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("密文长度错误: %d", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, errors.New("填充错误，密钥可能不正确")
+	}
+	for _, b := range out[len(out)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("填充错误，密钥可能不正确")
+		}
+	}
+	return out[:len(out)-pad], nil
+}
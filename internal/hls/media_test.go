@@ -0,0 +1,58 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"net/url"
+	"testing"
+)
+
+func TestParseMediaPlaylist(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/v/index.m3u8")
+	body := "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:7\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key,1.bin\",IV=0x000102030405060708090a0b0c0d0e0f\n" +
+		"#EXTINF:4.5,\nseg0.ts\n" +
+		"#EXT-X-KEY:METHOD=NONE\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"100@0\"\n" +
+		"#EXT-X-BYTERANGE:500@100\n#EXTINF:4,\nmain.mp4\n#EXT-X-BYTERANGE:300\n#EXTINF:4,\nmain.mp4\n#EXT-X-ENDLIST\n"
+
+	pl, err := ParseMediaPlaylist([]byte(body), base)
+	if err != nil {
+		t.Fatalf("ParseMediaPlaylist error: %v", err)
+	}
+	if !pl.Ended || len(pl.Segments) != 3 {
+		t.Fatalf("unexpected playlist: %+v", pl)
+	}
+	s0, s1, s2 := pl.Segments[0], pl.Segments[1], pl.Segments[2]
+	if s0.Key == nil || s0.Key.URI != "https://cdn.example.com/v/key,1.bin" || s0.IV()[15] != 0x0f || s0.Duration != 4.5 {
+		t.Errorf("unexpected first segment: %+v", s0)
+	}
+	if s1.Key != nil || s1.Sequence != 8 || s1.MapURI != "https://cdn.example.com/v/init.mp4" || s1.MapRange.Header() != "bytes=0-99" {
+		t.Errorf("unexpected second segment: %+v", s1)
+	}
+	if s2.Range == nil || s2.Range.Header() != "bytes=600-899" {
+		t.Errorf("byte range not continued: %+v", s2.Range)
+	}
+	if s2.IV()[15] != 9 {
+		t.Errorf("sequence IV = %x", s2.IV())
+	}
+}
+
+func TestDecryptAES128(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, aes.BlockSize)
+	plain := []byte("hello segment data")
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	enc := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(enc, padded)
+
+	got, err := DecryptAES128(enc, key, iv)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("DecryptAES128 = %q, %v", got, err)
+	}
+	if _, err := DecryptAES128(enc, []byte("fedcba9876543210"), iv); err == nil {
+		t.Error("expected padding error with wrong key")
+	}
+}
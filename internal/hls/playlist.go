@@ -0,0 +1,118 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// URIKind 播放列表中 URI 的用途
+type URIKind int
+
+const (
+	URIKindPlaylist URIKind = iota // 子播放列表（码率变体、音轨、字幕、I 帧列表）
+	URIKindSegment                 // 媒体分片或 EXT-X-MAP 初始化分片
+	URIKindKey                     // EXT-X-KEY / EXT-X-SESSION-KEY 密钥
+)
+
+// RewriteFunc 将（已解析为绝对地址的）URI 改写为新的地址
+type RewriteFunc func(absURI string, kind URIKind) string
+
+var uriAttrRegexp = regexp.MustCompile(`URI="([^"]*)"`)
+
+// IsPlaylist 根据 Content-Type、地址或内容判断是否为 m3u8 播放列表
+func IsPlaylist(contentType string, rawURL string, head []byte) bool {
+	ct := strings.ToLower(contentType)
+	if strings.Contains(ct, "mpegurl") {
+		return true
+	}
+	if u, err := url.Parse(rawURL); err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".m3u8") {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(head, "\ufeff \t\r\n"), []byte("#EXTM3U"))
+}
+
+// IsMasterPlaylist 判断是否为多码率主播放列表
+func IsMasterPlaylist(body []byte) bool {
+	return bytes.Contains(body, []byte("#EXT-X-STREAM-INF")) || bytes.Contains(body, []byte("#EXT-X-I-FRAME-STREAM-INF"))
+}
+
+// ResolveURI 以播放列表地址为基准解析相对 URI
+func ResolveURI(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// RewritePlaylist 改写播放列表中所有 URI（码率变体、分片、密钥、初始化分片等），
+// 相对地址会先以 base 解析为绝对地址再交给 rewrite。其余行保持原样。
+func RewritePlaylist(body []byte, base *url.URL, rewrite RewriteFunc) []byte {
+	master := IsMasterPlaylist(body)
+	var out bytes.Buffer
+	out.Grow(len(body) + len(body)/2)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			out.WriteString(line)
+		case strings.HasPrefix(trimmed, "#"):
+			out.WriteString(rewriteTagURI(line, base, tagURIKind(trimmed), rewrite))
+		default:
+			kind := URIKindSegment
+			if master || isPlaylistRef(trimmed) {
+				kind = URIKindPlaylist
+			}
+			out.WriteString(rewrite(ResolveURI(base, trimmed), kind))
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// tagURIKind 返回标签中 URI 属性的用途，-1 表示该标签不含需要改写的 URI
+func tagURIKind(tag string) URIKind {
+	switch {
+	case strings.HasPrefix(tag, "#EXT-X-KEY"), strings.HasPrefix(tag, "#EXT-X-SESSION-KEY"):
+		return URIKindKey
+	case strings.HasPrefix(tag, "#EXT-X-MAP"):
+		return URIKindSegment
+	case strings.HasPrefix(tag, "#EXT-X-MEDIA"), strings.HasPrefix(tag, "#EXT-X-I-FRAME-STREAM-INF"):
+		return URIKindPlaylist
+	default:
+		return -1
+	}
+}
+
+func rewriteTagURI(line string, base *url.URL, kind URIKind, rewrite RewriteFunc) string {
+	if kind < 0 {
+		return line
+	}
+	return uriAttrRegexp.ReplaceAllStringFunc(line, func(m string) string {
+		sub := uriAttrRegexp.FindStringSubmatch(m)
+		// data: 内联密钥等无需代理
+		if strings.HasPrefix(sub[1], "data:") || sub[1] == "" {
+			return m
+		}
+		return `URI="` + rewrite(ResolveURI(base, sub[1]), kind) + `"`
+	})
+}
+
+func isPlaylistRef(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".m3u8")
+}
@@ -0,0 +1,39 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRewritePlaylist(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/v/index.m3u8?token=1")
+	rewrite := func(abs string, kind URIKind) string {
+		return []string{"P", "S", "K"}[kind] + "(" + abs + ")"
+	}
+
+	master := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720/index.m3u8\n#EXT-X-MEDIA:TYPE=AUDIO,URI=\"audio/a.m3u8\"\n"
+	got := string(RewritePlaylist([]byte(master), base, rewrite))
+	for _, want := range []string{
+		"P(https://cdn.example.com/v/720/index.m3u8)",
+		`URI="P(https://cdn.example.com/v/audio/a.m3u8)"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("master playlist missing %q:\n%s", want, got)
+		}
+	}
+
+	media := "#EXTM3U\r\n#EXT-X-KEY:METHOD=AES-128,URI=\"/key.bin\",IV=0x01\r\n#EXT-X-MAP:URI=\"init.mp4\"\r\n#EXTINF:4.0,\r\nseg0.ts\r\n#EXTINF:4.0,\r\nhttps://other.example.com/seg1.ts\r\n#EXT-X-ENDLIST\r\n"
+	got = string(RewritePlaylist([]byte(media), base, rewrite))
+	for _, want := range []string{
+		`URI="K(https://cdn.example.com/key.bin)",IV=0x01`,
+		`URI="S(https://cdn.example.com/v/init.mp4)"`,
+		"S(https://cdn.example.com/v/seg0.ts)\n",
+		"S(https://other.example.com/seg1.ts)\n",
+		"#EXT-X-ENDLIST\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("media playlist missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package jsengine
+
+import (
+	"time"
+
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// contextObject 构造入口函数的 ctx 参数：
+// ctx.method / page / locale / params / filters / source{id,name,domain,settings}，
+// ctx.store.get(key) / set(key, value[, ttlSeconds]) / delete(key)（失败时抛出异常），以及 ctx.log(...)。
+// store 同时注册为全局变量，脚本主体中也可以使用
+func (e *Engine) contextObject(cc *scriptlib.CallContext) goja.Value {
+	obj := e.vm.ToValue(cc.Values()).ToObject(e.vm)
+	checkStore := func() {
+		if cc.Store == nil {
+			e.throwIfError(scriptlib.ErrStoreUnavailable)
+		}
+	}
+
+	store := e.vm.NewObject()
+	// store.get(key) -> 值，不存在或已过期时为 undefined
+	_ = store.Set("get", func(key string) goja.Value {
+		checkStore()
+		value, ok := cc.Store.Get(key)
+		if !ok {
+			return goja.Undefined()
+		}
+		return e.vm.ToValue(value)
+	})
+	_ = store.Set("set", func(key string, value goja.Value, ttlSeconds float64) {
+		checkStore()
+		ttl := time.Duration(ttlSeconds * float64(time.Second))
+		e.throwIfError(cc.Store.Set(key, exportValue(value), ttl))
+	})
+	_ = store.Set("delete", func(key string) {
+		checkStore()
+		e.throwIfError(cc.Store.Delete(key))
+	})
+	_ = obj.Set("store", store)
+	e.vm.Set("store", store)
+
+	// ctx.log 与 console.log 相同
+	if console := e.vm.Get("console"); console != nil {
+		_ = obj.Set("log", console.ToObject(e.vm).Get("log"))
+	}
+	return obj
+}
@@ -0,0 +1,83 @@
+package jsengine
+
+import (
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// jsOptions 读取可选的选项对象参数
+func (e *Engine) jsOptions(call goja.FunctionCall, n int) scriptlib.Options {
+	if v := call.Argument(n); !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if m, ok := v.Export().(map[string]interface{}); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+// throwIfError 出错时抛出 JS 异常（可被脚本 try/catch 捕获）
+func (e *Engine) throwIfError(err error) {
+	if err == nil {
+		return
+	}
+	if obj, cerr := e.vm.New(e.vm.Get("Error"), e.vm.ToValue(err.Error())); cerr == nil {
+		panic(obj)
+	}
+	panic(e.vm.NewGoError(err))
+}
+
+// bindCrypto 绑定加解密库（与 Lua 引擎的 crypto 表语义一致，实现见 scriptlib）
+func (e *Engine) bindCrypto() {
+	cryptoLib := e.vm.NewObject()
+	for _, algo := range []string{"md5", "sha1", "sha256", "sha512"} {
+		algo := algo
+		// crypto.md5(data[, output]) -> string（output 默认 hex）
+		_ = cryptoLib.Set(algo, func(call goja.FunctionCall) goja.Value {
+			output := "hex"
+			if v := call.Argument(1); !goja.IsUndefined(v) {
+				output = v.String()
+			}
+			s, err := scriptlib.Digest(algo, call.Argument(0).String(), output)
+			e.throwIfError(err)
+			return e.vm.ToValue(s)
+		})
+	}
+	// crypto.hmac(algo, key, data[, opts]) -> string
+	_ = cryptoLib.Set("hmac", func(call goja.FunctionCall) goja.Value {
+		s, err := scriptlib.HMAC(call.Argument(0).String(), call.Argument(1).String(), call.Argument(2).String(), e.jsOptions(call, 3))
+		e.throwIfError(err)
+		return e.vm.ToValue(s)
+	})
+	// crypto.aesEncrypt(data, key[, opts]) -> string
+	_ = cryptoLib.Set("aesEncrypt", func(call goja.FunctionCall) goja.Value {
+		s, err := scriptlib.AESEncrypt(call.Argument(0).String(), call.Argument(1).String(), e.jsOptions(call, 2))
+		e.throwIfError(err)
+		return e.vm.ToValue(s)
+	})
+	// crypto.aesDecrypt(data, key[, opts]) -> string
+	_ = cryptoLib.Set("aesDecrypt", func(call goja.FunctionCall) goja.Value {
+		s, err := scriptlib.AESDecrypt(call.Argument(0).String(), call.Argument(1).String(), e.jsOptions(call, 2))
+		e.throwIfError(err)
+		return e.vm.ToValue(s)
+	})
+	// crypto.rsaEncrypt(data, publicKey[, opts]) -> string
+	_ = cryptoLib.Set("rsaEncrypt", func(call goja.FunctionCall) goja.Value {
+		s, err := scriptlib.RSAEncrypt(call.Argument(0).String(), call.Argument(1).String(), e.jsOptions(call, 2))
+		e.throwIfError(err)
+		return e.vm.ToValue(s)
+	})
+	// crypto.rsaVerify(data, signature, publicKey[, opts]) -> boolean
+	_ = cryptoLib.Set("rsaVerify", func(call goja.FunctionCall) goja.Value {
+		ok, err := scriptlib.RSAVerify(call.Argument(0).String(), call.Argument(1).String(), call.Argument(2).String(), e.jsOptions(call, 3))
+		e.throwIfError(err)
+		return e.vm.ToValue(ok)
+	})
+	_ = cryptoLib.Set("hexEncode", func(s string) string { return scriptlib.HexEncode(s) })
+	_ = cryptoLib.Set("hexDecode", func(s string) string {
+		out, err := scriptlib.HexDecode(s)
+		e.throwIfError(err)
+		return out
+	})
+	e.vm.Set("crypto", cryptoLib)
+}
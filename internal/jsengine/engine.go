@@ -4,8 +4,10 @@ import (
 	"compress/flate"
 	"compress/gzip"
 	"compress/zlib"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -23,6 +25,7 @@ import (
 
 	"video-crawler/internal/crawler"
 	"video-crawler/internal/logger"
+	"video-crawler/internal/scriptlib"
 )
 
 // Engine 提供最小可用的 JS 执行环境（goja）
@@ -30,7 +33,13 @@ type Engine struct {
 	vm      *goja.Runtime
 	browser crawler.BrowserRequest
 	logSink func(string)
-	ctx     *gin.Context // 添加gin.Context支持
+	ctx     *gin.Context    // 添加gin.Context支持
+	execCtx context.Context // 脚本执行所属的 context，见 SetContext
+
+	loop       *eventLoop
+	fetchSlots chan struct{} // 限制同时进行的异步 fetch 数量
+
+	parseTracer scriptlib.ParseTracer // 非 nil 时记录 HTML 解析与选择器调用
 }
 
 func New(browser crawler.BrowserRequest) *Engine {
@@ -48,6 +57,21 @@ func NewWithContext(browser crawler.BrowserRequest, ctx *gin.Context) *Engine {
 	return e
 }
 
+// SetContext 设置脚本执行所属的 context，结束时（如客户端断开）事件循环立即停止；
+// 未设置时使用 gin 请求的 context
+func (e *Engine) SetContext(ctx context.Context) { e.execCtx = ctx }
+
+// execContext 事件循环使用的 context
+func (e *Engine) execContext() context.Context {
+	if e.execCtx != nil {
+		return e.execCtx
+	}
+	if e.ctx != nil && e.ctx.Request != nil {
+		return e.ctx.Request.Context()
+	}
+	return context.Background()
+}
+
 // SetLogSink 设置日志输出回调，用于回流到前端调试面板
 func (e *Engine) SetLogSink(sink func(string)) { e.logSink = sink }
 
@@ -90,13 +114,16 @@ func (e *Engine) wrapSelection(sel *goquery.Selection) *goja.Object {
 		return goja.Undefined()
 	})
 	_ = obj.Set("querySelector", func(css string) goja.Value {
+		start := time.Now()
 		s := sel.Find(css).First()
+		e.traceParse("querySelector", css, 0, s.Length(), start)
 		if s.Length() == 0 {
 			return goja.Undefined()
 		}
 		return e.wrapSelection(s)
 	})
 	_ = obj.Set("querySelectorAll", func(css string) *goja.Object {
+		start := time.Now()
 		arr := e.vm.NewArray()
 		var idx int64 = 0
 		sel.Find(css).Each(func(i int, s *goquery.Selection) {
@@ -104,8 +131,10 @@ func (e *Engine) wrapSelection(sel *goquery.Selection) *goja.Object {
 			idx++
 		})
 		arr.Set("length", idx)
+		e.traceParse("querySelectorAll", css, 0, int(idx), start)
 		return arr
 	})
+	e.bindXPath(obj, sel)
 	_ = obj.Set("getElementsByTagName", func(tag string) *goja.Object { return e.wrapSelection(sel.Find(tag)) })
 	_ = obj.Set("getElementsByClassName", func(cls string) *goja.Object { return e.wrapSelection(sel.Find("." + cls)) })
 	_ = obj.Set("getElementById", func(id string) goja.Value {
@@ -166,18 +195,52 @@ func (e *Engine) wrapSelection(sel *goquery.Selection) *goja.Object {
 	return obj
 }
 
+// bindXPath 为 DOM 封装对象添加 XPath 查询，表达式错误时抛出异常
+func (e *Engine) bindXPath(obj *goja.Object, sel *goquery.Selection) {
+	_ = obj.Set("xpath", func(expr string) *goja.Object {
+		start := time.Now()
+		result, err := scriptlib.XPath(sel, expr)
+		e.throwIfError(err)
+		e.traceParse("xpath", expr, 0, result.Length(), start)
+		arr := e.vm.NewArray()
+		var idx int64 = 0
+		result.Each(func(i int, s *goquery.Selection) {
+			arr.Set(strconv.FormatInt(idx, 10), e.wrapSelection(s))
+			idx++
+		})
+		arr.Set("length", idx)
+		return arr
+	})
+	xpathOne := func(expr string) goja.Value {
+		start := time.Now()
+		result, err := scriptlib.XPath(sel, expr)
+		e.throwIfError(err)
+		e.traceParse("xpathOne", expr, 0, min(result.Length(), 1), start)
+		if result.Length() == 0 {
+			return goja.Undefined()
+		}
+		return e.wrapSelection(result.First())
+	}
+	_ = obj.Set("xpathOne", xpathOne)
+	// 与 Lua 引擎同名，便于移植规则
+	_ = obj.Set("xpath_one", xpathOne)
+}
+
 func (e *Engine) wrapDocument(doc *goquery.Document) *goja.Object {
 	// Document 方法代理到根 selection
 	root := doc.Selection
 	obj := e.vm.NewObject()
 	_ = obj.Set("querySelector", func(css string) goja.Value {
+		start := time.Now()
 		s := root.Find(css).First()
+		e.traceParse("querySelector", css, 0, s.Length(), start)
 		if s.Length() == 0 {
 			return goja.Undefined()
 		}
 		return e.wrapSelection(s)
 	})
 	_ = obj.Set("querySelectorAll", func(css string) *goja.Object {
+		start := time.Now()
 		arr := e.vm.NewArray()
 		var idx int64 = 0
 		root.Find(css).Each(func(i int, s *goquery.Selection) {
@@ -185,8 +248,10 @@ func (e *Engine) wrapDocument(doc *goquery.Document) *goja.Object {
 			idx++
 		})
 		arr.Set("length", idx)
+		e.traceParse("querySelectorAll", css, 0, int(idx), start)
 		return arr
 	})
+	e.bindXPath(obj, root)
 	_ = obj.Set("getElementsByTagName", func(tag string) *goja.Object { return e.wrapSelection(root.Find(tag)) })
 	_ = obj.Set("getElementsByClassName", func(cls string) *goja.Object { return e.wrapSelection(root.Find("." + cls)) })
 	_ = obj.Set("getElementById", func(id string) goja.Value {
@@ -203,6 +268,9 @@ func (e *Engine) wrapDocument(doc *goquery.Document) *goja.Object {
 
 // bindApis 绑定可用的全局函数到 JS
 func (e *Engine) bindApis() {
+	// 事件循环与定时器
+	e.bindEventLoop()
+
 	// HTTP（兼容）
 	e.vm.Set("httpGet", func(url string) map[string]interface{} {
 		resp, err := e.browser.Get(url)
@@ -280,19 +348,25 @@ func (e *Engine) bindApis() {
 
 	// DOMParser 与 parseHtml
 	e.vm.Set("parseHtml", func(html string) *goja.Object {
+		start := time.Now()
 		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 		if err != nil {
+			e.traceParse("parseHtml", "", len(html), 0, start)
 			return e.vm.NewObject()
 		}
+		e.traceParse("parseHtml", "", len(html), 1, start)
 		return e.wrapDocument(doc)
 	})
 	e.vm.Set("DOMParser", func(call goja.ConstructorCall) *goja.Object {
 		obj := e.vm.NewObject()
 		_ = obj.Set("parseFromString", func(html string, _type string) *goja.Object {
+			start := time.Now()
 			d, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 			if err != nil {
+				e.traceParse("parseFromString", "", len(html), 0, start)
 				return e.vm.NewObject()
 			}
+			e.traceParse("parseFromString", "", len(html), 1, start)
 			return e.wrapDocument(d)
 		})
 		return obj
@@ -526,195 +600,48 @@ func (e *Engine) bindApis() {
 	})
 	e.vm.Set("unicode", unicodeLib)
 
-	// fetch：同步实现（与 await 兼容：await 非 thenable 值将立即返回）
-	e.vm.Set("fetch", func(call goja.FunctionCall) goja.Value {
-		var url string
-		if len(call.Arguments) > 0 {
-			url = call.Arguments[0].String()
-		}
-		method := "GET"
-		headers := map[string]string{}
-		var bodyBytes []byte
-		var contentType string
-		var timeoutMs int64 = -1
-		redirect := "follow" // follow | manual | error
-		if len(call.Arguments) > 1 {
-			opt := call.Arguments[1].Export()
-			if m, ok := opt.(map[string]interface{}); ok {
-				if v, ok := m["method"].(string); ok && v != "" {
-					method = strings.ToUpper(v)
-				}
-				if hv, ok := m["headers"]; ok {
-					switch h := hv.(type) {
-					case map[string]interface{}:
-						for k, vv := range h {
-							headers[k] = fmt.Sprint(vv)
-						}
-					case map[string]string:
-						for k, vv := range h {
-							headers[k] = vv
-						}
-					}
-				}
-				if b, ok := m["body"]; ok {
-					// 推断 body 类型：字符串 => 原样；对象/数组 => JSON；其它 => toString
-					switch bb := b.(type) {
-					case string:
-						bodyBytes = []byte(bb)
-						if contentType == "" {
-							contentType = headers["Content-Type"]
-						}
-					case map[string]interface{}, []interface{}:
-						js, _ := json.Marshal(b)
-						bodyBytes = js
-						if contentType == "" {
-							contentType = "application/json"
-						}
-					default:
-						bodyBytes = []byte(fmt.Sprint(b))
-					}
-				}
-				// Node/Web 常见选项：timeout(毫秒) & redirect
-				if t, ok := m["timeout"].(int64); ok {
-					timeoutMs = t
-				}
-				if t2, ok := m["timeout"].(float64); ok {
-					timeoutMs = int64(t2)
-				}
-				if rv, ok := m["redirect"].(string); ok {
-					redirect = strings.ToLower(rv)
-				}
-			}
-		}
-
-		// 处理超时与重定向策略
-		var restoreTimeout time.Duration
-		if timeoutMs > 0 {
-			restoreTimeout = e.browser.GetTimeout()
-			e.browser.SetTimeout(time.Duration(timeoutMs) * time.Millisecond)
-		}
-		var restoreFollow *bool
-		if redirect == "manual" || redirect == "error" {
-			prev := e.browser.GetFollowRedirects()
-			restoreFollow = &prev
-			e.browser.SetFollowRedirects(false)
-		}
-
-		// 合成请求头
-		h := map[string]string{}
-		for k, v := range headers {
-			h[k] = v
-		}
-		if contentType != "" && h["Content-Type"] == "" {
-			h["Content-Type"] = contentType
-		}
+	// 加解密与 JSONPath 库
+	e.bindCrypto()
+	e.bindJSONPath()
 
-		// 发起请求（统一使用 Do）
-		resp, err := e.browser.Do(method, url, bodyBytes, h)
+	// 表单提交
+	e.bindForm()
 
-		// 恢复配置
-		if timeoutMs > 0 {
-			e.browser.SetTimeout(restoreTimeout)
-		}
-		if restoreFollow != nil {
-			e.browser.SetFollowRedirects(*restoreFollow)
-		}
+	// 共享模块
+	e.bindRequire()
 
-		if err != nil {
-			return e.vm.ToValue(map[string]interface{}{"error": err.Error()})
-		}
-		defer resp.Body.Close()
-
-		// 构造 Headers 对象
-		hdrObj := e.vm.NewObject()
-		hdrMap := map[string]string{}
-		for k, v := range resp.Header {
-			if len(v) > 0 {
-				hdrMap[strings.ToLower(k)] = v[0]
-			}
-		}
-		_ = hdrObj.Set("get", func(name string) goja.Value {
-			if v, ok := hdrMap[strings.ToLower(name)]; ok {
-				return e.vm.ToValue(v)
-			}
-			return goja.Undefined()
-		})
-		_ = hdrObj.Set("has", func(name string) bool { _, ok := hdrMap[strings.ToLower(name)]; return ok })
-		_ = hdrObj.Set("keys", func() []string {
-			ks := make([]string, 0, len(hdrMap))
-			for k := range hdrMap {
-				ks = append(ks, k)
-			}
-			return ks
-		})
-		_ = hdrObj.Set("values", func() []string {
-			vs := make([]string, 0, len(hdrMap))
-			for _, v := range hdrMap {
-				vs = append(vs, v)
-			}
-			return vs
-		})
-		_ = hdrObj.Set("entries", func() []map[string]string {
-			arr := []map[string]string{}
-			for k, v := range hdrMap {
-				arr = append(arr, map[string]string{"0": k, "1": v})
-			}
-			return arr
-		})
-		_ = hdrObj.Set("forEach", func(cb goja.Callable) {
-			for k, v := range hdrMap {
-				_, _ = cb(nil, e.vm.ToValue(v), e.vm.ToValue(k))
-			}
-		})
-
-		// 读取 body（自动解压）
-		b, _ := readDecompressedBody(resp)
-
-		// 构造 Response 对象（同步）
-		respObj := e.vm.NewObject()
-		_ = respObj.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
-		_ = respObj.Set("status", resp.StatusCode)
-		_ = respObj.Set("statusText", resp.Status)
-		_ = respObj.Set("url", resp.Request.URL.String())
-		_ = respObj.Set("headers", hdrObj)
-		_ = respObj.Set("redirected", resp.Request.URL.String() != url)
-		_ = respObj.Set("type", "basic")
-		_ = respObj.Set("text", func() string { return string(b) })
-		_ = respObj.Set("json", func() goja.Value {
-			var v interface{}
-			if err := json.Unmarshal(b, &v); err != nil {
-				return goja.Undefined()
-			}
-			return e.vm.ToValue(v)
-		})
-		_ = respObj.Set("arrayBuffer", func() []byte { return b })
-
-		// 重定向策略（manual | error）
-		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
-			loc := resp.Header.Get("Location")
-			if redirect == "error" {
-				return e.vm.ToValue(map[string]interface{}{"error": fmt.Sprintf("redirect not allowed: %d %s", resp.StatusCode, loc)})
-			}
-			if redirect == "manual" {
-				_ = respObj.Set("location", loc)
-			}
-		}
-
-		return respObj
-	})
+	// fetch / fetchAll
+	e.bindFetch()
 }
 
-// ExecuteWrapped 执行完整脚本文本，返回其最后一个表达式的值（用于 {data,err} 对象）
+// ExecuteWrapped 执行完整脚本文本，返回其最后一个表达式的值（用于 {data,err} 对象）。
+// 行首的 import 语句会被改写为 require 调用。
+// 脚本主体执行完后运行事件循环直到定时器与异步请求全部完成；
+// 最后的值或其 data 字段为 Promise（如 async 入口函数的返回值）时取其结果，被拒绝时写入 err。
 func (e *Engine) ExecuteWrapped(script string) (map[string]interface{}, error) {
-	v, err := e.vm.RunString(script)
+	v, err := e.vm.RunString(scriptlib.RewriteImports(script))
 	if err != nil {
-		return nil, fmt.Errorf("execute js error: %w", err)
+		return nil, fmt.Errorf("execute js error: %w", jsScriptError(err, err.Error(), script))
+	}
+	if err := e.runEventLoop(); err != nil {
+		return e.loopError(err, script)
 	}
 	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
 		return map[string]interface{}{}, nil
 	}
-	got := v.Export()
+	got, err := awaitValue(v.Export())
+	if err != nil {
+		return errorResult(err, script), nil
+	}
 	if m, ok := got.(map[string]interface{}); ok {
+		if data, err := awaitValue(m["data"]); err != nil {
+			m["data"], m["err"] = nil, err.Error()
+			if se, ok := scriptlib.AsScriptError(err); ok {
+				m["error"] = se.WithSnippet(script)
+			}
+		} else if _, ok := m["data"]; ok {
+			m["data"] = data
+		}
 		return m, nil
 	}
 	// 兜底：尝试 JSON 解析
@@ -727,6 +654,64 @@ func (e *Engine) ExecuteWrapped(script string) (map[string]interface{}, error) {
 	return out, nil
 }
 
+// CallFunction 执行脚本后按名称调用全局函数，参数以原生 JS 值传入，不拼接进脚本文本。
+// *scriptlib.CallContext 类型的参数转换为 ctx 对象（含 store 与 log）。
+// 返回 {data, err}：函数抛出异常或返回的 Promise 被拒绝时写入 err，与 ExecuteWrapped 的约定一致；
+// 此时 error 为带行号与调用栈的 *scriptlib.ScriptError
+func (e *Engine) CallFunction(script string, funcName string, args ...interface{}) (map[string]interface{}, error) {
+	// 先转换参数：ctx 中的全局 store 需要在脚本主体执行前可用
+	jsArgs := make([]goja.Value, len(args))
+	for i, arg := range args {
+		if cc, ok := arg.(*scriptlib.CallContext); ok {
+			jsArgs[i] = e.contextObject(cc)
+			continue
+		}
+		jsArgs[i] = e.vm.ToValue(arg)
+	}
+	if _, err := e.vm.RunString(scriptlib.RewriteImports(script)); err != nil {
+		return nil, fmt.Errorf("execute js error: %w", jsScriptError(err, err.Error(), script))
+	}
+	fn, ok := goja.AssertFunction(e.vm.Get(funcName))
+	if !ok {
+		return nil, fmt.Errorf("execute js error: 函数 %s 未定义", funcName)
+	}
+	ret, callErr := fn(goja.Undefined(), jsArgs...)
+	loopErr := e.runEventLoop()
+	if callErr != nil {
+		var ex *goja.Exception
+		if errors.As(callErr, &ex) {
+			return errorResult(jsScriptError(ex, ex.Value().String(), script), script), nil
+		}
+		return nil, fmt.Errorf("execute js error: %w", callErr)
+	}
+	if loopErr != nil {
+		return e.loopError(loopErr, script)
+	}
+	data, err := awaitValue(exportValue(ret))
+	if err != nil {
+		return errorResult(err, script), nil
+	}
+	return map[string]interface{}{"data": data, "err": nil}, nil
+}
+
+// loopError 事件循环的错误：定时器回调的未捕获异常作为脚本错误写入 err，其余（超时、取消）作为执行错误返回
+func (e *Engine) loopError(err error, script string) (map[string]interface{}, error) {
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		return errorResult(jsScriptError(ex, ex.Value().String(), script), script), nil
+	}
+	return nil, fmt.Errorf("execute js error: %w", err)
+}
+
+// errorResult 入口函数抛出异常或 Promise 被拒绝时的结果：err 为错误文本，error 为结构化错误（*scriptlib.ScriptError）
+func errorResult(err error, script string) map[string]interface{} {
+	m := map[string]interface{}{"data": nil, "err": err.Error()}
+	if se, ok := scriptlib.AsScriptError(err); ok {
+		m["error"] = se.WithSnippet(script)
+	}
+	return m
+}
+
 // readDecompressedBody 与 Lua 引擎一致：自动解压 gzip/deflate
 func readDecompressedBody(resp *http.Response) ([]byte, error) {
 	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
@@ -0,0 +1,94 @@
+package jsengine
+
+import (
+	"testing"
+
+	"video-crawler/internal/scriptlib"
+)
+
+func TestCallFunction(t *testing.T) {
+	script := `
+function search_video(keyword) { return {keyword: keyword, len: keyword.length} }
+async function get_video_detail(url) { throw new Error("bad: " + url) }`
+	hostile := []string{
+		`"); throw 1; ("`,
+		"a\"b'c\\d\ne f",
+		"`${globalThis.x = 1}`",
+		"</script><!-- */ //",
+	}
+	for _, keyword := range hostile {
+		m, err := New(nil).CallFunction(script, "search_video", keyword)
+		if err != nil {
+			t.Fatalf("%q: %v", keyword, err)
+		}
+		data, _ := m["data"].(map[string]interface{})
+		if data["keyword"] != keyword || data["len"] != int64(len([]rune(keyword))) {
+			t.Fatalf("%q: data = %v", keyword, m["data"])
+		}
+	}
+
+	// async 函数被拒绝时写入 err
+	m, err := New(nil).CallFunction(script, "get_video_detail", `"x"`)
+	if err != nil || m["data"] != nil || m["err"] != `Error: bad: "x"` {
+		t.Fatalf("rejected = %v, %v", m, err)
+	}
+	if _, err := New(nil).CallFunction(script, "missing"); err == nil {
+		t.Fatal("missing function should fail")
+	}
+}
+
+func TestCallFunctionContext(t *testing.T) {
+	cc := scriptlib.NewCallContext("get_video_detail", map[string]string{"line": "2", "page": "x"})
+	cc.Source = scriptlib.SourceInfo{ID: "s1", Domain: "https://a.com", Settings: map[string]interface{}{"token": "t"}}
+	cc.Store = scriptlib.NewMemoryStore()
+	script := `
+function get_video_detail(url, ctx) {
+  ctx.store.set("last", {url: url});
+  ctx.log("line", ctx.params.line);
+  return {page: ctx.page, line: ctx.params.line, domain: ctx.source.domain, token: ctx.source.settings.token, last: ctx.store.get("last").url};
+}`
+	m, err := New(nil).CallFunction(script, "get_video_detail", "/v/1", cc)
+	data, _ := m["data"].(map[string]interface{})
+	if err != nil || data["page"] != int64(1) || data["line"] != "2" || data["domain"] != "https://a.com" || data["token"] != "t" || data["last"] != "/v/1" {
+		t.Fatalf("ctx call = %v, %v", m, err)
+	}
+
+	// 没有键值存储时 store 操作抛出异常
+	cc.Store = nil
+	m, err = New(nil).CallFunction(`function f(u, ctx) { ctx.store.get("a") }`, "f", "", cc)
+	if err != nil || m["err"] == nil {
+		t.Fatalf("nil store = %v, %v", m, err)
+	}
+}
+
+func TestCallFunctionScriptError(t *testing.T) {
+	script := "function add(a) {\n  return a.b.c\n}\nasync function search_video(k) {\n  await null\n  return add({})\n}"
+	m, _ := New(nil).CallFunction(script, "search_video", "kw")
+	se, ok := m["error"].(*scriptlib.ScriptError)
+	if !ok || se.Line != 2 || se.Kind != scriptlib.ScriptErrorRuntime || len(se.Stack) < 2 || se.Stack[0].Function != "add" {
+		t.Fatalf("rejected error = %+v", m["error"])
+	}
+	if se.Error() != m["err"] || !se.Snippet[1].Current {
+		t.Fatalf("error text = %q, snippet = %+v", se.Error(), se.Snippet)
+	}
+
+	_, err := New(nil).CallFunction("function search_video(k) {\n  let y = = 2\n}", "search_video", "kw")
+	if se, ok := scriptlib.AsScriptError(err); !ok || se.Kind != scriptlib.ScriptErrorCompile || se.Line != 2 || se.Column != 11 {
+		t.Fatalf("syntax error = %+v", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	script := "function search_video(keyword, ctx) {\n  return foo(keyword)\n  keyword = 1\n}\nconst get_video_detail = (url) => typeof bar\nfunction outer() {\n  function get_play_video_detail(url) {}\n}"
+	r := Lint(script)
+	codes := map[string]int{}
+	for _, d := range r.Diagnostics {
+		codes[d.Code] = d.Line
+	}
+	if r.Valid || codes[scriptlib.LintUndefinedGlobal] != 2 || codes[scriptlib.LintUnreachable] != 3 || codes[scriptlib.LintEntrySignature] != 7 || len(r.Diagnostics) != 3 {
+		t.Fatalf("lint = %+v", r.Diagnostics)
+	}
+	if r = Lint("function search_video(k) {\n  let y = = 2\n}"); r.Errors != 1 || r.Diagnostics[0].Line != 2 || r.Diagnostics[0].Code != scriptlib.LintSyntax {
+		t.Fatalf("syntax lint = %+v", r.Diagnostics)
+	}
+}
@@ -0,0 +1,93 @@
+package jsengine
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/scriptlib"
+)
+
+var (
+	// jsStackLine Error 对象 stack 属性中的一行，如 "at f (<eval>:1:21(3))" 或 "at module:util:2:5(10)"
+	jsStackLine = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+?):(\d+):(\d+)\(\d+\)\)?$`)
+	// jsSyntaxPosition 语法错误信息中的位置，如 "(anonymous): Line 2:11 Unexpected token ="
+	jsSyntaxPosition = regexp.MustCompile(`(\S+): Line (\d+):(\d+) (.+?)(?: \(and \d+ more errors\))?$`)
+)
+
+// jsScriptError 将脚本执行中的错误转换为结构化错误：异常取抛出时的调用栈，语法错误从错误信息中解析位置。
+// raw 为 Error() 返回的文本，与原有的错误信息保持一致
+func jsScriptError(err error, raw string, script string) *scriptlib.ScriptError {
+	var ex *goja.Exception
+	if !errors.As(err, &ex) {
+		return scriptlib.NewScriptError("js", scriptlib.ScriptErrorRuntime, err.Error(), raw).WithSnippet(script)
+	}
+	message := err.Error()
+	if ex.Value() != nil {
+		message = ex.Value().String()
+	}
+
+	se := scriptlib.NewScriptError("js", scriptlib.ScriptErrorRuntime, message, raw)
+	if m := jsSyntaxPosition.FindStringSubmatch(message); m != nil && strings.Contains(message, "SyntaxError") {
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		frame := jsFrame("<anonymous>", m[1], line, column)
+		se.Message = "SyntaxError: " + m[4]
+		if frame.Source == scriptlib.ScriptSourceMain {
+			// 入口脚本的语法错误：脚本没有开始执行
+			se.Kind = scriptlib.ScriptErrorCompile
+			se.Line, se.Column = line, column
+		}
+		se.Stack = append(se.Stack, frame)
+	}
+	for _, f := range ex.Stack() {
+		if f.SrcName() == "<native>" {
+			continue
+		}
+		pos := f.Position()
+		se.Stack = append(se.Stack, jsFrame(f.FuncName(), pos.Filename, pos.Line, pos.Column))
+	}
+	return se.WithSnippet(script)
+}
+
+// jsValueError 被拒绝的 Promise 的结果转换为错误：Error 对象从 stack 属性中解析调用栈。
+// 返回的错误文本与结果的字符串形式一致
+func jsValueError(v goja.Value) *scriptlib.ScriptError {
+	se := scriptlib.NewScriptError("js", scriptlib.ScriptErrorRuntime, v.String(), v.String())
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return se
+	}
+	stack := obj.Get("stack")
+	if stack == nil || goja.IsUndefined(stack) {
+		return se
+	}
+	for _, line := range strings.Split(stack.String(), "\n") {
+		m := jsStackLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[3])
+		column, _ := strconv.Atoi(m[4])
+		name := m[1]
+		if name == "" {
+			name = "<anonymous>"
+		}
+		se.Stack = append(se.Stack, jsFrame(name, m[2], n, column))
+	}
+	return se.Locate()
+}
+
+// jsFrame 构造调用栈帧：入口脚本统一命名为 script，模块第一行的列号减去包装函数前缀
+func jsFrame(function, source string, line, column int) scriptlib.ScriptStackFrame {
+	switch {
+	case source == "" || source == "<eval>" || source == "(anonymous)":
+		source = scriptlib.ScriptSourceMain
+	case strings.HasPrefix(source, "module:") && line == 1 && column > len(scriptlib.JSModulePrefix):
+		column -= len(scriptlib.JSModulePrefix)
+	}
+	return scriptlib.ScriptStackFrame{Function: function, Source: source, Line: line, Column: column}
+}
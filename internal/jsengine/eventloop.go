@@ -0,0 +1,209 @@
+package jsengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// EventLoopTimeout 脚本主体执行完后，等待定时器与异步请求完成的最长时间
+const EventLoopTimeout = 60 * time.Second
+
+const minTimerInterval = 4 * time.Millisecond
+
+// eventLoop 单线程事件循环：虚拟机只在 runEventLoop 所在的协程中执行，
+// 异步操作在其它协程完成后通过 post 把回调投递回来。
+type eventLoop struct {
+	mutex   sync.Mutex
+	queue   []func()
+	wake    chan struct{}
+	pending int // 已开始但尚未投递回调的异步操作数，只在虚拟机协程中读写
+
+	timers  map[int64]*jsTimer
+	timerID int64
+}
+
+type jsTimer struct {
+	id       int64
+	when     time.Time
+	interval time.Duration
+	repeat   bool
+	fn       goja.Callable
+	args     []goja.Value
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{wake: make(chan struct{}, 1), timers: map[int64]*jsTimer{}}
+}
+
+// begin 登记一个异步操作，操作完成时必须调用一次 post
+func (l *eventLoop) begin() {
+	l.pending++
+}
+
+// post 从任意协程投递回调，回调在虚拟机协程中执行
+func (l *eventLoop) post(fn func()) {
+	l.mutex.Lock()
+	l.queue = append(l.queue, fn)
+	l.mutex.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *eventLoop) drain() []func() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	queue := l.queue
+	l.queue = nil
+	return queue
+}
+
+// nextTimer 返回最早到期的定时器（相同时间按创建顺序）
+func (l *eventLoop) nextTimer() *jsTimer {
+	var next *jsTimer
+	for _, t := range l.timers {
+		if next == nil || t.when.Before(next.when) || (t.when.Equal(next.when) && t.id < next.id) {
+			next = t
+		}
+	}
+	return next
+}
+
+// bindEventLoop 绑定定时器：setTimeout / setInterval / clearTimeout / clearInterval / queueMicrotask
+func (e *Engine) bindEventLoop() {
+	e.loop = newEventLoop()
+	addTimer := func(call goja.FunctionCall, repeat bool) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(e.vm.NewTypeError("callback must be a function"))
+		}
+		delay := time.Duration(call.Argument(1).ToInteger()) * time.Millisecond
+		if delay < 0 {
+			delay = 0
+		}
+		// 与浏览器一致，setInterval 的间隔有下限，避免空转
+		if repeat && delay < minTimerInterval {
+			delay = minTimerInterval
+		}
+		var args []goja.Value
+		if len(call.Arguments) > 2 {
+			args = append(args, call.Arguments[2:]...)
+		}
+		e.loop.timerID++
+		t := &jsTimer{id: e.loop.timerID, when: time.Now().Add(delay), interval: delay, repeat: repeat, fn: fn, args: args}
+		e.loop.timers[t.id] = t
+		return e.vm.ToValue(t.id)
+	}
+	clearTimer := func(call goja.FunctionCall) goja.Value {
+		delete(e.loop.timers, call.Argument(0).ToInteger())
+		return goja.Undefined()
+	}
+	e.vm.Set("setTimeout", func(call goja.FunctionCall) goja.Value { return addTimer(call, false) })
+	e.vm.Set("setInterval", func(call goja.FunctionCall) goja.Value { return addTimer(call, true) })
+	e.vm.Set("clearTimeout", clearTimer)
+	e.vm.Set("clearInterval", clearTimer)
+	// queueMicrotask 借助 Promise 任务队列实现
+	_, _ = e.vm.RunString("globalThis.queueMicrotask = function (fn) { Promise.resolve().then(function () { fn(); }); };")
+}
+
+// runEventLoop 执行投递的回调与到期的定时器，直到没有待处理的任务。
+// 调用方的 context 结束（如客户端断开）时立即停止；定时器回调抛出未捕获的异常时
+// 与浏览器的未处理异常一致，以该异常（*goja.Exception）结束脚本
+func (e *Engine) runEventLoop() error {
+	l := e.loop
+	ctx := e.execContext()
+	deadline := time.NewTimer(EventLoopTimeout)
+	defer deadline.Stop()
+	timeout := func() error {
+		return fmt.Errorf("事件循环超时（%s）：仍有 %d 个定时器、%d 个异步请求未完成", EventLoopTimeout, len(l.timers), l.pending)
+	}
+	canceled := func() error {
+		return fmt.Errorf("脚本已取消：%w", context.Cause(ctx))
+	}
+	for {
+		select {
+		case <-deadline.C:
+			return timeout()
+		case <-ctx.Done():
+			return canceled()
+		default:
+		}
+		for _, fn := range l.drain() {
+			l.pending--
+			fn()
+		}
+		next := l.nextTimer()
+		if next == nil && l.pending == 0 {
+			return nil
+		}
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if next != nil {
+			wait := time.Until(next.when)
+			if wait <= 0 {
+				if err := e.fireTimer(next); err != nil {
+					return err
+				}
+				continue
+			}
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		}
+		var err error
+		select {
+		case <-l.wake:
+		case <-fire:
+			err = e.fireTimer(next)
+		case <-deadline.C:
+			err = timeout()
+		case <-ctx.Done():
+			err = canceled()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fireTimer 执行到期的定时器，返回回调抛出的异常
+func (e *Engine) fireTimer(t *jsTimer) error {
+	if _, ok := e.loop.timers[t.id]; !ok {
+		return nil
+	}
+	if t.repeat {
+		t.when = time.Now().Add(t.interval)
+	} else {
+		delete(e.loop.timers, t.id)
+	}
+	if _, err := t.fn(goja.Undefined(), t.args...); err != nil {
+		e.emit(fmt.Sprintf("[ERROR] 定时器回调异常: %v", err))
+		return err
+	}
+	return nil
+}
+
+// awaitValue 取出 Promise 的结果：已兑现返回其值，已拒绝返回 *scriptlib.ScriptError；非 Promise 原样返回
+func awaitValue(v interface{}) (interface{}, error) {
+	p, ok := v.(*goja.Promise)
+	if !ok {
+		return v, nil
+	}
+	switch p.State() {
+	case goja.PromiseStateFulfilled:
+		return exportValue(p.Result()), nil
+	case goja.PromiseStateRejected:
+		return nil, jsValueError(p.Result())
+	default:
+		return nil, errors.New("Promise 未完成：事件循环已空，没有可以完成它的任务")
+	}
+}
@@ -0,0 +1,100 @@
+package jsengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"video-crawler/internal/crawler"
+)
+
+func TestEventLoop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+	browser, err := crawler.NewHTTPBrowser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// async 入口函数：Promise.all + 定时器，data 字段的 Promise 在返回前被兑现
+	m, err := New(browser).ExecuteWrapped(`
+async function search_video(k) {
+  await new Promise(function (r) { setTimeout(r, 10) });
+  var rs = await Promise.all(["/a", "/b"].map(function (p) { return fetch("` + srv.URL + `" + p) }));
+  return [k].concat(rs.map(function (r) { return r.text() }));
+}
+({data: search_video("x"), err: null})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m["data"], []interface{}{"x", "/a", "/b"}) {
+		t.Fatalf("data = %v", m["data"])
+	}
+
+	// 旧的同步写法仍然可用
+	m, err = New(browser).ExecuteWrapped(`({data: fetch("` + srv.URL + `/s").text()})`)
+	if err != nil || m["data"] != "/s" {
+		t.Fatalf("sync fetch = %v, %v", m, err)
+	}
+
+	// 被拒绝的 Promise 写入 err
+	m, err = New(browser).ExecuteWrapped(`({data: Promise.reject(new Error("boom")), err: null})`)
+	if err != nil || m["data"] != nil || m["err"] != "Error: boom" {
+		t.Fatalf("rejected = %v, %v", m, err)
+	}
+}
+
+func TestEventLoopTimerError(t *testing.T) {
+	browser, err := crawler.NewHTTPBrowser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 定时器回调中未捕获的异常作为脚本错误返回，不再等待剩余的定时器
+	start := time.Now()
+	m, err := New(browser).CallFunction(`
+function search_video(k) {
+  setTimeout(function () { throw new Error("timer " + k) }, 10);
+  setTimeout(function () { throw new Error("second") }, 20);
+  setInterval(function () {}, 1000);
+  return [k];
+}`, "search_video", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["data"] != nil || m["err"] != "Error: timer x" || m["error"] == nil {
+		t.Fatalf("timer error = %v", m)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("uncaught timer error did not stop the event loop")
+	}
+}
+
+func TestEventLoopContext(t *testing.T) {
+	browser, err := crawler.NewHTTPBrowser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未清除的 setInterval 在调用方 context 结束时停止，而不是等到 EventLoopTimeout
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	e := New(browser)
+	e.SetContext(ctx)
+	start := time.Now()
+	_, err = e.ExecuteWrapped(`setInterval(function () {}, 10); ({data: 1})`)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "取消") {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("context cancel did not stop the event loop")
+	}
+}
@@ -0,0 +1,319 @@
+package jsengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/scriptlib"
+)
+
+// fetchRequest 已解析的 fetch 参数，执行时不再访问虚拟机
+type fetchRequest struct {
+	url      string
+	method   string
+	headers  map[string]string
+	body     []byte
+	timeout  time.Duration
+	redirect string // follow | manual | error
+}
+
+// fetchResult 请求结果，body 已读取并解压
+type fetchResult struct {
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// fetchResponseFields 兼容同步写法时，可以直接在 fetch 返回的 Promise 上读取的 Response 字段与方法
+var (
+	fetchResponseFields  = []string{"ok", "status", "statusText", "url", "headers", "redirected", "type", "location", "error"}
+	fetchResponseMethods = []string{"text", "json", "arrayBuffer"}
+)
+
+// bindFetch 绑定 fetch 与 fetchAll，请求在后台协程执行，完成后经事件循环兑现 Promise
+func (e *Engine) bindFetch() {
+	e.fetchSlots = make(chan struct{}, scriptlib.MaxBatchConcurrency)
+
+	// fetch(url, options) -> Promise<Response>，网络错误时 Promise 被拒绝。
+	// 为兼容旧的同步写法，也可以直接读取返回值上的 status、text() 等，此时会等待请求完成
+	e.vm.Set("fetch", func(call goja.FunctionCall) goja.Value {
+		return e.fetchAsync(e.parseFetchRequest(call.Argument(0), call.Argument(1)))
+	})
+
+	// fetchAll(requests[, {concurrency}])：在 Go 侧并发执行请求，返回按输入顺序排列的 Response 数组的 Promise。
+	// requests 的每一项可以是 URL 字符串、{url, ...fetch 选项} 或 [url, 选项]；单个请求失败时对应位置为 {error}
+	e.vm.Set("fetchAll", func(call goja.FunctionCall) goja.Value {
+		list := call.Argument(0)
+		if goja.IsUndefined(list) || goja.IsNull(list) {
+			panic(e.vm.NewTypeError("fetchAll: requests must be an array"))
+		}
+		obj := list.ToObject(e.vm)
+		requests := make([]fetchRequest, int(obj.Get("length").ToInteger()))
+		for i := range requests {
+			requests[i] = e.parseFetchItem(obj.Get(fmt.Sprint(i)))
+		}
+		concurrency := 0
+		if opts, ok := call.Argument(1).Export().(map[string]interface{}); ok {
+			if n, ok := opts["concurrency"]; ok {
+				concurrency = int(e.vm.ToValue(n).ToInteger())
+			}
+		}
+
+		promise, resolve, _ := e.vm.NewPromise()
+		e.loop.begin()
+		go func() {
+			results := make([]fetchResult, len(requests))
+			scriptlib.Parallel(len(requests), concurrency, func(i int) {
+				results[i] = e.doFetch(requests[i])
+			})
+			e.loop.post(func() {
+				responses := make([]interface{}, len(requests))
+				for i := range requests {
+					responses[i], _ = e.fetchResponse(requests[i], results[i])
+				}
+				_ = resolve(e.vm.NewArray(responses...))
+			})
+		}()
+		return e.vm.ToValue(promise)
+	})
+}
+
+// fetchAsync 在后台协程发起请求，返回的 Promise 上附带等待请求完成的同步访问器
+func (e *Engine) fetchAsync(req fetchRequest) goja.Value {
+	promise, resolve, reject := e.vm.NewPromise()
+	done := make(chan struct{})
+	var result fetchResult
+	var response goja.Value
+	// settle 只在虚拟机协程中调用：构造 Response 并兑现 Promise，重复调用时直接返回
+	settle := func() goja.Value {
+		if response == nil {
+			var err error
+			response, err = e.fetchResponse(req, result)
+			if err != nil {
+				_ = reject(e.vm.NewTypeError("%s", err.Error()))
+			} else {
+				_ = resolve(response)
+			}
+		}
+		return response
+	}
+	wait := func() *goja.Object {
+		<-done
+		return settle().ToObject(e.vm)
+	}
+
+	e.loop.begin()
+	go func() {
+		e.fetchSlots <- struct{}{}
+		result = e.doFetch(req)
+		<-e.fetchSlots
+		close(done)
+		e.loop.post(func() { settle() })
+	}()
+
+	obj := e.vm.ToValue(promise).ToObject(e.vm)
+	for _, name := range fetchResponseFields {
+		name := name
+		getter := e.vm.ToValue(func() goja.Value { return wait().Get(name) })
+		_ = obj.DefineAccessorProperty(name, getter, nil, goja.FLAG_TRUE, goja.FLAG_TRUE)
+	}
+	for _, name := range fetchResponseMethods {
+		name := name
+		_ = obj.Set(name, func(call goja.FunctionCall) goja.Value {
+			target := wait()
+			fn, ok := goja.AssertFunction(target.Get(name))
+			if !ok {
+				return goja.Undefined()
+			}
+			v, err := fn(target, call.Arguments...)
+			e.throwIfError(err)
+			return v
+		})
+	}
+	return obj
+}
+
+// parseFetchItem 解析 fetchAll 中的单项
+func (e *Engine) parseFetchItem(item goja.Value) fetchRequest {
+	if item == nil || goja.IsUndefined(item) || goja.IsNull(item) {
+		return e.parseFetchRequest(goja.Undefined(), goja.Undefined())
+	}
+	switch v := item.Export().(type) {
+	case []interface{}:
+		obj := item.ToObject(e.vm)
+		return e.parseFetchRequest(obj.Get("0"), obj.Get("1"))
+	case map[string]interface{}:
+		return e.parseFetchRequest(e.vm.ToValue(v["url"]), item)
+	}
+	return e.parseFetchRequest(item, goja.Undefined())
+}
+
+// parseFetchRequest 解析 fetch(url, options) 参数
+func (e *Engine) parseFetchRequest(urlValue, options goja.Value) fetchRequest {
+	req := fetchRequest{
+		method:   "GET",
+		headers:  map[string]string{},
+		redirect: "follow",
+	}
+	if urlValue != nil && !goja.IsUndefined(urlValue) && !goja.IsNull(urlValue) {
+		req.url = urlValue.String()
+	}
+	if options == nil {
+		return req
+	}
+	m, ok := options.Export().(map[string]interface{})
+	if !ok {
+		return req
+	}
+
+	var contentType string
+	if v, ok := m["method"].(string); ok && v != "" {
+		req.method = strings.ToUpper(v)
+	}
+	if hv, ok := m["headers"]; ok {
+		switch h := hv.(type) {
+		case map[string]interface{}:
+			for k, vv := range h {
+				req.headers[k] = fmt.Sprint(vv)
+			}
+		case map[string]string:
+			for k, vv := range h {
+				req.headers[k] = vv
+			}
+		}
+	}
+	if b, ok := m["body"]; ok {
+		// 推断 body 类型：字符串 => 原样；对象/数组 => JSON；其它 => toString
+		switch bb := b.(type) {
+		case string:
+			req.body = []byte(bb)
+		case map[string]interface{}, []interface{}:
+			js, _ := json.Marshal(b)
+			req.body = js
+			contentType = "application/json"
+		default:
+			req.body = []byte(fmt.Sprint(b))
+		}
+	}
+	// Node/Web 常见选项：timeout(毫秒) & redirect
+	if t, ok := m["timeout"].(int64); ok && t > 0 {
+		req.timeout = time.Duration(t) * time.Millisecond
+	}
+	if t, ok := m["timeout"].(float64); ok && t > 0 {
+		req.timeout = time.Duration(t * float64(time.Millisecond))
+	}
+	if rv, ok := m["redirect"].(string); ok {
+		req.redirect = strings.ToLower(rv)
+	}
+	if contentType != "" && req.headers["Content-Type"] == "" {
+		req.headers["Content-Type"] = contentType
+	}
+	return req
+}
+
+// doFetch 执行请求并读取响应体；只使用单次请求选项，可以在多个协程中并发调用
+func (e *Engine) doFetch(req fetchRequest) fetchResult {
+	options := crawler.RequestOptions{Headers: req.headers, Timeout: req.timeout}
+	if req.redirect == "manual" || req.redirect == "error" {
+		follow := false
+		options.FollowRedirects = &follow
+	}
+	resp, err := e.browser.DoWithOptions(req.method, req.url, req.body, options)
+	if err != nil {
+		return fetchResult{err: err}
+	}
+	defer resp.Body.Close()
+	// 读取 body（自动解压）
+	body, _ := readDecompressedBody(resp)
+	return fetchResult{resp: resp, body: body}
+}
+
+// fetchResponse 构造 Response 对象，必须在虚拟机所在协程调用。
+// 请求失败时返回 {error} 对象（同步写法通过 error 字段判断）与对应的错误
+func (e *Engine) fetchResponse(req fetchRequest, result fetchResult) (goja.Value, error) {
+	if result.err != nil {
+		return e.vm.ToValue(map[string]interface{}{"error": result.err.Error()}), result.err
+	}
+	resp, b := result.resp, result.body
+
+	// 构造 Headers 对象
+	hdrObj := e.vm.NewObject()
+	hdrMap := map[string]string{}
+	for k, v := range resp.Header {
+		if len(v) > 0 {
+			hdrMap[strings.ToLower(k)] = v[0]
+		}
+	}
+	_ = hdrObj.Set("get", func(name string) goja.Value {
+		if v, ok := hdrMap[strings.ToLower(name)]; ok {
+			return e.vm.ToValue(v)
+		}
+		return goja.Undefined()
+	})
+	_ = hdrObj.Set("has", func(name string) bool { _, ok := hdrMap[strings.ToLower(name)]; return ok })
+	_ = hdrObj.Set("keys", func() []string {
+		ks := make([]string, 0, len(hdrMap))
+		for k := range hdrMap {
+			ks = append(ks, k)
+		}
+		return ks
+	})
+	_ = hdrObj.Set("values", func() []string {
+		vs := make([]string, 0, len(hdrMap))
+		for _, v := range hdrMap {
+			vs = append(vs, v)
+		}
+		return vs
+	})
+	_ = hdrObj.Set("entries", func() []map[string]string {
+		arr := []map[string]string{}
+		for k, v := range hdrMap {
+			arr = append(arr, map[string]string{"0": k, "1": v})
+		}
+		return arr
+	})
+	_ = hdrObj.Set("forEach", func(cb goja.Callable) {
+		for k, v := range hdrMap {
+			_, _ = cb(nil, e.vm.ToValue(v), e.vm.ToValue(k))
+		}
+	})
+
+	// 构造 Response 对象（同步）
+	respObj := e.vm.NewObject()
+	_ = respObj.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
+	_ = respObj.Set("status", resp.StatusCode)
+	_ = respObj.Set("statusText", resp.Status)
+	_ = respObj.Set("url", resp.Request.URL.String())
+	_ = respObj.Set("headers", hdrObj)
+	_ = respObj.Set("redirected", resp.Request.URL.String() != req.url)
+	_ = respObj.Set("type", "basic")
+	_ = respObj.Set("text", func() string { return string(b) })
+	_ = respObj.Set("json", func() goja.Value {
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return goja.Undefined()
+		}
+		return e.vm.ToValue(v)
+	})
+	_ = respObj.Set("arrayBuffer", func() []byte { return b })
+
+	// 重定向策略（manual | error）
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		loc := resp.Header.Get("Location")
+		if req.redirect == "error" {
+			err := fmt.Errorf("redirect not allowed: %d %s", resp.StatusCode, loc)
+			return e.vm.ToValue(map[string]interface{}{"error": err.Error()}), err
+		}
+		if req.redirect == "manual" {
+			_ = respObj.Set("location", loc)
+		}
+	}
+
+	return respObj, nil
+}
@@ -0,0 +1,69 @@
+package jsengine
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// batchServer /item/<n>?delay=毫秒[&status=码]，返回 item-<n>，并记录同时处理中的请求数峰值
+func batchServer(peak *int32) http.Handler {
+	var inflight int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(peak)
+			if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+				break
+			}
+		}
+		delay, _ := strconv.Atoi(r.URL.Query().Get("delay"))
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+		if status, _ := strconv.Atoi(r.URL.Query().Get("status")); status > 0 {
+			w.WriteHeader(status)
+		}
+		w.Write([]byte("item-" + strings.TrimPrefix(r.URL.Path, "/item/")))
+	})
+}
+
+func TestFetchAll(t *testing.T) {
+	// 序号越大响应越快，结果仍按输入顺序返回；第 5 项连接失败、第 6 项返回 500，不影响其他项
+	script := `
+async function search_video(u) {
+  const reqs = [];
+  for (let i = 1; i <= 8; i++) reqs.push(u + "/item/" + i + "?delay=" + (9 - i) * 15);
+  reqs[4] = "http://127.0.0.1:1/unreachable";
+  reqs[5] = [u + "/item/6?status=500", {method: "GET"}];
+  const resps = await fetchAll(reqs, {concurrency: 3});
+  return resps.map(r => r.error ? {error: r.error} : {ok: r.ok, status: r.status, body: r.text()});
+}`
+	var peak int32
+	data, _ := callWithServer(t, batchServer(&peak), script).([]interface{})
+	if len(data) != 8 {
+		t.Fatalf("data = %v", data)
+	}
+	for i, item := range data {
+		r := item.(map[string]interface{})
+		switch i + 1 {
+		case 5:
+			if r["error"] == nil || r["error"] == "" {
+				t.Fatalf("unreachable = %v", r)
+			}
+		case 6:
+			if r["ok"] != false || r["status"] != int64(500) || r["body"] != "item-6" {
+				t.Fatalf("500 = %v", r)
+			}
+		default:
+			if r["ok"] != true || r["body"] != "item-"+strconv.Itoa(i+1) || r["error"] != nil {
+				t.Fatalf("%d = %v", i+1, r)
+			}
+		}
+	}
+	if peak > 3 || peak < 2 {
+		t.Fatalf("peak in-flight requests = %d, want 2..3", peak)
+	}
+}
@@ -0,0 +1,106 @@
+package jsengine
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/crawler"
+)
+
+// bindForm 绑定表单提交：httpPostForm(url, data) 与 httpPostMultipart(url, fields, files)。
+// 返回值与 httpPost 一致：{status_code, body, url, headers[, err]}
+func (e *Engine) bindForm() {
+	// httpPostForm(url, {k: "v", tags: ["a", "b"]})：数组值编码为重复的键
+	e.vm.Set("httpPostForm", func(call goja.FunctionCall) goja.Value {
+		url := call.Argument(0).String()
+		resp, err := e.browser.PostForm(url, e.exportFormValues(call.Argument(1)))
+		return e.formResponse(url, resp, err)
+	})
+	// httpPostMultipart(url, fields, [{name, filename, content, contentType}])
+	// content 可以是字符串、ArrayBuffer 或 Uint8Array
+	e.vm.Set("httpPostMultipart", func(call goja.FunctionCall) goja.Value {
+		url := call.Argument(0).String()
+		files, err := e.exportMultipartFiles(call.Argument(2))
+		e.throwIfError(err)
+		resp, err := e.browser.PostMultipart(url, e.exportFormValues(call.Argument(1)), files)
+		return e.formResponse(url, resp, err)
+	})
+}
+
+func (e *Engine) exportFormValues(v goja.Value) map[string][]string {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return map[string][]string{}
+	}
+	m, _ := v.Export().(map[string]interface{})
+	return crawler.FormValues(m)
+}
+
+func (e *Engine) exportMultipartFiles(v goja.Value) ([]crawler.MultipartFile, error) {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, nil
+	}
+	obj := v.ToObject(e.vm)
+	length := int(obj.Get("length").ToInteger())
+	files := make([]crawler.MultipartFile, 0, length)
+	for i := 0; i < length; i++ {
+		item := obj.Get(fmt.Sprint(i))
+		if item == nil || goja.IsUndefined(item) || goja.IsNull(item) {
+			return nil, fmt.Errorf("files[%d] must be an object", i)
+		}
+		o := item.ToObject(e.vm)
+		file := crawler.MultipartFile{
+			Field:       optString(o.Get("name")),
+			FileName:    optString(o.Get("filename")),
+			ContentType: optString(o.Get("contentType")),
+			Data:        exportBytes(o.Get("content")),
+		}
+		if file.Field == "" {
+			return nil, fmt.Errorf("files[%d].name is required", i)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// exportBytes 将字符串、ArrayBuffer 或 TypedArray 转为字节
+func exportBytes(v goja.Value) []byte {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	switch data := v.Export().(type) {
+	case goja.ArrayBuffer:
+		return data.Bytes()
+	case []byte:
+		return data
+	}
+	return []byte(v.String())
+}
+
+func optString(v goja.Value) string {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return ""
+	}
+	return v.String()
+}
+
+func (e *Engine) formResponse(url string, resp *http.Response, err error) goja.Value {
+	if err != nil {
+		return e.vm.ToValue(map[string]interface{}{"status_code": 0, "body": "", "url": url, "err": err.Error()})
+	}
+	defer resp.Body.Close()
+	body, _ := readDecompressedBody(resp)
+	h := map[string]string{}
+	for k, v := range resp.Header {
+		if len(v) > 0 {
+			h[k] = v[0]
+		}
+	}
+	return e.vm.ToValue(map[string]interface{}{
+		"status_code": resp.StatusCode,
+		"body":        string(body),
+		"url":         resp.Request.URL.String(),
+		"headers":     h,
+	})
+}
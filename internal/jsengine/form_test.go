@@ -0,0 +1,106 @@
+package jsengine
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"video-crawler/internal/crawler"
+)
+
+// parsedForm 测试服务端实际解析到的表单
+type parsedForm struct {
+	ContentType string                `json:"content_type"`
+	Form        url.Values            `json:"form"`
+	Files       map[string]parsedFile `json:"files"`
+}
+
+type parsedFile struct {
+	FileName    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+func formEchoHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	result := parsedForm{ContentType: mediaType, Files: map[string]parsedFile{}}
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for field, headers := range r.MultipartForm.File {
+			f, _ := headers[0].Open()
+			data, _ := io.ReadAll(f)
+			f.Close()
+			result.Files[field] = parsedFile{FileName: headers[0].Filename, ContentType: headers[0].Header.Get("Content-Type"), Content: string(data)}
+		}
+	} else if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result.Form = r.PostForm
+	json.NewEncoder(w).Encode(result)
+}
+
+// callWithServer 以 httptest 服务地址为参数调用 search_video，返回脚本结果
+func callWithServer(t *testing.T, handler http.Handler, script string) interface{} {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	browser, err := crawler.NewHTTPBrowser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(browser).CallFunction(script, "search_video", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["err"] != nil && m["err"] != "" {
+		t.Fatalf("script error: %v", m["err"])
+	}
+	return m["data"]
+}
+
+func TestHttpPostForm(t *testing.T) {
+	script := `
+function search_video(u) {
+  const form = {tag: ["a", "b"], q: "x y&z"};
+  const files = [
+    {name: "cover", filename: "c.png", content: new Uint8Array([0, 80, 78, 71, 13, 10]), contentType: "image/png"},
+    {name: "raw", content: "text"},
+  ];
+  return [httpPostForm(u, form), httpPostMultipart(u, form, files)].map(r => {
+    if (r.err) throw new Error(r.err);
+    return r.body;
+  });
+}`
+	data, _ := callWithServer(t, http.HandlerFunc(formEchoHandler), script).([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("data = %v", data)
+	}
+	wantForm := url.Values{"tag": {"a", "b"}, "q": {"x y&z"}}
+	var got parsedForm
+	if err := json.Unmarshal([]byte(data[0].(string)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ContentType != "application/x-www-form-urlencoded" || !reflect.DeepEqual(got.Form, wantForm) {
+		t.Fatalf("form = %+v", got)
+	}
+	got = parsedForm{}
+	if err := json.Unmarshal([]byte(data[1].(string)), &got); err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := map[string]parsedFile{
+		"cover": {FileName: "c.png", ContentType: "image/png", Content: "\x00PNG\r\n"},
+		"raw":   {FileName: "raw", ContentType: "application/octet-stream", Content: "text"},
+	}
+	if got.ContentType != "multipart/form-data" || !reflect.DeepEqual(got.Form, wantForm) || !reflect.DeepEqual(got.Files, wantFiles) {
+		t.Fatalf("multipart = %+v", got)
+	}
+}
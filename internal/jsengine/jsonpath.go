@@ -0,0 +1,28 @@
+package jsengine
+
+import (
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// bindJSONPath 绑定 JSONPath 查询库（与 Lua 引擎的 jsonpath 表语义一致，实现见 scriptlib）
+func (e *Engine) bindJSONPath() {
+	jsonpathLib := e.vm.NewObject()
+	// jsonpath.query(data, path) -> Array（data 为字符串时按原始 JSON 解析）
+	_ = jsonpathLib.Set("query", func(call goja.FunctionCall) goja.Value {
+		results, err := scriptlib.JSONPathQuery(call.Argument(0).Export(), call.Argument(1).String())
+		e.throwIfError(err)
+		return e.vm.ToValue(results)
+	})
+	// jsonpath.get(data, path[, default]) -> 第一个匹配值，没有匹配时返回 default
+	_ = jsonpathLib.Set("get", func(call goja.FunctionCall) goja.Value {
+		results, err := scriptlib.JSONPathQuery(call.Argument(0).Export(), call.Argument(1).String())
+		e.throwIfError(err)
+		if len(results) == 0 {
+			return call.Argument(2)
+		}
+		return e.vm.ToValue(results[0])
+	})
+	e.vm.Set("jsonpath", jsonpathLib)
+}
@@ -0,0 +1,494 @@
+package jsengine
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+	"github.com/dop251/goja/token"
+
+	"video-crawler/internal/scriptlib"
+)
+
+var (
+	jsGlobalsOnce sync.Once
+	jsGlobals     map[string]bool
+)
+
+// jsKnownGlobals 引擎注册的全局变量（含内置对象），从一个新建的引擎中读取；
+// store 在调用入口函数时注册，arguments 在函数内可用
+func jsKnownGlobals() map[string]bool {
+	jsGlobalsOnce.Do(func() {
+		jsGlobals = map[string]bool{"store": true, "arguments": true}
+		vm := New(nil).vm
+		names, err := vm.RunString("Object.getOwnPropertyNames(globalThis)")
+		if err != nil {
+			return
+		}
+		var list []string
+		if err := vm.ExportTo(names, &list); err == nil {
+			for _, name := range list {
+				jsGlobals[name] = true
+			}
+		}
+	})
+	return jsGlobals
+}
+
+// Lint 静态检查 JS 脚本：语法与编译错误、入口函数、未定义的全局变量、不可达代码与不存在的共享模块。
+// 未定义的全局变量按整个脚本中声明过的名字判断，不区分作用域
+func Lint(script string) *scriptlib.LintResult {
+	l := scriptlib.NewLinter(script)
+	// 与执行时一致，先将 import 语句改写为 require
+	prg, err := parser.ParseFile(nil, "", scriptlib.RewriteImports(script), 0)
+	if err != nil {
+		var list parser.ErrorList
+		// 之后的错误多由第一个错误引起，与执行时一致只报告第一个
+		if errors.As(err, &list) && len(list) > 0 {
+			e := list[0]
+			l.Add(scriptlib.LintError, scriptlib.LintSyntax, e.Position.Line, l.RuneColumn(e.Position.Line, e.Position.Column), "SyntaxError: %s", e.Message)
+		} else {
+			l.Add(scriptlib.LintError, scriptlib.LintSyntax, 0, 0, "%s", err.Error())
+		}
+		return l.Result("js")
+	}
+	if _, err := goja.CompileAST(prg, false); err != nil {
+		var syntaxErr *goja.CompilerSyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.File != nil {
+			l.AddSpan(scriptlib.LintError, scriptlib.LintSyntax, syntaxErr.Offset, 0, "SyntaxError: %s", syntaxErr.Message)
+		} else {
+			l.Add(scriptlib.LintError, scriptlib.LintSyntax, 0, 0, "%s", err.Error())
+		}
+		return l.Result("js")
+	}
+
+	w := &jsLinter{l: l, base: prg.File.Base(), declared: map[string]bool{}, assigned: map[string]bool{}, defs: map[string]scriptlib.EntryDef{}}
+	w.entries(prg.Body)
+	w.stmts(prg.Body)
+	known := jsKnownGlobals()
+	for _, ref := range w.refs {
+		name := ref.Name.String()
+		if !known[name] && !w.declared[name] && !w.assigned[name] {
+			l.AddSpan(scriptlib.LintWarning, scriptlib.LintUndefinedGlobal, w.offset(ref.Idx), len(name), "未定义的全局变量 %s", name)
+		}
+	}
+	l.CheckEntries(w.defs, "undefined")
+	return l.Result("js")
+}
+
+// jsLinter 遍历语法树，收集声明过的名字与对全局变量的引用
+type jsLinter struct {
+	l        *scriptlib.Linter
+	base     int
+	declared map[string]bool // 脚本中任意位置声明过的名字
+	assigned map[string]bool // 未声明直接赋值的名字（隐式全局变量）
+	refs     []*ast.Identifier
+	defs     map[string]scriptlib.EntryDef
+}
+
+// offset 语法树位置转换为脚本中的字节偏移
+func (w *jsLinter) offset(idx file.Idx) int {
+	return int(idx) - w.base
+}
+
+// entries 记录顶层定义的入口函数：函数声明、var/let/const 与直接赋值
+func (w *jsLinter) entries(body []ast.Statement) {
+	for _, stmt := range body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDeclaration:
+			if s.Function.Name != nil {
+				w.define(s.Function.Name, s.Function, true)
+			}
+		case *ast.VariableStatement:
+			w.defineBindings(s.List)
+		case *ast.LexicalDeclaration:
+			w.defineBindings(s.List)
+		case *ast.ExpressionStatement:
+			if assign, ok := s.Expression.(*ast.AssignExpression); ok && assign.Operator == token.ASSIGN {
+				if ident, ok := assign.Left.(*ast.Identifier); ok {
+					w.define(ident, assign.Right, true)
+				}
+			}
+		}
+	}
+}
+
+func (w *jsLinter) defineBindings(list []*ast.Binding) {
+	for _, b := range list {
+		if ident, ok := b.Target.(*ast.Identifier); ok {
+			w.define(ident, b.Initializer, true)
+		}
+	}
+}
+
+// define 记录入口函数的定义，同名只记录第一次
+func (w *jsLinter) define(name *ast.Identifier, value ast.Node, global bool) {
+	if _, ok := w.defs[name.Name.String()]; ok {
+		return
+	}
+	line, _ := w.l.Position(w.offset(name.Idx))
+	def := scriptlib.EntryDef{Line: line, Global: global}
+	var params *ast.ParameterList
+	switch fn := value.(type) {
+	case *ast.FunctionLiteral:
+		params = fn.ParameterList
+	case *ast.ArrowFunctionLiteral:
+		params = fn.ParameterList
+	}
+	if params != nil {
+		def.IsFunc = true
+		def.Params = len(params.List)
+		def.Vararg = params.Rest != nil
+	}
+	w.defs[name.Name.String()] = def
+}
+
+// stmts 检查语句列表：在必然跳出的语句之后出现的第一条语句报告为不可达（函数声明会提升，不报告）
+func (w *jsLinter) stmts(list []ast.Statement) {
+	terminated := false
+	for _, stmt := range list {
+		if terminated {
+			switch stmt.(type) {
+			case *ast.FunctionDeclaration, *ast.EmptyStatement:
+			default:
+				line, _ := w.l.Position(w.offset(stmt.Idx0()))
+				w.l.AddLine(scriptlib.LintWarning, scriptlib.LintUnreachable, line, "不可达代码")
+				terminated = false
+			}
+		}
+		w.stmt(stmt)
+		if jsTerminates(stmt) {
+			terminated = true
+		}
+	}
+}
+
+// jsTerminates 语句执行后必然跳出当前语句列表
+func jsTerminates(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement, *ast.BranchStatement:
+		return true
+	case *ast.BlockStatement:
+		for _, inner := range s.List {
+			if jsTerminates(inner) {
+				return true
+			}
+		}
+	case *ast.IfStatement:
+		return s.Alternate != nil && jsTerminates(s.Consequent) && jsTerminates(s.Alternate)
+	case *ast.WhileStatement:
+		test, ok := s.Test.(*ast.BooleanLiteral)
+		return ok && test.Value && !jsLeavesLoop(s.Body)
+	case *ast.ForStatement:
+		return s.Test == nil && !jsLeavesLoop(s.Body)
+	}
+	return false
+}
+
+// jsLeavesLoop 循环体中是否有 break（不含内层循环、switch 与函数；带标签的 break 一律视为跳出）
+func jsLeavesLoop(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.BranchStatement:
+		return s.Token == token.BREAK
+	case *ast.BlockStatement:
+		for _, inner := range s.List {
+			if jsLeavesLoop(inner) {
+				return true
+			}
+		}
+	case *ast.IfStatement:
+		return jsLeavesLoop(s.Consequent) || (s.Alternate != nil && jsLeavesLoop(s.Alternate))
+	case *ast.TryStatement:
+		return jsLeavesLoop(s.Body) || (s.Catch != nil && jsLeavesLoop(s.Catch.Body)) || (s.Finally != nil && jsLeavesLoop(s.Finally))
+	case *ast.LabelledStatement:
+		return jsLeavesLoop(s.Statement)
+	}
+	return false
+}
+
+func (w *jsLinter) stmt(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.BlockStatement:
+		w.stmts(s.List)
+	case *ast.ExpressionStatement:
+		w.expr(s.Expression)
+	case *ast.VariableStatement:
+		w.bindings(s.List)
+	case *ast.LexicalDeclaration:
+		w.bindings(s.List)
+	case *ast.FunctionDeclaration:
+		if s.Function.Name != nil {
+			w.define(s.Function.Name, s.Function, false)
+		}
+		w.function(s.Function)
+	case *ast.ClassDeclaration:
+		w.class(s.Class)
+	case *ast.IfStatement:
+		w.expr(s.Test)
+		w.stmt(s.Consequent)
+		if s.Alternate != nil {
+			w.stmt(s.Alternate)
+		}
+	case *ast.ForStatement:
+		switch init := s.Initializer.(type) {
+		case *ast.ForLoopInitializerExpression:
+			w.expr(init.Expression)
+		case *ast.ForLoopInitializerVarDeclList:
+			w.bindings(init.List)
+		case *ast.ForLoopInitializerLexicalDecl:
+			w.bindings(init.LexicalDeclaration.List)
+		}
+		w.expr(s.Test)
+		w.expr(s.Update)
+		w.stmt(s.Body)
+	case *ast.ForInStatement:
+		w.forInto(s.Into)
+		w.expr(s.Source)
+		w.stmt(s.Body)
+	case *ast.ForOfStatement:
+		w.forInto(s.Into)
+		w.expr(s.Source)
+		w.stmt(s.Body)
+	case *ast.WhileStatement:
+		w.expr(s.Test)
+		w.stmt(s.Body)
+	case *ast.DoWhileStatement:
+		w.stmt(s.Body)
+		w.expr(s.Test)
+	case *ast.ReturnStatement:
+		w.expr(s.Argument)
+	case *ast.ThrowStatement:
+		w.expr(s.Argument)
+	case *ast.SwitchStatement:
+		w.expr(s.Discriminant)
+		for _, c := range s.Body {
+			w.expr(c.Test)
+			w.stmts(c.Consequent)
+		}
+	case *ast.TryStatement:
+		w.stmt(s.Body)
+		if s.Catch != nil {
+			if s.Catch.Parameter != nil {
+				w.target(s.Catch.Parameter, true)
+			}
+			w.stmt(s.Catch.Body)
+		}
+		if s.Finally != nil {
+			w.stmt(s.Finally)
+		}
+	case *ast.LabelledStatement:
+		w.stmt(s.Statement)
+	case *ast.WithStatement:
+		w.expr(s.Object)
+		w.stmt(s.Body)
+	}
+}
+
+func (w *jsLinter) forInto(into ast.ForInto) {
+	switch i := into.(type) {
+	case *ast.ForIntoVar:
+		w.bindings([]*ast.Binding{i.Binding})
+	case *ast.ForDeclaration:
+		w.target(i.Target, true)
+	case *ast.ForIntoExpression:
+		w.target(i.Expression, false)
+	}
+}
+
+func (w *jsLinter) bindings(list []*ast.Binding) {
+	for _, b := range list {
+		w.target(b.Target, true)
+		w.expr(b.Initializer)
+	}
+}
+
+// target 检查声明（declare 为 true）或赋值的目标，包括解构模式与默认值
+func (w *jsLinter) target(t ast.Expression, declare bool) {
+	switch e := t.(type) {
+	case *ast.Identifier:
+		if declare {
+			w.declared[e.Name.String()] = true
+		} else {
+			w.assigned[e.Name.String()] = true
+		}
+	case *ast.ArrayPattern:
+		for _, el := range e.Elements {
+			w.target(el, declare)
+		}
+		w.target(e.Rest, declare)
+	case *ast.ArrayLiteral:
+		for _, el := range e.Value {
+			w.target(el, declare)
+		}
+	case *ast.ObjectPattern:
+		w.properties(e.Properties, declare)
+		w.target(e.Rest, declare)
+	case *ast.ObjectLiteral:
+		w.properties(e.Value, declare)
+	case *ast.AssignExpression:
+		w.target(e.Left, declare)
+		w.expr(e.Right)
+	case *ast.SpreadElement:
+		w.target(e.Expression, declare)
+	case nil:
+	default:
+		w.expr(t)
+	}
+}
+
+func (w *jsLinter) properties(props []ast.Property, declare bool) {
+	for _, p := range props {
+		switch prop := p.(type) {
+		case *ast.PropertyShort:
+			name := prop.Name
+			w.target(&name, declare)
+			w.expr(prop.Initializer)
+		case *ast.PropertyKeyed:
+			if prop.Computed {
+				w.expr(prop.Key)
+			}
+			w.target(prop.Value, declare)
+		case *ast.SpreadElement:
+			w.target(prop.Expression, declare)
+		}
+	}
+}
+
+func (w *jsLinter) params(list *ast.ParameterList) {
+	if list == nil {
+		return
+	}
+	w.bindings(list.List)
+	w.target(list.Rest, true)
+}
+
+func (w *jsLinter) function(fn *ast.FunctionLiteral) {
+	if fn.Name != nil {
+		w.declared[fn.Name.Name.String()] = true
+	}
+	w.params(fn.ParameterList)
+	if fn.Body != nil {
+		w.stmt(fn.Body)
+	}
+}
+
+func (w *jsLinter) class(c *ast.ClassLiteral) {
+	if c.Name != nil {
+		w.declared[c.Name.Name.String()] = true
+	}
+	w.expr(c.SuperClass)
+	for _, el := range c.Body {
+		switch m := el.(type) {
+		case *ast.FieldDefinition:
+			if m.Computed {
+				w.expr(m.Key)
+			}
+			w.expr(m.Initializer)
+		case *ast.MethodDefinition:
+			if m.Computed {
+				w.expr(m.Key)
+			}
+			w.function(m.Body)
+		case *ast.ClassStaticBlock:
+			w.stmt(m.Block)
+		}
+	}
+}
+
+func (w *jsLinter) exprs(list []ast.Expression) {
+	for _, e := range list {
+		w.expr(e)
+	}
+}
+
+func (w *jsLinter) expr(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		w.refs = append(w.refs, e)
+	case *ast.DotExpression:
+		w.expr(e.Left)
+	case *ast.PrivateDotExpression:
+		w.expr(e.Left)
+	case *ast.BracketExpression:
+		w.expr(e.Left)
+		w.expr(e.Member)
+	case *ast.CallExpression:
+		if callee, ok := e.Callee.(*ast.Identifier); ok && callee.Name == "require" && len(e.ArgumentList) > 0 {
+			if name, ok := e.ArgumentList[0].(*ast.StringLiteral); ok {
+				line, _ := w.l.Position(w.offset(name.Idx))
+				w.l.CheckModule(scriptlib.EngineJS, name.Value.String(), line)
+			}
+		}
+		w.expr(e.Callee)
+		w.exprs(e.ArgumentList)
+	case *ast.NewExpression:
+		w.expr(e.Callee)
+		w.exprs(e.ArgumentList)
+	case *ast.AssignExpression:
+		w.target(e.Left, false)
+		w.expr(e.Right)
+	case *ast.BinaryExpression:
+		w.expr(e.Left)
+		w.expr(e.Right)
+	case *ast.UnaryExpression:
+		// typeof x 常用于判断全局变量是否存在
+		if _, ok := e.Operand.(*ast.Identifier); ok && e.Operator == token.TYPEOF {
+			return
+		}
+		w.expr(e.Operand)
+	case *ast.ConditionalExpression:
+		w.expr(e.Test)
+		w.expr(e.Consequent)
+		w.expr(e.Alternate)
+	case *ast.SequenceExpression:
+		w.exprs(e.Sequence)
+	case *ast.ArrayLiteral:
+		w.exprs(e.Value)
+	case *ast.ObjectLiteral:
+		for _, p := range e.Value {
+			switch prop := p.(type) {
+			case *ast.PropertyShort:
+				name := prop.Name
+				w.expr(&name)
+				w.expr(prop.Initializer)
+			case *ast.PropertyKeyed:
+				if prop.Computed {
+					w.expr(prop.Key)
+				}
+				w.expr(prop.Value)
+			case *ast.SpreadElement:
+				w.expr(prop.Expression)
+			}
+		}
+	case *ast.SpreadElement:
+		w.expr(e.Expression)
+	case *ast.FunctionLiteral:
+		w.function(e)
+	case *ast.ArrowFunctionLiteral:
+		w.params(e.ParameterList)
+		switch body := e.Body.(type) {
+		case *ast.BlockStatement:
+			w.stmt(body)
+		case *ast.ExpressionBody:
+			w.expr(body.Expression)
+		}
+	case *ast.ClassLiteral:
+		w.class(e)
+	case *ast.TemplateLiteral:
+		w.expr(e.Tag)
+		w.exprs(e.Expressions)
+	case *ast.YieldExpression:
+		w.expr(e.Argument)
+	case *ast.AwaitExpression:
+		w.expr(e.Argument)
+	case *ast.OptionalChain:
+		w.expr(e.Expression)
+	case *ast.Optional:
+		w.expr(e.Expression)
+	case *ast.ArrayPattern, *ast.ObjectPattern:
+		w.target(e, false)
+	}
+}
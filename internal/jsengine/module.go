@@ -0,0 +1,40 @@
+package jsengine
+
+import (
+	"github.com/dop251/goja"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// bindRequire 绑定 CommonJS 风格的 require：只能加载管理员维护的共享模块。
+// 模块通过 module.exports / exports 导出，同一引擎内只执行一次；
+// 循环依赖时与 Node.js 一致返回尚未完成的 exports。
+func (e *Engine) bindRequire() {
+	modules := map[string]*goja.Object{}
+	e.vm.Set("require", func(name string) goja.Value {
+		if module, ok := modules[name]; ok {
+			return module.Get("exports")
+		}
+		code, err := scriptlib.ResolveModule(scriptlib.EngineJS, name)
+		e.throwIfError(err)
+		wrapper, err := e.vm.RunScript("module:"+name, scriptlib.WrapJSModule(code))
+		e.throwIfError(err)
+		fn, ok := goja.AssertFunction(wrapper)
+		if !ok {
+			panic(e.vm.NewTypeError("模块 %s 加载失败", name))
+		}
+
+		module := e.vm.NewObject()
+		exports := e.vm.NewObject()
+		_ = module.Set("exports", exports)
+		modules[name] = module
+		if _, err := fn(goja.Undefined(), module, exports, e.vm.Get("require")); err != nil {
+			delete(modules, name)
+			if ex, ok := err.(*goja.Exception); ok {
+				panic(ex)
+			}
+			e.throwIfError(err)
+		}
+		return module.Get("exports")
+	})
+}
@@ -0,0 +1,118 @@
+package jsengine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// SandboxTimeout 沙箱脚本的默认执行超时
+const SandboxTimeout = 5 * time.Second
+
+// Sandbox 不含网络、DOM、console 等宿主能力的纯 ECMAScript 运行时，
+// 供 Lua 脚本执行页面中的混淆代码（如 eval(function(p,a,c,k,e,d){...})）。
+type Sandbox struct {
+	vm      *goja.Runtime
+	ctx     context.Context
+	timeout time.Duration
+}
+
+// NewSandbox 创建沙箱，timeout <= 0 时使用 SandboxTimeout；
+// ctx 为调用方（如 Lua 脚本）的 context，结束时中断正在执行的代码，nil 表示不受外部取消
+func NewSandbox(ctx context.Context, timeout time.Duration) *Sandbox {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 {
+		timeout = SandboxTimeout
+	}
+	vm := goja.New()
+	// 混淆代码常通过 window / self 访问全局对象
+	global := vm.GlobalObject()
+	_ = global.Set("window", global)
+	_ = global.Set("self", global)
+	_ = global.Set("atob", func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return string(b), err
+	})
+	_ = global.Set("btoa", func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) })
+	return &Sandbox{vm: vm, ctx: ctx, timeout: timeout}
+}
+
+// Eval 执行代码并返回最后一个表达式的值（已导出为 Go 值）
+func (s *Sandbox) Eval(code string) (interface{}, error) {
+	v, err := s.run(func() (goja.Value, error) { return s.vm.RunString(code) })
+	if err != nil {
+		return nil, err
+	}
+	return exportValue(v), nil
+}
+
+// Call 执行代码后调用其中定义的函数，fn 支持 a.b.c 形式的属性路径
+func (s *Sandbox) Call(code, fn string, args ...interface{}) (interface{}, error) {
+	v, err := s.run(func() (goja.Value, error) {
+		if _, err := s.vm.RunString(code); err != nil {
+			return nil, err
+		}
+		var target goja.Value = s.vm.GlobalObject()
+		var this goja.Value = goja.Undefined()
+		for _, name := range strings.Split(fn, ".") {
+			obj := target.ToObject(s.vm)
+			this, target = obj, obj.Get(name)
+			if target == nil || goja.IsUndefined(target) || goja.IsNull(target) {
+				return nil, fmt.Errorf("函数不存在: %s", fn)
+			}
+		}
+		callable, ok := goja.AssertFunction(target)
+		if !ok {
+			return nil, fmt.Errorf("%s 不是函数", fn)
+		}
+		jsArgs := make([]goja.Value, len(args))
+		for i, arg := range args {
+			jsArgs[i] = s.vm.ToValue(arg)
+		}
+		return callable(this, jsArgs...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return exportValue(v), nil
+}
+
+// run 在超时与调用方 context 控制下执行，超时或 context 结束后中断运行时
+func (s *Sandbox) run(fn func() (goja.Value, error)) (goja.Value, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, fmt.Errorf("JS 执行已取消: %w", err)
+	}
+	timer := time.AfterFunc(s.timeout, func() { s.vm.Interrupt("timeout") })
+	stop := context.AfterFunc(s.ctx, func() { s.vm.Interrupt("canceled") })
+	defer func() {
+		timer.Stop()
+		stop()
+		s.vm.ClearInterrupt()
+	}()
+	v, err := fn()
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			if interrupted.Value() == "canceled" {
+				return nil, fmt.Errorf("JS 执行已取消: %w", s.ctx.Err())
+			}
+			return nil, fmt.Errorf("JS 执行超时（%s）", s.timeout)
+		}
+		return nil, fmt.Errorf("JS 执行错误: %w", err)
+	}
+	return v, nil
+}
+
+func exportValue(v goja.Value) interface{} {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	return v.Export()
+}
@@ -0,0 +1,58 @@
+package jsengine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandbox(t *testing.T) {
+	// 典型的 packed 代码：去掉开头的 eval 即可得到解包后的源码
+	packed := `eval(function(p,a,c,k,e,d){e=function(c){return c.toString(36)};if(!''.replace(/^/,String)){while(c--){d[c.toString(a)]=k[c]||c.toString(a)}k=[function(e){return d[e]}];e=function(){return'\\w+'};c=1};while(c--){if(k[c]){p=p.replace(new RegExp('\\b'+e(c)+'\\b','g'),k[c])}}return p}('0 1="2://3.4/5.6"',7,7,'var|url|https|example|com|index|m3u8'.split('|'),0,{}))`
+	v, err := NewSandbox(context.Background(), 0).Eval(strings.TrimPrefix(packed, "eval"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `var url="https://example.com/index.m3u8"` {
+		t.Fatalf("unpacked = %v", v)
+	}
+
+	v, err = NewSandbox(context.Background(), 0).Call(`var player = { sign: function(a, b) { return { s: a + b.join(",") } } }`, "player.sign", "x", []interface{}{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := v.(map[string]interface{}); !ok || m["s"] != "x1,2" {
+		t.Fatalf("call = %v", v)
+	}
+
+	if _, err := NewSandbox(context.Background(), 0).Call(`var a = 1`, "missing"); err == nil {
+		t.Fatal("expected error for missing function")
+	}
+	if _, err := NewSandbox(context.Background(), 0).Eval(`fetch("http://example.com")`); err == nil {
+		t.Fatal("sandbox must not expose host APIs")
+	}
+
+	start := time.Now()
+	if _, err := NewSandbox(context.Background(), 100*time.Millisecond).Eval(`for (;;) {}`); err == nil || !strings.Contains(err.Error(), "超时") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("timeout did not interrupt the runtime")
+	}
+
+	// 调用方 context 结束时中断执行，不必等到超时
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start = time.Now()
+	if _, err := NewSandbox(ctx, time.Minute).Eval(`for (;;) {}`); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("cancel did not interrupt the runtime")
+	}
+	if _, err := NewSandbox(ctx, 0).Eval(`1`); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled before running, got %v", err)
+	}
+}
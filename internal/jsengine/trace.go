@@ -0,0 +1,30 @@
+package jsengine
+
+import (
+	"time"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// SetParseTracer 设置 HTML 解析与选择器调用的记录回调，nil 关闭记录
+func (e *Engine) SetParseTracer(tracer scriptlib.ParseTracer) {
+	e.parseTracer = tracer
+}
+
+// traceParse 记录一次解析或选择器调用；调用位置取调用栈中最近的脚本帧
+func (e *Engine) traceParse(op, selector string, inputSize, matches int, start time.Time) {
+	if e.parseTracer == nil {
+		return
+	}
+	rec := scriptlib.ParseTrace{Op: op, Selector: selector, InputSize: inputSize, Matches: matches, DurationMs: scriptlib.ElapsedMs(start)}
+	for _, f := range e.vm.CaptureCallStack(0, nil) {
+		if f.SrcName() == "<native>" {
+			continue
+		}
+		pos := f.Position()
+		frame := jsFrame(f.FuncName(), pos.Filename, pos.Line, pos.Column)
+		rec.Source, rec.Line = frame.Source, frame.Line
+		break
+	}
+	e.parseTracer(rec)
+}
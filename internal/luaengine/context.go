@@ -0,0 +1,61 @@
+package lua
+
+import (
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// contextTable 构造入口函数的 ctx 参数：
+// ctx.method / page / locale / params / filters / source{id,name,domain,settings}，
+// ctx.store.get(key) / set(key, value[, ttl_seconds]) / delete(key)，以及 ctx.log(...)。
+// store 同时注册为全局变量，脚本主体中也可以使用
+func (e *LuaEngine) contextTable(cc *scriptlib.CallContext) *lua.LTable {
+	L := e.L
+	tbl, _ := interfaceToLua(L, cc.Values()).(*lua.LTable)
+	store := L.NewTable()
+	L.SetFuncs(store, map[string]lua.LGFunction{
+		// store.get(key) -> value（不存在或已过期时为 nil）
+		"get": func(L *lua.LState) int {
+			if cc.Store == nil {
+				return luaHttpError(L, scriptlib.ErrStoreUnavailable.Error())
+			}
+			value, ok := cc.Store.Get(L.CheckString(1))
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(interfaceToLua(L, value))
+			return 1
+		},
+		// store.set(key, value[, ttl_seconds]) -> true, err
+		"set": func(L *lua.LState) int {
+			if cc.Store == nil {
+				return luaHttpError(L, scriptlib.ErrStoreUnavailable.Error())
+			}
+			ttl := time.Duration(float64(L.OptNumber(3, 0)) * float64(time.Second))
+			if err := cc.Store.Set(L.CheckString(1), luaToInterfaceJSON(L.Get(2)), ttl); err != nil {
+				return luaHttpError(L, err.Error())
+			}
+			L.Push(lua.LTrue)
+			return 1
+		},
+		// store.delete(key) -> true, err
+		"delete": func(L *lua.LState) int {
+			if cc.Store == nil {
+				return luaHttpError(L, scriptlib.ErrStoreUnavailable.Error())
+			}
+			if err := cc.Store.Delete(L.CheckString(1)); err != nil {
+				return luaHttpError(L, err.Error())
+			}
+			L.Push(lua.LTrue)
+			return 1
+		},
+	})
+	tbl.RawSetString("store", store)
+	L.SetGlobal("store", store)
+	tbl.RawSetString("log", L.NewFunction(e.luaLog))
+	return tbl
+}
@@ -0,0 +1,92 @@
+package lua
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// luaOptions 读取可选的选项表参数
+func luaOptions(L *lua.LState, n int) scriptlib.Options {
+	if t := L.OptTable(n, nil); t != nil {
+		return luaTableToMap(t)
+	}
+	return nil
+}
+
+// pushStringResult 按 value, err 约定压栈
+func pushStringResult(L *lua.LState, s string, err error) int {
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(s))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// createCryptoLibrary 创建加解密库（与 JS 引擎的 crypto 对象语义一致，实现见 scriptlib）
+func (e *LuaEngine) createCryptoLibrary() *lua.LTable {
+	cryptoTable := e.L.CreateTable(0, 12)
+
+	// crypto.md5 / sha1 / sha256 / sha512(data[, output]) -> string, err（output 默认 hex）
+	for _, algo := range []string{"md5", "sha1", "sha256", "sha512"} {
+		algo := algo
+		cryptoTable.RawSetString(algo, e.L.NewFunction(func(L *lua.LState) int {
+			s, err := scriptlib.Digest(algo, L.CheckString(1), L.OptString(2, "hex"))
+			return pushStringResult(L, s, err)
+		}))
+	}
+
+	// crypto.hmac(algo, key, data[, opts]) -> string, err
+	cryptoTable.RawSetString("hmac", e.L.NewFunction(func(L *lua.LState) int {
+		s, err := scriptlib.HMAC(L.CheckString(1), L.CheckString(2), L.CheckString(3), luaOptions(L, 4))
+		return pushStringResult(L, s, err)
+	}))
+
+	// crypto.aes_encrypt(data, key[, opts]) -> string, err
+	cryptoTable.RawSetString("aes_encrypt", e.L.NewFunction(func(L *lua.LState) int {
+		s, err := scriptlib.AESEncrypt(L.CheckString(1), L.CheckString(2), luaOptions(L, 3))
+		return pushStringResult(L, s, err)
+	}))
+
+	// crypto.aes_decrypt(data, key[, opts]) -> string, err
+	cryptoTable.RawSetString("aes_decrypt", e.L.NewFunction(func(L *lua.LState) int {
+		s, err := scriptlib.AESDecrypt(L.CheckString(1), L.CheckString(2), luaOptions(L, 3))
+		return pushStringResult(L, s, err)
+	}))
+
+	// crypto.rsa_encrypt(data, public_key[, opts]) -> string, err
+	cryptoTable.RawSetString("rsa_encrypt", e.L.NewFunction(func(L *lua.LState) int {
+		s, err := scriptlib.RSAEncrypt(L.CheckString(1), L.CheckString(2), luaOptions(L, 3))
+		return pushStringResult(L, s, err)
+	}))
+
+	// crypto.rsa_verify(data, signature, public_key[, opts]) -> boolean, err
+	cryptoTable.RawSetString("rsa_verify", e.L.NewFunction(func(L *lua.LState) int {
+		ok, err := scriptlib.RSAVerify(L.CheckString(1), L.CheckString(2), L.CheckString(3), luaOptions(L, 4))
+		if err != nil {
+			L.Push(lua.LFalse)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LBool(ok))
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// crypto.hex_encode(str) -> string
+	cryptoTable.RawSetString("hex_encode", e.L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(scriptlib.HexEncode(L.CheckString(1))))
+		return 1
+	}))
+
+	// crypto.hex_decode(str) -> string, err
+	cryptoTable.RawSetString("hex_decode", e.L.NewFunction(func(L *lua.LState) int {
+		s, err := scriptlib.HexDecode(L.CheckString(1))
+		return pushStringResult(L, s, err)
+	}))
+
+	return cryptoTable
+}
@@ -0,0 +1,649 @@
+package lua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// DebugPauseTimeout 暂停后等待调试命令的最长时间，超时后终止脚本
+	DebugPauseTimeout = 10 * time.Minute
+	// DebugEvalTimeout 单个表达式求值的最长时间
+	DebugEvalTimeout = 5 * time.Second
+	// DebugCommandTimeout 调试命令等待虚拟机响应的最长时间
+	DebugCommandTimeout = 10 * time.Second
+
+	// debugMainSource 入口脚本的源名称（LoadString 编译的代码块），断点只作用于入口脚本
+	debugMainSource = "<string>"
+	// debugValueDepth / debugValueItems 变量展示时表的最大嵌套层数与每层的最大元素数
+	debugValueDepth = 3
+	debugValueItems = 100
+)
+
+// 暂停原因
+const (
+	DebugReasonEntry      = "entry"      // 脚本开始执行（stop_on_entry）
+	DebugReasonBreakpoint = "breakpoint" // 命中断点
+	DebugReasonStep       = "step"       // 单步执行完成
+	DebugReasonPause      = "pause"      // 收到暂停命令
+)
+
+var (
+	// ErrDebugNotPaused 当前没有暂停，无法执行需要暂停状态的命令
+	ErrDebugNotPaused = errors.New("脚本未处于暂停状态")
+	// ErrDebugAborted 调试会话已终止
+	ErrDebugAborted = errors.New("调试已终止")
+)
+
+type debugMode int
+
+const (
+	debugRun debugMode = iota
+	debugStepInto
+	debugStepOver
+	debugStepOut
+)
+
+// DebugFrame 调用栈中的一层，Level 0 为当前执行的函数
+type DebugFrame struct {
+	Level  int    `json:"level"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Line   int    `json:"line"` // Go 函数为 -1
+	What   string `json:"what"` // main | Lua | G | tail
+}
+
+// DebugPause 暂停事件
+type DebugPause struct {
+	Reason string       `json:"reason"`
+	Source string       `json:"source"`
+	Line   int          `json:"line"`
+	Stack  []DebugFrame `json:"stack"`
+}
+
+// DebugVariable 变量或求值结果，Value 为可序列化的展示值（表按层数与元素数截断）
+type DebugVariable struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// DebugScope 某一层调用栈可见的变量
+type DebugScope struct {
+	Frame    int             `json:"frame"`
+	Locals   []DebugVariable `json:"locals"`
+	Upvalues []DebugVariable `json:"upvalues"`
+	Globals  []DebugVariable `json:"globals"` // 脚本定义的全局变量，不含引擎注册的函数与库
+}
+
+// DebugEvent 调试事件：paused（Pause 有值）或 resumed
+type DebugEvent struct {
+	Type  string      `json:"type"`
+	Pause *DebugPause `json:"pause,omitempty"`
+}
+
+// debugRequest 需要在虚拟机协程中执行的命令，只在暂停时被处理
+type debugRequest struct {
+	command    string
+	frame      int
+	expression string
+	reply      chan debugReply
+}
+
+type debugReply struct {
+	value interface{}
+	err   error
+}
+
+// Debugger Lua 单步调试器。
+// gopher-lua 没有行钩子，但设置了 Context 的虚拟机在执行每条指令前都会调用 ctx.Done()，
+// Debugger 作为虚拟机的 Context，在 Done() 中检查行号变化、断点与单步条件，需要暂停时阻塞等待调试命令。
+// 协程（coroutine）使用派生的 Context，其中的代码不会触发断点
+type Debugger struct {
+	parent context.Context
+	cancel context.CancelFunc
+	engine *LuaEngine
+
+	mutex       sync.Mutex
+	breakpoints map[int]bool
+	paused      bool
+
+	pauseRequested atomic.Bool
+	aborted        atomic.Bool
+
+	// 以下字段只在虚拟机协程中读写
+	mode      debugMode
+	stepDepth int
+	inHook    bool
+	lastFn    *lua.LFunction
+	lastLine  int
+	lines     []int // 每层调用栈最近执行的行号，用于区分进入新行与从被调函数返回
+
+	baseGlobals map[string]bool
+	requests    chan debugRequest
+	events      chan DebugEvent
+}
+
+// NewDebugger 为引擎创建调试器并接管虚拟机的 Context。
+// parent 结束（如客户端断开）时脚本随之终止；stopOnEntry 为 true 时在执行第一行前暂停
+func NewDebugger(parent context.Context, engine *LuaEngine, breakpoints []int, stopOnEntry bool) *Debugger {
+	ctx, cancel := context.WithCancel(parent)
+	d := &Debugger{
+		parent:      ctx,
+		cancel:      cancel,
+		engine:      engine,
+		breakpoints: map[int]bool{},
+		baseGlobals: map[string]bool{"store": true},
+		requests:    make(chan debugRequest),
+		events:      make(chan DebugEvent, 16),
+	}
+	for _, line := range breakpoints {
+		d.breakpoints[line] = true
+	}
+	if stopOnEntry {
+		d.mode = debugStepInto
+	}
+	// 引擎注册的函数与库不作为脚本的全局变量展示
+	engine.L.G.Global.ForEach(func(k, _ lua.LValue) {
+		d.baseGlobals[k.String()] = true
+	})
+	engine.L.SetContext(d)
+	return d
+}
+
+// Events 暂停与恢复事件；脚本结束后不再有新事件
+func (d *Debugger) Events() <-chan DebugEvent {
+	return d.events
+}
+
+// Deadline 实现 context.Context
+func (d *Debugger) Deadline() (time.Time, bool) {
+	return d.parent.Deadline()
+}
+
+// Err 实现 context.Context
+func (d *Debugger) Err() error {
+	return d.parent.Err()
+}
+
+// Value 实现 context.Context
+func (d *Debugger) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// Done 虚拟机在执行每条指令前调用：先执行调试钩子，再返回真正的 Done 通道
+func (d *Debugger) Done() <-chan struct{} {
+	if !d.inHook {
+		d.inHook = true
+		d.hook()
+		d.inHook = false
+	}
+	return d.parent.Done()
+}
+
+// hook 判断当前指令是否需要暂停
+func (d *Debugger) hook() {
+	L := d.engine.L
+	if d.parent.Err() != nil {
+		return
+	}
+	dbg, ok := L.GetStack(0)
+	if !ok {
+		return
+	}
+	fnValue, err := L.GetInfo("lf", dbg, lua.LNil)
+	fn, _ := fnValue.(*lua.LFunction)
+	if err != nil || fn == nil || dbg.CurrentLine <= 0 {
+		return
+	}
+	// 同一函数的同一行内只在有暂停请求时处理，避免每条指令都计算调用栈深度
+	sameLine := fn == d.lastFn && dbg.CurrentLine == d.lastLine
+	d.lastFn, d.lastLine = fn, dbg.CurrentLine
+	if sameLine && !d.pauseRequested.Load() {
+		return
+	}
+
+	depth := d.depth()
+	entered := true
+	if depth < len(d.lines) {
+		entered = d.lines[depth] != dbg.CurrentLine
+		d.lines = d.lines[:depth+1]
+	} else {
+		for len(d.lines) <= depth {
+			d.lines = append(d.lines, -1)
+		}
+	}
+	d.lines[depth] = dbg.CurrentLine
+
+	reason := ""
+	switch {
+	case d.pauseRequested.Load():
+		reason = DebugReasonPause
+	case !entered:
+		return
+	case d.mode == debugStepInto:
+		reason = DebugReasonStep
+		if d.stepDepth == 0 {
+			reason = DebugReasonEntry
+		}
+	case d.mode == debugStepOver && depth <= d.stepDepth, d.mode == debugStepOut && depth < d.stepDepth:
+		reason = DebugReasonStep
+	case fn.Proto.SourceName == debugMainSource && d.hasBreakpoint(dbg.CurrentLine):
+		reason = DebugReasonBreakpoint
+	default:
+		return
+	}
+	d.pauseRequested.Store(false)
+	d.pause(reason, fn.Proto.SourceName, dbg.CurrentLine, depth)
+}
+
+// depth 当前调用栈深度（与 GetStack 的层级一致）
+func (d *Debugger) depth() int {
+	n := 0
+	for {
+		if _, ok := d.engine.L.GetStack(n); !ok {
+			return n
+		}
+		n++
+	}
+}
+
+func (d *Debugger) hasBreakpoint(line int) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.breakpoints[line]
+}
+
+// pause 发出暂停事件并在虚拟机协程中处理调试命令，直到继续、单步、终止或超时
+func (d *Debugger) pause(reason, source string, line, depth int) {
+	d.setPaused(true)
+	defer d.setPaused(false)
+
+	event := DebugEvent{Type: "paused", Pause: &DebugPause{Reason: reason, Source: source, Line: line, Stack: d.stack()}}
+	select {
+	case d.events <- event:
+	case <-d.parent.Done():
+		return
+	}
+
+	timeout := time.NewTimer(DebugPauseTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case req := <-d.requests:
+			var reply debugReply
+			resume := true
+			switch req.command {
+			case "continue":
+				d.mode = debugRun
+			case "step_into":
+				d.mode = debugStepInto
+			case "step_over":
+				d.mode = debugStepOver
+			case "step_out":
+				d.mode = debugStepOut
+			case "variables":
+				resume = false
+				reply.value, reply.err = d.variables(req.frame)
+			case "evaluate":
+				resume = false
+				reply.value, reply.err = d.evaluate(req.frame, req.expression)
+			default:
+				resume = false
+				reply.err = fmt.Errorf("未知的调试命令: %s", req.command)
+			}
+			if resume {
+				d.stepDepth = depth
+				d.setPaused(false)
+			}
+			req.reply <- reply
+			if resume {
+				select {
+				case d.events <- DebugEvent{Type: "resumed"}:
+				case <-d.parent.Done():
+				}
+				return
+			}
+		case <-timeout.C:
+			d.Abort()
+			return
+		case <-d.parent.Done():
+			return
+		}
+	}
+}
+
+func (d *Debugger) setPaused(paused bool) {
+	d.mutex.Lock()
+	d.paused = paused
+	d.mutex.Unlock()
+}
+
+// Paused 当前是否处于暂停状态
+func (d *Debugger) Paused() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.paused
+}
+
+// SetBreakpoints 替换入口脚本的断点（行号从 1 开始），运行中也可以修改
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.breakpoints = map[int]bool{}
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// Pause 请求在执行下一条指令前暂停
+func (d *Debugger) Pause() {
+	d.pauseRequested.Store(true)
+}
+
+// Abort 终止脚本，虚拟机在下一条指令处抛出错误
+func (d *Debugger) Abort() {
+	d.aborted.Store(true)
+	d.cancel()
+}
+
+// Aborted 脚本是否因 Abort 或暂停超时而终止
+func (d *Debugger) Aborted() bool {
+	return d.aborted.Load()
+}
+
+// Continue 继续执行到下一个断点
+func (d *Debugger) Continue() error {
+	_, err := d.request(debugRequest{command: "continue"})
+	return err
+}
+
+// StepInto 执行到下一行，遇到函数调用时进入被调函数
+func (d *Debugger) StepInto() error {
+	_, err := d.request(debugRequest{command: "step_into"})
+	return err
+}
+
+// StepOver 执行到当前函数（或调用方）的下一行，不进入被调函数
+func (d *Debugger) StepOver() error {
+	_, err := d.request(debugRequest{command: "step_over"})
+	return err
+}
+
+// StepOut 执行到当前函数返回后调用方的下一行
+func (d *Debugger) StepOut() error {
+	_, err := d.request(debugRequest{command: "step_out"})
+	return err
+}
+
+// Variables 返回第 frame 层调用栈的局部变量、上值与脚本全局变量
+func (d *Debugger) Variables(frame int) (*DebugScope, error) {
+	v, err := d.request(debugRequest{command: "variables", frame: frame})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*DebugScope), nil
+}
+
+// Evaluate 在第 frame 层调用栈中对表达式求值。
+// 表达式可以读取该层的局部变量与上值；在独立环境中执行，对变量赋值不会影响脚本
+func (d *Debugger) Evaluate(frame int, expression string) (*DebugVariable, error) {
+	v, err := d.request(debugRequest{command: "evaluate", frame: frame, expression: expression})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*DebugVariable), nil
+}
+
+// request 把命令交给处于暂停状态的虚拟机协程执行并等待结果
+func (d *Debugger) request(req debugRequest) (interface{}, error) {
+	if d.parent.Err() != nil {
+		return nil, ErrDebugAborted
+	}
+	if !d.Paused() {
+		return nil, ErrDebugNotPaused
+	}
+	req.reply = make(chan debugReply, 1)
+	timeout := time.NewTimer(DebugCommandTimeout)
+	defer timeout.Stop()
+	select {
+	case d.requests <- req:
+	case <-d.parent.Done():
+		return nil, ErrDebugAborted
+	case <-timeout.C:
+		return nil, ErrDebugNotPaused
+	}
+	select {
+	case reply := <-req.reply:
+		return reply.value, reply.err
+	case <-d.parent.Done():
+		return nil, ErrDebugAborted
+	}
+}
+
+// stack 当前调用栈
+func (d *Debugger) stack() []DebugFrame {
+	L := d.engine.L
+	frames := []DebugFrame{}
+	for level := 0; ; level++ {
+		dbg, ok := L.GetStack(level)
+		if !ok {
+			return frames
+		}
+		fn, err := L.GetInfo("Slnf", dbg, lua.LNil)
+		if err != nil {
+			continue
+		}
+		frames = append(frames, DebugFrame{Level: level, Name: luaFrameName(L, dbg, fn), Source: dbg.Source, Line: dbg.CurrentLine, What: dbg.What})
+	}
+}
+
+// frameValues 第 frame 层的局部变量与上值，按声明顺序排列
+func (d *Debugger) frameValues(frame int) (locals, upvalues []DebugVariable, env map[string]lua.LValue, err error) {
+	L := d.engine.L
+	dbg, ok := L.GetStack(frame)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("调用栈第 %d 层不存在", frame)
+	}
+	fnValue, err := L.GetInfo("fu", dbg, lua.LNil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	env = map[string]lua.LValue{}
+	locals, upvalues = []DebugVariable{}, []DebugVariable{}
+	fn, ok := fnValue.(*lua.LFunction)
+	if !ok || fn.IsG {
+		return locals, upvalues, env, nil
+	}
+	for i := 1; i <= dbg.NUpvalues; i++ {
+		name, value := L.GetUpvalue(fn, i)
+		if name == "" {
+			continue
+		}
+		upvalues = append(upvalues, debugVariable(name, value))
+		env[name] = value
+	}
+	pc := debugFramePc(dbg)
+	for i := 1; ; i++ {
+		// GetLocal 只用于读取寄存器，变量名按 debugLocalName 的规则确定；(*temporary) 等内部寄存器不展示
+		_, value := L.GetLocal(dbg, i)
+		name := debugLocalName(fn.Proto, i, pc)
+		if name == "" {
+			break
+		}
+		locals = append(locals, debugVariable(name, value))
+		env[name] = value
+	}
+	return locals, upvalues, env, nil
+}
+
+// debugFramePc 帧正在执行的指令序号；gopher-lua 没有公开该字段，通过反射读取
+func debugFramePc(dbg *lua.Debug) int {
+	frame := reflect.ValueOf(dbg).Elem().FieldByName("frame")
+	if !frame.IsValid() || frame.IsNil() {
+		return -1
+	}
+	return int(frame.Elem().FieldByName("Pc").Int()) - 1
+}
+
+// debugLocalName 第 regno 个局部变量在 pc 处的名称。
+// gopher-lua 的 GetLocal 按 StartPc < pc 判断作用域，刚赋值的局部变量在下一行开始处不可见，
+// 这里按 Lua 的规则（StartPc <= pc < EndPc）查找
+func debugLocalName(proto *lua.FunctionProto, regno, pc int) string {
+	for _, local := range proto.DbgLocals {
+		if local.StartPc > pc {
+			break
+		}
+		if pc < local.EndPc {
+			regno--
+			if regno == 0 {
+				return local.Name
+			}
+		}
+	}
+	return ""
+}
+
+// variables 在虚拟机协程中收集变量
+func (d *Debugger) variables(frame int) (*DebugScope, error) {
+	locals, upvalues, _, err := d.frameValues(frame)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	values := map[string]lua.LValue{}
+	d.engine.L.G.Global.ForEach(func(k, v lua.LValue) {
+		name := k.String()
+		if !d.baseGlobals[name] {
+			names = append(names, name)
+			values[name] = v
+		}
+	})
+	sort.Strings(names)
+	globals := []DebugVariable{}
+	for _, name := range names {
+		globals = append(globals, debugVariable(name, values[name]))
+	}
+	return &DebugScope{Frame: frame, Locals: locals, Upvalues: upvalues, Globals: globals}, nil
+}
+
+// evaluate 在虚拟机协程中求值：表达式在新线程中执行，不会改动暂停中的寄存器；
+// 环境表依次查找局部变量、上值、全局变量。不是表达式时按语句执行
+func (d *Debugger) evaluate(frame int, expression string) (*DebugVariable, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, errors.New("表达式不能为空")
+	}
+	_, _, values, err := d.frameValues(frame)
+	if err != nil {
+		return nil, err
+	}
+	L := d.engine.L
+	co, cancel := L.NewThread()
+	if cancel != nil {
+		defer cancel()
+	}
+	ctx, stop := context.WithTimeout(d.parent, DebugEvalTimeout)
+	defer stop()
+	co.SetContext(ctx)
+
+	fn, err := co.LoadString("return " + expression)
+	if err != nil {
+		if fn, err = co.LoadString(expression); err != nil {
+			return nil, fmt.Errorf("表达式语法错误: %w", err)
+		}
+	}
+	env := co.NewTable()
+	for name, value := range values {
+		env.RawSetString(name, value)
+	}
+	meta := co.NewTable()
+	meta.RawSetString("__index", co.G.Global)
+	co.SetMetatable(env, meta)
+	fn.Env = env
+
+	co.Push(fn)
+	if err := co.PCall(0, lua.MultRet, nil); err != nil {
+		return nil, fmt.Errorf("求值失败: %w", err)
+	}
+	results := make([]lua.LValue, co.GetTop())
+	for i := range results {
+		results[i] = co.Get(i + 1)
+	}
+	switch len(results) {
+	case 0:
+		result := debugVariable(expression, lua.LNil)
+		return &result, nil
+	case 1:
+		result := debugVariable(expression, results[0])
+		return &result, nil
+	}
+	list := co.NewTable()
+	for _, v := range results {
+		list.Append(v)
+	}
+	result := debugVariable(expression, list)
+	result.Type = "multiple"
+	return &result, nil
+}
+
+func debugVariable(name string, v lua.LValue) DebugVariable {
+	return DebugVariable{Name: name, Type: v.Type().String(), Value: debugValue(v, debugValueDepth)}
+}
+
+// debugValue 把 Lua 值转换为可序列化的展示值；表按层数与元素数截断，可以处理循环引用
+func debugValue(v lua.LValue, depth int) interface{} {
+	switch val := v.(type) {
+	case *lua.LNilType:
+		return nil
+	case lua.LBool:
+		return bool(val)
+	case lua.LNumber:
+		return float64(val)
+	case lua.LString:
+		return string(val)
+	case *lua.LTable:
+		if depth <= 0 {
+			return fmt.Sprintf("table: %p", val)
+		}
+		n := val.Len()
+		if n > 0 && n == countTableKeys(val) {
+			arr := []interface{}{}
+			for i := 1; i <= n && i <= debugValueItems; i++ {
+				arr = append(arr, debugValue(val.RawGetInt(i), depth-1))
+			}
+			if n > debugValueItems {
+				arr = append(arr, fmt.Sprintf("... 共 %d 项", n))
+			}
+			return arr
+		}
+		m := map[string]interface{}{}
+		count := 0
+		val.ForEach(func(k, vv lua.LValue) {
+			count++
+			if count <= debugValueItems {
+				m[k.String()] = debugValue(vv, depth-1)
+			}
+		})
+		if count > debugValueItems {
+			m["..."] = fmt.Sprintf("共 %d 项", count)
+		}
+		return m
+	}
+	return v.String()
+}
+
+func countTableKeys(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(_, _ lua.LValue) { n++ })
+	return n
+}
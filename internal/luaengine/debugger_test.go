@@ -0,0 +1,139 @@
+package lua
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitPaused(t *testing.T, d *Debugger, line int, reason string) *DebugPause {
+	t.Helper()
+	select {
+	case ev := <-d.Events():
+		for ev.Type != "paused" {
+			ev = <-d.Events()
+		}
+		if ev.Pause.Line != line || ev.Pause.Reason != reason {
+			t.Fatalf("paused = %+v, want line %d (%s)", ev.Pause, line, reason)
+		}
+		return ev.Pause
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for pause at line %d", line)
+	}
+	return nil
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	script := `local base = 10
+function add(a, b)
+  local s = a + b
+  return s
+end
+function search_video(k)
+  local x = add(1, 2)
+  local y = x * base
+  return {{name = k .. y}}, nil
+end`
+	engine := NewLuaEngine(nil)
+	defer engine.Close()
+	d := NewDebugger(context.Background(), engine, []int{7}, false)
+
+	done := make(chan map[string]interface{})
+	go func() {
+		ret, err := engine.CallFunction(script, "search_video", "kw")
+		if err != nil {
+			t.Error(err)
+		}
+		done <- ret
+	}()
+
+	pause := waitPaused(t, d, 7, DebugReasonBreakpoint)
+	if pause.Stack[0].Name != "search_video" {
+		t.Fatalf("stack = %+v", pause.Stack)
+	}
+	scope, err := d.Variables(0)
+	if err != nil || len(scope.Locals) != 1 || scope.Locals[0].Value != "kw" || len(scope.Upvalues) != 1 || scope.Upvalues[0].Name != "base" {
+		t.Fatalf("scope = %+v, err = %v", scope, err)
+	}
+	if v, err := d.Evaluate(0, "k .. base"); err != nil || v.Value != "kw10" {
+		t.Fatalf("evaluate = %+v, err = %v", v, err)
+	}
+	// 求值中的赋值不影响脚本
+	if _, err := d.Evaluate(0, "k = 'changed'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.StepInto(); err != nil {
+		t.Fatal(err)
+	}
+	waitPaused(t, d, 3, DebugReasonStep)
+	if scope, _ := d.Variables(0); len(scope.Locals) != 2 || scope.Locals[1].Name != "b" {
+		t.Fatalf("add scope = %+v", scope)
+	}
+	if err := d.StepOut(); err != nil {
+		t.Fatal(err)
+	}
+	waitPaused(t, d, 8, DebugReasonStep)
+	if err := d.StepOver(); err != nil {
+		t.Fatal(err)
+	}
+	waitPaused(t, d, 9, DebugReasonStep)
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	ret := <-done
+	items := ret["data"].([]interface{})
+	if items[0].(map[string]interface{})["name"] != "kw30" {
+		t.Fatalf("ret = %v", ret)
+	}
+	if err := d.Continue(); err != ErrDebugNotPaused {
+		t.Fatalf("continue after finish: %v", err)
+	}
+}
+
+func TestDebuggerPauseAbort(t *testing.T) {
+	engine := NewLuaEngine(nil)
+	defer engine.Close()
+	d := NewDebugger(context.Background(), engine, nil, false)
+
+	done := make(chan error)
+	go func() {
+		_, err := engine.CallFunction("function search_video(k)\n  while true do end\nend", "search_video", "kw")
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	d.Pause()
+	waitPaused(t, d, 2, DebugReasonPause)
+	d.Abort()
+	select {
+	case err := <-done:
+		if err == nil || !d.Aborted() {
+			t.Fatalf("err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("abort timeout")
+	}
+}
+
+func TestDebuggerAbortJsEval(t *testing.T) {
+	engine := NewLuaEngine(nil)
+	defer engine.Close()
+	d := NewDebugger(context.Background(), engine, nil, false)
+
+	done := make(chan error)
+	go func() {
+		_, err := engine.CallFunction("function search_video(k)\n  js_eval('for (;;) {}')\n  while true do end\nend", "search_video", "kw")
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	d.Abort()
+	// 沙箱随脚本中断，不必等到 SandboxTimeout
+	select {
+	case err := <-done:
+		if err == nil || !d.Aborted() {
+			t.Fatalf("err = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("js_eval was not interrupted by abort")
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"video-crawler/internal/crawler"
 	"video-crawler/internal/logger"
+	"video-crawler/internal/scriptlib"
 )
 
 const (
@@ -37,8 +38,11 @@ type luaSelection struct{ sel *goquery.Selection }
 type LuaEngine struct {
 	L       *lua.LState
 	browser crawler.BrowserRequest
-	output  chan string  // 用于流式输出的通道
-	ctx     *gin.Context // 添加gin.Context支持
+	output  chan string           // 用于流式输出的通道
+	ctx     *gin.Context          // 添加gin.Context支持
+	modules map[string]lua.LValue // 已加载的共享模块（require 缓存）
+
+	parseTracer scriptlib.ParseTracer // 非 nil 时记录 HTML 解析与选择器调用
 }
 
 // NewLuaEngine 创建新的Lua引擎
@@ -48,6 +52,7 @@ func NewLuaEngine(browser crawler.BrowserRequest) *LuaEngine {
 		L:       L,
 		browser: browser,
 		output:  make(chan string, 100), // 缓冲通道，避免阻塞
+		modules: map[string]lua.LValue{},
 	}
 
 	// 注册所有函数到Lua
@@ -64,6 +69,7 @@ func NewLuaEngineWithContext(browser crawler.BrowserRequest, ctx *gin.Context) *
 		browser: browser,
 		output:  make(chan string, 100), // 缓冲通道，避免阻塞
 		ctx:     ctx,
+		modules: map[string]lua.LValue{},
 	}
 
 	// 注册所有函数到Lua
@@ -121,6 +127,10 @@ func (e *LuaEngine) registerFunctions() {
 	// 注册HTTP请求函数
 	e.L.SetGlobal("http_get", e.L.NewFunction(e.luaHttpGet))
 	e.L.SetGlobal("http_post", e.L.NewFunction(e.luaHttpPost))
+	e.L.SetGlobal("http_request", e.L.NewFunction(e.luaHttpRequest))
+	e.L.SetGlobal("http_batch", e.L.NewFunction(e.luaHttpBatch))
+	e.L.SetGlobal("http_post_form", e.L.NewFunction(e.luaHttpPostForm))
+	e.L.SetGlobal("http_post_multipart", e.L.NewFunction(e.luaHttpPostMultipart))
 	e.L.SetGlobal("set_headers", e.L.NewFunction(e.luaSetHeaders))
 	e.L.SetGlobal("set_cookies", e.L.NewFunction(e.luaSetCookies))
 	e.L.SetGlobal("set_user_agent", e.L.NewFunction(e.luaSetUserAgent))
@@ -129,7 +139,7 @@ func (e *LuaEngine) registerFunctions() {
 	e.L.SetGlobal("set_ua_2_current_request_ua", e.L.NewFunction(e.luaSetUA2CurrentRequestUA))
 
 	// 注册HTML解析函数（链式入口）
-	e.L.SetGlobal("parse_html", e.L.NewFunction(e.luaParseHtml))
+	e.L.SetGlobal("parse_html", e.L.NewFunction(e.traced("parse_html", e.luaParseHtml)))
 
 	// 注册工具函数
 	e.L.SetGlobal("print", e.L.NewFunction(e.luaPrint))
@@ -143,15 +153,24 @@ func (e *LuaEngine) registerFunctions() {
 	e.L.SetGlobal("json_encode", e.L.NewFunction(e.luaJsonEncode))
 	e.L.SetGlobal("json_decode", e.L.NewFunction(e.luaJsonDecode))
 
-	// URL、Unicode 和 Base64 库
+	// 在 JS 沙箱中执行页面混淆代码
+	e.L.SetGlobal("js_eval", e.L.NewFunction(e.luaJsEval))
+	e.L.SetGlobal("js_call", e.L.NewFunction(e.luaJsCall))
+
+	// URL、Unicode、Base64、加解密、正则与 JSONPath 库
 	e.L.SetGlobal("url", e.createUrlLibrary())
 	e.L.SetGlobal("unicode", e.createUnicodeLibrary())
 	e.L.SetGlobal("base64", e.createBase64Library())
+	e.L.SetGlobal("crypto", e.createCryptoLibrary())
+	e.L.SetGlobal("regex", e.createRegexLibrary())
+	e.L.SetGlobal("jsonpath", e.createJSONPathLibrary())
+
+	// 只能加载共享模块的 require
+	e.L.SetGlobal("require", e.L.NewFunction(e.luaRequire))
 
 	// 禁用危险的系统函数，并提供禁用信息
 	e.L.SetGlobal("io", e.createDisabledTable("io"))
 	e.L.SetGlobal("package", e.createDisabledTable("package"))
-	e.L.SetGlobal("require", e.L.NewFunction(e.luaDisabledFunction("require")))
 	e.L.SetGlobal("dofile", e.L.NewFunction(e.luaDisabledFunction("dofile")))
 	e.L.SetGlobal("loadfile", e.L.NewFunction(e.luaDisabledFunction("loadfile")))
 
@@ -167,8 +186,10 @@ func (e *LuaEngine) registerGoqueryTypes() {
 	// Document methods
 	mtDoc := e.L.NewTypeMetatable(mtGoqueryDocument)
 	e.L.SetField(mtDoc, "__index", e.L.SetFuncs(e.L.NewTable(), map[string]lua.LGFunction{
-		"select":     e.luaSelect,
-		"select_one": e.luaSelectOne,
+		"select":     e.traced("select", e.luaSelect),
+		"select_one": e.traced("select_one", e.luaSelectOne),
+		"xpath":      e.traced("xpath", e.luaXPath),
+		"xpath_one":  e.traced("xpath_one", e.luaXPathOne),
 		"html":       e.luaHtml,
 		"text":       e.luaText,
 	}))
@@ -176,8 +197,10 @@ func (e *LuaEngine) registerGoqueryTypes() {
 	// Selection methods
 	mtSel := e.L.NewTypeMetatable(mtGoquerySelection)
 	e.L.SetField(mtSel, "__index", e.L.SetFuncs(e.L.NewTable(), map[string]lua.LGFunction{
-		"select":     e.luaSelect,
-		"select_one": e.luaSelectOne,
+		"select":     e.traced("select", e.luaSelect),
+		"select_one": e.traced("select_one", e.luaSelectOne),
+		"xpath":      e.traced("xpath", e.luaXPath),
+		"xpath_one":  e.traced("xpath_one", e.luaXPathOne),
 		"first":      e.luaFirst,
 		"parent":     e.luaParent,
 		"children":   e.luaChildren,
@@ -569,6 +592,57 @@ func (e *LuaEngine) luaSelectOne(L *lua.LState) int {
 	return 2
 }
 
+// luaXPath 在 document/selection 上执行 XPath 查询，返回可继续链式调用的 selection
+func (e *LuaEngine) luaXPath(L *lua.LState) int {
+	expr := L.CheckString(2)
+	sel, ok := e.getSel(L)
+	if !ok {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("invalid context for xpath"))
+		return 2
+	}
+	selection, err := scriptlib.XPath(sel, expr)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	udSel := L.NewUserData()
+	udSel.Value = &luaSelection{sel: selection}
+	L.SetMetatable(udSel, L.GetTypeMetatable(mtGoquerySelection))
+	L.Push(udSel)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaXPathOne 返回 XPath 查询的第一个节点，未找到时与 select_one 一致返回 nil 和错误信息
+func (e *LuaEngine) luaXPathOne(L *lua.LState) int {
+	expr := L.CheckString(2)
+	sel, ok := e.getSel(L)
+	if !ok {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("invalid context for xpath_one"))
+		return 2
+	}
+	selection, err := scriptlib.XPath(sel, expr)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	if selection.Length() == 0 {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("no element found"))
+		return 2
+	}
+	udSel := L.NewUserData()
+	udSel.Value = &luaSelection{sel: selection.First()}
+	L.SetMetatable(udSel, L.GetTypeMetatable(mtGoquerySelection))
+	L.Push(udSel)
+	L.Push(lua.LNil)
+	return 2
+}
+
 // luaAttr Lua中的attr函数
 func (e *LuaEngine) luaAttr(L *lua.LState) int {
 	name := L.CheckString(2)
@@ -935,18 +1009,20 @@ func reflectToFloat64(v interface{}) float64 {
 }
 
 // Execute 执行Lua脚本，返回顶层 return 的表（map[string]interface{}）。无返回或非表时返回空map。
+// 编译或执行失败时错误链中包含 *scriptlib.ScriptError（行号、调用栈与源码片段）。
 func (e *LuaEngine) Execute(script string) (map[string]interface{}, error) {
 	L := e.L
 	fn, err := L.LoadString(script)
 	if err != nil {
-		return nil, fmt.Errorf("compile error: %w", err)
+		return nil, fmt.Errorf("compile error: %w", luaScriptError(err, nil, script))
 	}
 	// 将编译后的函数压栈
 	L.Push(fn)
 	base := L.GetTop() - 1 // 函数压栈后，base 为函数之前的位置
-	// 固定接收 1 个返回值
-	if err := L.PCall(0, 1, nil); err != nil {
-		return nil, fmt.Errorf("execute error: %w", err)
+	// 固定接收 1 个返回值；出错时由错误处理函数记录调用栈
+	var frames []scriptlib.ScriptStackFrame
+	if err := L.PCall(0, 1, e.errorHandler(&frames)); err != nil {
+		return nil, fmt.Errorf("execute error: %w", luaScriptError(err, frames, script))
 	}
 	top := L.GetTop()
 	nret := top - base
@@ -966,6 +1042,37 @@ func (e *LuaEngine) Execute(script string) (map[string]interface{}, error) {
 	return result, nil
 }
 
+// CallFunction 执行脚本后按名称调用全局函数，参数以原生 Lua 值传入，不拼接进脚本文本。
+// *scriptlib.CallContext 类型的参数转换为 ctx 表（含 store 与 log）。
+// 入口函数约定返回 (data, err)，结果为 {data, err}，与包装脚本 return { data = ..., err = ... } 一致
+func (e *LuaEngine) CallFunction(script string, funcName string, args ...interface{}) (map[string]interface{}, error) {
+	L := e.L
+	// 先转换参数：ctx 中的全局 store 需要在脚本主体执行前可用
+	luaArgs := make([]lua.LValue, len(args))
+	for i, arg := range args {
+		if cc, ok := arg.(*scriptlib.CallContext); ok {
+			luaArgs[i] = e.contextTable(cc)
+			continue
+		}
+		luaArgs[i] = interfaceToLua(L, arg)
+	}
+	if _, err := e.Execute(script); err != nil {
+		return nil, err
+	}
+	fn, ok := L.GetGlobal(funcName).(*lua.LFunction)
+	if !ok {
+		return nil, fmt.Errorf("execute error: 函数 %s 未定义", funcName)
+	}
+	base := L.GetTop()
+	var frames []scriptlib.ScriptStackFrame
+	if err := L.CallByParam(lua.P{Fn: fn, NRet: 2, Protect: true, Handler: e.errorHandler(&frames)}, luaArgs...); err != nil {
+		return nil, fmt.Errorf("execute error: %w", luaScriptError(err, frames, script))
+	}
+	data, errValue := luaToGo(L.Get(base+1)), luaToGo(L.Get(base+2))
+	L.SetTop(base)
+	return map[string]interface{}{"data": data, "err": errValue}, nil
+}
+
 // ExecuteFile 执行Lua文件，返回顶层 return 的表（map[string]interface{}）。
 func (e *LuaEngine) ExecuteFile(filename string) (map[string]interface{}, error) {
 	L := e.L
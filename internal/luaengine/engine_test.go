@@ -0,0 +1,118 @@
+package lua
+
+import (
+	"testing"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// 参数以原生值传入，引号、换行、Lua 长字符串与注释符号都不会破坏或注入脚本
+var hostileKeywords = []string{
+	`"); os.exit(1) --`,
+	"a\"b'c\\d\ne",
+	`]]..error("injected")..[[`,
+	"\\u0041 %s %q",
+}
+
+func TestCallFunction(t *testing.T) {
+	script := `
+function search_video(keyword)
+  return {keyword = keyword, len = #keyword}, nil
+end
+function fail(keyword)
+  return nil, "bad: " .. keyword
+end`
+	for _, keyword := range hostileKeywords {
+		e := NewLuaEngine(nil)
+		ret, err := e.CallFunction(script, "search_video", keyword)
+		e.Close()
+		if err != nil {
+			t.Fatalf("%q: %v", keyword, err)
+		}
+		data, _ := ret["data"].(map[string]interface{})
+		if data["keyword"] != keyword || data["len"] != float64(len(keyword)) {
+			t.Fatalf("%q: data = %v", keyword, ret["data"])
+		}
+	}
+
+	e := NewLuaEngine(nil)
+	defer e.Close()
+	ret, err := e.CallFunction(script, "fail", `"x"`)
+	if err != nil || ret["data"] != nil || ret["err"] != `bad: "x"` {
+		t.Fatalf("fail = %v, %v", ret, err)
+	}
+	if _, err := e.CallFunction(script, "missing"); err == nil {
+		t.Fatal("missing function should fail")
+	}
+}
+
+func TestCallFunctionContext(t *testing.T) {
+	cc := scriptlib.NewCallContext("search_video", map[string]string{"page": "2", "filter_year": "2024", "locale": "en-US"})
+	cc.Source = scriptlib.SourceInfo{ID: "s1", Settings: map[string]interface{}{"api": "https://a.com"}}
+	cc.Store = scriptlib.NewMemoryStore()
+	script := `
+function search_video(keyword, ctx)
+  local n = (ctx.store.get("n") or 0) + 1
+  assert(ctx.store.set("n", n, 60))
+  ctx.log("page", ctx.page)
+  return {keyword = keyword, page = ctx.page, year = ctx.filters.year, locale = ctx.locale, api = ctx.source.settings.api, n = n}, nil
+end
+function old(keyword) return keyword, nil end`
+	for i := 1; i <= 2; i++ {
+		e := NewLuaEngine(nil)
+		ret, err := e.CallFunction(script, "search_video", "k", cc)
+		e.Close()
+		data, _ := ret["data"].(map[string]interface{})
+		if err != nil || data["page"] != float64(2) || data["year"] != "2024" || data["locale"] != "en-US" || data["api"] != "https://a.com" || data["n"] != float64(i) {
+			t.Fatalf("ctx call %d = %v, %v", i, ret, err)
+		}
+	}
+
+	// 只声明一个参数的旧脚本不受影响
+	e := NewLuaEngine(nil)
+	defer e.Close()
+	if ret, err := e.CallFunction(script, "old", "k", cc); err != nil || ret["data"] != "k" {
+		t.Fatalf("old = %v, %v", ret, err)
+	}
+}
+
+func TestCallFunctionScriptError(t *testing.T) {
+	e := NewLuaEngine(nil)
+	defer e.Close()
+	script := "local x = 1\nfunction add(a)\n  return a.b.c\nend\nfunction search_video(k)\n  return add({}), nil\nend"
+	_, err := e.CallFunction(script, "search_video", "kw")
+	se, ok := scriptlib.AsScriptError(err)
+	if !ok || se.Line != 3 || se.Message != "attempt to index a non-table object(nil) with key 'c'" || len(se.Stack) != 2 {
+		t.Fatalf("runtime error = %+v", err)
+	}
+	if se.Stack[0].Function != "add" || se.Stack[1].Function != "search_video" || se.Stack[1].Line != 6 || len(se.Snippet) != 5 || !se.Snippet[2].Current {
+		t.Fatalf("stack = %+v, snippet = %+v", se.Stack, se.Snippet)
+	}
+
+	_, err = e.CallFunction("function search_video(k)\n  local y = = 2\nend", "search_video", "kw")
+	if se, ok := scriptlib.AsScriptError(err); !ok || se.Kind != scriptlib.ScriptErrorCompile || se.Line != 2 || se.Column != 13 {
+		t.Fatalf("syntax error = %+v", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	script := "function search_video(keyword, ctx)\n  local f = io.open(keyword)\n  error('x')\n  return foo\nend\nfunction get_video_detail(url) return os.execute(url) end\nlocal function get_play_video_detail(url) end"
+	r := Lint(script)
+	lines := map[string][]int{}
+	for _, d := range r.Diagnostics {
+		lines[d.Code] = append(lines[d.Code], d.Line)
+	}
+	if r.Valid || len(lines[scriptlib.LintDisabledGlobal]) != 2 || lines[scriptlib.LintUnreachable][0] != 4 ||
+		lines[scriptlib.LintUndefinedGlobal][0] != 4 || lines[scriptlib.LintEntrySignature][0] != 7 {
+		t.Fatalf("lint = %+v", r.Diagnostics)
+	}
+	if r = Lint("function search_video(k)\n  return return\nend"); r.Errors != 1 || r.Diagnostics[0].Line != 2 || r.Diagnostics[0].Code != scriptlib.LintSyntax {
+		t.Fatalf("syntax lint = %+v", r.Diagnostics)
+	}
+	// store 在调用入口函数时注册，不是未定义的全局变量
+	for _, d := range Lint("function search_video(k)\n  store.set('k', k)\n  return {}\nend").Diagnostics {
+		if d.Code == scriptlib.LintUndefinedGlobal {
+			t.Fatalf("store lint = %+v", d)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// luaPositionPrefix 运行时错误信息开头的位置前缀，如 "<string>:3: " 或 "module:util:12: "
+var luaPositionPrefix = regexp.MustCompile(`^(?:<string>|module:[^:\s]+|\[G\]):(?:\d+:)?\s*`)
+
+// errorHandler 错误处理函数：在调用栈展开前记录各层位置，错误对象原样返回
+func (e *LuaEngine) errorHandler(frames *[]scriptlib.ScriptStackFrame) *lua.LFunction {
+	return e.L.NewFunction(func(L *lua.LState) int {
+		*frames = luaStackFrames(L)
+		L.Push(L.Get(1))
+		return 1
+	})
+}
+
+// luaStackFrames 当前调用栈中的 Lua 帧（跳过 Go 函数），从最内层开始
+func luaStackFrames(L *lua.LState) []scriptlib.ScriptStackFrame {
+	frames := []scriptlib.ScriptStackFrame{}
+	for level := 0; ; level++ {
+		dbg, ok := L.GetStack(level)
+		if !ok {
+			return frames
+		}
+		fn, err := L.GetInfo("Slnf", dbg, lua.LNil)
+		if err != nil || dbg.Source == "" {
+			continue
+		}
+		frames = append(frames, scriptlib.ScriptStackFrame{
+			Function: luaFrameName(L, dbg, fn),
+			Source:   luaSourceName(dbg.Source),
+			Line:     dbg.CurrentLine,
+		})
+	}
+}
+
+// luaFrameName 帧的函数名：没有调用方的帧都被命名为 main chunk，由 Go 直接调用的入口函数按全局变量名显示
+func luaFrameName(L *lua.LState, dbg *lua.Debug, fn lua.LValue) string {
+	name := dbg.Name
+	if name == "" || (dbg.What == "main" && dbg.LineDefined > 0) {
+		name = luaGlobalName(L, fn)
+	}
+	if name == "" {
+		name = "?"
+		if dbg.LineDefined > 0 {
+			name = fmt.Sprintf("function@%d", dbg.LineDefined)
+		}
+	}
+	return name
+}
+
+// luaGlobalName 查找引用该函数的全局变量名
+func luaGlobalName(L *lua.LState, fn lua.LValue) string {
+	name := ""
+	L.G.Global.ForEach(func(k, v lua.LValue) {
+		if name == "" && v == fn {
+			name = k.String()
+		}
+	})
+	return name
+}
+
+// luaSourceName 将引擎中的源码名转换为 ScriptStackFrame.Source
+func luaSourceName(source string) string {
+	if source == debugMainSource {
+		return scriptlib.ScriptSourceMain
+	}
+	return source
+}
+
+// luaScriptError 将编译或执行错误转换为结构化错误；frames 为 errorHandler 记录的调用栈
+func luaScriptError(err error, frames []scriptlib.ScriptStackFrame, script string) *scriptlib.ScriptError {
+	var apiErr *lua.ApiError
+	if !errors.As(err, &apiErr) {
+		se := scriptlib.NewScriptError("lua", scriptlib.ScriptErrorRuntime, err.Error(), err.Error())
+		if frames != nil {
+			se.Stack = frames
+		}
+		return se.WithSnippet(script)
+	}
+
+	var parseErr *parse.Error
+	var compileErr *lua.CompileError
+	switch {
+	case errors.As(apiErr.Cause, &parseErr):
+		message := parseErr.Message
+		if parseErr.Token != "" && parseErr.Pos.Line > 0 {
+			message = fmt.Sprintf("%s near '%s'", parseErr.Message, parseErr.Token)
+		}
+		se := scriptlib.NewScriptError("lua", scriptlib.ScriptErrorCompile, message, err.Error())
+		se.Line, se.Column = parseErr.Pos.Line, parseErr.Pos.Column
+		if se.Line <= 0 {
+			// 意外的文件结尾，定位到最后一个非空行
+			se.Line, se.Column = strings.Count(strings.TrimRight(script, "\r\n"), "\n")+1, 0
+		}
+		se.Stack = append(se.Stack, scriptlib.ScriptStackFrame{Function: "main chunk", Source: scriptlib.ScriptSourceMain, Line: se.Line, Column: se.Column})
+		return se.WithSnippet(script)
+	case errors.As(apiErr.Cause, &compileErr):
+		se := scriptlib.NewScriptError("lua", scriptlib.ScriptErrorCompile, compileErr.Message, err.Error())
+		se.Line = compileErr.Line
+		se.Stack = append(se.Stack, scriptlib.ScriptStackFrame{Function: "main chunk", Source: scriptlib.ScriptSourceMain, Line: se.Line})
+		return se.WithSnippet(script)
+	}
+
+	// 运行时错误：去掉位置前缀，表类型的错误对象优先取 message 字段
+	message := apiErr.Object.String()
+	if tbl, ok := apiErr.Object.(*lua.LTable); ok {
+		if m := tbl.RawGetString("message"); m != lua.LNil {
+			message = m.String()
+		}
+	}
+	message = luaPositionPrefix.ReplaceAllString(message, "")
+	se := scriptlib.NewScriptError("lua", scriptlib.ScriptErrorRuntime, message, err.Error())
+	if frames != nil {
+		se.Stack = frames
+	}
+	return se.WithSnippet(script)
+}
@@ -0,0 +1,402 @@
+package lua
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/scriptlib"
+)
+
+// luaHttpRequest Lua中的http_request函数：任意方法、单次请求选项、二进制安全的请求/响应体
+//
+//	http_request{method=, url=, headers=, query=, body=, form=, files=, json=, timeout=, follow_redirects=, proxy=} -> response, err
+//	http_request(url, {...}) -> response, err
+//
+// body / form / json 三选一，带 files 时 form 与 files 以 multipart/form-data 提交；timeout 单位为毫秒。
+func (e *LuaEngine) luaHttpRequest(L *lua.LState) int {
+	var opts *lua.LTable
+	rawURL := ""
+	if L.Get(1).Type() == lua.LTString {
+		rawURL = L.CheckString(1)
+		opts = L.OptTable(2, L.NewTable())
+	} else {
+		opts = L.CheckTable(1)
+	}
+	req, err := parseLuaHttpRequest(rawURL, opts)
+	if err != nil {
+		return luaHttpError(L, err.Error())
+	}
+	result := e.doHttpRequest(req)
+	if result.err != nil {
+		return luaHttpError(L, result.err.Error())
+	}
+	L.Push(e.httpResponseTable(L, result.response, result.body, result.ttfb, result.total))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaHttpBatch Lua中的http_batch函数：在 Go 侧并发执行多个请求，Lua 虚拟机只在全部完成后继续
+//
+//	http_batch(requests[, {concurrency=N}]) -> responses, err
+//
+// requests 的每一项是 URL 字符串或 http_request 的选项表；responses 与输入顺序一致，
+// 单个请求失败时对应项为 {ok=false, status_code=0, error=错误信息}。
+func (e *LuaEngine) luaHttpBatch(L *lua.LState) int {
+	list := L.CheckTable(1)
+	concurrency := 0
+	if opts := L.OptTable(2, nil); opts != nil {
+		if n, ok := opts.RawGetString("concurrency").(lua.LNumber); ok {
+			concurrency = int(n)
+		}
+	}
+
+	requests := make([]luaHttpRequestSpec, list.Len())
+	for i := range requests {
+		var err error
+		switch item := list.RawGetInt(i + 1).(type) {
+		case lua.LString:
+			requests[i], err = parseLuaHttpRequest(string(item), L.NewTable())
+		case *lua.LTable:
+			requests[i], err = parseLuaHttpRequest("", item)
+		default:
+			err = fmt.Errorf("must be a url or an options table")
+		}
+		if err != nil {
+			return luaHttpError(L, fmt.Sprintf("requests[%d]: %v", i+1, err))
+		}
+	}
+
+	results := make([]luaHttpResult, len(requests))
+	scriptlib.Parallel(len(requests), concurrency, func(i int) {
+		results[i] = e.doHttpRequest(requests[i])
+	})
+
+	responses := L.CreateTable(len(results), 0)
+	for _, r := range results {
+		if r.err != nil {
+			item := L.CreateTable(0, 3)
+			item.RawSetString("ok", lua.LFalse)
+			item.RawSetString("status_code", lua.LNumber(0))
+			item.RawSetString("error", lua.LString(r.err.Error()))
+			responses.Append(item)
+			continue
+		}
+		responses.Append(e.httpResponseTable(L, r.response, r.body, r.ttfb, r.total))
+	}
+	L.Push(responses)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaHttpRequestSpec 已解析的请求，执行时不再访问 Lua 虚拟机
+type luaHttpRequestSpec struct {
+	method  string
+	url     string
+	body    []byte
+	options crawler.RequestOptions
+}
+
+// luaHttpResult 已读取响应体的请求结果
+type luaHttpResult struct {
+	response *http.Response
+	body     []byte
+	ttfb     time.Duration
+	total    time.Duration
+	err      error
+}
+
+// parseLuaHttpRequest 解析 http_request 选项表，rawURL 为空时取 opts.url
+func parseLuaHttpRequest(rawURL string, opts *lua.LTable) (luaHttpRequestSpec, error) {
+	if rawURL == "" {
+		rawURL = lua.LVAsString(opts.RawGetString("url"))
+	}
+	if rawURL == "" {
+		return luaHttpRequestSpec{}, fmt.Errorf("url is required")
+	}
+
+	// 查询参数追加到已有查询串之后，不重新编码原有部分
+	if q := opts.RawGetString("query"); q != lua.LNil {
+		tbl, ok := q.(*lua.LTable)
+		if !ok {
+			return luaHttpRequestSpec{}, fmt.Errorf("query must be a table")
+		}
+		if encoded := luaTableToValues(tbl).Encode(); encoded != "" {
+			if strings.Contains(rawURL, "?") {
+				rawURL += "&" + encoded
+			} else {
+				rawURL += "?" + encoded
+			}
+		}
+	}
+
+	headers := map[string]string{}
+	if h := opts.RawGetString("headers"); h != lua.LNil {
+		tbl, ok := h.(*lua.LTable)
+		if !ok {
+			return luaHttpRequestSpec{}, fmt.Errorf("headers must be a table")
+		}
+		tbl.ForEach(func(k, v lua.LValue) {
+			headers[k.String()] = v.String()
+		})
+	}
+
+	body, contentType, err := luaRequestBody(opts)
+	if err != nil {
+		return luaHttpRequestSpec{}, err
+	}
+	if contentType != "" && !hasHeader(headers, "Content-Type") {
+		headers["Content-Type"] = contentType
+	}
+
+	method := strings.ToUpper(lua.LVAsString(opts.RawGetString("method")))
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+
+	options := crawler.RequestOptions{
+		Headers: headers,
+		Proxy:   lua.LVAsString(opts.RawGetString("proxy")),
+	}
+	if t, ok := opts.RawGetString("timeout").(lua.LNumber); ok && t > 0 {
+		options.Timeout = time.Duration(float64(t) * float64(time.Millisecond))
+	}
+	if f, ok := opts.RawGetString("follow_redirects").(lua.LBool); ok {
+		follow := bool(f)
+		options.FollowRedirects = &follow
+	}
+	return luaHttpRequestSpec{method: method, url: rawURL, body: body, options: options}, nil
+}
+
+// doHttpRequest 执行请求并读取响应体，可以在多个协程中并发调用
+func (e *LuaEngine) doHttpRequest(req luaHttpRequestSpec) luaHttpResult {
+	start := time.Now()
+	response, err := e.browser.DoWithOptions(req.method, req.url, req.body, req.options)
+	if err != nil {
+		return luaHttpResult{err: err}
+	}
+	defer response.Body.Close()
+	ttfb := time.Since(start)
+	data, err := readDecompressedBody(response)
+	if err != nil {
+		return luaHttpResult{err: fmt.Errorf("failed to read response body: %v", err)}
+	}
+	return luaHttpResult{response: response, body: data, ttfb: ttfb, total: time.Since(start)}
+}
+
+// httpResponseTable 构造 http_request 的响应表
+func (e *LuaEngine) httpResponseTable(L *lua.LState, response *http.Response, body []byte, ttfb, total time.Duration) *lua.LTable {
+	result := L.CreateTable(0, 10)
+	result.RawSetString("status_code", lua.LNumber(response.StatusCode))
+	result.RawSetString("status", lua.LString(response.Status))
+	result.RawSetString("ok", lua.LBool(response.StatusCode >= 200 && response.StatusCode < 300))
+	// 跟随重定向后的最终地址
+	result.RawSetString("url", lua.LString(response.Request.URL.String()))
+	// Lua 字符串可以包含任意字节，二进制响应体原样返回
+	result.RawSetString("body", lua.LString(body))
+
+	// headers 与 http_get 一致只取首个值；headers_all 保留全部值（如多个 Set-Cookie）
+	headers := L.CreateTable(0, len(response.Header))
+	headersAll := L.CreateTable(0, len(response.Header))
+	for key, values := range response.Header {
+		if len(values) == 0 {
+			continue
+		}
+		headers.RawSetString(key, lua.LString(values[0]))
+		list := L.CreateTable(len(values), 0)
+		for _, v := range values {
+			list.Append(lua.LString(v))
+		}
+		headersAll.RawSetString(key, list)
+	}
+	result.RawSetString("headers", headers)
+	result.RawSetString("headers_all", headersAll)
+
+	// cookies 为 name -> value；cookie_list 保留完整属性
+	cookies := response.Cookies()
+	cookieMap := L.CreateTable(0, len(cookies))
+	cookieList := L.CreateTable(len(cookies), 0)
+	for _, c := range cookies {
+		cookieMap.RawSetString(c.Name, lua.LString(c.Value))
+		item := L.CreateTable(0, 7)
+		item.RawSetString("name", lua.LString(c.Name))
+		item.RawSetString("value", lua.LString(c.Value))
+		item.RawSetString("domain", lua.LString(c.Domain))
+		item.RawSetString("path", lua.LString(c.Path))
+		if !c.Expires.IsZero() {
+			item.RawSetString("expires", lua.LNumber(c.Expires.Unix()))
+		}
+		item.RawSetString("http_only", lua.LBool(c.HttpOnly))
+		item.RawSetString("secure", lua.LBool(c.Secure))
+		cookieList.Append(item)
+	}
+	result.RawSetString("cookies", cookieMap)
+	result.RawSetString("cookie_list", cookieList)
+
+	// 耗时（毫秒）：ttfb 为收到响应头的时间，total 含读取响应体
+	timing := L.CreateTable(0, 2)
+	timing.RawSetString("ttfb_ms", lua.LNumber(float64(ttfb.Microseconds())/1000))
+	timing.RawSetString("total_ms", lua.LNumber(float64(total.Microseconds())/1000))
+	result.RawSetString("timing", timing)
+	return result
+}
+
+// luaHttpPostForm Lua中的http_post_form函数：以 application/x-www-form-urlencoded 提交表单
+// http_post_form(url, form) -> response, err；form 的数组值展开为重复的键
+func (e *LuaEngine) luaHttpPostForm(L *lua.LState) int {
+	rawURL := L.CheckString(1)
+	form := luaTableToValues(L.CheckTable(2))
+	start := time.Now()
+	response, err := e.browser.PostForm(rawURL, form)
+	return e.pushHttpResponse(L, response, err, start)
+}
+
+// luaHttpPostMultipart Lua中的http_post_multipart函数：以 multipart/form-data 提交字段与文件
+// http_post_multipart(url, fields, files) -> response, err
+// files 为数组，每项 {name=字段名, filename=, content=原始字节, content_type=}
+func (e *LuaEngine) luaHttpPostMultipart(L *lua.LState) int {
+	rawURL := L.CheckString(1)
+	fields := luaTableToValues(L.OptTable(2, L.NewTable()))
+	files, err := luaMultipartFiles(L.OptTable(3, L.NewTable()))
+	if err != nil {
+		return luaHttpError(L, err.Error())
+	}
+	start := time.Now()
+	response, err := e.browser.PostMultipart(rawURL, fields, files)
+	return e.pushHttpResponse(L, response, err, start)
+}
+
+// pushHttpResponse 读取响应并压入 response, err
+func (e *LuaEngine) pushHttpResponse(L *lua.LState, response *http.Response, err error, start time.Time) int {
+	if err != nil {
+		return luaHttpError(L, err.Error())
+	}
+	defer response.Body.Close()
+	ttfb := time.Since(start)
+	data, err := readDecompressedBody(response)
+	if err != nil {
+		return luaHttpError(L, fmt.Sprintf("failed to read response body: %v", err))
+	}
+	L.Push(e.httpResponseTable(L, response, data, ttfb, time.Since(start)))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaMultipartFiles 解析文件数组 {{name=, filename=, content=, content_type=}, ...}
+func luaMultipartFiles(tbl *lua.LTable) ([]crawler.MultipartFile, error) {
+	var files []crawler.MultipartFile
+	for i := 1; i <= tbl.Len(); i++ {
+		item, ok := tbl.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			return nil, fmt.Errorf("files[%d] must be a table", i)
+		}
+		file := crawler.MultipartFile{
+			Field:       lua.LVAsString(item.RawGetString("name")),
+			FileName:    lua.LVAsString(item.RawGetString("filename")),
+			ContentType: lua.LVAsString(item.RawGetString("content_type")),
+			Data:        []byte(lua.LVAsString(item.RawGetString("content"))),
+		}
+		if file.Field == "" {
+			return nil, fmt.Errorf("files[%d].name is required", i)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// luaRequestBody 根据 body / form / files / json 选项生成请求体与默认 Content-Type
+func luaRequestBody(opts *lua.LTable) ([]byte, string, error) {
+	body := opts.RawGetString("body")
+	form := opts.RawGetString("form")
+	jsonValue := opts.RawGetString("json")
+	if files := opts.RawGetString("files"); files != lua.LNil {
+		if body != lua.LNil || jsonValue != lua.LNil {
+			return nil, "", fmt.Errorf("files can only be combined with form")
+		}
+		filesTable, ok := files.(*lua.LTable)
+		if !ok {
+			return nil, "", fmt.Errorf("files must be a table")
+		}
+		fields := url.Values{}
+		if form != lua.LNil {
+			formTable, ok := form.(*lua.LTable)
+			if !ok {
+				return nil, "", fmt.Errorf("form must be a table")
+			}
+			fields = luaTableToValues(formTable)
+		}
+		parts, err := luaMultipartFiles(filesTable)
+		if err != nil {
+			return nil, "", err
+		}
+		return crawler.EncodeMultipart(fields, parts)
+	}
+
+	set := 0
+	for _, v := range []lua.LValue{body, form, jsonValue} {
+		if v != lua.LNil {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, "", fmt.Errorf("body, form and json are mutually exclusive")
+	}
+
+	switch {
+	case body != lua.LNil:
+		return []byte(lua.LVAsString(body)), "", nil
+	case form != lua.LNil:
+		tbl, ok := form.(*lua.LTable)
+		if !ok {
+			return nil, "", fmt.Errorf("form must be a table")
+		}
+		return []byte(luaTableToValues(tbl).Encode()), "application/x-www-form-urlencoded", nil
+	case jsonValue != lua.LNil:
+		data, err := json.Marshal(luaToInterfaceJSON(jsonValue))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode json: %v", err)
+		}
+		return data, "application/json", nil
+	}
+	return nil, "", nil
+}
+
+// luaTableToValues 将 Lua 表转换为 url.Values，数组值展开为重复的键
+func luaTableToValues(tbl *lua.LTable) url.Values {
+	values := url.Values{}
+	tbl.ForEach(func(k, v lua.LValue) {
+		key := k.String()
+		if list, ok := v.(*lua.LTable); ok {
+			list.ForEach(func(_, item lua.LValue) {
+				values.Add(key, item.String())
+			})
+			return
+		}
+		values.Add(key, v.String())
+	})
+	return values
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func luaHttpError(L *lua.LState, msg string) int {
+	L.Push(lua.LNil)
+	L.Push(lua.LString(msg))
+	return 2
+}
@@ -0,0 +1,274 @@
+package lua
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"video-crawler/internal/crawler"
+)
+
+// parsedForm 测试服务端实际解析到的表单
+type parsedForm struct {
+	ContentType string                `json:"content_type"`
+	Form        url.Values            `json:"form"`
+	Files       map[string]parsedFile `json:"files"`
+}
+
+type parsedFile struct {
+	FileName    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+func formEchoHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	result := parsedForm{ContentType: mediaType, Files: map[string]parsedFile{}}
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for field, headers := range r.MultipartForm.File {
+			f, _ := headers[0].Open()
+			data, _ := io.ReadAll(f)
+			f.Close()
+			result.Files[field] = parsedFile{FileName: headers[0].Filename, ContentType: headers[0].Header.Get("Content-Type"), Content: string(data)}
+		}
+	} else if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result.Form = r.PostForm
+	json.NewEncoder(w).Encode(result)
+}
+
+// callWithServer 以 httptest 服务地址为参数调用 search_video，返回脚本结果
+func callWithServer(t *testing.T, handler http.Handler, script string) interface{} {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	browser, err := crawler.NewHTTPBrowser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewLuaEngine(browser)
+	defer e.Close()
+	ret, err := e.CallFunction(script, "search_video", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret["err"] != nil && ret["err"] != "" {
+		t.Fatalf("script error: %v", ret["err"])
+	}
+	return ret["data"]
+}
+
+func TestHttpPostForm(t *testing.T) {
+	script := `
+local form = {tag = {"a", "b"}, q = "x y&z"}
+local files = {
+  {name = "cover", filename = "c.png", content = "\0PNG\r\n", content_type = "image/png"},
+  {name = "raw", content = "text"},
+}
+function search_video(u)
+  local bodies = {}
+  for _, call in ipairs({
+    function() return http_post_form(u, form) end,
+    function() return http_post_multipart(u, form, files) end,
+    function() return http_request{url = u, form = form} end,
+    function() return http_request{url = u, form = form, files = files} end,
+  }) do
+    local resp, err = call()
+    if err then return nil, err end
+    table.insert(bodies, resp.body)
+  end
+  return bodies, nil
+end`
+	data, _ := callWithServer(t, http.HandlerFunc(formEchoHandler), script).([]interface{})
+	if len(data) != 4 {
+		t.Fatalf("data = %v", data)
+	}
+	wantForm := url.Values{"tag": {"a", "b"}, "q": {"x y&z"}}
+	wantFiles := map[string]parsedFile{
+		"cover": {FileName: "c.png", ContentType: "image/png", Content: "\x00PNG\r\n"},
+		"raw":   {FileName: "raw", ContentType: "application/octet-stream", Content: "text"},
+	}
+	for i, body := range data {
+		var got parsedForm
+		if err := json.Unmarshal([]byte(body.(string)), &got); err != nil {
+			t.Fatalf("%d: %v (%v)", i, err, body)
+		}
+		multipart := i%2 == 1
+		if !reflect.DeepEqual(got.Form, wantForm) {
+			t.Fatalf("%d: form = %v", i, got.Form)
+		}
+		if multipart && (got.ContentType != "multipart/form-data" || !reflect.DeepEqual(got.Files, wantFiles)) {
+			t.Fatalf("%d: multipart = %+v", i, got)
+		}
+		if !multipart && got.ContentType != "application/x-www-form-urlencoded" {
+			t.Fatalf("%d: content type = %s", i, got.ContentType)
+		}
+	}
+}
+
+// batchServer /item/<n>?delay=毫秒[&status=码]，返回 item-<n>，并记录同时处理中的请求数峰值
+func batchServer(peak *int32) http.Handler {
+	var inflight int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(peak)
+			if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+				break
+			}
+		}
+		delay, _ := strconv.Atoi(r.URL.Query().Get("delay"))
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+		if status, _ := strconv.Atoi(r.URL.Query().Get("status")); status > 0 {
+			w.WriteHeader(status)
+		}
+		w.Write([]byte("item-" + strings.TrimPrefix(r.URL.Path, "/item/")))
+	})
+}
+
+func TestHttpBatch(t *testing.T) {
+	// 序号越大响应越快，结果仍按输入顺序返回；第 5 项连接失败、第 6 项返回 500，不影响其他项
+	script := `
+function search_video(u)
+  local reqs = {}
+  for i = 1, 8 do
+    table.insert(reqs, u .. "/item/" .. i .. "?delay=" .. (9 - i) * 15)
+  end
+  reqs[5] = "http://127.0.0.1:1/unreachable"
+  reqs[6] = {url = u .. "/item/6?status=500", method = "GET"}
+  local resps, err = http_batch(reqs, {concurrency = 3})
+  if err then return nil, err end
+  local out = {}
+  for i, r in ipairs(resps) do
+    out[i] = {ok = r.ok, status = r.status_code, body = r.body or "", error = r.error or ""}
+  end
+  return out, nil
+end`
+	var peak int32
+	data, _ := callWithServer(t, batchServer(&peak), script).([]interface{})
+	if len(data) != 8 {
+		t.Fatalf("data = %v", data)
+	}
+	for i, item := range data {
+		r := item.(map[string]interface{})
+		switch i + 1 {
+		case 5:
+			if r["ok"] != false || r["status"] != float64(0) || r["error"] == "" {
+				t.Fatalf("unreachable = %v", r)
+			}
+		case 6:
+			if r["ok"] != false || r["status"] != float64(500) || r["body"] != "item-6" {
+				t.Fatalf("500 = %v", r)
+			}
+		default:
+			if r["ok"] != true || r["body"] != "item-"+strconv.Itoa(i+1) || r["error"] != "" {
+				t.Fatalf("%d = %v", i+1, r)
+			}
+		}
+	}
+	if peak > 3 || peak < 2 {
+		t.Fatalf("peak in-flight requests = %d, want 2..3", peak)
+	}
+}
+
+func TestHttpRequest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Add("X-Multi", "1")
+		w.Header().Add("X-Multi", "2")
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/", HttpOnly: true})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"method":       r.Method,
+			"query":        r.URL.RawQuery,
+			"header":       r.Header.Get("X-Test"),
+			"content_type": r.Header.Get("Content-Type"),
+			"body":         string(body),
+		})
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo?from=redirect", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	})
+
+	script := `
+function search_video(u)
+  local out = {}
+  local r, err = http_request{method = "put", url = u .. "/echo?x=1", query = {y = {"2", "3"}}, headers = {["X-Test"] = "h"}, json = {a = 1}}
+  if err then return nil, err end
+  out.echo, out.status, out.ok, out.url = r.body, r.status_code, r.ok, r.url
+  out.first, out.multi, out.sid = r.headers["X-Multi"], r.headers_all["X-Multi"], r.cookies.sid
+  out.cookie_http_only = r.cookie_list[1].http_only
+
+  r = http_request(u .. "/echo", {method = "POST", body = "raw\0bytes", headers = {["Content-Type"] = "text/plain"}})
+  out.raw = r.body
+
+  r = http_request(u .. "/redirect")
+  out.followed_url, out.followed_status = r.url, r.status_code
+  r = http_request(u .. "/redirect", {follow_redirects = false})
+  out.manual_status, out.location, out.manual_url = r.status_code, r.headers["Location"], r.url
+
+  r, err = http_request{url = u .. "/slow", timeout = 50}
+  out.timeout_response, out.timeout_err = r == nil, err or ""
+  return out, nil
+end`
+	var server string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server = "http://" + r.Host
+		mux.ServeHTTP(w, r)
+	})
+	out, _ := callWithServer(t, handler, script).(map[string]interface{})
+	echo := func(key string) map[string]string {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(out[key].(string)), &m); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		return m
+	}
+
+	if got := echo("echo"); !reflect.DeepEqual(got, map[string]string{
+		"method": "PUT", "query": "x=1&y=2&y=3", "header": "h", "content_type": "application/json", "body": `{"a":1}`,
+	}) {
+		t.Fatalf("echo = %v", got)
+	}
+	if out["status"] != float64(201) || out["ok"] != true || out["url"] != server+"/echo?x=1&y=2&y=3" {
+		t.Fatalf("response = %v", out)
+	}
+	if out["first"] != "1" || !reflect.DeepEqual(out["multi"], []interface{}{"1", "2"}) || out["sid"] != "abc" || out["cookie_http_only"] != true {
+		t.Fatalf("headers = %v", out)
+	}
+	if got := echo("raw"); got["method"] != "POST" || got["body"] != "raw\x00bytes" || got["content_type"] != "text/plain" {
+		t.Fatalf("raw = %v", got)
+	}
+
+	// 默认跟随重定向，url 为最终地址；follow_redirects=false 时返回 3xx 本身
+	if out["followed_status"] != float64(201) || out["followed_url"] != server+"/echo?from=redirect" {
+		t.Fatalf("followed = %v %v", out["followed_status"], out["followed_url"])
+	}
+	if out["manual_status"] != float64(302) || out["location"] != "/echo?from=redirect" || out["manual_url"] != server+"/redirect" {
+		t.Fatalf("manual = %v %v %v", out["manual_status"], out["location"], out["manual_url"])
+	}
+
+	if out["timeout_response"] != true || !strings.Contains(out["timeout_err"].(string), "Timeout") {
+		t.Fatalf("timeout = %v %q", out["timeout_response"], out["timeout_err"])
+	}
+}
@@ -0,0 +1,60 @@
+package lua
+
+import (
+	"context"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/jsengine"
+)
+
+// luaJsEval Lua中的js_eval函数：在独立的 JS 沙箱中执行代码，返回最后一个表达式的值
+// js_eval(code) -> value, err
+func (e *LuaEngine) luaJsEval(L *lua.LState) int {
+	value, err := jsengine.NewSandbox(e.scriptContext(L), jsengine.SandboxTimeout).Eval(L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(interfaceToLua(L, value))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// luaJsCall Lua中的js_call函数：执行代码后调用其中的函数，参数按 JSON 规则转换
+// js_call(code, fn, args...) -> value, err
+func (e *LuaEngine) luaJsCall(L *lua.LState) int {
+	code := L.CheckString(1)
+	fn := L.CheckString(2)
+	args := make([]interface{}, 0, L.GetTop()-2)
+	for i := 3; i <= L.GetTop(); i++ {
+		args = append(args, luaToInterfaceJSON(L.Get(i)))
+	}
+	value, err := jsengine.NewSandbox(e.scriptContext(L), jsengine.SandboxTimeout).Call(code, fn, args...)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(interfaceToLua(L, value))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// scriptContext 当前脚本执行所属的 context，用于让沙箱随脚本一起终止：
+// 调试会话取调试器的父 context（调试器自身的 Done 会触发断点检查），
+// 其余依次取虚拟机的 context 与 gin 请求的 context
+func (e *LuaEngine) scriptContext(L *lua.LState) context.Context {
+	switch ctx := L.Context().(type) {
+	case nil:
+	case *Debugger:
+		return ctx.parent
+	default:
+		return ctx
+	}
+	if e.ctx != nil && e.ctx.Request != nil {
+		return e.ctx.Request.Context()
+	}
+	return context.Background()
+}
@@ -0,0 +1,53 @@
+package lua
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// luaJSONPathData 读取查询数据：字符串按原始 JSON 处理，table 转换为 Go 值
+func luaJSONPathData(L *lua.LState) interface{} {
+	v := L.CheckAny(1)
+	if s, ok := v.(lua.LString); ok {
+		return string(s)
+	}
+	return luaToInterfaceJSON(v)
+}
+
+// createJSONPathLibrary 创建 JSONPath 查询库，避免解码后逐层索引与判空
+func (e *LuaEngine) createJSONPathLibrary() *lua.LTable {
+	jsonpathTable := e.L.CreateTable(0, 2)
+
+	// jsonpath.query(data, path) -> {value...}, err
+	jsonpathTable.RawSetString("query", e.L.NewFunction(func(L *lua.LState) int {
+		results, err := scriptlib.JSONPathQuery(luaJSONPathData(L), L.CheckString(2))
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(interfaceToLua(L, results))
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// jsonpath.get(data, path[, default]) -> value, err（没有匹配时返回 default）
+	jsonpathTable.RawSetString("get", e.L.NewFunction(func(L *lua.LState) int {
+		value, err := scriptlib.JSONPathGet(luaJSONPathData(L), L.CheckString(2), nil)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		if value == nil {
+			L.Push(L.Get(3))
+		} else {
+			L.Push(interfaceToLua(L, value))
+		}
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	return jsonpathTable
+}
@@ -0,0 +1,332 @@
+package lua
+
+import (
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+
+	"video-crawler/internal/scriptlib"
+)
+
+var (
+	// luaDisabledGlobals 引擎中被禁用的全局变量，调用时只返回错误信息
+	luaDisabledGlobals = map[string]bool{"io": true, "package": true, "dofile": true, "loadfile": true}
+	// luaDisabledOs 被禁用的 os 函数
+	luaDisabledOs = map[string]bool{"execute": true, "remove": true, "rename": true, "tmpname": true, "getenv": true, "setlocale": true}
+
+	luaGlobalsOnce sync.Once
+	luaGlobals     map[string]bool
+)
+
+// luaKnownGlobals 引擎注册的全局变量（含标准库），从一个新建的引擎中读取；
+// store 在调用入口函数时注册
+func luaKnownGlobals() map[string]bool {
+	luaGlobalsOnce.Do(func() {
+		e := NewLuaEngine(nil)
+		defer e.Close()
+		luaGlobals = map[string]bool{"store": true}
+		e.L.G.Global.ForEach(func(k, _ lua.LValue) {
+			luaGlobals[k.String()] = true
+		})
+	})
+	return luaGlobals
+}
+
+// Lint 静态检查 Lua 脚本：编译错误、入口函数、禁用的全局函数、未定义的全局变量、不可达代码与不存在的共享模块
+func Lint(script string) *scriptlib.LintResult {
+	l := scriptlib.NewLinter(script)
+	L := lua.NewState()
+	defer L.Close()
+	if _, err := L.LoadString(script); err != nil {
+		se := luaScriptError(err, nil, script)
+		l.Add(scriptlib.LintError, scriptlib.LintSyntax, se.Line, se.Column, "%s", se.Message)
+		return l.Result("lua")
+	}
+	chunk, err := parse.Parse(strings.NewReader(script), debugMainSource)
+	if err != nil {
+		return l.Result("lua")
+	}
+
+	w := &luaLinter{l: l, known: luaKnownGlobals(), assigned: map[string]bool{}, defs: map[string]scriptlib.EntryDef{}}
+	w.block(chunk, nil)
+	for _, ref := range w.refs {
+		if !w.known[ref.name] && !w.assigned[ref.name] {
+			l.AddIdent(scriptlib.LintWarning, scriptlib.LintUndefinedGlobal, ref.line, ref.name, "未定义的全局变量 %s", ref.name)
+		}
+	}
+	l.CheckEntries(w.defs, "nil")
+	return l.Result("lua")
+}
+
+// luaRef 对全局变量的引用
+type luaRef struct {
+	name string
+	line int
+}
+
+// luaLinter 按作用域遍历语法树；全局变量在脚本任意位置赋值即视为已定义
+type luaLinter struct {
+	l        *scriptlib.Linter
+	known    map[string]bool
+	scopes   []map[string]bool
+	assigned map[string]bool // 脚本中赋值过的全局变量
+	refs     []luaRef
+	defs     map[string]scriptlib.EntryDef
+}
+
+func (w *luaLinter) isLocal(name string) bool {
+	for i := len(w.scopes) - 1; i >= 0; i-- {
+		if w.scopes[i][name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *luaLinter) declare(names ...string) {
+	for _, name := range names {
+		w.scopes[len(w.scopes)-1][name] = true
+	}
+}
+
+// block 在新的作用域中检查语句列表，locals 为预先声明的局部变量（函数参数、循环变量）；
+// 在必然跳出的语句之后出现的第一条语句报告为不可达
+func (w *luaLinter) block(stmts []ast.Stmt, locals []string) {
+	w.scopes = append(w.scopes, map[string]bool{})
+	defer func() { w.scopes = w.scopes[:len(w.scopes)-1] }()
+	w.declare(locals...)
+
+	terminated := false
+	for _, stmt := range stmts {
+		if terminated {
+			// goto 可以跳转到标签，标签之后的代码可达
+			if _, ok := stmt.(*ast.LabelStmt); !ok {
+				w.l.AddLine(scriptlib.LintWarning, scriptlib.LintUnreachable, stmt.Line(), "不可达代码")
+			}
+			terminated = false
+		}
+		w.stmt(stmt)
+		if w.terminates(stmt) {
+			terminated = true
+		}
+	}
+}
+
+// terminates 语句执行后必然跳出当前语句列表
+func (w *luaLinter) terminates(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt, *ast.BreakStmt, *ast.GotoStmt:
+		return true
+	case *ast.FuncCallStmt:
+		call, ok := s.Expr.(*ast.FuncCallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := call.Func.(*ast.IdentExpr)
+		return ok && ident.Value == "error" && !w.isLocal("error")
+	case *ast.DoBlockStmt:
+		return w.blockTerminates(s.Stmts)
+	case *ast.IfStmt:
+		return len(s.Else) > 0 && w.blockTerminates(s.Then) && w.blockTerminates(s.Else)
+	case *ast.WhileStmt:
+		_, forever := s.Condition.(*ast.TrueExpr)
+		return forever && !luaLeavesLoop(s.Stmts)
+	}
+	return false
+}
+
+func (w *luaLinter) blockTerminates(stmts []ast.Stmt) bool {
+	for _, stmt := range stmts {
+		if w.terminates(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+// luaLeavesLoop 循环体中是否有跳出该循环的 break 或 goto（不含内层循环与函数）
+func luaLeavesLoop(stmts []ast.Stmt) bool {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.BreakStmt, *ast.GotoStmt:
+			return true
+		case *ast.DoBlockStmt:
+			if luaLeavesLoop(s.Stmts) {
+				return true
+			}
+		case *ast.IfStmt:
+			if luaLeavesLoop(s.Then) || luaLeavesLoop(s.Else) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *luaLinter) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		w.exprs(s.Rhs)
+		for i, lhs := range s.Lhs {
+			ident, ok := lhs.(*ast.IdentExpr)
+			if !ok {
+				w.expr(lhs)
+				continue
+			}
+			if w.isLocal(ident.Value) {
+				continue
+			}
+			w.assigned[ident.Value] = true
+			var value ast.Expr
+			if i < len(s.Rhs) {
+				value = s.Rhs[i]
+			}
+			w.define(ident.Value, s.Line(), value, true)
+		}
+	case *ast.LocalAssignStmt:
+		if len(s.Names) == 1 && len(s.Exprs) == 1 {
+			if _, ok := s.Exprs[0].(*ast.FunctionExpr); ok {
+				// local function f：函数体内可以引用 f 自身
+				w.declare(s.Names[0])
+			}
+		}
+		w.exprs(s.Exprs)
+		for i, name := range s.Names {
+			if len(w.scopes) == 1 {
+				var value ast.Expr
+				if i < len(s.Exprs) {
+					value = s.Exprs[i]
+				}
+				w.define(name, s.Line(), value, false)
+			}
+		}
+		w.declare(s.Names...)
+	case *ast.FuncCallStmt:
+		w.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		w.block(s.Stmts, nil)
+	case *ast.WhileStmt:
+		w.expr(s.Condition)
+		w.block(s.Stmts, nil)
+	case *ast.RepeatStmt:
+		// until 条件可以使用循环体中的局部变量
+		w.scopes = append(w.scopes, map[string]bool{})
+		w.block(s.Stmts, nil)
+		for _, inner := range s.Stmts {
+			if local, ok := inner.(*ast.LocalAssignStmt); ok {
+				w.declare(local.Names...)
+			}
+		}
+		w.expr(s.Condition)
+		w.scopes = w.scopes[:len(w.scopes)-1]
+	case *ast.IfStmt:
+		w.expr(s.Condition)
+		w.block(s.Then, nil)
+		w.block(s.Else, nil)
+	case *ast.NumberForStmt:
+		w.expr(s.Init)
+		w.expr(s.Limit)
+		w.expr(s.Step)
+		w.block(s.Stmts, []string{s.Name})
+	case *ast.GenericForStmt:
+		w.exprs(s.Exprs)
+		w.block(s.Stmts, s.Names)
+	case *ast.FuncDefStmt:
+		locals := s.Func.ParList.Names
+		if s.Name.Method != "" {
+			locals = append([]string{"self"}, locals...)
+			w.expr(s.Name.Receiver)
+		} else if ident, ok := s.Name.Func.(*ast.IdentExpr); ok {
+			if !w.isLocal(ident.Value) {
+				w.assigned[ident.Value] = true
+				w.define(ident.Value, s.Line(), s.Func, true)
+			}
+		} else {
+			w.expr(s.Name.Func)
+		}
+		w.block(s.Func.Stmts, locals)
+	case *ast.ReturnStmt:
+		w.exprs(s.Exprs)
+	}
+}
+
+// define 记录入口函数的定义，同名只记录第一次
+func (w *luaLinter) define(name string, line int, value ast.Expr, global bool) {
+	if _, ok := w.defs[name]; ok {
+		return
+	}
+	def := scriptlib.EntryDef{Line: line, Global: global}
+	if fn, ok := value.(*ast.FunctionExpr); ok {
+		def.IsFunc = true
+		def.Params = len(fn.ParList.Names)
+		def.Vararg = fn.ParList.HasVargs
+	}
+	w.defs[name] = def
+}
+
+func (w *luaLinter) exprs(exprs []ast.Expr) {
+	for _, e := range exprs {
+		w.expr(e)
+	}
+}
+
+func (w *luaLinter) expr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		if w.isLocal(e.Value) {
+			return
+		}
+		if luaDisabledGlobals[e.Value] {
+			w.l.AddIdent(scriptlib.LintWarning, scriptlib.LintDisabledGlobal, e.Line(), e.Value, "%s 已被禁用，调用只会返回错误信息", e.Value)
+			return
+		}
+		w.refs = append(w.refs, luaRef{name: e.Value, line: e.Line()})
+	case *ast.AttrGetExpr:
+		if obj, ok := e.Object.(*ast.IdentExpr); ok && obj.Value == "os" && !w.isLocal("os") {
+			if key, ok := e.Key.(*ast.StringExpr); ok && luaDisabledOs[key.Value] {
+				w.l.AddIdent(scriptlib.LintWarning, scriptlib.LintDisabledGlobal, e.Line(), key.Value, "os.%s 已被禁用，调用只会返回错误信息", key.Value)
+			}
+		}
+		w.expr(e.Object)
+		w.expr(e.Key)
+	case *ast.TableExpr:
+		for _, f := range e.Fields {
+			w.expr(f.Key)
+			w.expr(f.Value)
+		}
+	case *ast.FuncCallExpr:
+		if ident, ok := e.Func.(*ast.IdentExpr); ok && ident.Value == "require" && !w.isLocal("require") {
+			if len(e.Args) > 0 {
+				if name, ok := e.Args[0].(*ast.StringExpr); ok {
+					w.l.CheckModule(scriptlib.EngineLua, name.Value, e.Line())
+				}
+			}
+		}
+		w.expr(e.Func)
+		w.expr(e.Receiver)
+		w.exprs(e.Args)
+	case *ast.LogicalOpExpr:
+		w.expr(e.Lhs)
+		w.expr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		w.expr(e.Lhs)
+		w.expr(e.Rhs)
+	case *ast.StringConcatOpExpr:
+		w.expr(e.Lhs)
+		w.expr(e.Rhs)
+	case *ast.ArithmeticOpExpr:
+		w.expr(e.Lhs)
+		w.expr(e.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		w.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		w.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		w.expr(e.Expr)
+	case *ast.FunctionExpr:
+		w.block(e.Stmts, e.ParList.Names)
+	}
+}
@@ -0,0 +1,47 @@
+package lua
+
+import (
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// luaRequire 安全的 require：只能加载管理员维护的共享模块，不访问文件系统。
+// 与标准 require 一致，模块的返回值会被缓存，加载失败时抛出错误。
+func (e *LuaEngine) luaRequire(L *lua.LState) int {
+	name := L.CheckString(1)
+	if value, ok := e.modules[name]; ok {
+		if value == nil {
+			L.RaiseError("模块 %s 存在循环依赖", name)
+		}
+		L.Push(value)
+		return 1
+	}
+
+	code, err := scriptlib.ResolveModule(scriptlib.EngineLua, name)
+	if err != nil {
+		L.RaiseError("require %s 失败: %v", name, err)
+	}
+	fn, err := L.Load(strings.NewReader(code), "module:"+name)
+	if err != nil {
+		L.RaiseError("require %s 失败: %v", name, err)
+	}
+
+	// 加载期间占位，用于识别循环依赖
+	e.modules[name] = nil
+	L.Push(fn)
+	if err := L.PCall(0, 1, nil); err != nil {
+		delete(e.modules, name)
+		L.RaiseError("require %s 失败: %v", name, err)
+	}
+	value := L.Get(-1)
+	L.Pop(1)
+	if value == lua.LNil {
+		value = lua.LTrue
+	}
+	e.modules[name] = value
+	L.Push(value)
+	return 1
+}
@@ -0,0 +1,446 @@
+package lua
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// regexCacheSize 已编译正则的缓存上限，脚本通常在循环中反复使用同一表达式
+	regexCacheSize = 256
+	// regexp2MatchTimeout 回溯引擎单次匹配的超时，防止灾难性回溯卡死脚本
+	regexp2MatchTimeout = 2 * time.Second
+)
+
+// regexMatch 引擎无关的匹配结果，偏移均为字节偏移
+type regexMatch struct {
+	start, end int
+	groups     []regexGroup // 下标 0 为整体匹配
+}
+
+type regexGroup struct {
+	text    string
+	matched bool
+}
+
+// compiledRegex 统一 Go regexp（RE2）与 regexp2（支持环视、反向引用）的接口
+type compiledRegex interface {
+	findAll(s string, n int) ([]regexMatch, error)
+	groupNames() []string // 与分组下标对应，未命名分组为空字符串
+}
+
+var (
+	regexCacheMutex sync.Mutex
+	regexCache      = map[string]compiledRegex{}
+)
+
+// compileRegex 编译正则表达式。
+// flags: i 忽略大小写，m 多行模式，s 点号匹配换行；engine: auto（默认）/ re2 / regexp2。
+// auto 模式优先使用 RE2，表达式含 RE2 不支持的语法（如环视）时回退到 regexp2。
+func compileRegex(pattern, flags, engine string) (compiledRegex, error) {
+	for _, f := range flags {
+		if !strings.ContainsRune("ims", f) {
+			return nil, fmt.Errorf("不支持的正则标志: %c", f)
+		}
+	}
+	cacheKey := engine + "\x00" + flags + "\x00" + pattern
+	regexCacheMutex.Lock()
+	re, ok := regexCache[cacheKey]
+	regexCacheMutex.Unlock()
+	if ok {
+		return re, nil
+	}
+
+	var err error
+	switch engine {
+	case "", "auto":
+		if re, err = compileRE2(pattern, flags); err != nil {
+			if re2, err2 := compileRegexp2(pattern, flags); err2 == nil {
+				re, err = re2, nil
+			}
+		}
+	case "re2":
+		re, err = compileRE2(pattern, flags)
+	case "regexp2":
+		re, err = compileRegexp2(pattern, flags)
+	default:
+		return nil, fmt.Errorf("不支持的正则引擎: %s", engine)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	regexCacheMutex.Lock()
+	if len(regexCache) >= regexCacheSize {
+		regexCache = map[string]compiledRegex{}
+	}
+	regexCache[cacheKey] = re
+	regexCacheMutex.Unlock()
+	return re, nil
+}
+
+type re2Regex struct{ re *regexp.Regexp }
+
+func compileRE2(pattern, flags string) (compiledRegex, error) {
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re2Regex{re: re}, nil
+}
+
+func (r re2Regex) findAll(s string, n int) ([]regexMatch, error) {
+	var matches []regexMatch
+	for _, loc := range r.re.FindAllStringSubmatchIndex(s, n) {
+		m := regexMatch{start: loc[0], end: loc[1], groups: make([]regexGroup, len(loc)/2)}
+		for i := range m.groups {
+			if loc[2*i] >= 0 {
+				m.groups[i] = regexGroup{text: s[loc[2*i]:loc[2*i+1]], matched: true}
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+func (r re2Regex) groupNames() []string {
+	return r.re.SubexpNames()
+}
+
+type regexp2Regex struct {
+	re    *regexp2.Regexp
+	names []string
+}
+
+func compileRegexp2(pattern, flags string) (compiledRegex, error) {
+	var opts regexp2.RegexOptions
+	if strings.ContainsRune(flags, 'i') {
+		opts |= regexp2.IgnoreCase
+	}
+	if strings.ContainsRune(flags, 'm') {
+		opts |= regexp2.Multiline
+	}
+	if strings.ContainsRune(flags, 's') {
+		opts |= regexp2.Singleline
+	}
+	re, err := regexp2.Compile(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	re.MatchTimeout = regexp2MatchTimeout
+
+	// regexp2 沿用 .NET 的编号规则（命名分组排在未命名分组之后），按编号整理名称以对齐 Groups()
+	names := make([]string, 0, len(re.GetGroupNumbers()))
+	for _, num := range re.GetGroupNumbers() {
+		name := re.GroupNameFromNumber(num)
+		if name == strconv.Itoa(num) {
+			name = ""
+		}
+		names = append(names, name)
+	}
+	return &regexp2Regex{re: re, names: names}, nil
+}
+
+func (r *regexp2Regex) findAll(s string, n int) ([]regexMatch, error) {
+	// regexp2 以 rune 为单位返回位置，需换算为字节偏移
+	offsets := make([]int, 0, utf8.RuneCountInString(s)+1)
+	for i := range s {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(s))
+
+	var matches []regexMatch
+	m, err := r.re.FindStringMatch(s)
+	for m != nil && (n < 0 || len(matches) < n) {
+		rm := regexMatch{start: offsets[m.Index], end: offsets[m.Index+m.Length]}
+		for _, g := range m.Groups() {
+			if len(g.Captures) == 0 {
+				rm.groups = append(rm.groups, regexGroup{})
+				continue
+			}
+			rm.groups = append(rm.groups, regexGroup{text: g.String(), matched: true})
+		}
+		matches = append(matches, rm)
+		m, err = r.re.FindNextMatch(m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func (r *regexp2Regex) groupNames() []string {
+	return r.names
+}
+
+// expandTemplate 展开替换模板：$1 / ${1} / ${name}，$$ 表示字面量 $
+func expandTemplate(template string, m regexMatch, names []string) string {
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c != '$' || i+1 >= len(template) {
+			b.WriteByte(c)
+			continue
+		}
+		next := template[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(template[i+2:], '}')
+			if end < 0 {
+				b.WriteByte(c)
+				continue
+			}
+			ref := template[i+2 : i+2+end]
+			b.WriteString(groupByRef(ref, m, names))
+			i += end + 2
+		case next >= '0' && next <= '9':
+			j := i + 1
+			for j < len(template) && template[j] >= '0' && template[j] <= '9' {
+				j++
+			}
+			b.WriteString(groupByRef(template[i+1:j], m, names))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func groupByRef(ref string, m regexMatch, names []string) string {
+	if idx, err := strconv.Atoi(ref); err == nil {
+		if idx >= 0 && idx < len(m.groups) {
+			return m.groups[idx].text
+		}
+		return ""
+	}
+	for i, name := range names {
+		if name == ref && i < len(m.groups) {
+			return m.groups[i].text
+		}
+	}
+	return ""
+}
+
+// luaRegexCompile 读取第 n 个参数作为选项并编译，选项可以是标志字符串或 {flags=, engine=} 表
+func luaRegexCompile(L *lua.LState, pattern string, n int) (compiledRegex, error) {
+	var flags, engine string
+	switch v := L.Get(n).(type) {
+	case lua.LString:
+		flags = string(v)
+	case *lua.LTable:
+		flags = lua.LVAsString(v.RawGetString("flags"))
+		engine = lua.LVAsString(v.RawGetString("engine"))
+	}
+	return compileRegex(pattern, flags, engine)
+}
+
+// matchToTable 将匹配结果转换为 Lua 表：
+// {text=整体匹配, start=起始位置, stop=结束位置（1 起始、闭区间，与 string.find 一致）, groups={...}, named={...}}
+// 未参与匹配的分组在 groups 中为空字符串，在 named 中缺省
+func matchToTable(L *lua.LState, m regexMatch, names []string) *lua.LTable {
+	tbl := L.CreateTable(0, 5)
+	tbl.RawSetString("text", lua.LString(m.groups[0].text))
+	tbl.RawSetString("start", lua.LNumber(m.start+1))
+	tbl.RawSetString("stop", lua.LNumber(m.end))
+
+	groups := L.CreateTable(len(m.groups)-1, 0)
+	named := L.NewTable()
+	for i, g := range m.groups[1:] {
+		groups.RawSetInt(i+1, lua.LString(g.text))
+		if i+1 < len(names) && names[i+1] != "" && g.matched {
+			named.RawSetString(names[i+1], lua.LString(g.text))
+		}
+	}
+	tbl.RawSetString("groups", groups)
+	tbl.RawSetString("named", named)
+	return tbl
+}
+
+// pushRegexError 按 value, err 约定返回错误
+func pushRegexError(L *lua.LState, value lua.LValue, err error) int {
+	L.Push(value)
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
+
+// createRegexLibrary 创建正则表达式库（弥补 Lua 模式匹配能力的不足，如提取内联脚本中的 JSON）
+func (e *LuaEngine) createRegexLibrary() *lua.LTable {
+	regexTable := e.L.CreateTable(0, 7)
+
+	// regex.test(pattern, str[, opts]) -> boolean, err
+	regexTable.RawSetString("test", e.L.NewFunction(func(L *lua.LState) int {
+		re, err := luaRegexCompile(L, L.CheckString(1), 3)
+		if err != nil {
+			return pushRegexError(L, lua.LFalse, err)
+		}
+		matches, err := re.findAll(L.CheckString(2), 1)
+		if err != nil {
+			return pushRegexError(L, lua.LFalse, err)
+		}
+		L.Push(lua.LBool(len(matches) > 0))
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// regex.match(pattern, str[, opts]) -> match|nil, err
+	regexTable.RawSetString("match", e.L.NewFunction(func(L *lua.LState) int {
+		re, err := luaRegexCompile(L, L.CheckString(1), 3)
+		if err != nil {
+			return pushRegexError(L, lua.LNil, err)
+		}
+		matches, err := re.findAll(L.CheckString(2), 1)
+		if err != nil {
+			return pushRegexError(L, lua.LNil, err)
+		}
+		if len(matches) == 0 {
+			L.Push(lua.LNil)
+		} else {
+			L.Push(matchToTable(L, matches[0], re.groupNames()))
+		}
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// regex.find_all(pattern, str[, opts]) -> {match...}, err；opts 为表时可用 limit 限制数量
+	regexTable.RawSetString("find_all", e.L.NewFunction(func(L *lua.LState) int {
+		re, err := luaRegexCompile(L, L.CheckString(1), 3)
+		if err != nil {
+			return pushRegexError(L, lua.LNil, err)
+		}
+		limit := -1
+		if opts, ok := L.Get(3).(*lua.LTable); ok {
+			if n, ok := opts.RawGetString("limit").(lua.LNumber); ok && n > 0 {
+				limit = int(n)
+			}
+		}
+		matches, err := re.findAll(L.CheckString(2), limit)
+		if err != nil {
+			return pushRegexError(L, lua.LNil, err)
+		}
+		names := re.groupNames()
+		result := L.CreateTable(len(matches), 0)
+		for i, m := range matches {
+			result.RawSetInt(i+1, matchToTable(L, m, names))
+		}
+		L.Push(result)
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// regex.replace(pattern, str, repl[, opts]) -> string, count, err
+	// repl 为字符串时支持 $1 / ${name} 引用；为函数时以匹配表调用，返回 nil 或 false 保留原文
+	regexTable.RawSetString("replace", e.L.NewFunction(func(L *lua.LState) int {
+		s := L.CheckString(2)
+		repl := L.Get(3)
+		if repl.Type() != lua.LTString && repl.Type() != lua.LTNumber && repl.Type() != lua.LTFunction {
+			L.ArgError(3, "需要字符串或函数")
+		}
+		re, err := luaRegexCompile(L, L.CheckString(1), 4)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LNumber(0))
+			L.Push(lua.LString(err.Error()))
+			return 3
+		}
+		limit := -1
+		if opts, ok := L.Get(4).(*lua.LTable); ok {
+			if n, ok := opts.RawGetString("limit").(lua.LNumber); ok && n > 0 {
+				limit = int(n)
+			}
+		}
+		matches, err := re.findAll(s, limit)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LNumber(0))
+			L.Push(lua.LString(err.Error()))
+			return 3
+		}
+
+		names := re.groupNames()
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			b.WriteString(s[last:m.start])
+			last = m.end
+			if fn, ok := repl.(*lua.LFunction); ok {
+				L.Push(fn)
+				L.Push(matchToTable(L, m, names))
+				L.Call(1, 1)
+				ret := L.Get(-1)
+				L.Pop(1)
+				if ret == lua.LNil || ret == lua.LFalse {
+					b.WriteString(m.groups[0].text)
+				} else {
+					b.WriteString(lua.LVAsString(ret))
+				}
+				continue
+			}
+			b.WriteString(expandTemplate(lua.LVAsString(repl), m, names))
+		}
+		b.WriteString(s[last:])
+
+		L.Push(lua.LString(b.String()))
+		L.Push(lua.LNumber(len(matches)))
+		L.Push(lua.LNil)
+		return 3
+	}))
+
+	// regex.split(pattern, str[, limit[, opts]]) -> {string...}, err；limit 与 Go regexp.Split 语义一致
+	regexTable.RawSetString("split", e.L.NewFunction(func(L *lua.LState) int {
+		s := L.CheckString(2)
+		limit := L.OptInt(3, -1)
+		re, err := luaRegexCompile(L, L.CheckString(1), 4)
+		if err != nil {
+			return pushRegexError(L, lua.LNil, err)
+		}
+		result := L.NewTable()
+		if limit == 0 {
+			L.Push(result)
+			L.Push(lua.LNil)
+			return 2
+		}
+		matches, err := re.findAll(s, -1)
+		if err != nil {
+			return pushRegexError(L, lua.LNil, err)
+		}
+		last := 0
+		for _, m := range matches {
+			if limit > 0 && result.Len() == limit-1 {
+				break
+			}
+			// 与 strings.Split 一致：开头的空匹配不产生空元素
+			if m.end == 0 {
+				continue
+			}
+			result.Append(lua.LString(s[last:m.start]))
+			last = m.end
+		}
+		result.Append(lua.LString(s[last:]))
+		L.Push(result)
+		L.Push(lua.LNil)
+		return 2
+	}))
+
+	// regex.escape(str) -> string，转义正则元字符
+	regexTable.RawSetString("escape", e.L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(regexp.QuoteMeta(L.CheckString(1))))
+		return 1
+	}))
+
+	return regexTable
+}
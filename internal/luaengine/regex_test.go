@@ -0,0 +1,37 @@
+package lua
+
+import "testing"
+
+func TestRegexLibrary(t *testing.T) {
+	e := NewLuaEngine(nil)
+	defer e.Close()
+
+	script := `
+local html = [[<script>var player_aaaa = {"url":"a.m3u8","from":"x"};</script>]]
+local m, err = regex.match([[player_aaaa\s*=\s*(?P<json>\{.*?\})]], html)
+assert(err == nil, err)
+assert(m.named.json == '{"url":"a.m3u8","from":"x"}', m.named.json)
+assert(m.start == 13 and m.groups[1] == m.named.json)
+
+local all = regex.find_all("(\\d+)-(\\d+)", "1-2, 30-40")
+assert(#all == 2 and all[2].groups[1] == "30" and all[2].groups[2] == "40")
+
+local s, n = regex.replace("(\\w+)@(\\w+)", "a@b c@d", "${2}@$1")
+assert(s == "b@a d@c" and n == 2, s)
+s = regex.replace("\\d+", "a1b22", function(m) return "<" .. m.text .. ">" end)
+assert(s == "a<1>b<22>", s)
+
+local parts = regex.split("\\s*,\\s*", "a , b,c")
+assert(#parts == 3 and parts[3] == "c")
+
+-- RE2 不支持环视，自动回退到 regexp2
+m = regex.match("(?<=id=)\\d+", "x id=42", "i")
+assert(m and m.text == "42")
+
+local ok, err = regex.test("(", "x")
+assert(ok == false and err ~= nil)
+`
+	if err := e.L.DoString(script); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package lua
+
+import (
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"video-crawler/internal/scriptlib"
+)
+
+// SetParseTracer 设置 HTML 解析与选择器调用的记录回调，nil 关闭记录
+func (e *LuaEngine) SetParseTracer(tracer scriptlib.ParseTracer) {
+	e.parseTracer = tracer
+}
+
+// traced 包装 parse_html 与选择器函数：记录选择器、匹配数量、耗时与调用所在的脚本位置
+func (e *LuaEngine) traced(op string, fn lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		if e.parseTracer == nil {
+			return fn(L)
+		}
+		rec := scriptlib.ParseTrace{Op: op}
+		if op == "parse_html" {
+			if html, ok := L.Get(1).(lua.LString); ok {
+				rec.InputSize = len(html)
+			}
+		} else if selector, ok := L.Get(2).(lua.LString); ok {
+			rec.Selector = string(selector)
+		}
+		if dbg, ok := L.GetStack(1); ok {
+			if _, err := L.GetInfo("Sl", dbg, lua.LNil); err == nil {
+				rec.Source, rec.Line = luaSourceName(dbg.Source), dbg.CurrentLine
+			}
+		}
+
+		start := time.Now()
+		n := fn(L)
+		rec.DurationMs = scriptlib.ElapsedMs(start)
+		// 返回值为 (结果, 错误信息)
+		base := L.GetTop() - n
+		switch v := L.Get(base + 1).(type) {
+		case *lua.LUserData:
+			switch ud := v.Value.(type) {
+			case *luaDocument:
+				rec.Matches = 1
+			case *luaSelection:
+				rec.Matches = ud.sel.Length()
+			}
+		case *lua.LTable:
+			rec.Matches = 1
+		}
+		if n > 1 {
+			if msg, ok := L.Get(base + 2).(lua.LString); ok {
+				rec.Error = string(msg)
+			}
+		}
+		e.parseTracer(rec)
+		return n
+	}
+}
@@ -21,6 +21,13 @@ var routerWhiteList = []string{
 	"/api/config",
 }
 
+// signedRouterList 携带签名（sig 参数）时不进行JWT认证的路由，签名由接口自行校验；
+// 播放器直接请求这些地址，无法附带 Authorization
+var signedRouterList = []string{
+	"/api/video/proxy/hls",
+	"/api/video/proxy/segment",
+}
+
 // JWTAuthMiddleware JWT 认证中间件
 func JWTAuthMiddleware(cfg *config.Config, jwtManager *utils.JWTManager, userService services.UserServiceInterface) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -37,6 +44,10 @@ func JWTAuthMiddleware(cfg *config.Config, jwtManager *utils.JWTManager, userSer
 			c.Next()
 			return
 		}
+		if slices.Contains(signedRouterList, c.Request.URL.Path) && c.Query("sig") != "" {
+			c.Next()
+			return
+		}
 		// 获取 Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -107,6 +118,10 @@ func OptionalJWTAuthMiddleware(cfg *config.Config, jwtManager *utils.JWTManager)
 			c.Next()
 			return
 		}
+		if slices.Contains(signedRouterList, c.Request.URL.Path) && c.Query("sig") != "" {
+			c.Next()
+			return
+		}
 		// 获取 Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
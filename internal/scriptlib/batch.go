@@ -0,0 +1,40 @@
+package scriptlib
+
+import "sync"
+
+// 脚本批量请求的并发数：未指定时使用默认值，超过上限时按上限执行
+const (
+	DefaultBatchConcurrency = 5
+	MaxBatchConcurrency     = 20
+)
+
+// BatchConcurrency 规范化脚本传入的并发数
+func BatchConcurrency(n int) int {
+	if n <= 0 {
+		return DefaultBatchConcurrency
+	}
+	if n > MaxBatchConcurrency {
+		return MaxBatchConcurrency
+	}
+	return n
+}
+
+// Parallel 以最多 concurrency 个协程执行 fn(0..count-1)，全部完成后返回。
+// fn 中不能访问脚本虚拟机，结果应写入调用方按下标准备好的切片。
+func Parallel(count, concurrency int, fn func(i int)) {
+	concurrency = BatchConcurrency(concurrency)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
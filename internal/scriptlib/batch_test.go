@@ -0,0 +1,38 @@
+package scriptlib
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	for _, c := range []struct{ in, want int }{{0, DefaultBatchConcurrency}, {-1, DefaultBatchConcurrency}, {3, 3}, {100, MaxBatchConcurrency}} {
+		if got := BatchConcurrency(c.in); got != c.want {
+			t.Fatalf("BatchConcurrency(%d) = %d, want %d", c.in, got, c.want)
+		}
+	}
+
+	var running, peak int32
+	results := make([]int, 10)
+	Parallel(len(results), 3, func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(len(results)-i) * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		results[i] = i * i
+	})
+	for i, v := range results {
+		if v != i*i {
+			t.Fatalf("results[%d] = %d", i, v)
+		}
+	}
+	if peak != 3 {
+		t.Fatalf("peak = %d, want 3", peak)
+	}
+}
@@ -0,0 +1,160 @@
+package scriptlib
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallContext 入口函数的第二个参数（ctx）：请求参数、站点信息、键值存储与日志。
+// 只声明一个参数的旧脚本忽略多余的参数即可，不受影响
+type CallContext struct {
+	Method  string            // 入口函数名
+	Page    int               // 页码，从 1 开始
+	Locale  string            // 用户语言，如 zh-CN
+	Params  map[string]string // 请求参数，如 line、quality
+	Filters map[string]string // 筛选条件：filter_ 前缀的请求参数（去掉前缀）
+	Source  SourceInfo        // 当前站点
+	Store   Store             // 站点的键值存储，为 nil 时脚本中的 store 操作返回错误
+}
+
+// SourceInfo 脚本可以读取的站点信息
+type SourceInfo struct {
+	ID       string
+	Name     string
+	Domain   string
+	Settings map[string]interface{} // 站点自定义配置
+}
+
+// NewCallContext 由请求参数构造上下文：page、locale 单独解析，filter_ 前缀的参数归入 Filters
+func NewCallContext(method string, params map[string]string) *CallContext {
+	c := &CallContext{Method: method, Page: 1, Params: map[string]string{}, Filters: map[string]string{}}
+	for k, v := range params {
+		c.Params[k] = v
+		if name := strings.TrimPrefix(k, "filter_"); name != k && name != "" {
+			c.Filters[name] = v
+		}
+	}
+	if n, err := strconv.Atoi(params["page"]); err == nil && n > 0 {
+		c.Page = n
+	}
+	c.Locale = params["locale"]
+	return c
+}
+
+// Values 返回上下文中的数据字段（不含 store 与 log），由引擎转换为 Lua 表或 JS 对象
+func (c *CallContext) Values() map[string]interface{} {
+	settings := c.Source.Settings
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"method":  c.Method,
+		"page":    c.Page,
+		"locale":  c.Locale,
+		"params":  stringMap(c.Params),
+		"filters": stringMap(c.Filters),
+		"source": map[string]interface{}{
+			"id":       c.Source.ID,
+			"name":     c.Source.Name,
+			"domain":   c.Source.Domain,
+			"settings": settings,
+		},
+	}
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// ErrStoreUnavailable 当前调用没有可用的键值存储
+var ErrStoreUnavailable = errors.New("键值存储不可用")
+
+// Store 站点脚本的键值存储，值为可 JSON 序列化的数据
+type Store interface {
+	Get(key string) (interface{}, bool)
+	// Set 写入键值，ttl 为 0 表示不过期
+	Set(key string, value interface{}, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// StoreProvider 按站点ID提供持久化的键值存储，由服务层在启动时注册
+type StoreProvider interface {
+	SourceStore(sourceID string) Store
+}
+
+var (
+	storeProviderMutex sync.RWMutex
+	storeProvider      StoreProvider
+)
+
+// SetStoreProvider 注册站点键值存储的提供者
+func SetStoreProvider(p StoreProvider) {
+	storeProviderMutex.Lock()
+	defer storeProviderMutex.Unlock()
+	storeProvider = p
+}
+
+// SourceStore 返回站点的键值存储；未注册提供者（例如在独立工具中运行引擎）时返回 nil
+func SourceStore(sourceID string) Store {
+	storeProviderMutex.RLock()
+	p := storeProvider
+	storeProviderMutex.RUnlock()
+	if p == nil || sourceID == "" {
+		return nil
+	}
+	return p.SourceStore(sourceID)
+}
+
+// MemoryStore 进程内的键值存储，用于调试等不需要持久化的场景
+type MemoryStore struct {
+	mutex sync.Mutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	value   interface{}
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: map[string]memoryItem{}}
+}
+
+func (s *MemoryStore) Get(key string) (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	item, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		delete(s.items, key)
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (s *MemoryStore) Set(key string, value interface{}, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expires = time.Now().Add(ttl)
+	}
+	s.items[key] = item
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.items, key)
+	return nil
+}
@@ -0,0 +1,409 @@
+// Package scriptlib 提供 Lua 与 JS 引擎共用的脚本库实现。
+// 引擎侧只负责参数转换与错误约定（Lua 返回 value, err；JS 抛出异常），
+// 具体语义全部在这里实现，保证两种脚本得到完全一致的结果。
+package scriptlib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Options 脚本传入的选项表（Lua table / JS object 转换而来）
+type Options map[string]interface{}
+
+// String 读取字符串选项，不存在或为空时返回默认值（不区分大小写）
+func (o Options) String(key, def string) string {
+	if o == nil {
+		return def
+	}
+	if v, ok := o[key]; ok && v != nil {
+		if s := strings.TrimSpace(fmt.Sprint(v)); s != "" {
+			return s
+		}
+	}
+	return def
+}
+
+// Decode 按编码将脚本字符串转为字节：utf8（原样）、hex、base64、base64url
+func Decode(s, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf8", "utf-8", "raw", "binary":
+		return []byte(s), nil
+	case "hex":
+		return hex.DecodeString(strings.TrimSpace(s))
+	case "base64":
+		s = strings.TrimSpace(s)
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return b, nil
+		}
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	case "base64url":
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+	default:
+		return nil, fmt.Errorf("不支持的编码: %s", encoding)
+	}
+}
+
+// Encode 按编码将字节转为脚本字符串
+func Encode(b []byte, encoding string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf8", "utf-8", "raw", "binary":
+		return string(b), nil
+	case "hex":
+		return hex.EncodeToString(b), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(b), nil
+	default:
+		return "", fmt.Errorf("不支持的编码: %s", encoding)
+	}
+}
+
+func newHash(algo string) (func() hash.Hash, crypto.Hash, error) {
+	switch strings.ToLower(strings.ReplaceAll(algo, "-", "")) {
+	case "md5":
+		return md5.New, crypto.MD5, nil
+	case "sha1":
+		return sha1.New, crypto.SHA1, nil
+	case "sha256":
+		return sha256.New, crypto.SHA256, nil
+	case "sha512":
+		return sha512.New, crypto.SHA512, nil
+	default:
+		return nil, 0, fmt.Errorf("不支持的哈希算法: %s", algo)
+	}
+}
+
+// Digest 计算摘要，output 为输出编码（默认 hex）
+func Digest(algo, data, output string) (string, error) {
+	newFn, _, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	h := newFn()
+	h.Write([]byte(data))
+	return Encode(h.Sum(nil), defaultString(output, "hex"))
+}
+
+// HMAC 计算 HMAC。选项：key_encoding（默认 utf8）、output（默认 hex）
+func HMAC(algo, key, data string, opts Options) (string, error) {
+	newFn, _, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	k, err := Decode(key, opts.String("key_encoding", "utf8"))
+	if err != nil {
+		return "", fmt.Errorf("密钥解码失败: %w", err)
+	}
+	m := hmac.New(newFn, k)
+	m.Write([]byte(data))
+	return Encode(m.Sum(nil), opts.String("output", "hex"))
+}
+
+// aesParams AES 选项解析结果
+type aesParams struct {
+	mode    string
+	padding string
+	key     []byte
+	iv      []byte
+	aad     []byte
+}
+
+func parseAESParams(key string, opts Options) (*aesParams, error) {
+	p := &aesParams{
+		mode:    strings.ToUpper(opts.String("mode", "CBC")),
+		padding: strings.ToLower(opts.String("padding", "pkcs7")),
+	}
+	var err error
+	if p.key, err = Decode(key, opts.String("key_encoding", "utf8")); err != nil {
+		return nil, fmt.Errorf("密钥解码失败: %w", err)
+	}
+	switch len(p.key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("AES 密钥长度必须为 16/24/32 字节，实际 %d", len(p.key))
+	}
+	if p.iv, err = Decode(opts.String("iv", ""), opts.String("iv_encoding", "utf8")); err != nil {
+		return nil, fmt.Errorf("IV 解码失败: %w", err)
+	}
+	if p.aad, err = Decode(opts.String("aad", ""), opts.String("aad_encoding", "utf8")); err != nil {
+		return nil, fmt.Errorf("AAD 解码失败: %w", err)
+	}
+	switch p.mode {
+	case "CBC", "CTR":
+		if len(p.iv) != aes.BlockSize {
+			return nil, fmt.Errorf("%s 模式的 IV 必须为 16 字节，实际 %d", p.mode, len(p.iv))
+		}
+	case "GCM":
+		if len(p.iv) == 0 {
+			return nil, errors.New("GCM 模式需要提供 iv（nonce）")
+		}
+	case "ECB":
+	default:
+		return nil, fmt.Errorf("不支持的 AES 模式: %s", p.mode)
+	}
+	switch p.padding {
+	case "pkcs7", "pkcs5", "zero", "none":
+	default:
+		return nil, fmt.Errorf("不支持的填充方式: %s", p.padding)
+	}
+	return p, nil
+}
+
+// AESEncrypt AES 加密。选项：
+// mode（CBC/ECB/CTR/GCM，默认 CBC）、padding（pkcs7/zero/none，仅 CBC/ECB 生效）、
+// iv、aad（GCM 附加数据）、key_encoding/iv_encoding/aad_encoding/input_encoding（默认 utf8）、
+// output（默认 base64）。GCM 输出为密文与 16 字节认证标签的拼接。
+func AESEncrypt(data, key string, opts Options) (string, error) {
+	p, err := parseAESParams(key, opts)
+	if err != nil {
+		return "", err
+	}
+	plain, err := Decode(data, opts.String("input_encoding", "utf8"))
+	if err != nil {
+		return "", fmt.Errorf("明文解码失败: %w", err)
+	}
+	block, err := aes.NewCipher(p.key)
+	if err != nil {
+		return "", err
+	}
+	var out []byte
+	switch p.mode {
+	case "CBC", "ECB":
+		if plain, err = pad(plain, p.padding); err != nil {
+			return "", err
+		}
+		out = make([]byte, len(plain))
+		if p.mode == "CBC" {
+			cipher.NewCBCEncrypter(block, p.iv).CryptBlocks(out, plain)
+		} else {
+			ecbCrypt(block, out, plain, true)
+		}
+	case "CTR":
+		out = make([]byte, len(plain))
+		cipher.NewCTR(block, p.iv).XORKeyStream(out, plain)
+	case "GCM":
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(p.iv))
+		if err != nil {
+			return "", err
+		}
+		out = gcm.Seal(nil, p.iv, plain, p.aad)
+	}
+	return Encode(out, opts.String("output", "base64"))
+}
+
+// AESDecrypt AES 解密，选项同 AESEncrypt；input_encoding 默认 base64，output 默认 utf8
+func AESDecrypt(data, key string, opts Options) (string, error) {
+	p, err := parseAESParams(key, opts)
+	if err != nil {
+		return "", err
+	}
+	ct, err := Decode(data, opts.String("input_encoding", "base64"))
+	if err != nil {
+		return "", fmt.Errorf("密文解码失败: %w", err)
+	}
+	block, err := aes.NewCipher(p.key)
+	if err != nil {
+		return "", err
+	}
+	var out []byte
+	switch p.mode {
+	case "CBC", "ECB":
+		if len(ct) == 0 || len(ct)%aes.BlockSize != 0 {
+			return "", fmt.Errorf("密文长度必须为 16 的整数倍，实际 %d", len(ct))
+		}
+		out = make([]byte, len(ct))
+		if p.mode == "CBC" {
+			cipher.NewCBCDecrypter(block, p.iv).CryptBlocks(out, ct)
+		} else {
+			ecbCrypt(block, out, ct, false)
+		}
+		if out, err = unpad(out, p.padding); err != nil {
+			return "", err
+		}
+	case "CTR":
+		out = make([]byte, len(ct))
+		cipher.NewCTR(block, p.iv).XORKeyStream(out, ct)
+	case "GCM":
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(p.iv))
+		if err != nil {
+			return "", err
+		}
+		if out, err = gcm.Open(nil, p.iv, ct, p.aad); err != nil {
+			return "", errors.New("GCM 认证失败，密钥、nonce 或数据不正确")
+		}
+	}
+	return Encode(out, opts.String("output", "utf8"))
+}
+
+func ecbCrypt(block cipher.Block, dst, src []byte, encrypt bool) {
+	for i := 0; i < len(src); i += aes.BlockSize {
+		if encrypt {
+			block.Encrypt(dst[i:i+aes.BlockSize], src[i:i+aes.BlockSize])
+		} else {
+			block.Decrypt(dst[i:i+aes.BlockSize], src[i:i+aes.BlockSize])
+		}
+	}
+}
+
+func pad(b []byte, padding string) ([]byte, error) {
+	switch padding {
+	case "pkcs7", "pkcs5":
+		n := aes.BlockSize - len(b)%aes.BlockSize
+		return append(append([]byte{}, b...), bytes.Repeat([]byte{byte(n)}, n)...), nil
+	case "zero":
+		if len(b)%aes.BlockSize == 0 && len(b) > 0 {
+			return b, nil
+		}
+		n := aes.BlockSize - len(b)%aes.BlockSize
+		return append(append([]byte{}, b...), make([]byte, n)...), nil
+	default:
+		if len(b)%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("无填充时明文长度必须为 16 的整数倍，实际 %d", len(b))
+		}
+		return b, nil
+	}
+}
+
+func unpad(b []byte, padding string) ([]byte, error) {
+	switch padding {
+	case "pkcs7", "pkcs5":
+		n := int(b[len(b)-1])
+		if n == 0 || n > aes.BlockSize || n > len(b) {
+			return nil, errors.New("PKCS7 填充错误，密钥或 IV 可能不正确")
+		}
+		for _, c := range b[len(b)-n:] {
+			if int(c) != n {
+				return nil, errors.New("PKCS7 填充错误，密钥或 IV 可能不正确")
+			}
+		}
+		return b[:len(b)-n], nil
+	case "zero":
+		return bytes.TrimRight(b, "\x00"), nil
+	default:
+		return b, nil
+	}
+}
+
+// ParseRSAPublicKey 解析 RSA 公钥：支持 PEM（PUBLIC KEY / RSA PUBLIC KEY）及站点 JS 中常见的无头部 base64 DER
+func ParseRSAPublicKey(key string) (*rsa.PublicKey, error) {
+	key = strings.TrimSpace(key)
+	var der []byte
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		der = block.Bytes
+	} else {
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key), ""))
+		if err != nil {
+			return nil, errors.New("无法识别的公钥格式，需为 PEM 或 base64 DER")
+		}
+		der = b
+	}
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if rsaPub, ok := pub.(*rsa.PublicKey); ok {
+			return rsaPub, nil
+		}
+		return nil, errors.New("公钥不是 RSA 类型")
+	}
+	if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return pub, nil
+	}
+	return nil, errors.New("解析 RSA 公钥失败")
+}
+
+// RSAEncrypt RSA 公钥加密。选项：padding（pkcs1/oaep，默认 pkcs1）、hash（OAEP 使用，默认 sha256）、
+// input_encoding（默认 utf8）、output（默认 base64）
+func RSAEncrypt(data, publicKey string, opts Options) (string, error) {
+	pub, err := ParseRSAPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := Decode(data, opts.String("input_encoding", "utf8"))
+	if err != nil {
+		return "", fmt.Errorf("明文解码失败: %w", err)
+	}
+	var out []byte
+	switch strings.ToLower(opts.String("padding", "pkcs1")) {
+	case "pkcs1":
+		out, err = rsa.EncryptPKCS1v15(rand.Reader, pub, plain)
+	case "oaep":
+		newFn, _, herr := newHash(opts.String("hash", "sha256"))
+		if herr != nil {
+			return "", herr
+		}
+		out, err = rsa.EncryptOAEP(newFn(), rand.Reader, pub, plain, nil)
+	default:
+		return "", fmt.Errorf("不支持的 RSA 填充方式: %s", opts.String("padding", ""))
+	}
+	if err != nil {
+		return "", err
+	}
+	return Encode(out, opts.String("output", "base64"))
+}
+
+// RSAVerify 使用 RSA 公钥验证签名。选项：padding（pkcs1/pss，默认 pkcs1）、hash（默认 sha256）、
+// signature_encoding（默认 base64）。签名不匹配返回 false 与 nil 错误
+func RSAVerify(data, signature, publicKey string, opts Options) (bool, error) {
+	pub, err := ParseRSAPublicKey(publicKey)
+	if err != nil {
+		return false, err
+	}
+	sig, err := Decode(signature, opts.String("signature_encoding", "base64"))
+	if err != nil {
+		return false, fmt.Errorf("签名解码失败: %w", err)
+	}
+	newFn, hashID, err := newHash(opts.String("hash", "sha256"))
+	if err != nil {
+		return false, err
+	}
+	h := newFn()
+	h.Write([]byte(data))
+	digest := h.Sum(nil)
+	switch strings.ToLower(opts.String("padding", "pkcs1")) {
+	case "pkcs1":
+		err = rsa.VerifyPKCS1v15(pub, hashID, digest, sig)
+	case "pss":
+		err = rsa.VerifyPSS(pub, hashID, digest, sig, nil)
+	default:
+		return false, fmt.Errorf("不支持的 RSA 填充方式: %s", opts.String("padding", ""))
+	}
+	return err == nil, nil
+}
+
+// HexEncode 十六进制编码
+func HexEncode(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+// HexDecode 十六进制解码
+func HexDecode(s string) (string, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func defaultString(s, def string) string {
+	if strings.TrimSpace(s) == "" {
+		return def
+	}
+	return s
+}
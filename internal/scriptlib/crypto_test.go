@@ -0,0 +1,99 @@
+package scriptlib
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func TestDigestAndHMAC(t *testing.T) {
+	if got, _ := Digest("md5", "abc", ""); got != "900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("md5 = %s", got)
+	}
+	if got, _ := Digest("SHA-1", "abc", "base64"); got != "qZk+NkcGgWq6PiVxeFDCbJzQ2J0=" {
+		t.Errorf("sha1 = %s", got)
+	}
+	if got, _ := HMAC("sha256", "key", "abc", nil); got != "9c196e32dc0175f86f4b1cb89289d6619de6bee699e4c378e68309ed97a1a6ab" {
+		t.Errorf("hmac = %s", got)
+	}
+	if _, err := Digest("sha3", "abc", ""); err == nil {
+		t.Error("expected unsupported algorithm error")
+	}
+}
+
+func TestAES(t *testing.T) {
+	// 期望值由 openssl enc -aes-128-cbc / -aes-128-ecb 生成
+	cbc := Options{"iv": "abcdefghabcdefgh"}
+	if got, err := AESEncrypt("hello world", "1234567890123456", cbc); err != nil || got != "JeFROc8AVv7H/owCsEqCVA==" {
+		t.Errorf("cbc encrypt = %s, %v", got, err)
+	}
+	if got, err := AESDecrypt("JeFROc8AVv7H/owCsEqCVA==", "1234567890123456", cbc); err != nil || got != "hello world" {
+		t.Errorf("cbc decrypt = %s, %v", got, err)
+	}
+	ecb := Options{"mode": "ecb", "key_encoding": "hex"}
+	if got, err := AESEncrypt("hello world", "31323334353637383930313233343536", ecb); err != nil || got != "roLzT3GBhVQw22WrUPAdsw==" {
+		t.Errorf("ecb encrypt = %s, %v", got, err)
+	}
+
+	for _, opts := range []Options{
+		{"mode": "CBC", "iv": "abcdefghabcdefgh", "padding": "zero"},
+		{"mode": "CTR", "iv": "abcdefghabcdefgh"},
+		{"mode": "GCM", "iv": "123456789012", "aad": "meta"},
+	} {
+		opts["output"] = "hex"
+		enc, err := AESEncrypt("play-url", "1234567890123456", opts)
+		if err != nil {
+			t.Fatalf("%v encrypt: %v", opts["mode"], err)
+		}
+		delete(opts, "output")
+		opts["input_encoding"] = "hex"
+		dec, err := AESDecrypt(enc, "1234567890123456", opts)
+		if err != nil || dec != "play-url" {
+			t.Errorf("%v roundtrip = %q, %v", opts["mode"], dec, err)
+		}
+	}
+
+	if _, err := AESDecrypt("JeFROc8AVv7H/owCsEqCVA==", "1234567890123456", Options{"iv": "0000000000000000"}); err == nil {
+		t.Error("expected padding error with wrong iv")
+	}
+	if _, err := AESEncrypt("x", "short", cbc); err == nil {
+		t.Error("expected key length error")
+	}
+}
+
+func TestRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	bareKey := base64.StdEncoding.EncodeToString(der)
+
+	for _, key := range []string{pemKey, bareKey} {
+		enc, err := RSAEncrypt("secret", key, nil)
+		if err != nil {
+			t.Fatalf("RSAEncrypt: %v", err)
+		}
+		raw, _ := base64.StdEncoding.DecodeString(enc)
+		plain, err := rsa.DecryptPKCS1v15(nil, priv, raw)
+		if err != nil || string(plain) != "secret" {
+			t.Errorf("decrypt = %q, %v", plain, err)
+		}
+	}
+
+	digest := sha256.Sum256([]byte("payload"))
+	sig, _ := rsa.SignPKCS1v15(nil, priv, crypto.SHA256, digest[:])
+	sigB64 := base64.StdEncoding.EncodeToString(sig)
+	if ok, err := RSAVerify("payload", sigB64, pemKey, nil); !ok || err != nil {
+		t.Errorf("verify = %v, %v", ok, err)
+	}
+	if ok, _ := RSAVerify("tampered", sigB64, pemKey, nil); ok {
+		t.Error("tampered payload verified")
+	}
+}
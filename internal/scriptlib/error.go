@@ -0,0 +1,124 @@
+package scriptlib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 脚本错误的类型
+const (
+	ScriptErrorCompile = "compile" // 语法或编译错误，脚本没有开始执行
+	ScriptErrorRuntime = "runtime" // 运行时错误（含脚本主动抛出的错误）
+)
+
+// ScriptSourceMain 调用栈中入口脚本的来源名；共享模块为 module:<name>
+const ScriptSourceMain = "script"
+
+// JSModulePrefix JS 模块包装函数的前缀，与模块代码的第一行拼接在一起，定位模块第一行的列号时需要减去
+const JSModulePrefix = "(function (module, exports, require) {"
+
+// snippetContext 源码片段中出错行前后各保留的行数
+const snippetContext = 2
+
+// ScriptError 两个引擎统一的结构化脚本错误：行号、列号对应编辑器中的入口脚本
+type ScriptError struct {
+	Engine  string             `json:"engine"`            // lua / js
+	Kind    string             `json:"kind"`              // compile / runtime
+	Message string             `json:"message"`           // 去掉位置前缀的错误信息
+	Line    int                `json:"line"`              // 入口脚本中的行号（从 1 开始）；错误发生在模块中时为调用模块的行，无法定位时为 0
+	Column  int                `json:"column"`            // 列号（从 1 开始），引擎不提供时为 0
+	Stack   []ScriptStackFrame `json:"stack"`             // 调用栈，从出错位置向外
+	Snippet []ScriptSourceLine `json:"snippet,omitempty"` // 入口脚本中出错行附近的源码
+
+	raw string // 引擎原始错误文本
+}
+
+// ScriptStackFrame 调用栈中的一层
+type ScriptStackFrame struct {
+	Function string `json:"function"` // 函数名，顶层代码为 main chunk（Lua）或 <anonymous>（JS）
+	Source   string `json:"source"`   // script 或 module:<name>
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// ScriptSourceLine 源码片段中的一行
+type ScriptSourceLine struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Current bool   `json:"current,omitempty"` // 出错行
+}
+
+// NewScriptError 创建脚本错误，raw 为引擎原始错误文本，Error() 原样返回以保持已有的错误信息不变
+func NewScriptError(engine, kind, message, raw string) *ScriptError {
+	return &ScriptError{Engine: engine, Kind: kind, Message: message, Stack: []ScriptStackFrame{}, raw: raw}
+}
+
+func (e *ScriptError) Error() string {
+	return e.raw
+}
+
+// Locate 按调用栈确定入口脚本中的位置：取最内层位于入口脚本的帧，已有位置时不覆盖
+func (e *ScriptError) Locate() *ScriptError {
+	if e.Line > 0 {
+		return e
+	}
+	for _, f := range e.Stack {
+		if f.Source == ScriptSourceMain && f.Line > 0 {
+			e.Line, e.Column = f.Line, f.Column
+			break
+		}
+	}
+	return e
+}
+
+// WithSnippet 截取入口脚本中出错行附近的源码
+func (e *ScriptError) WithSnippet(script string) *ScriptError {
+	e.Locate()
+	e.Snippet = SourceSnippet(script, e.Line)
+	return e
+}
+
+// Detail 多行文本形式：位置、错误信息与调用栈，用于纯文本日志
+func (e *ScriptError) Detail() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "第 %d 行", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, "第 %d 列", e.Column)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	for _, f := range e.Stack {
+		fmt.Fprintf(&b, "\n    at %s (%s:%d", f.Function, f.Source, f.Line)
+		if f.Column > 0 {
+			fmt.Fprintf(&b, ":%d", f.Column)
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// AsScriptError 从错误链中取出结构化脚本错误
+func AsScriptError(err error) (*ScriptError, bool) {
+	var se *ScriptError
+	if errors.As(err, &se) {
+		return se, true
+	}
+	return nil, false
+}
+
+// SourceSnippet 返回第 line 行及前后各 snippetContext 行；line 超出范围时返回 nil
+func SourceSnippet(script string, line int) []ScriptSourceLine {
+	lines := strings.Split(script, "\n")
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+	from, to := max(1, line-snippetContext), min(len(lines), line+snippetContext)
+	snippet := make([]ScriptSourceLine, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		snippet = append(snippet, ScriptSourceLine{Line: n, Text: strings.TrimRight(lines[n-1], "\r"), Current: n == line})
+	}
+	return snippet
+}
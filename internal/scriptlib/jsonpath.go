@@ -0,0 +1,39 @@
+package scriptlib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ohler55/ojg/jp"
+)
+
+// JSONPathQuery 在数据上执行 JSONPath 查询（如 $.data.list[*].name、$..url、$.list[?(@.type == 'mp4')]），
+// 返回全部匹配值。data 为字符串时按原始 JSON 解析；没有匹配时返回空切片而不是错误。
+func JSONPathQuery(data interface{}, path string) ([]interface{}, error) {
+	expr, err := jp.ParseString(path)
+	if err != nil {
+		return nil, fmt.Errorf("JSONPath 表达式错误 %q: %w", path, err)
+	}
+	if raw, ok := data.(string); ok {
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return nil, fmt.Errorf("JSON 解析失败: %w", err)
+		}
+	}
+	results := expr.Get(data)
+	if results == nil {
+		results = []interface{}{}
+	}
+	return results, nil
+}
+
+// JSONPathGet 返回第一个匹配值，没有匹配时返回 def
+func JSONPathGet(data interface{}, path string, def interface{}) (interface{}, error) {
+	results, err := JSONPathQuery(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return def, nil
+	}
+	return results[0], nil
+}
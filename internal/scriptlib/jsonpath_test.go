@@ -0,0 +1,36 @@
+package scriptlib
+
+import "testing"
+
+func TestJSONPath(t *testing.T) {
+	raw := `{"code":0,"data":{"list":[{"name":"第1集","url":"a.m3u8","type":"m3u8"},{"name":"第2集","url":"b.mp4","type":"mp4"}]}}`
+
+	names, err := JSONPathQuery(raw, "$.data.list[*].name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[1] != "第2集" {
+		t.Fatalf("names = %v", names)
+	}
+
+	url, err := JSONPathGet(raw, "$.data.list[?(@.type == 'mp4')].url", "")
+	if err != nil || url != "b.mp4" {
+		t.Fatalf("url = %v, err = %v", url, err)
+	}
+
+	// 已解码的数据与缺失路径
+	data := map[string]interface{}{"a": []interface{}{1.0, 2.0}}
+	if v, _ := JSONPathGet(data, "$.a[-1]", nil); v != 2.0 {
+		t.Fatalf("a[-1] = %v", v)
+	}
+	if v, _ := JSONPathGet(data, "$.b.c", "默认"); v != "默认" {
+		t.Fatalf("missing = %v", v)
+	}
+
+	if _, err := JSONPathQuery(data, "$.a[?(@ =="); err == nil {
+		t.Fatal("expected error for malformed path")
+	}
+	if _, err := JSONPathQuery("{bad json", "$.a"); err == nil {
+		t.Fatal("expected error for malformed JSON")
+	}
+}
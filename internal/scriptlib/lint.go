@@ -0,0 +1,219 @@
+package scriptlib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 诊断级别：error 会阻止保存，warning 只提示
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// 诊断代码
+const (
+	LintSyntax          = "syntax"           // 语法或编译错误
+	LintMissingEntry    = "missing-entry"    // 缺少入口函数
+	LintEntrySignature  = "entry-signature"  // 入口函数定义方式或参数个数不对
+	LintDisabledGlobal  = "disabled-global"  // 使用了引擎禁用的全局函数
+	LintUndefinedGlobal = "undefined-global" // 使用了未定义的全局变量
+	LintUnreachable     = "unreachable-code" // 不可达代码
+	LintUnknownModule   = "unknown-module"   // require 的共享模块不存在
+)
+
+// EntryFunction 视频接口调用的入口函数及其第一个参数
+type EntryFunction struct {
+	Name  string
+	Param string
+}
+
+// EntryFunctions 站点脚本必须定义的入口函数；调用时传入两个参数：Param 与 ctx
+var EntryFunctions = []EntryFunction{
+	{Name: "search_video", Param: "keyword"},
+	{Name: "get_video_detail", Param: "video_url"},
+	{Name: "get_play_video_detail", Param: "video_url"},
+}
+
+// entryMaxParams 入口函数实际收到的参数个数
+const entryMaxParams = 2
+
+// LintDiagnostic 一条静态检查结果，行号与列号（从 1 开始）对应编辑器中的脚本；0 表示无法定位
+type LintDiagnostic struct {
+	Severity string `json:"severity"` // error / warning
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	EndLine  int    `json:"end_line,omitempty"`
+	EndCol   int    `json:"end_column,omitempty"`
+}
+
+// LintResult 脚本静态检查结果
+type LintResult struct {
+	Engine      string           `json:"engine"` // lua / js
+	Valid       bool             `json:"valid"`  // 没有 error 级别的诊断
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+	Diagnostics []LintDiagnostic `json:"diagnostics"`
+}
+
+// EntryDef 脚本中入口函数的定义
+type EntryDef struct {
+	Line   int
+	Global bool // 定义为全局函数（引擎按全局名称调用入口函数）
+	IsFunc bool // 值可以静态确认是函数
+	Params int  // 形参个数
+	Vararg bool // 有可变参数（Lua 的 ...，JS 的 ...rest）
+}
+
+// Linter 收集两个引擎共用的诊断，负责定位列号与入口函数、共享模块的检查
+type Linter struct {
+	script      string
+	lines       []string
+	lineOffsets []int
+	diags       []LintDiagnostic
+	seen        map[string]bool
+}
+
+// NewLinter 创建针对 script 的诊断收集器
+func NewLinter(script string) *Linter {
+	l := &Linter{script: script, lines: strings.Split(script, "\n"), seen: map[string]bool{}}
+	offset := 0
+	for _, line := range l.lines {
+		l.lineOffsets = append(l.lineOffsets, offset)
+		offset += len(line) + 1
+	}
+	return l
+}
+
+// Add 添加诊断
+func (l *Linter) Add(severity, code string, line, column int, format string, args ...interface{}) {
+	l.add(LintDiagnostic{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...), Line: line, Column: column})
+}
+
+// AddIdent 添加指向标识符 name 的诊断：列号取该行中第一次出现 name 的位置，并标记到标识符结尾
+func (l *Linter) AddIdent(severity, code string, line int, name string, format string, args ...interface{}) {
+	d := LintDiagnostic{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...), Line: line}
+	if d.Column = l.IdentColumn(line, name); d.Column > 0 {
+		d.EndLine, d.EndCol = line, d.Column+utf8.RuneCountInString(name)
+	}
+	l.add(d)
+}
+
+// AddSpan 添加标记脚本中 [offset, offset+length) 字节范围的诊断
+func (l *Linter) AddSpan(severity, code string, offset, length int, format string, args ...interface{}) {
+	d := LintDiagnostic{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...)}
+	d.Line, d.Column = l.Position(offset)
+	d.EndLine, d.EndCol = l.Position(offset + length)
+	l.add(d)
+}
+
+// AddLine 添加标记整行（去掉缩进）的诊断
+func (l *Linter) AddLine(severity, code string, line int, format string, args ...interface{}) {
+	d := LintDiagnostic{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...), Line: line}
+	if line > 0 && line <= len(l.lines) {
+		text := strings.TrimRight(l.lines[line-1], " \t\r")
+		indent := len(text) - len(strings.TrimLeft(text, " \t"))
+		d.Column = indent + 1
+		d.EndLine, d.EndCol = line, utf8.RuneCountInString(text)+1
+	}
+	l.add(d)
+}
+
+// add 同一位置、同一代码与信息的诊断只保留一条
+func (l *Linter) add(d LintDiagnostic) {
+	key := fmt.Sprintf("%d:%d:%s:%s", d.Line, d.Column, d.Code, d.Message)
+	if l.seen[key] {
+		return
+	}
+	l.seen[key] = true
+	l.diags = append(l.diags, d)
+}
+
+// Position 将脚本中的字节偏移（从 0 开始）转换为行号与列号，列号按字符计算
+func (l *Linter) Position(offset int) (line, column int) {
+	i := sort.Search(len(l.lineOffsets), func(i int) bool { return l.lineOffsets[i] > offset }) - 1
+	if i < 0 {
+		return 0, 0
+	}
+	start := l.lineOffsets[i]
+	end := min(offset, len(l.script))
+	return i + 1, utf8.RuneCountInString(l.script[start:end]) + 1
+}
+
+// RuneColumn 将第 line 行中按字节计算的列号（从 1 开始）转换为按字符计算
+func (l *Linter) RuneColumn(line, byteColumn int) int {
+	if line <= 0 || line > len(l.lines) || byteColumn <= 0 {
+		return byteColumn
+	}
+	_, column := l.Position(l.lineOffsets[line-1] + byteColumn - 1)
+	return column
+}
+
+// IdentColumn name 在第 line 行中第一次作为完整标识符出现的列号，找不到时为 0
+func (l *Linter) IdentColumn(line int, name string) int {
+	if line <= 0 || line > len(l.lines) || name == "" {
+		return 0
+	}
+	text := l.lines[line-1]
+	re := regexp.MustCompile(`(^|[^\w$])` + regexp.QuoteMeta(name) + `($|[^\w$])`)
+	loc := re.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return 0
+	}
+	return utf8.RuneCountInString(text[:loc[3]]) + 1
+}
+
+// CheckEntries 检查入口函数：缺少或未定义为全局函数为 error，参数个数不对与无法确认是函数为 warning
+func (l *Linter) CheckEntries(defs map[string]EntryDef, nilName string) {
+	for _, entry := range EntryFunctions {
+		def, ok := defs[entry.Name]
+		switch {
+		case !ok:
+			l.Add(LintError, LintMissingEntry, 0, 0, "缺少入口函数 %s", entry.Name)
+		case !def.Global:
+			l.AddIdent(LintError, LintEntrySignature, def.Line, entry.Name, "入口函数 %s 必须定义在脚本顶层的全局作用域中", entry.Name)
+		case !def.IsFunc:
+			l.AddIdent(LintWarning, LintEntrySignature, def.Line, entry.Name, "无法确认 %s 是函数", entry.Name)
+		case def.Params == 0 && !def.Vararg:
+			l.AddIdent(LintWarning, LintEntrySignature, def.Line, entry.Name, "入口函数 %s 没有参数，无法读取 %s", entry.Name, entry.Param)
+		case def.Params > entryMaxParams:
+			l.AddIdent(LintWarning, LintEntrySignature, def.Line, entry.Name, "入口函数 %s 只会收到 %s 与 ctx 两个参数，其余参数始终为 %s", entry.Name, entry.Param, nilName)
+		}
+	}
+}
+
+// CheckModule 检查 require 的共享模块是否存在；未配置共享模块服务时跳过
+func (l *Linter) CheckModule(engineType int, name string, line int) {
+	if _, err := ResolveModule(engineType, name); err != nil && !errors.Is(err, ErrModuleUnavailable) {
+		l.AddIdent(LintWarning, LintUnknownModule, line, name, "require %s 失败: %v", name, err)
+	}
+}
+
+// Result 按位置排序并汇总诊断
+func (l *Linter) Result(engine string) *LintResult {
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		if l.diags[i].Line != l.diags[j].Line {
+			return l.diags[i].Line < l.diags[j].Line
+		}
+		return l.diags[i].Column < l.diags[j].Column
+	})
+	r := &LintResult{Engine: engine, Diagnostics: l.diags}
+	if r.Diagnostics == nil {
+		r.Diagnostics = []LintDiagnostic{}
+	}
+	for _, d := range r.Diagnostics {
+		if d.Severity == LintError {
+			r.Errors++
+		} else {
+			r.Warnings++
+		}
+	}
+	r.Valid = r.Errors == 0
+	return r
+}
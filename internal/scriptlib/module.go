@@ -0,0 +1,121 @@
+package scriptlib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 共享模块所属的引擎类型，与站点的 engine_type 一致
+const (
+	EngineLua = 0
+	EngineJS  = 1
+)
+
+// ErrModuleUnavailable 未注册模块解析器（例如在独立工具中运行引擎）
+var ErrModuleUnavailable = errors.New("共享模块不可用")
+
+// ModuleResolver 按名称解析共享模块源码，由服务层在启动时注册
+type ModuleResolver interface {
+	ResolveModule(engineType int, name string) (string, error)
+}
+
+var (
+	moduleResolverMutex sync.RWMutex
+	moduleResolver      ModuleResolver
+)
+
+// SetModuleResolver 注册共享模块解析器
+func SetModuleResolver(r ModuleResolver) {
+	moduleResolverMutex.Lock()
+	defer moduleResolverMutex.Unlock()
+	moduleResolver = r
+}
+
+// ResolveModule 获取共享模块源码，脚本中的 require 只能加载这里返回的模块
+func ResolveModule(engineType int, name string) (string, error) {
+	moduleResolverMutex.RLock()
+	r := moduleResolver
+	moduleResolverMutex.RUnlock()
+	if r == nil {
+		return "", ErrModuleUnavailable
+	}
+	return r.ResolveModule(engineType, name)
+}
+
+var (
+	luaRequirePattern = regexp.MustCompile(`\brequire\s*\(?\s*["']([^"']+)["']`)
+	jsRequirePattern  = regexp.MustCompile(`\brequire\s*\(\s*["']([^"']+)["']\s*\)`)
+	jsImportPattern   = regexp.MustCompile(`(?m)^([ \t]*)import\s+(?:(.+?)\s+from\s+)?["']([^"']+)["'][ \t]*;?`)
+	jsImportAsPattern = regexp.MustCompile(`\s+as\s+`)
+)
+
+// ModuleDependencies 静态扫描脚本直接引用的模块名（按出现顺序去重）
+func ModuleDependencies(engineType int, code string) []string {
+	var matches [][]string
+	if engineType == EngineJS {
+		matches = append(jsRequirePattern.FindAllStringSubmatch(code, -1), jsImportPattern.FindAllStringSubmatch(code, -1)...)
+	} else {
+		matches = luaRequirePattern.FindAllStringSubmatch(code, -1)
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range matches {
+		name := m[len(m)-1]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RewriteImports 将行首的 ES import 语句改写为 require 调用（goja 不支持模块语法），行号保持不变：
+//
+//	import x from "m"          -> var x = require("m");
+//	import * as x from "m"     -> var x = require("m");
+//	import { a, b as c } from "m" -> var { a, b: c } = require("m");
+//	import "m"                 -> require("m");
+func RewriteImports(code string) string {
+	if !strings.Contains(code, "import") {
+		return code
+	}
+	return jsImportPattern.ReplaceAllStringFunc(code, func(stmt string) string {
+		m := jsImportPattern.FindStringSubmatch(stmt)
+		indent, clause, name := m[1], strings.TrimSpace(m[2]), m[3]
+		require := fmt.Sprintf("require(%q)", name)
+		if clause == "" {
+			return indent + require + ";"
+		}
+
+		var defaultName, named string
+		if i := strings.Index(clause, "{"); i >= 0 {
+			defaultName = strings.TrimSuffix(strings.TrimSpace(clause[:i]), ",")
+			named = clause[i:]
+		} else {
+			defaultName = clause
+		}
+		defaultName = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(defaultName), "* as "))
+
+		var parts []string
+		if defaultName != "" {
+			parts = append(parts, fmt.Sprintf("var %s = %s;", defaultName, require))
+		}
+		if named != "" {
+			source := require
+			if defaultName != "" {
+				source = defaultName
+			}
+			named = jsImportAsPattern.ReplaceAllString(named, ": ")
+			parts = append(parts, fmt.Sprintf("var %s = %s;", named, source))
+		}
+		return indent + strings.Join(parts, " ")
+	})
+}
+
+// WrapJSModule 将 JS 模块代码包装为 CommonJS 风格的函数表达式，调用时传入 module、exports、require
+func WrapJSModule(code string) string {
+	return JSModulePrefix + RewriteImports(code) + "\n})"
+}
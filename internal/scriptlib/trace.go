@@ -0,0 +1,23 @@
+package scriptlib
+
+import "time"
+
+// ParseTrace HTML 解析或选择器调用的记录，用于调试时的执行时间线
+type ParseTrace struct {
+	Op         string  `json:"op"`                   // 引擎中的函数名，如 parse_html、select、xpath_one、querySelectorAll
+	Selector   string  `json:"selector,omitempty"`   // CSS 选择器或 XPath 表达式
+	InputSize  int     `json:"input_size,omitempty"` // 解析的 HTML 字节数
+	Matches    int     `json:"matches"`              // 匹配的节点数，解析成功的文档计为 1
+	Error      string  `json:"error,omitempty"`
+	Source     string  `json:"source,omitempty"` // 调用所在的源码：script 或 module:<name>
+	Line       int     `json:"line,omitempty"`   // 调用所在的行
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// ParseTracer 接收解析记录
+type ParseTracer func(ParseTrace)
+
+// ElapsedMs 自 start 起经过的毫秒数，保留到微秒
+func ElapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,35 @@
+package scriptlib
+
+import (
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// XPath 以选择集中的每个节点为上下文执行 XPath 查询，结果合并去重后作为同一文档的选择集返回，
+// 因此可以继续链式调用 CSS 选择器。
+// 表达式须返回节点集；text() 与 @attr 的结果可以通过 Text() 读取其内容。
+func XPath(sel *goquery.Selection, expr string) (*goquery.Selection, error) {
+	exp, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("XPath 表达式错误 %q: %w", expr, err)
+	}
+
+	var nodes []*html.Node
+	for i, node := range sel.Nodes {
+		// count()、string() 等表达式返回的是数值或字符串，无法转换为选择集
+		if i == 0 {
+			if _, ok := exp.Evaluate(htmlquery.CreateXPathNavigator(node)).(*xpath.NodeIterator); !ok {
+				return nil, fmt.Errorf("XPath 表达式必须返回节点集: %s", expr)
+			}
+		}
+		nodes = append(nodes, htmlquery.QuerySelectorAll(node, exp)...)
+	}
+	// 先过滤出同一文档下的空选择集再添加节点（AddNodes 会去重）。
+	// 不能用 Slice(0, 0)：它与原选择集共用底层数组，append 会覆盖原来的节点
+	empty := sel.FilterFunction(func(int, *goquery.Selection) bool { return false })
+	return empty.AddNodes(nodes...), nil
+}
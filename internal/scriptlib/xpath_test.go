@@ -0,0 +1,44 @@
+package scriptlib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestXPath(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`
+<div class="info"><span>导演</span><a href="/p/1">甲</a><span>主演</span><a href="/p/2">乙</a><a href="/p/3">丙</a></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, err := XPath(doc.Selection, `//span[text()="主演"]/following-sibling::a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actors.Length() != 2 || actors.First().Text() != "乙" {
+		t.Fatalf("actors = %d %q", actors.Length(), actors.First().Text())
+	}
+
+	// 以选择集为上下文的相对查询，结果可继续使用 CSS 选择器
+	info := doc.Find("div.info")
+	hrefs, err := XPath(info, `./a/@href`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hrefs.Length() != 3 || hrefs.Eq(2).Text() != "/p/3" {
+		t.Fatalf("hrefs = %d %q", hrefs.Length(), hrefs.Eq(2).Text())
+	}
+	if links, _ := XPath(doc.Selection, `//div`); links.Find("a").Length() != 3 {
+		t.Fatal("chained CSS selection failed")
+	}
+
+	if _, err := XPath(doc.Selection, `//a[`); err == nil {
+		t.Fatal("expected error for malformed expression")
+	}
+	if _, err := XPath(doc.Selection, `count(//a)`); err == nil {
+		t.Fatal("expected error for non node-set expression")
+	}
+}
@@ -0,0 +1,927 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"video-crawler/internal/config"
+	"video-crawler/internal/consts"
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/hls"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// DownloadService 离线下载任务管理：持久化队列、并发调度、暂停/继续/取消与进度推送
+type DownloadService interface {
+	// Create 创建下载任务并加入队列（需已解析出 MediaURL）
+	Create(task entities.DownloadTask) (entities.DownloadTask, error)
+	// List 列出任务，userID 为空时返回全部
+	List(userID string) []entities.DownloadTask
+	Get(id string) (entities.DownloadTask, bool)
+	Pause(id string) error
+	Resume(id string) error
+	Cancel(id string) error
+	// Delete 删除任务及其文件
+	Delete(id string) error
+	// FilePath 返回已完成任务的本地文件路径
+	FilePath(id string) (string, error)
+	// Subscribe 订阅任务事件，返回的函数用于取消订阅
+	Subscribe() (<-chan entities.DownloadEvent, func())
+}
+
+var (
+	ErrDownloadNotFound      = errors.New("下载任务不存在")
+	ErrDownloadQuotaExceeded = errors.New("超出下载空间配额")
+)
+
+// downloadProgressInterval 进度事件与持久化的最小间隔
+const downloadProgressInterval = 500 * time.Millisecond
+
+// downloadSegmentRetries HLS 分片下载失败重试次数
+const downloadSegmentRetries = 3
+
+type downloadService struct {
+	cfg         config.DownloadConfig
+	tasksFile   string
+	filesDir    string
+	mutex       sync.Mutex
+	tasks       map[string]*entities.DownloadTask
+	running     map[string]context.CancelFunc
+	subscribers map[chan entities.DownloadEvent]struct{}
+	lastEmit    map[string]time.Time
+	// newBrowser 创建下载使用的请求实例
+	newBrowser func() (crawler.BrowserRequest, error)
+}
+
+// NewDownloadService 创建下载服务，加载持久化队列并恢复未完成的任务。
+// 分片与密钥地址来自远程播放列表，下载时拒绝访问内网地址
+func NewDownloadService(cfg config.DownloadConfig) DownloadService {
+	return newDownloadService(cfg, crawler.NewPublicBrowser)
+}
+
+func newDownloadService(cfg config.DownloadConfig, newBrowser func() (crawler.BrowserRequest, error)) *downloadService {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 2
+	}
+	if cfg.SegmentConcurrency <= 0 {
+		cfg.SegmentConcurrency = 4
+	}
+	baseDir := filepath.Join(config.GetDataDir(), "downloads")
+	s := &downloadService{
+		cfg:         cfg,
+		tasksFile:   filepath.Join(baseDir, "tasks.json"),
+		filesDir:    filepath.Join(baseDir, "files"),
+		tasks:       map[string]*entities.DownloadTask{},
+		running:     map[string]context.CancelFunc{},
+		subscribers: map[chan entities.DownloadEvent]struct{}{},
+		lastEmit:    map[string]time.Time{},
+		newBrowser:  newBrowser,
+	}
+	if err := os.MkdirAll(s.filesDir, 0755); err != nil {
+		logrus.WithError(err).Error("Failed to create download directory")
+	}
+	s.load()
+	s.schedule()
+	return s
+}
+
+func (s *downloadService) load() {
+	data, err := os.ReadFile(s.tasksFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Error("Failed to read download tasks")
+		}
+		return
+	}
+	var list []entities.DownloadTask
+	if err := json.Unmarshal(data, &list); err != nil {
+		logrus.WithError(err).Error("Failed to parse download tasks")
+		return
+	}
+	for i := range list {
+		t := list[i]
+		// 进程退出时正在下载的任务重新排队，已下载部分会续传
+		if t.Status == consts.DownloadStatusRunning {
+			t.Status = consts.DownloadStatusPending
+		}
+		s.tasks[t.ID] = &t
+	}
+}
+
+// saveLocked 持久化任务队列，调用方需持有锁
+func (s *downloadService) saveLocked() {
+	list := s.sortedLocked("")
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal download tasks")
+		return
+	}
+	tmp := s.tasksFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logrus.WithError(err).Error("Failed to save download tasks")
+		return
+	}
+	if err := os.Rename(tmp, s.tasksFile); err != nil {
+		logrus.WithError(err).Error("Failed to save download tasks")
+	}
+}
+
+func (s *downloadService) sortedLocked(userID string) []entities.DownloadTask {
+	list := make([]entities.DownloadTask, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		if userID == "" || t.UserID == userID {
+			list = append(list, *t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt < list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+func (s *downloadService) Create(task entities.DownloadTask) (entities.DownloadTask, error) {
+	if task.MediaURL == "" {
+		return task, errors.New("播放地址不能为空")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cfg.MaxTasksPerUser > 0 {
+		active := 0
+		for _, t := range s.tasks {
+			if t.UserID == task.UserID && !isDownloadFinished(t.Status) {
+				active++
+			}
+		}
+		if active >= s.cfg.MaxTasksPerUser {
+			return task, fmt.Errorf("未完成的下载任务已达上限 %d 个", s.cfg.MaxTasksPerUser)
+		}
+	}
+	if s.quotaExceededLocked(task.UserID) {
+		return task, ErrDownloadQuotaExceeded
+	}
+
+	now := time.Now().Unix()
+	task.ID = uuid.New().String()
+	task.Status = consts.DownloadStatusPending
+	task.Error = ""
+	task.DownloadedBytes, task.TotalBytes = 0, 0
+	task.DoneSegments, task.TotalSegments = 0, 0
+	task.CreatedAt, task.UpdatedAt = now, now
+	if task.MediaType == "" && hls.IsPlaylist("", task.MediaURL, nil) {
+		task.MediaType = consts.DownloadMediaHLS
+	}
+	s.tasks[task.ID] = &task
+	s.saveLocked()
+	s.emitLocked("status", &task)
+	go s.schedule()
+	return task, nil
+}
+
+func (s *downloadService) List(userID string) []entities.DownloadTask {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sortedLocked(userID)
+}
+
+func (s *downloadService) Get(id string) (entities.DownloadTask, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return entities.DownloadTask{}, false
+	}
+	return *t, true
+}
+
+func (s *downloadService) Pause(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return ErrDownloadNotFound
+	}
+	if t.Status != consts.DownloadStatusPending && t.Status != consts.DownloadStatusRunning {
+		return fmt.Errorf("当前状态 %s 无法暂停", t.Status)
+	}
+	s.setStatusLocked(t, consts.DownloadStatusPaused, "")
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	}
+	return nil
+}
+
+func (s *downloadService) Resume(id string) error {
+	s.mutex.Lock()
+	t, ok := s.tasks[id]
+	if !ok {
+		s.mutex.Unlock()
+		return ErrDownloadNotFound
+	}
+	if t.Status != consts.DownloadStatusPaused && t.Status != consts.DownloadStatusFailed {
+		s.mutex.Unlock()
+		return fmt.Errorf("当前状态 %s 无法继续", t.Status)
+	}
+	s.setStatusLocked(t, consts.DownloadStatusPending, "")
+	s.mutex.Unlock()
+	s.schedule()
+	return nil
+}
+
+func (s *downloadService) Cancel(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return ErrDownloadNotFound
+	}
+	if isDownloadFinished(t.Status) {
+		return fmt.Errorf("当前状态 %s 无法取消", t.Status)
+	}
+	t.DownloadedBytes, t.DoneSegments = 0, 0
+	s.setStatusLocked(t, consts.DownloadStatusCanceled, "")
+	if cancel, ok := s.running[id]; ok {
+		// 正在下载的任务由下载协程退出后清理临时文件
+		cancel()
+	} else {
+		s.removeFiles(t)
+	}
+	return nil
+}
+
+func (s *downloadService) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return ErrDownloadNotFound
+	}
+	delete(s.tasks, id)
+	delete(s.lastEmit, id)
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	} else {
+		s.removeFiles(t)
+	}
+	s.saveLocked()
+	s.emitLocked("removed", t)
+	return nil
+}
+
+func (s *downloadService) FilePath(id string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return "", ErrDownloadNotFound
+	}
+	if t.Status != consts.DownloadStatusCompleted {
+		return "", errors.New("任务尚未完成")
+	}
+	return s.outputPath(t), nil
+}
+
+func (s *downloadService) Subscribe() (<-chan entities.DownloadEvent, func()) {
+	ch := make(chan entities.DownloadEvent, 64)
+	s.mutex.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mutex.Unlock()
+	return ch, func() {
+		s.mutex.Lock()
+		delete(s.subscribers, ch)
+		s.mutex.Unlock()
+	}
+}
+
+// emitLocked 广播事件，订阅方处理不过来时丢弃（前端会收到后续的最新状态）
+func (s *downloadService) emitLocked(eventType string, t *entities.DownloadTask) {
+	ev := entities.DownloadEvent{Type: eventType, Task: *t}
+	for ch := range s.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (s *downloadService) setStatusLocked(t *entities.DownloadTask, status, errMsg string) {
+	t.Status = status
+	t.Error = errMsg
+	t.UpdatedAt = time.Now().Unix()
+	s.saveLocked()
+	s.emitLocked("status", t)
+}
+
+// quotaExceededLocked 用户已占用空间（含下载中的部分）是否达到配额
+func (s *downloadService) quotaExceededLocked(userID string) bool {
+	if s.cfg.UserQuotaMB <= 0 {
+		return false
+	}
+	var used int64
+	for _, t := range s.tasks {
+		if t.UserID == userID && t.Status != consts.DownloadStatusCanceled {
+			used += t.DownloadedBytes
+		}
+	}
+	return used >= s.cfg.UserQuotaMB<<20
+}
+
+// schedule 按创建顺序启动排队中的任务，直到达到并发上限
+func (s *downloadService) schedule() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, t := range s.sortedLocked("") {
+		if len(s.running) >= s.cfg.MaxConcurrent {
+			return
+		}
+		if t.Status != consts.DownloadStatusPending {
+			continue
+		}
+		if _, busy := s.running[t.ID]; busy {
+			// 暂停后立即继续的任务，等上一次下载协程退出后再启动
+			continue
+		}
+		task := s.tasks[t.ID]
+		ctx, cancel := context.WithCancel(context.Background())
+		s.running[task.ID] = cancel
+		s.setStatusLocked(task, consts.DownloadStatusRunning, "")
+		go s.run(ctx, cancel, *task)
+	}
+}
+
+func (s *downloadService) run(ctx context.Context, cancel context.CancelFunc, task entities.DownloadTask) {
+	defer cancel()
+	err := s.download(ctx, &task)
+
+	s.mutex.Lock()
+	delete(s.running, task.ID)
+	t, ok := s.tasks[task.ID]
+	switch {
+	case !ok:
+		// 任务已被删除
+		s.removeFiles(&task)
+	case t.Status == consts.DownloadStatusCanceled:
+		s.removeFiles(t)
+	case t.Status == consts.DownloadStatusPaused:
+	case t.Status == consts.DownloadStatusPending:
+		// 已被重新加入队列，由下方的 schedule 重新启动
+	case err != nil:
+		logrus.WithError(err).WithField("task_id", task.ID).Warn("download failed")
+		s.setStatusLocked(t, consts.DownloadStatusFailed, err.Error())
+	default:
+		t.MediaType = task.MediaType
+		s.setStatusLocked(t, consts.DownloadStatusCompleted, "")
+	}
+	s.mutex.Unlock()
+	s.schedule()
+}
+
+// progress 累加下载进度，超出配额时返回错误以终止任务
+func (s *downloadService) progress(id string, bytes int64, segments int, update func(t *entities.DownloadTask)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return ErrDownloadNotFound
+	}
+	t.DownloadedBytes += bytes
+	t.DoneSegments += segments
+	if update != nil {
+		update(t)
+	}
+	t.UpdatedAt = time.Now().Unix()
+	if bytes > 0 && s.quotaExceededLocked(t.UserID) {
+		return ErrDownloadQuotaExceeded
+	}
+	if time.Since(s.lastEmit[id]) >= downloadProgressInterval || (t.TotalSegments > 0 && t.DoneSegments == t.TotalSegments) {
+		s.lastEmit[id] = time.Now()
+		s.saveLocked()
+		s.emitLocked("progress", t)
+	}
+	return nil
+}
+
+func (s *downloadService) download(ctx context.Context, task *entities.DownloadTask) error {
+	browser, err := s.newBrowser()
+	if err != nil {
+		return fmt.Errorf("创建浏览器实例失败: %w", err)
+	}
+	defer browser.Close()
+	browser.SetHeaders(task.Headers)
+	if ua := task.Headers["User-Agent"]; ua != "" {
+		browser.SetUserAgent(ua)
+	}
+
+	if task.MediaType == "" {
+		task.MediaType = consts.DownloadMediaMP4
+		resp, err := fetchWithContext(ctx, browser, task.MediaURL, map[string]string{"Range": "bytes=0-15", "Accept-Encoding": "identity"})
+		if err != nil {
+			return err
+		}
+		head, _ := io.ReadAll(io.LimitReader(resp.Body, 16))
+		resp.Body.Close()
+		if hls.IsPlaylist(resp.Header.Get("Content-Type"), resp.Request.URL.String(), head) {
+			task.MediaType = consts.DownloadMediaHLS
+		}
+		_ = s.progress(task.ID, 0, 0, func(t *entities.DownloadTask) { t.MediaType = task.MediaType })
+	}
+	if task.MediaType == consts.DownloadMediaHLS {
+		browser.SetTimeout(60 * time.Second)
+		return s.downloadHLS(ctx, browser, task)
+	}
+	// 直链文件不限制整体耗时，由取消来结束
+	browser.SetTimeout(0)
+	return s.downloadFile(ctx, browser, task)
+}
+
+// downloadFile 下载直链文件，已有 .part 时使用 Range 续传
+func (s *downloadService) downloadFile(ctx context.Context, browser crawler.BrowserRequest, task *entities.DownloadTask) error {
+	partPath := s.outputPath(task) + ".part"
+	var offset int64
+	if fi, err := os.Stat(partPath); err == nil {
+		offset = fi.Size()
+	}
+	headers := map[string]string{"Accept-Encoding": "identity"}
+	if offset > 0 {
+		headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+	}
+	resp, err := fetchWithContext(ctx, browser, task.MediaURL, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		flag |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// 已完整下载
+		return s.finishFile(task, partPath, offset)
+	case resp.StatusCode == http.StatusOK:
+		// 服务端不支持 Range，只能从头开始
+		flag |= os.O_TRUNC
+		offset = 0
+	default:
+		return fmt.Errorf("下载失败: %s", resp.Status)
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	if err := s.progress(task.ID, 0, 0, func(t *entities.DownloadTask) {
+		t.DownloadedBytes = offset
+		if total > 0 {
+			t.TotalBytes = total
+		}
+	}); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(partPath, flag, 0644)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 256*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				f.Close()
+				return err
+			}
+			offset += int64(n)
+			if err := s.progress(task.ID, int64(n), 0, nil); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			f.Close()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("下载中断: %w", readErr)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if total > 0 && offset != total {
+		return fmt.Errorf("文件不完整: %d/%d", offset, total)
+	}
+	return s.finishFile(task, partPath, offset)
+}
+
+func (s *downloadService) finishFile(task *entities.DownloadTask, partPath string, size int64) error {
+	if err := os.Rename(partPath, s.outputPath(task)); err != nil {
+		return err
+	}
+	return s.progress(task.ID, 0, 0, func(t *entities.DownloadTask) {
+		t.DownloadedBytes, t.TotalBytes = size, size
+		t.FileName = downloadFileName(t)
+	})
+}
+
+// downloadHLS 下载 HLS：选择最高码率，并发拉取并解密分片，完成后按顺序拼接为 TS（fMP4 为 MP4）
+func (s *downloadService) downloadHLS(ctx context.Context, browser crawler.BrowserRequest, task *entities.DownloadTask) error {
+	body, base, err := fetchPlaylist(ctx, browser, task.MediaURL)
+	if err != nil {
+		return err
+	}
+	if hls.IsMasterPlaylist(body) {
+		variant, ok := hls.BestVariant(hls.ParseMasterPlaylist(body, base))
+		if !ok {
+			return errors.New("主播放列表中没有可用的码率")
+		}
+		if body, base, err = fetchPlaylist(ctx, browser, variant.URI); err != nil {
+			return err
+		}
+	}
+	playlist, err := hls.ParseMediaPlaylist(body, base)
+	if err != nil {
+		return err
+	}
+	if !playlist.Ended {
+		return errors.New("不支持下载直播流")
+	}
+	if len(playlist.Segments) == 0 {
+		return errors.New("播放列表中没有分片")
+	}
+	for _, seg := range playlist.Segments {
+		if seg.Key != nil && seg.Key.Method != "AES-128" {
+			return fmt.Errorf("不支持的加密方式: %s", seg.Key.Method)
+		}
+	}
+
+	partsDir := s.partsDir(task)
+	if err := os.MkdirAll(partsDir, 0755); err != nil {
+		return err
+	}
+	// 统计已下载的分片，用于续传
+	var doneBytes int64
+	doneSegments := 0
+	pending := make([]int, 0, len(playlist.Segments))
+	for i := range playlist.Segments {
+		if fi, err := os.Stat(segmentPartPath(partsDir, i)); err == nil {
+			doneBytes += fi.Size()
+			doneSegments++
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if err := s.progress(task.ID, 0, 0, func(t *entities.DownloadTask) {
+		t.TotalSegments = len(playlist.Segments)
+		t.DoneSegments = doneSegments
+		t.DownloadedBytes = doneBytes
+	}); err != nil {
+		return err
+	}
+
+	keys := newKeyCache(browser)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		jobs     = make(chan int)
+	)
+	for w := 0; w < s.cfg.SegmentConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				size, err := downloadSegment(ctx, browser, keys, playlist.Segments[i], segmentPartPath(partsDir, i))
+				if err == nil {
+					err = s.progress(task.ID, size, 1, nil)
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("分片 %d 下载失败: %w", i, err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, i := range pending {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		if errors.Is(firstErr, context.Canceled) && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.concatSegments(ctx, browser, task, playlist, partsDir)
+}
+
+// concatSegments 按顺序拼接分片，fMP4 在初始化分片变化处写入 EXT-X-MAP
+func (s *downloadService) concatSegments(ctx context.Context, browser crawler.BrowserRequest, task *entities.DownloadTask, playlist *hls.MediaPlaylist, partsDir string) error {
+	out := s.outputPath(task)
+	if hasInitSegment(playlist) {
+		out = strings.TrimSuffix(out, filepath.Ext(out)) + ".mp4"
+	}
+	tmp := out + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	var size int64
+	lastMap := ""
+	for i, seg := range playlist.Segments {
+		if seg.MapURI != "" {
+			mapKey := seg.MapURI
+			if seg.MapRange != nil {
+				mapKey += "#" + seg.MapRange.Header()
+			}
+			if mapKey != lastMap {
+				headers := map[string]string{"Accept-Encoding": "identity"}
+				if seg.MapRange != nil {
+					headers["Range"] = seg.MapRange.Header()
+				}
+				data, err := fetchBytes(ctx, browser, seg.MapURI, headers, seg.MapRange)
+				if err != nil {
+					f.Close()
+					return fmt.Errorf("初始化分片下载失败: %w", err)
+				}
+				if _, err := f.Write(data); err != nil {
+					f.Close()
+					return err
+				}
+				size += int64(len(data))
+				lastMap = mapKey
+			}
+		}
+		part, err := os.Open(segmentPartPath(partsDir, i))
+		if err != nil {
+			f.Close()
+			return err
+		}
+		n, err := io.Copy(f, part)
+		part.Close()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		size += n
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		return err
+	}
+	_ = os.RemoveAll(partsDir)
+	return s.progress(task.ID, 0, 0, func(t *entities.DownloadTask) {
+		t.DownloadedBytes, t.TotalBytes = size, size
+		t.FileName = downloadFileName(t)
+		if hasInitSegment(playlist) {
+			t.FileName = strings.TrimSuffix(t.FileName, filepath.Ext(t.FileName)) + ".mp4"
+		}
+	})
+}
+
+// outputPath 任务输出文件路径：HLS 默认拼接为 .ts，已下载完成的任务以实际文件名后缀为准
+func (s *downloadService) outputPath(t *entities.DownloadTask) string {
+	ext := filepath.Ext(t.FileName)
+	if ext == "" {
+		ext = ".mp4"
+		if t.MediaType == consts.DownloadMediaHLS {
+			ext = ".ts"
+		}
+	}
+	return filepath.Join(s.filesDir, t.ID+ext)
+}
+
+func (s *downloadService) partsDir(t *entities.DownloadTask) string {
+	return filepath.Join(s.filesDir, t.ID+".parts")
+}
+
+// removeFiles 删除任务的输出文件与临时文件
+func (s *downloadService) removeFiles(t *entities.DownloadTask) {
+	matches, _ := filepath.Glob(filepath.Join(s.filesDir, t.ID+".*"))
+	for _, m := range matches {
+		if err := os.RemoveAll(m); err != nil {
+			logrus.WithError(err).WithField("path", m).Warn("Failed to remove download file")
+		}
+	}
+}
+
+func isDownloadFinished(status string) bool {
+	return status == consts.DownloadStatusCompleted || status == consts.DownloadStatusCanceled
+}
+
+func hasInitSegment(p *hls.MediaPlaylist) bool {
+	for _, seg := range p.Segments {
+		if seg.MapURI != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func segmentPartPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d.seg", i))
+}
+
+// downloadFileName 生成展示/另存为使用的文件名
+func downloadFileName(t *entities.DownloadTask) string {
+	name := strings.TrimSpace(t.Title)
+	if ep := strings.TrimSpace(t.Episode); ep != "" {
+		if name != "" {
+			name += " - "
+		}
+		name += ep
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = t.ID
+	}
+	ext := ".mp4"
+	if t.MediaType == consts.DownloadMediaHLS {
+		ext = ".ts"
+	}
+	return name + ext
+}
+
+// fetchWithContext 发起 GET 请求，任务取消时关闭响应体以中断传输；
+// 调用方关闭响应体时一并注销取消回调，避免长时间运行的任务累积回调
+func fetchWithContext(ctx context.Context, browser crawler.BrowserRequest, rawURL string, headers map[string]string) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp, err := browser.Do(http.MethodGet, rawURL, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	body := resp.Body
+	resp.Body = &contextBody{ReadCloser: body, stop: context.AfterFunc(ctx, func() { body.Close() })}
+	return resp, nil
+}
+
+// contextBody 关闭时注销 context.AfterFunc 注册的回调
+type contextBody struct {
+	io.ReadCloser
+	stop func() bool
+}
+
+func (b *contextBody) Close() error {
+	b.stop()
+	return b.ReadCloser.Close()
+}
+
+// fetchBytes 下载小资源（分片、密钥），r 非空时服务端不支持 Range 则在本地截取
+func fetchBytes(ctx context.Context, browser crawler.BrowserRequest, rawURL string, headers map[string]string, r *hls.ByteRange) ([]byte, error) {
+	resp, err := fetchWithContext(ctx, browser, rawURL, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if r != nil && resp.StatusCode == http.StatusOK {
+		if r.Offset+r.Length > int64(len(data)) {
+			return nil, errors.New("字节范围超出资源大小")
+		}
+		data = data[r.Offset : r.Offset+r.Length]
+	}
+	return data, nil
+}
+
+func fetchPlaylist(ctx context.Context, browser crawler.BrowserRequest, rawURL string) ([]byte, *url.URL, error) {
+	resp, err := fetchWithContext(ctx, browser, rawURL, map[string]string{"Accept-Encoding": "identity"})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("拉取播放列表失败: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Contains(body, []byte("#EXTM3U")) {
+		return nil, nil, errors.New("目标地址不是 m3u8 播放列表")
+	}
+	return body, resp.Request.URL, nil
+}
+
+// downloadSegment 下载（并解密）单个分片，先写临时文件再重命名，保证续传时已存在的分片完整
+func downloadSegment(ctx context.Context, browser crawler.BrowserRequest, keys *keyCache, seg hls.Segment, path string) (int64, error) {
+	headers := map[string]string{"Accept-Encoding": "identity"}
+	if seg.Range != nil {
+		headers["Range"] = seg.Range.Header()
+	}
+	var data []byte
+	var err error
+	for attempt := 0; attempt <= downloadSegmentRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		data, err = fetchBytes(ctx, browser, seg.URI, headers, seg.Range)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if seg.Key != nil {
+		key, err := keys.get(ctx, seg.Key.URI)
+		if err != nil {
+			return 0, fmt.Errorf("获取密钥失败: %w", err)
+		}
+		if data, err = hls.DecryptAES128(data, key, seg.IV()); err != nil {
+			return 0, err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// keyCache 缓存同一任务内的 AES 密钥，避免每个分片重复请求
+type keyCache struct {
+	browser crawler.BrowserRequest
+	mutex   sync.Mutex
+	keys    map[string][]byte
+}
+
+func newKeyCache(browser crawler.BrowserRequest) *keyCache {
+	return &keyCache{browser: browser, keys: map[string][]byte{}}
+}
+
+func (c *keyCache) get(ctx context.Context, uri string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if k, ok := c.keys[uri]; ok {
+		return k, nil
+	}
+	k, err := fetchBytes(ctx, c.browser, uri, map[string]string{"Accept-Encoding": "identity"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[uri] = k
+	return k, nil
+}
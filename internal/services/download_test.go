@@ -0,0 +1,299 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"video-crawler/internal/config"
+	"video-crawler/internal/consts"
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/entities"
+)
+
+// waitDownload 轮询任务直到满足条件
+func waitDownload(t *testing.T, s DownloadService, id string, cond func(entities.DownloadTask) bool) entities.DownloadTask {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		task, _ := s.Get(id)
+		if cond(task) {
+			return task
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("download task did not reach the expected state: %+v", task)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func isDownloadDone(task entities.DownloadTask) bool {
+	return task.Status == consts.DownloadStatusCompleted || task.Status == consts.DownloadStatusFailed
+}
+
+// stallingFileServer 提供 size 字节的文件：不带 Range 的请求只返回前一半并保持连接，
+// 直到客户端断开或测试结束；带 Range 的请求正常返回，用于测试暂停后续传。
+// 返回的函数获取目前收到的 Range 请求头
+func stallingFileServer(t *testing.T, size int) (*httptest.Server, []byte, func() []string) {
+	content := bytes.Repeat([]byte("0123456789"), size/10)
+	var mu sync.Mutex
+	ranges := []string{}
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(content))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:len(content)/2])
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server, content, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	server, content, requestRanges := stallingFileServer(t, 100000)
+
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", t.TempDir())
+	s := newDownloadService(config.DownloadConfig{}, crawler.NewDefaultBrowser)
+	task, err := s.Create(entities.DownloadTask{UserID: "u", Title: "电影", MediaURL: server.URL + "/video.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := int64(len(content) / 2)
+	waitDownload(t, s, task.ID, func(task entities.DownloadTask) bool { return task.DownloadedBytes >= half })
+
+	if err := s.Pause(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Resume(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	task = waitDownload(t, s, task.ID, isDownloadDone)
+	if task.Status != consts.DownloadStatusCompleted || task.MediaType != consts.DownloadMediaMP4 || task.FileName != "电影.mp4" {
+		t.Fatalf("task = %+v", task)
+	}
+	path, err := s.FilePath(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("file size = %d, want %d", len(data), len(content))
+	}
+	// 首个请求为类型探测，续传请求从已下载的位置开始
+	ranges := requestRanges()
+	if last := ranges[len(ranges)-1]; last != "bytes="+strconv.FormatInt(half, 10)+"-" {
+		t.Fatalf("resume range = %q, requests = %q", last, ranges)
+	}
+}
+
+func TestDownloadPauseCancel(t *testing.T) {
+	server, content, _ := stallingFileServer(t, 100000)
+
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", t.TempDir())
+	s := newDownloadService(config.DownloadConfig{}, crawler.NewDefaultBrowser)
+	task, err := s.Create(entities.DownloadTask{UserID: "u", MediaURL: server.URL + "/video.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDownload(t, s, task.ID, func(task entities.DownloadTask) bool { return task.DownloadedBytes >= int64(len(content)/2) })
+
+	if err := s.Pause(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pause(task.ID); err == nil {
+		t.Fatal("pausing a paused task should fail")
+	}
+	part := filepath.Join(config.GetDataDir(), "downloads", "files", task.ID+".mp4.part")
+	if _, err := os.Stat(part); err != nil {
+		t.Fatalf("paused task should keep its partial file: %v", err)
+	}
+
+	if err := s.Cancel(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	task = waitDownload(t, s, task.ID, func(task entities.DownloadTask) bool {
+		_, err := os.Stat(part)
+		return os.IsNotExist(err)
+	})
+	if task.Status != consts.DownloadStatusCanceled || task.DownloadedBytes != 0 {
+		t.Fatalf("task = %+v", task)
+	}
+	if err := s.Resume(task.ID); err == nil {
+		t.Fatal("resuming a canceled task should fail")
+	}
+	if _, err := s.FilePath(task.ID); err == nil {
+		t.Fatal("canceled task should have no file")
+	}
+}
+
+func TestDownloadQuota(t *testing.T) {
+	// 任务数上限：失败的任务仍占用名额，取消后释放
+	offline := func() (crawler.BrowserRequest, error) { return nil, errors.New("offline") }
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", t.TempDir())
+	s := newDownloadService(config.DownloadConfig{MaxTasksPerUser: 1}, offline)
+	first, err := s.Create(entities.DownloadTask{UserID: "u", MediaURL: "https://example.com/a.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDownload(t, s, first.ID, isDownloadDone)
+	if _, err := s.Create(entities.DownloadTask{UserID: "u", MediaURL: "https://example.com/b.mp4"}); err == nil {
+		t.Fatal("second unfinished task should exceed the per-user limit")
+	}
+	other, err := s.Create(entities.DownloadTask{UserID: "other", MediaURL: "https://example.com/c.mp4"})
+	if err != nil {
+		t.Fatalf("other users are not limited: %v", err)
+	}
+	waitDownload(t, s, other.ID, isDownloadDone)
+	if err := s.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Create(entities.DownloadTask{UserID: "u", MediaURL: "https://example.com/b.mp4"})
+	if err != nil {
+		t.Fatalf("limit should be released after cancel: %v", err)
+	}
+	waitDownload(t, s, second.ID, isDownloadDone)
+
+	// 空间配额：下载超过 1MB 时任务失败，之后不能再创建任务
+	big := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "big.mp4", time.Time{}, bytes.NewReader(make([]byte, 3<<20)))
+	}))
+	defer big.Close()
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", t.TempDir())
+	quota := newDownloadService(config.DownloadConfig{UserQuotaMB: 1}, crawler.NewDefaultBrowser)
+	task, err := quota.Create(entities.DownloadTask{UserID: "q", MediaURL: big.URL + "/big.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task = waitDownload(t, quota, task.ID, isDownloadDone)
+	if task.Status != consts.DownloadStatusFailed || task.Error != ErrDownloadQuotaExceeded.Error() {
+		t.Fatalf("task = %+v", task)
+	}
+	if _, err := quota.Create(entities.DownloadTask{UserID: "q", MediaURL: big.URL + "/big.mp4"}); err != ErrDownloadQuotaExceeded {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+}
+
+func TestDownloadRefusesPrivateNetwork(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("#EXTM3U\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n"))
+	}))
+	defer server.Close()
+
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", t.TempDir())
+	s := NewDownloadService(config.DownloadConfig{})
+	task, err := s.Create(entities.DownloadTask{UserID: "u", MediaURL: server.URL + "/index.m3u8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task = waitDownload(t, s, task.ID, isDownloadDone)
+	if task.Status != consts.DownloadStatusFailed || !strings.Contains(task.Error, crawler.ErrPrivateNetwork.Error()) {
+		t.Fatalf("task = %+v", task)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Fatalf("loopback server received %d requests", n)
+	}
+}
+
+func TestDownloadHLS(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, aes.BlockSize)
+	iv[15] = 1
+	// AES-128-CBC + PKCS#7 加密第 2 个分片
+	plain := []byte("segment-1|")
+	padded := append(plain, bytes.Repeat([]byte{byte(aes.BlockSize - len(plain)%aes.BlockSize)}, aes.BlockSize-len(plain)%aes.BlockSize)...)
+	encrypted := make([]byte, len(padded))
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	var keyRequests int32
+	resources := map[string][]byte{
+		"/master.m3u8": []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100000\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=900000\nhigh/index.m3u8\n"),
+		"/high/index.m3u8": []byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n" +
+			"#EXT-X-KEY:METHOD=AES-128,URI=\"/key\",IV=0x00000000000000000000000000000001\n#EXTINF:4,\nseg1.ts\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:4,\nseg2.ts\n#EXT-X-ENDLIST\n"),
+		"/high/seg0.ts": []byte("segment-0|"),
+		"/high/seg1.ts": encrypted,
+		"/high/seg2.ts": []byte("segment-2"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/key" {
+			atomic.AddInt32(&keyRequests, 1)
+			w.Write(key)
+			return
+		}
+		data, ok := resources[r.URL.Path]
+		if !ok {
+			// 低码率列表不应被请求
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", t.TempDir())
+	s := newDownloadService(config.DownloadConfig{SegmentConcurrency: 2}, crawler.NewDefaultBrowser)
+	task, err := s.Create(entities.DownloadTask{UserID: "u", Title: "片名", Episode: "第1集", MediaURL: server.URL + "/master.m3u8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.MediaType != consts.DownloadMediaHLS {
+		t.Fatalf("media type = %s", task.MediaType)
+	}
+
+	task = waitDownload(t, s, task.ID, isDownloadDone)
+	if task.Status != consts.DownloadStatusCompleted || task.TotalSegments != 3 || task.DoneSegments != 3 || task.FileName != "片名 - 第1集.ts" {
+		t.Fatalf("task = %+v", task)
+	}
+
+	path, err := s.FilePath(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "segment-0|segment-1|segment-2" || task.DownloadedBytes != int64(len(data)) {
+		t.Fatalf("file = %q, downloaded = %d", data, task.DownloadedBytes)
+	}
+	if keyRequests != 1 {
+		t.Fatalf("key requested %d times, want 1", keyRequests)
+	}
+	// 拼接完成后删除分片目录
+	if _, err := os.Stat(path[:len(path)-len(".ts")] + ".parts"); !os.IsNotExist(err) {
+		t.Fatalf("parts dir should be removed: %v", err)
+	}
+}
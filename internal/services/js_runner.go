@@ -8,6 +8,7 @@ import (
 	"video-crawler/internal/crawler"
 	"video-crawler/internal/entities"
 	"video-crawler/internal/jsengine"
+	"video-crawler/internal/scriptlib"
 )
 
 type JSTestService interface {
@@ -40,6 +41,8 @@ func (s *jsTestService) ExecuteScript(ctx context.Context, script string) (<-cha
 	browser.SetHeaders(headers)
 
 	eng := jsengine.New(browser) // 测试服务不需要ctxlog，保持原有行为
+	// 客户端断开时停止等待定时器与异步请求
+	eng.SetContext(ctx)
 
 	out := make(chan string, 200)
 	// 将 console.* 输出回流到前端
@@ -58,7 +61,7 @@ func (s *jsTestService) ExecuteScript(ctx context.Context, script string) (<-cha
 		// 直接执行脚本，保留调用方在结尾 return 的 {data, err}
 		m, err := eng.ExecuteWrapped(script)
 		if err != nil {
-			out <- fmt.Sprintf("[ERROR] %v", err)
+			out <- "[ERROR] " + scriptErrorDetail(err)
 			out <- "[END] 脚本执行结束"
 			return
 		}
@@ -95,64 +98,38 @@ func (s *jsTestService) ExecuteAdvancedTest(ctx context.Context, script string,
 	browser.SetHeaders(headers)
 
 	eng := jsengine.New(browser)
+	eng.SetContext(ctx)
 
-	// 构建测试脚本
-	var testScript string
-	switch method {
-	case "search_video":
-		if keyword, ok := params["keyword"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
-// 执行测试
-console.log("[TEST] 执行 search_video 方法");
-console.log("[TEST] 参数: %s");
-const result = search_video("%s");
-console.log("[TEST] 结果:", JSON.stringify(result, null, 2));
-return {data: result, err: null};
-`, script, keyword, keyword)
-		}
-	case "get_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
-// 执行测试
-console.log("[TEST] 执行 get_video_detail 方法");
-console.log("[TEST] 参数: %s");
-const result = get_video_detail("%s");
-console.log("[TEST] 结果:", JSON.stringify(result, null, 2));
-return {data: result, err: null};
-`, script, videoURL, videoURL)
-		}
-	case "get_play_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
-// 执行测试
-console.log("[TEST] 执行 get_play_video_detail 方法");
-console.log("[TEST] 参数: %s");
-const result = get_play_video_detail("%s");
-console.log("[TEST] 结果:", JSON.stringify(result, null, 2));
-return {data: result, err: null};
-`, script, videoURL, videoURL)
-		}
-	default:
-		return nil, "", fmt.Errorf("不支持的方法: %s", method)
+	// 入口函数参数，以原生值传入脚本
+	arg, err := advancedTestArg(method, params)
+	if err != nil {
+		return nil, "", err
 	}
 
 	// 收集console输出
 	var consoleOutput []string
+	for _, line := range advancedTestHeader(method, arg) {
+		consoleOutput = append(consoleOutput, "[TEST] "+line)
+	}
 	eng.SetLogSink(func(line string) {
 		consoleOutput = append(consoleOutput, line)
 	})
 
+	// 按需记录请求与解析的执行时间线
+	var trace *scriptTrace
+	if traceEnabled(ctx) {
+		trace = newScriptTrace(nil)
+		trace.attach(browser, eng)
+	}
+
 	// 执行脚本
-	result, err := eng.ExecuteWrapped(testScript)
+	result, err := eng.CallFunction(script, method, arg, advancedTestContext(method, params))
 	if err != nil {
 		return nil, "", fmt.Errorf("脚本执行失败: %w", err)
 	}
+	for _, line := range advancedTestFooter(result) {
+		consoleOutput = append(consoleOutput, "[TEST] "+line)
+	}
 
 	// 获取原始结果
 	var originalResult interface{}
@@ -162,13 +139,8 @@ return {data: result, err: null};
 		}
 	}
 
-	// 转换结果
-	var convertedResult interface{}
-	if originalResult != nil {
-		// 这里可以根据方法类型进行结构体转换
-		// 暂时返回原始结果
-		convertedResult = originalResult
-	}
+	// 转换结果：与视频接口走同一套校验转换，并给出逐字段报告
+	convertedResult, report, _ := entities.ConvertScriptResult(method, originalResult)
 
 	// 构建console输出字符串
 	consoleStr := ""
@@ -176,9 +148,13 @@ return {data: result, err: null};
 		consoleStr += line + "\n"
 	}
 
+	scriptErr, _ := result["error"].(*scriptlib.ScriptError)
 	return &entities.AdvancedTestResult{
-		Original:  originalResult,
-		Converted: convertedResult,
+		Original:    originalResult,
+		Converted:   convertedResult,
+		Report:      report,
+		ScriptError: scriptErr,
+		Trace:       trace.close(),
 	}, consoleStr, nil
 }
 
@@ -204,51 +180,13 @@ func (s *jsTestService) ExecuteAdvancedTestSSE(ctx context.Context, script strin
 	browser.SetHeaders(headers)
 
 	eng := jsengine.New(browser)
+	eng.SetContext(ctx)
 
-	// 构建测试脚本
-	var testScript string
-	switch method {
-	case "search_video":
-		if keyword, ok := params["keyword"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
-// 执行测试
-console.log("[TEST] 执行 search_video 方法");
-console.log("[TEST] 参数: %s");
-const result = search_video("%s");
-console.log("[TEST] 结果:", JSON.stringify(result, null, 2));
-return {data: result, err: null};
-`, script, keyword, keyword)
-		}
-	case "get_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
-// 执行测试
-console.log("[TEST] 执行 get_video_detail 方法");
-console.log("[TEST] 参数: %s");
-const result = get_video_detail("%s");
-console.log("[TEST] 结果:", JSON.stringify(result, null, 2));
-return {data: result, err: null};
-`, script, videoURL, videoURL)
-		}
-	case "get_play_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
-// 执行测试
-console.log("[TEST] 执行 get_play_video_detail 方法");
-console.log("[TEST] 参数: %s");
-const result = get_play_video_detail("%s");
-console.log("[TEST] 结果:", JSON.stringify(result, null, 2));
-return {data: result, err: null};
-`, script, videoURL, videoURL)
-		}
-	default:
-		return nil, fmt.Errorf("不支持的方法: %s", method)
+	// 入口函数参数，以原生值传入脚本
+	arg, err := advancedTestArg(method, params)
+	if err != nil {
+		_ = browser.Close()
+		return nil, err
 	}
 
 	// 创建输出通道
@@ -263,18 +201,44 @@ return {data: result, err: null};
 		}
 	})
 
+	// 按需记录执行时间线；异步 fetch 在其它协程中完成，关闭输出通道前先停止记录
+	var trace *scriptTrace
+	if traceEnabled(ctx) {
+		trace = newScriptTrace(func(ev entities.ScriptTraceEvent) {
+			select {
+			case out <- traceEvent(ev):
+			case <-ctx.Done():
+			}
+		})
+		trace.attach(browser, eng)
+	}
+
 	go func() {
 		defer close(out)
+		defer trace.close()
 		defer browser.Close()
 
 		out <- fmt.Sprintf("event: log\ndata: {\"message\":\"[INFO] 开始执行JS高级调试...\"}\n\n")
 
-		// 直接执行脚本，保留调用方在结尾 return 的 {data, err}
-		m, err := eng.ExecuteWrapped(testScript)
+		for _, line := range advancedTestHeader(method, arg) {
+			out <- fmt.Sprintf("event: log\ndata: {\"message\":\"[TEST] %s\"}\n\n", jsonEscape(line))
+		}
+
+		// 执行脚本并按名称调用入口函数，参数以原生值传入
+		m, err := eng.CallFunction(script, method, arg, advancedTestContext(method, params))
 		if err != nil {
+			if ev := scriptErrorEvent(err); ev != "" {
+				out <- ev
+			}
 			out <- fmt.Sprintf("event: error\ndata: {\"message\":\"%s\"}\n\n", jsonEscape(err.Error()))
 			return
 		}
+		for _, line := range advancedTestFooter(m) {
+			out <- fmt.Sprintf("event: log\ndata: {\"message\":\"[TEST] %s\"}\n\n", jsonEscape(line))
+		}
+		if se, ok := m["error"].(*scriptlib.ScriptError); ok {
+			out <- scriptErrorEvent(se)
+		}
 
 		// 获取原始结果
 		var originalResult interface{}
@@ -284,37 +248,14 @@ return {data: result, err: null};
 			}
 		}
 
-		// 根据方法类型验证和转换结果
-		var convertedResult interface{}
-		if originalResult != nil {
-			switch method {
-			case "search_video":
-				if filtered, err := entities.FilterSearchVideoResult(originalResult); err == nil {
-					convertedResult = filtered
-				} else {
-					convertedResult = originalResult
-				}
-			case "get_video_detail":
-				if filtered, err := entities.FilterVideoDetailResult(originalResult); err == nil {
-					convertedResult = filtered
-				} else {
-					convertedResult = originalResult
-				}
-			case "get_play_video_detail":
-				if filtered, err := entities.FilterPlayVideoDetailResult(originalResult); err == nil {
-					convertedResult = filtered
-				} else {
-					convertedResult = originalResult
-				}
-			default:
-				convertedResult = originalResult
-			}
-		}
+		// 与视频接口走同一套校验转换，并给出逐字段报告
+		convertedResult, report, _ := entities.ConvertScriptResult(method, originalResult)
 
 		// 发送结果事件
 		resultData := map[string]interface{}{
 			"original":  originalResult,
 			"converted": convertedResult,
+			"report":    report,
 		}
 		resultJSON, _ := json.Marshal(resultData)
 		out <- fmt.Sprintf("event: result\ndata: %s\n\n", string(resultJSON))
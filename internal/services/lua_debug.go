@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/entities"
+	lua "video-crawler/internal/luaengine"
+	"video-crawler/internal/scriptlib"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrLuaDebugSessionNotFound 调试会话不存在或已结束
+var ErrLuaDebugSessionNotFound = errors.New("调试会话不存在或已结束")
+
+// LuaDebugService Lua 单步调试会话管理：脚本在 SSE 连接中执行，暂停与命令通过会话ID关联
+type LuaDebugService interface {
+	// Start 启动调试会话，返回会话ID与 SSE 输出通道；ctx 结束（客户端断开）时脚本终止
+	Start(ctx context.Context, script string, method string, params map[string]interface{}, breakpoints []int, stopOnEntry bool) (string, <-chan string, error)
+	// Command 向会话发送调试命令，返回命令结果（variables / evaluate）
+	Command(cmd entities.LuaDebugCommand) (interface{}, error)
+}
+
+type luaDebugService struct {
+	mutex    sync.Mutex
+	sessions map[string]*lua.Debugger
+}
+
+func NewLuaDebugService() LuaDebugService {
+	return &luaDebugService{sessions: map[string]*lua.Debugger{}}
+}
+
+func (s *luaDebugService) Start(ctx context.Context, script string, method string, params map[string]interface{}, breakpoints []int, stopOnEntry bool) (string, <-chan string, error) {
+	// 入口函数参数，以原生值传入脚本
+	arg, err := advancedTestArg(method, params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 创建浏览器实例
+	browser, err := crawler.NewDefaultBrowser()
+	if err != nil {
+		return "", nil, fmt.Errorf("创建浏览器实例失败: %w", err)
+	}
+	if v := ctx.Value(CtxKeyRequestUA); v != nil {
+		if ua, ok := v.(string); ok && ua != "" {
+			browser.SetUserAgent(ua)
+		}
+	}
+
+	engine := lua.NewLuaEngine(browser)
+	debugger := lua.NewDebugger(ctx, engine, breakpoints, stopOnEntry)
+	sessionID := uuid.New().String()
+	s.mutex.Lock()
+	s.sessions[sessionID] = debugger
+	s.mutex.Unlock()
+
+	outputChan := make(chan string, 100)
+	event := func(name string, data interface{}) string {
+		b, _ := json.Marshal(data)
+		return fmt.Sprintf("event: %s\ndata: %s\n\n", name, string(b))
+	}
+	logMsg := func(msg string) string {
+		return event("log", map[string]string{"message": msg})
+	}
+
+	// 单协程串行写入，严格保证顺序
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.Errorf("Lua单步调试执行panic: %v", r)
+				outputChan <- event("error", map[string]string{"message": fmt.Sprintf("脚本执行panic: %v", r)})
+			}
+			s.mutex.Lock()
+			delete(s.sessions, sessionID)
+			s.mutex.Unlock()
+			_ = browser.Close()
+			close(outputChan)
+		}()
+
+		outputChan <- event("session", map[string]string{"session_id": sessionID})
+		for _, line := range advancedTestHeader(method, arg) {
+			outputChan <- logMsg("[TEST] " + line)
+		}
+
+		done := make(chan struct{})
+		var ret map[string]interface{}
+		var execErr error
+		go func() {
+			ret, execErr = engine.CallFunction(script, method, arg, advancedTestContext(method, params))
+			close(done)
+		}()
+
+		engOut := engine.GetOutputChannel()
+		for {
+			select {
+			case msg := <-engOut:
+				outputChan <- logMsg(msg)
+			case ev := <-debugger.Events():
+				if ev.Type == "paused" {
+					outputChan <- event("paused", ev.Pause)
+				} else {
+					outputChan <- event(ev.Type, map[string]string{})
+				}
+			case <-done:
+				engine.Close()
+				for msg := range engOut {
+					outputChan <- logMsg(msg)
+				}
+				if debugger.Aborted() {
+					outputChan <- event("error", map[string]string{"message": lua.ErrDebugAborted.Error()})
+					return
+				}
+				if execErr != nil {
+					if se, ok := scriptlib.AsScriptError(execErr); ok {
+						outputChan <- event("script_error", se)
+					}
+					outputChan <- event("error", map[string]string{"message": fmt.Sprintf("脚本执行失败: %v", execErr)})
+					return
+				}
+				for _, line := range advancedTestFooter(ret) {
+					outputChan <- logMsg("[TEST] " + line)
+				}
+				// 与视频接口走同一套校验转换，并给出逐字段报告
+				originalResult := ret["data"]
+				convertedResult, report, _ := entities.ConvertScriptResult(method, originalResult)
+				outputChan <- event("result", map[string]interface{}{
+					"original":  originalResult,
+					"converted": convertedResult,
+					"report":    report,
+				})
+				return
+			}
+		}
+	}()
+
+	return sessionID, outputChan, nil
+}
+
+func (s *luaDebugService) Command(cmd entities.LuaDebugCommand) (interface{}, error) {
+	s.mutex.Lock()
+	debugger, ok := s.sessions[cmd.SessionID]
+	s.mutex.Unlock()
+	if !ok {
+		return nil, ErrLuaDebugSessionNotFound
+	}
+
+	switch cmd.Command {
+	case entities.LuaDebugContinue:
+		return nil, debugger.Continue()
+	case entities.LuaDebugStepOver:
+		return nil, debugger.StepOver()
+	case entities.LuaDebugStepInto:
+		return nil, debugger.StepInto()
+	case entities.LuaDebugStepOut:
+		return nil, debugger.StepOut()
+	case entities.LuaDebugPause:
+		debugger.Pause()
+		return nil, nil
+	case entities.LuaDebugAbort:
+		debugger.Abort()
+		return nil, nil
+	case entities.LuaDebugBreakpoints:
+		debugger.SetBreakpoints(cmd.Breakpoints)
+		return nil, nil
+	case entities.LuaDebugVariables:
+		return debugger.Variables(cmd.Frame)
+	case entities.LuaDebugEvaluate:
+		return debugger.Evaluate(cmd.Frame, cmd.Expression)
+	}
+	return nil, fmt.Errorf("不支持的调试命令: %s", cmd.Command)
+}
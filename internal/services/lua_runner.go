@@ -8,6 +8,7 @@ import (
 	"video-crawler/internal/crawler"
 	"video-crawler/internal/entities"
 	lua "video-crawler/internal/luaengine"
+	"video-crawler/internal/scriptlib"
 
 	"github.com/sirupsen/logrus"
 )
@@ -119,7 +120,7 @@ func (s *luaTestService) ExecuteScript(ctx context.Context, script string) (<-ch
 					engOut = nil
 				}
 				if execErr != nil {
-					outputChan <- formatMsg("ERROR", fmt.Sprintf("脚本执行失败: %v", execErr))
+					outputChan <- formatMsg("ERROR", "脚本执行失败: "+scriptErrorDetail(execErr))
 				} else if ret != nil {
 					if data, mErr := json.MarshalIndent(ret, "", "  "); mErr == nil {
 						outputChan <- fmt.Sprintf("[RESULT] %s", string(data))
@@ -172,84 +173,49 @@ func (s *luaTestService) ExecuteAdvancedTest(ctx context.Context, script string,
 	}
 	browser.SetHeaders(headers)
 
+	// 入口函数参数，以原生值传入脚本
+	arg, err := advancedTestArg(method, params)
+	if err != nil {
+		return nil, "", err
+	}
+
 	// 创建Lua引擎
 	engine := lua.NewLuaEngine(browser)
 
-	// 构建测试脚本
-	var testScript string
-	switch method {
-	case "search_video":
-		if keyword, ok := params["keyword"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
--- 执行测试
-local result = search_video("%s")
-print("[TEST] 执行 search_video 方法")
-print("[TEST] 参数: %s")
-print("[TEST] 结果: " .. json_encode(result))
-return {data = result, err = nil}
-`, script, keyword, keyword)
-		}
-	case "get_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
--- 执行测试
-local result = get_video_detail("%s")
-print("[TEST] 执行 get_video_detail 方法")
-print("[TEST] 参数: %s")
-print("[TEST] 结果: " .. json_encode(result))
-return {data = result, err = nil}
-`, script, videoURL, videoURL)
-		}
-	case "get_play_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
--- 执行测试
-local result = get_play_video_detail("%s")
-print("[TEST] 执行 get_play_video_detail 方法")
-print("[TEST] 参数: %s")
-print("[TEST] 结果: " .. json_encode(result))
-return {data = result, err = nil}
-`, script, videoURL, videoURL)
-		}
-	default:
-		return nil, "", fmt.Errorf("不支持的方法: %s", method)
+	// 按需记录请求与解析的执行时间线
+	var trace *scriptTrace
+	if traceEnabled(ctx) {
+		trace = newScriptTrace(nil)
+		trace.attach(browser, engine)
 	}
 
-	// 收集console输出
-	var consoleOutput []string
-	outputChan := make(chan string, 100)
-	go func() {
-		for msg := range outputChan {
-			consoleOutput = append(consoleOutput, msg)
-		}
-	}()
-
 	// 执行脚本
-	result, err := engine.Execute(testScript)
-	fmt.Println(testScript)
+	result, err := engine.CallFunction(script, method, arg, advancedTestContext(method, params))
+	engine.Close()
 	if err != nil {
 		return nil, "", fmt.Errorf("脚本执行失败: %w", err)
 	}
 
+	// 收集console输出
+	var consoleOutput []string
+	for _, line := range advancedTestHeader(method, arg) {
+		consoleOutput = append(consoleOutput, "[TEST] "+line)
+	}
+	for msg := range engine.GetOutputChannel() {
+		consoleOutput = append(consoleOutput, msg)
+	}
+	for _, line := range advancedTestFooter(result) {
+		consoleOutput = append(consoleOutput, "[TEST] "+line)
+	}
+
 	// 获取原始结果
 	var originalResult interface{}
 	if data, ok := result["data"]; ok {
 		originalResult = data
 	}
 
-	// 转换结果
-	var convertedResult interface{}
-	if originalResult != nil {
-		// 这里可以根据方法类型进行结构体转换
-		// 暂时返回原始结果
-		convertedResult = originalResult
-	}
+	// 转换结果：与视频接口走同一套校验转换，并给出逐字段报告
+	convertedResult, report, _ := entities.ConvertScriptResult(method, originalResult)
 
 	// 构建console输出字符串
 	consoleStr := ""
@@ -260,6 +226,8 @@ return {data = result, err = nil}
 	return &entities.AdvancedTestResult{
 		Original:  originalResult,
 		Converted: convertedResult,
+		Report:    report,
+		Trace:     trace.close(),
 	}, consoleStr, nil
 }
 
@@ -297,55 +265,16 @@ func (s *luaTestService) ExecuteAdvancedTestSSE(ctx context.Context, script stri
 	}
 	browser.SetHeaders(headers)
 
+	// 入口函数参数，以原生值传入脚本
+	arg, err := advancedTestArg(method, params)
+	if err != nil {
+		_ = browser.Close()
+		return nil, err
+	}
+
 	// 创建Lua引擎
 	engine := lua.NewLuaEngine(browser)
 
-	// 构建测试脚本
-	var testScript string
-	switch method {
-	case "search_video":
-		if keyword, ok := params["keyword"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
--- 执行测试
-local result = search_video("%s")
-print("[TEST] 执行 search_video 方法")
-print("[TEST] 参数: %s")
-print("[TEST] 结果: " .. json_encode(result))
-return {data = result, err = nil}
-`, script, keyword, keyword)
-		}
-	case "get_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
--- 执行测试
-local result = get_video_detail("%s")
-print("[TEST] 执行 get_video_detail 方法")
-print("[TEST] 参数: %s")
-print("[TEST] 结果: " .. json_encode(result))
-return {data = result, err = nil}
-`, script, videoURL, videoURL)
-		}
-	case "get_play_video_detail":
-		if videoURL, ok := params["video_url"].(string); ok {
-			testScript = fmt.Sprintf(`
-%s
-
--- 执行测试
-local result = get_play_video_detail("%s")
-print("[TEST] 执行 get_play_video_detail 方法")
-print("[TEST] 参数: %s")
-print("[TEST] 结果: " .. json_encode(result))
-return {data = result, err = nil}
-`, script, videoURL, videoURL)
-		}
-	default:
-		return nil, fmt.Errorf("不支持的方法: %s", method)
-	}
-
 	// 创建输出通道
 	outputChan := make(chan string, 100)
 
@@ -353,6 +282,20 @@ return {data = result, err = nil}
 		return fmt.Sprintf("event: log\ndata: {\"message\":\"[%s] %s\"}\n\n", level, jsonEscape(msg))
 	}
 
+	// 按需记录执行时间线；记录先进入 traceChan，由下面的写入协程统一输出
+	var trace *scriptTrace
+	var traceChan chan string
+	if traceEnabled(ctx) {
+		traceChan = make(chan string, 100)
+		trace = newScriptTrace(func(ev entities.ScriptTraceEvent) {
+			select {
+			case traceChan <- traceEvent(ev):
+			case <-ctx.Done():
+			}
+		})
+		trace.attach(browser, engine)
+	}
+
 	// 单协程串行写入，严格保证顺序
 	go func() {
 		defer func() {
@@ -367,13 +310,16 @@ return {data = result, err = nil}
 
 		// 先发送开始消息
 		outputChan <- formatMsg("INFO", "开始执行Lua高级调试...")
+		for _, line := range advancedTestHeader(method, arg) {
+			outputChan <- formatMsg("TEST", line)
+		}
 
 		// 后台执行脚本；主循环继续串行转发输出
 		done := make(chan struct{})
 		var ret map[string]interface{}
 		var execErr error
 		go func() {
-			ret, execErr = engine.Execute(testScript)
+			ret, execErr = engine.CallFunction(script, method, arg, advancedTestContext(method, params))
 			close(done)
 		}()
 
@@ -386,6 +332,8 @@ return {data = result, err = nil}
 				} else {
 					outputChan <- fmt.Sprintf("event: log\ndata: {\"message\":\"%s\"}\n\n", jsonEscape(msg))
 				}
+			case ev := <-traceChan:
+				outputChan <- ev
 			case <-done:
 				// 关闭引擎，令输出通道完结，然后将剩余日志全部转发，最后输出结果
 				engine.Close()
@@ -395,46 +343,34 @@ return {data = result, err = nil}
 					}
 					engOut = nil
 				}
+				// 停止记录后转发剩余的时间线
+				trace.close()
+				for len(traceChan) > 0 {
+					outputChan <- <-traceChan
+				}
 				if execErr != nil {
 					outputChan <- formatMsg("ERROR", fmt.Sprintf("脚本执行失败: %v", execErr))
+					if ev := scriptErrorEvent(execErr); ev != "" {
+						outputChan <- ev
+					}
 				} else if ret != nil {
+					for _, line := range advancedTestFooter(ret) {
+						outputChan <- formatMsg("TEST", line)
+					}
 					// 获取原始结果
 					var originalResult interface{}
 					if data, ok := ret["data"]; ok {
 						originalResult = data
 					}
 
-					// 根据方法类型验证和转换结果
-					var convertedResult interface{}
-					if originalResult != nil {
-						switch method {
-						case "search_video":
-							if filtered, err := entities.FilterSearchVideoResult(originalResult); err == nil {
-								convertedResult = filtered
-							} else {
-								convertedResult = originalResult
-							}
-						case "get_video_detail":
-							if filtered, err := entities.FilterVideoDetailResult(originalResult); err == nil {
-								convertedResult = filtered
-							} else {
-								convertedResult = originalResult
-							}
-						case "get_play_video_detail":
-							if filtered, err := entities.FilterPlayVideoDetailResult(originalResult); err == nil {
-								convertedResult = filtered
-							} else {
-								convertedResult = originalResult
-							}
-						default:
-							convertedResult = originalResult
-						}
-					}
+					// 与视频接口走同一套校验转换，并给出逐字段报告
+					convertedResult, report, _ := entities.ConvertScriptResult(method, originalResult)
 
 					// 发送结果事件
 					resultData := map[string]interface{}{
 						"original":  originalResult,
 						"converted": convertedResult,
+						"report":    report,
 					}
 					resultJSON, _ := json.Marshal(resultData)
 					outputChan <- fmt.Sprintf("event: result\ndata: %s\n\n", string(resultJSON))
@@ -451,6 +387,77 @@ return {data = result, err = nil}
 	return outputChan, nil
 }
 
+// advancedTestArg 返回高级调试入口函数的参数：search_video 取 keyword，其它方法取 video_url
+func advancedTestArg(method string, params map[string]interface{}) (string, error) {
+	var key string
+	switch method {
+	case "search_video":
+		key = "keyword"
+	case "get_video_detail", "get_play_video_detail":
+		key = "video_url"
+	default:
+		return "", fmt.Errorf("不支持的方法: %s", method)
+	}
+	arg, _ := params[key].(string)
+	return arg, nil
+}
+
+// advancedTestContext 高级调试入口函数的 ctx 参数：params 中的其它字段作为请求参数，
+// settings 作为站点配置；键值存储只在本次调试中有效，不影响正式数据
+func advancedTestContext(method string, params map[string]interface{}) *scriptlib.CallContext {
+	values := map[string]string{}
+	for k, v := range params {
+		if s, ok := v.(string); ok {
+			values[k] = s
+		} else if v != nil {
+			b, _ := json.Marshal(v)
+			values[k] = string(b)
+		}
+	}
+	cc := scriptlib.NewCallContext(method, values)
+	if settings, ok := params["settings"].(map[string]interface{}); ok {
+		cc.Source.Settings = settings
+	}
+	cc.Store = scriptlib.NewMemoryStore()
+	return cc
+}
+
+// advancedTestHeader 高级调试开始执行入口函数前输出的说明
+func advancedTestHeader(method, arg string) []string {
+	return []string{
+		fmt.Sprintf("执行 %s 方法", method),
+		fmt.Sprintf("参数: %s", arg),
+	}
+}
+
+// advancedTestFooter 高级调试入口函数返回后输出的结果与脚本错误
+func advancedTestFooter(ret map[string]interface{}) []string {
+	data, _ := json.Marshal(ret["data"])
+	lines := []string{"结果: " + string(data)}
+	if v := ret["err"]; v != nil && fmt.Sprint(v) != "" {
+		lines = append(lines, fmt.Sprintf("脚本返回错误: %v", v))
+	}
+	return lines
+}
+
+// scriptErrorDetail 错误文本；包含结构化脚本错误时给出入口脚本中的位置与调用栈
+func scriptErrorDetail(err error) string {
+	if se, ok := scriptlib.AsScriptError(err); ok {
+		return se.Detail()
+	}
+	return err.Error()
+}
+
+// scriptErrorEvent 结构化脚本错误的 SSE 事件（script_error），供编辑器标记出错行；不包含结构化错误时返回空字符串
+func scriptErrorEvent(err error) string {
+	se, ok := scriptlib.AsScriptError(err)
+	if !ok {
+		return ""
+	}
+	b, _ := json.Marshal(se)
+	return fmt.Sprintf("event: script_error\ndata: %s\n\n", string(b))
+}
+
 // jsonEscape 转义JSON字符串
 func jsonEscape(s string) string {
 	escaped, _ := json.Marshal(s)
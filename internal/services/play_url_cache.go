@@ -0,0 +1,115 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"video-crawler/internal/entities"
+	"video-crawler/internal/scriptlib"
+)
+
+// PlayURLCacheTTL 播放地址缓存有效期（多数站点的播放地址带有时效签名，不宜过长）
+const PlayURLCacheTTL = 30 * time.Minute
+
+// playURLCacheIgnoredParams 接口自身使用、不影响脚本结果的请求参数，不计入缓存键
+var playURLCacheIgnoredParams = map[string]bool{
+	"source_id":   true,
+	"url":         true,
+	"refresh":     true,
+	"strict":      true,
+	"concurrency": true,
+}
+
+// PlayURLCacheService 剧集播放地址的内存缓存。
+// 缓存键包含站点脚本及其依赖模块版本、站点自定义配置与入口函数 ctx 中请求参数的摘要，
+// 脚本、共享模块或配置修改后旧结果自然失效，不同清晰度、线路、语言等参数的结果分开缓存。
+// cc 为 nil 时视为没有请求参数
+type PlayURLCacheService interface {
+	Get(src *entities.VideoSourceEntity, episodeURL string, cc *scriptlib.CallContext) (entities.PlayVideoDetailResult, bool)
+	Set(src *entities.VideoSourceEntity, episodeURL string, cc *scriptlib.CallContext, result entities.PlayVideoDetailResult)
+}
+
+type playURLCacheEntry struct {
+	result    entities.PlayVideoDetailResult
+	expiresAt time.Time
+}
+
+type playURLCacheService struct {
+	modules ScriptModuleService
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]playURLCacheEntry
+}
+
+func NewPlayURLCacheService(modules ScriptModuleService) PlayURLCacheService {
+	return &playURLCacheService{modules: modules, ttl: PlayURLCacheTTL, entries: map[string]playURLCacheEntry{}}
+}
+
+func (s *playURLCacheService) Get(src *entities.VideoSourceEntity, episodeURL string, cc *scriptlib.CallContext) (entities.PlayVideoDetailResult, bool) {
+	key := s.cacheKey(src, episodeURL, cc)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return entities.PlayVideoDetailResult{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return entities.PlayVideoDetailResult{}, false
+	}
+	return entry.result, true
+}
+
+func (s *playURLCacheService) Set(src *entities.VideoSourceEntity, episodeURL string, cc *scriptlib.CallContext, result entities.PlayVideoDetailResult) {
+	key := s.cacheKey(src, episodeURL, cc)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	// 写入时顺带清理过期条目，避免长期运行后无限增长
+	for k, e := range s.entries {
+		if now.After(e.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = playURLCacheEntry{result: result, expiresAt: now.Add(s.ttl)}
+}
+
+func (s *playURLCacheService) cacheKey(src *entities.VideoSourceEntity, episodeURL string, cc *scriptlib.CallContext) string {
+	script := src.LuaScript
+	if src.EngineType == 1 {
+		script = src.JsScript
+	}
+	if s.modules != nil {
+		script += "\x00" + s.modules.Fingerprint(src.EngineType, script)
+	}
+	// 站点配置按 JSON 序列化（map 的键有序），配置不变时摘要不变
+	settings, _ := json.Marshal(src.Settings)
+	script += "\x00" + string(settings) + "\x00" + callContextKey(cc)
+	sum := md5.Sum([]byte(script))
+	return src.Id + "|" + hex.EncodeToString(sum[:]) + "|" + episodeURL
+}
+
+// callContextKey 规范化 ctx 中影响脚本结果的字段：语言与按名称排序的请求参数
+func callContextKey(cc *scriptlib.CallContext) string {
+	if cc == nil {
+		return ""
+	}
+	names := make([]string, 0, len(cc.Params))
+	for k := range cc.Params {
+		if !playURLCacheIgnoredParams[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(cc.Locale)
+	for _, k := range names {
+		b.WriteString("\x00" + k + "=" + cc.Params[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"video-crawler/internal/entities"
+	"video-crawler/internal/scriptlib"
+)
+
+func TestPlayURLCacheService(t *testing.T) {
+	s := &playURLCacheService{ttl: time.Hour, entries: map[string]playURLCacheEntry{}}
+	src := &entities.VideoSourceEntity{Id: "a", EngineType: 0, LuaScript: "v1"}
+	result := entities.PlayVideoDetailResult{VideoURL: "https://cdn.example.com/1.m3u8"}
+	s.Set(src, "/play/1", nil, result)
+
+	if got, ok := s.Get(src, "/play/1", nil); !ok || got != result {
+		t.Fatalf("Get = %v, %v", got, ok)
+	}
+	if _, ok := s.Get(src, "/play/2", nil); ok {
+		t.Fatal("other episode should miss")
+	}
+	if _, ok := s.Get(&entities.VideoSourceEntity{Id: "b", LuaScript: "v1"}, "/play/1", nil); ok {
+		t.Fatal("other source should miss")
+	}
+	// 修改当前引擎的脚本后缓存失效，修改另一引擎的脚本不影响
+	if _, ok := s.Get(&entities.VideoSourceEntity{Id: "a", LuaScript: "v2"}, "/play/1", nil); ok {
+		t.Fatal("changed script should miss")
+	}
+	if _, ok := s.Get(&entities.VideoSourceEntity{Id: "a", LuaScript: "v1", JsScript: "other"}, "/play/1", nil); !ok {
+		t.Fatal("unused engine script should not affect the key")
+	}
+	if _, ok := s.Get(&entities.VideoSourceEntity{Id: "a", EngineType: 1, LuaScript: "v1"}, "/play/1", nil); ok {
+		t.Fatal("changed engine should miss")
+	}
+
+	// 站点配置与 ctx 中的请求参数计入缓存键，接口自身的参数不计入
+	withSettings := *src
+	withSettings.Settings = map[string]interface{}{"quality": "1080p"}
+	if _, ok := s.Get(&withSettings, "/play/1", nil); ok {
+		t.Fatal("changed settings should miss")
+	}
+	cc := scriptlib.NewCallContext("get_play_video_detail", map[string]string{"quality": "720p", "filter_lang": "en", "source_id": "a", "url": "/play/1"})
+	s.Set(src, "/play/1", cc, entities.PlayVideoDetailResult{VideoURL: "https://cdn.example.com/720.m3u8"})
+	if got, _ := s.Get(src, "/play/1", nil); got != result {
+		t.Fatalf("request without params = %v", got)
+	}
+	same := scriptlib.NewCallContext("get_play_video_detail", map[string]string{"filter_lang": "en", "quality": "720p", "refresh": "1"})
+	if got, ok := s.Get(src, "/play/1", same); !ok || got.VideoURL != "https://cdn.example.com/720.m3u8" {
+		t.Fatalf("same params = %v, %v", got, ok)
+	}
+	for _, params := range []map[string]string{
+		{"quality": "1080p", "filter_lang": "en"},
+		{"quality": "720p", "filter_lang": "zh"},
+		{"quality": "720p", "filter_lang": "en", "line": "2"},
+	} {
+		if _, ok := s.Get(src, "/play/1", scriptlib.NewCallContext("get_play_video_detail", params)); ok {
+			t.Fatalf("params %v should miss", params)
+		}
+	}
+	localized := scriptlib.NewCallContext("get_play_video_detail", map[string]string{"quality": "720p", "filter_lang": "en"})
+	localized.Locale = "en-US"
+	if _, ok := s.Get(src, "/play/1", localized); ok {
+		t.Fatal("other locale should miss")
+	}
+
+	s.ttl = 20 * time.Millisecond
+	s.Set(src, "/play/3", nil, result)
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := s.Get(src, "/play/3", nil); ok {
+		t.Fatal("expired entry should miss")
+	}
+	if _, ok := s.entries[s.cacheKey(src, "/play/3", nil)]; ok {
+		t.Fatal("expired entry should be removed on Get")
+	}
+	// 写入时清理其他过期条目
+	s.Set(src, "/play/4", nil, result)
+	time.Sleep(30 * time.Millisecond)
+	s.Set(src, "/play/5", nil, result)
+	if len(s.entries) != 3 {
+		t.Fatalf("entries = %d, want 3 (play/1 twice and play/5)", len(s.entries))
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"video-crawler/internal/entities"
+)
+
+func TestAdvancedTestHostileKeyword(t *testing.T) {
+	keyword := `"); error("injected") --` + "\n" + `"); throw 1; ("`
+	params := map[string]interface{}{"keyword": keyword}
+
+	luaScript := `function search_video(k) return {{name = k, url = "https://a.com/1"}}, nil end`
+	result, _, err := NewLuaTestService().ExecuteAdvancedTest(context.Background(), luaScript, "search_video", params)
+	if err != nil || !result.Report.Valid || result.Report.OutputItems != 1 {
+		t.Fatalf("lua: result = %+v, err = %v", result, err)
+	}
+
+	jsScript := `function search_video(k) { return [{name: k, url: "https://a.com/1"}] }`
+	result, _, err = NewJSTestService().ExecuteAdvancedTest(context.Background(), jsScript, "search_video", params)
+	if err != nil || !result.Report.Valid || result.Report.OutputItems != 1 {
+		t.Fatalf("js: result = %+v, err = %v", result, err)
+	}
+	items := result.Original.([]interface{})
+	if items[0].(map[string]interface{})["name"] != keyword {
+		t.Fatalf("js: name = %v", items[0])
+	}
+}
+
+func TestAdvancedTestTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/list", http.StatusFound)
+			return
+		}
+		w.Write([]byte(`<ul><li><a href="/1">a</a></li><li><a href="/2">b</a></li></ul>`))
+	}))
+	defer srv.Close()
+	ctx := context.WithValue(context.Background(), CtxKeyTrace, true)
+	params := map[string]interface{}{"keyword": srv.URL + "/old"}
+
+	check := func(name string, trace []entities.ScriptTraceEvent, op string, line int) {
+		if len(trace) != 3 || trace[0].Type != entities.ScriptTraceHTTP {
+			t.Fatalf("%s: trace = %+v", name, trace)
+		}
+		h := trace[0].HTTP
+		if h.Status != 200 || h.FinalURL != srv.URL+"/list" || len(h.Redirects) != 1 || h.BodySize == 0 || h.BodyPreview == "" {
+			t.Fatalf("%s: http = %+v", name, h)
+		}
+		p := trace[2].Parse
+		if p == nil || p.Op != op || p.Selector != "li a" || p.Matches != 2 || p.Line != line || p.Source != "script" {
+			t.Fatalf("%s: parse = %+v", name, p)
+		}
+	}
+
+	luaScript := `function search_video(u)
+  local resp = http_get(u)
+  local doc = parse_html(resp.body)
+  local links = doc:select("li a")
+  return {}, nil
+end`
+	result, _, err := NewLuaTestService().ExecuteAdvancedTest(ctx, luaScript, "search_video", params)
+	if err != nil {
+		t.Fatalf("lua: %v", err)
+	}
+	check("lua", result.Trace, "select", 4)
+
+	jsScript := `function search_video(u) {
+  const doc = parseHtml(httpGet(u).body)
+  const links = doc.querySelectorAll("li a")
+  return []
+}`
+	result, _, err = NewJSTestService().ExecuteAdvancedTest(ctx, jsScript, "search_video", params)
+	if err != nil {
+		t.Fatalf("js: %v", err)
+	}
+	check("js", result.Trace, "querySelectorAll", 3)
+
+	result, _, _ = NewJSTestService().ExecuteAdvancedTest(context.Background(), jsScript, "search_video", params)
+	if result.Trace != nil {
+		t.Fatalf("trace without flag = %+v", result.Trace)
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"video-crawler/internal/entities"
+	"video-crawler/internal/jsengine"
+	lua "video-crawler/internal/luaengine"
+	"video-crawler/internal/scriptlib"
+)
+
+// LintScript 按引擎类型静态检查脚本
+func LintScript(engineType int, script string) *scriptlib.LintResult {
+	if engineType == scriptlib.EngineJS {
+		return jsengine.Lint(script)
+	}
+	return lua.Lint(script)
+}
+
+// LintVideoSourceScript 静态检查站点当前引擎使用的脚本
+func LintVideoSourceScript(src entities.VideoSourceEntity) *scriptlib.LintResult {
+	if src.EngineType == scriptlib.EngineJS {
+		return LintScript(src.EngineType, src.JsScript)
+	}
+	return LintScript(src.EngineType, src.LuaScript)
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"video-crawler/internal/config"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/scriptlib"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// ScriptModuleService 共享脚本模块管理：持久化、版本历史与依赖分析。
+// 同时实现 scriptlib.ModuleResolver，供引擎中的 require 加载模块。
+type ScriptModuleService interface {
+	// List 列出模块（不含历史版本），engineType < 0 时返回全部
+	List(engineType int) []entities.ScriptModule
+	Get(engineType int, name string) (entities.ScriptModule, error)
+	// Save 创建或更新模块，代码变化时版本号递增并保留旧版本
+	Save(module entities.ScriptModule, userID string) (entities.ScriptModule, error)
+	Delete(engineType int, name string) error
+	// Rollback 以指定历史版本的代码生成一个新版本
+	Rollback(engineType int, name string, version int, userID string) (entities.ScriptModule, error)
+	ResolveModule(engineType int, name string) (string, error)
+	// Dependencies 返回脚本直接或间接引用的模块名
+	Dependencies(engineType int, code string) []string
+	// Fingerprint 返回脚本所依赖模块的版本摘要，模块修改后随之变化，用于缓存失效
+	Fingerprint(engineType int, code string) string
+}
+
+var ErrScriptModuleNotFound = errors.New("模块不存在")
+
+// scriptModuleHistoryLimit 每个模块保留的历史版本数
+const scriptModuleHistoryLimit = 20
+
+var scriptModuleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-/]{0,63}$`)
+
+type scriptModuleService struct {
+	file    string
+	mutex   sync.RWMutex
+	modules map[string]*entities.ScriptModule
+}
+
+// NewScriptModuleService 创建模块服务并注册为引擎的模块解析器
+func NewScriptModuleService() ScriptModuleService {
+	s := &scriptModuleService{
+		file:    filepath.Join(config.GetDataDir(), "script-modules.json"),
+		modules: map[string]*entities.ScriptModule{},
+	}
+	s.load()
+	scriptlib.SetModuleResolver(s)
+	return s
+}
+
+func scriptModuleKey(engineType int, name string) string {
+	return strconv.Itoa(engineType) + ":" + name
+}
+
+func (s *scriptModuleService) load() {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Error("Failed to read script modules")
+		}
+		return
+	}
+	var list []entities.ScriptModule
+	if err := json.Unmarshal(data, &list); err != nil {
+		logrus.WithError(err).Error("Failed to parse script modules")
+		return
+	}
+	for i := range list {
+		m := list[i]
+		s.modules[scriptModuleKey(m.EngineType, m.Name)] = &m
+	}
+}
+
+// saveLocked 持久化模块列表，调用方需持有写锁
+func (s *scriptModuleService) saveLocked() error {
+	list := make([]entities.ScriptModule, 0, len(s.modules))
+	for _, m := range s.modules {
+		list = append(list, *m)
+	}
+	sortScriptModules(list)
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0755); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+func sortScriptModules(list []entities.ScriptModule) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].EngineType != list[j].EngineType {
+			return list[i].EngineType < list[j].EngineType
+		}
+		return list[i].Name < list[j].Name
+	})
+}
+
+func (s *scriptModuleService) List(engineType int) []entities.ScriptModule {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	list := make([]entities.ScriptModule, 0, len(s.modules))
+	for _, m := range s.modules {
+		if engineType >= 0 && m.EngineType != engineType {
+			continue
+		}
+		item := *m
+		item.History = nil
+		list = append(list, item)
+	}
+	sortScriptModules(list)
+	return list
+}
+
+func (s *scriptModuleService) Get(engineType int, name string) (entities.ScriptModule, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	m, ok := s.modules[scriptModuleKey(engineType, name)]
+	if !ok {
+		return entities.ScriptModule{}, ErrScriptModuleNotFound
+	}
+	item := *m
+	item.History = append([]entities.ScriptModuleVersion(nil), m.History...)
+	return item, nil
+}
+
+func (s *scriptModuleService) Save(module entities.ScriptModule, userID string) (entities.ScriptModule, error) {
+	module.Name = strings.TrimSpace(module.Name)
+	if !scriptModuleNamePattern.MatchString(module.Name) {
+		return entities.ScriptModule{}, errors.New("模块名只能包含字母、数字、下划线、点、短横线和斜杠，且不超过 64 个字符")
+	}
+	if module.EngineType != scriptlib.EngineLua && module.EngineType != scriptlib.EngineJS {
+		return entities.ScriptModule{}, errors.New("不支持的引擎类型")
+	}
+	if err := checkScriptModuleSyntax(module.EngineType, module.Name, module.Code); err != nil {
+		return entities.ScriptModule{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// 模块依赖自身（直接或间接）时加载会无限递归，保存前拦截；
+	// 与写入在同一临界区内检查，避免并发保存分别通过检查后共同形成环
+	for _, dep := range s.dependenciesLocked(module.EngineType, module.Code, module.Name) {
+		if dep == module.Name {
+			return entities.ScriptModule{}, fmt.Errorf("模块 %s 存在循环依赖", module.Name)
+		}
+	}
+	key := scriptModuleKey(module.EngineType, module.Name)
+	existing, ok := s.modules[key]
+	now := time.Now().Unix()
+	if !ok {
+		existing = &entities.ScriptModule{Name: module.Name, EngineType: module.EngineType}
+		s.modules[key] = existing
+	}
+	updated := *existing
+	updated.Description = module.Description
+	if !ok || existing.Code != module.Code {
+		if ok {
+			updated.History = appendScriptModuleHistory(existing.History, entities.ScriptModuleVersion{
+				Version:   existing.Version,
+				Code:      existing.Code,
+				UpdatedAt: existing.UpdatedAt,
+				UpdatedBy: existing.UpdatedBy,
+			})
+		}
+		updated.Code = module.Code
+		updated.Version = existing.Version + 1
+		updated.UpdatedAt = now
+		updated.UpdatedBy = userID
+	}
+
+	previous := *existing
+	*existing = updated
+	if err := s.saveLocked(); err != nil {
+		if ok {
+			*existing = previous
+		} else {
+			delete(s.modules, key)
+		}
+		return entities.ScriptModule{}, err
+	}
+	return updated, nil
+}
+
+func appendScriptModuleHistory(history []entities.ScriptModuleVersion, v entities.ScriptModuleVersion) []entities.ScriptModuleVersion {
+	history = append(append([]entities.ScriptModuleVersion(nil), history...), v)
+	if len(history) > scriptModuleHistoryLimit {
+		history = history[len(history)-scriptModuleHistoryLimit:]
+	}
+	return history
+}
+
+func (s *scriptModuleService) Delete(engineType int, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := scriptModuleKey(engineType, name)
+	m, ok := s.modules[key]
+	if !ok {
+		return ErrScriptModuleNotFound
+	}
+	delete(s.modules, key)
+	if err := s.saveLocked(); err != nil {
+		s.modules[key] = m
+		return err
+	}
+	return nil
+}
+
+func (s *scriptModuleService) Rollback(engineType int, name string, version int, userID string) (entities.ScriptModule, error) {
+	current, err := s.Get(engineType, name)
+	if err != nil {
+		return entities.ScriptModule{}, err
+	}
+	for _, v := range current.History {
+		if v.Version == version {
+			current.Code = v.Code
+			return s.Save(current, userID)
+		}
+	}
+	return entities.ScriptModule{}, fmt.Errorf("版本 %d 不存在", version)
+}
+
+func (s *scriptModuleService) ResolveModule(engineType int, name string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	m, ok := s.modules[scriptModuleKey(engineType, name)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrScriptModuleNotFound, name)
+	}
+	return m.Code, nil
+}
+
+func (s *scriptModuleService) Dependencies(engineType int, code string) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.dependenciesLocked(engineType, code, "")
+}
+
+// dependenciesLocked 按广度优先展开依赖，调用方需持有锁；
+// override 非空时以 code 代替同名模块的已保存代码（用于保存前校验）
+func (s *scriptModuleService) dependenciesLocked(engineType int, code string, override string) []string {
+	seen := map[string]bool{}
+	var result []string
+	queue := scriptlib.ModuleDependencies(engineType, code)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+		if name == override {
+			continue
+		}
+		if m, ok := s.modules[scriptModuleKey(engineType, name)]; ok {
+			queue = append(queue, scriptlib.ModuleDependencies(engineType, m.Code)...)
+		}
+	}
+	return result
+}
+
+func (s *scriptModuleService) Fingerprint(engineType int, code string) string {
+	deps := s.Dependencies(engineType, code)
+	if len(deps) == 0 {
+		return ""
+	}
+	sort.Strings(deps)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	parts := make([]string, 0, len(deps))
+	for _, name := range deps {
+		version := 0
+		if m, ok := s.modules[scriptModuleKey(engineType, name)]; ok {
+			version = m.Version
+		}
+		parts = append(parts, name+"@"+strconv.Itoa(version))
+	}
+	return strings.Join(parts, ",")
+}
+
+// checkScriptModuleSyntax 保存前检查语法，避免错误模块影响所有依赖它的站点
+func checkScriptModuleSyntax(engineType int, name, code string) error {
+	if engineType == scriptlib.EngineJS {
+		if _, err := goja.Compile(name, scriptlib.WrapJSModule(code), false); err != nil {
+			return fmt.Errorf("语法错误: %w", err)
+		}
+		return nil
+	}
+	if _, err := parse.Parse(strings.NewReader(code), name); err != nil {
+		return fmt.Errorf("语法错误: %w", err)
+	}
+	return nil
+}
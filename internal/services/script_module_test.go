@@ -0,0 +1,106 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"video-crawler/internal/entities"
+	"video-crawler/internal/jsengine"
+	lua "video-crawler/internal/luaengine"
+	"video-crawler/internal/scriptlib"
+)
+
+func TestScriptModuleService(t *testing.T) {
+	s := &scriptModuleService{file: filepath.Join(t.TempDir(), "script-modules.json"), modules: map[string]*entities.ScriptModule{}}
+	scriptlib.SetModuleResolver(s)
+	defer scriptlib.SetModuleResolver(nil)
+
+	save := func(engineType int, name, code string) entities.ScriptModule {
+		t.Helper()
+		m, err := s.Save(entities.ScriptModule{Name: name, EngineType: engineType, Code: code}, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	save(scriptlib.EngineLua, "strings", `local M = {} function M.wrap(s) return "[" .. s .. "]" end return M`)
+	save(scriptlib.EngineLua, "utils", `local str = require("strings") local M = {} function M.title(s) return str.wrap(s) end return M`)
+	if m := save(scriptlib.EngineLua, "strings", `local M = {} function M.wrap(s) return "<" .. s .. ">" end return M`); m.Version != 2 || len(m.History) != 1 {
+		t.Fatalf("version = %d, history = %d", m.Version, len(m.History))
+	}
+
+	engine := lua.NewLuaEngine(nil)
+	defer engine.Close()
+	ret, err := engine.Execute(`local u = require("utils") return { title = u.title("x"), same = require("utils") == u }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret["title"] != "<x>" || ret["same"] != true {
+		t.Fatalf("lua result = %v", ret)
+	}
+
+	// 依赖与指纹：被依赖模块修改后指纹变化
+	script := `local u = require "utils"`
+	if deps := s.Dependencies(scriptlib.EngineLua, script); len(deps) != 2 {
+		t.Fatalf("deps = %v", deps)
+	}
+	before := s.Fingerprint(scriptlib.EngineLua, script)
+	if _, err := s.Rollback(scriptlib.EngineLua, "strings", 1, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if after := s.Fingerprint(scriptlib.EngineLua, script); after == before {
+		t.Fatalf("fingerprint unchanged: %s", after)
+	}
+
+	if _, err := s.Save(entities.ScriptModule{Name: "strings", EngineType: scriptlib.EngineLua, Code: `return require("utils")`}, "admin"); err == nil {
+		t.Fatal("expected circular dependency error")
+	}
+	if _, err := s.Save(entities.ScriptModule{Name: "bad", EngineType: scriptlib.EngineLua, Code: `function (`}, "admin"); err == nil {
+		t.Fatal("expected syntax error")
+	}
+
+	save(scriptlib.EngineJS, "fmt", `module.exports = { pad: function (n) { return n < 10 ? "0" + n : "" + n } }`)
+	save(scriptlib.EngineJS, "ep", `const fmt = require("fmt"); exports.name = function (n) { return "第" + fmt.pad(n) + "集" }`)
+	js := jsengine.New(nil)
+	m, err := js.ExecuteWrapped("import { name } from \"ep\"\nimport * as fmt from 'fmt';\n({ a: name(3), b: fmt.pad(12) })")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["a"] != "第03集" || m["b"] != "12" {
+		t.Fatalf("js result = %v", m)
+	}
+	save(scriptlib.EngineJS, "broken", `throw new Error("boom")`)
+	m, err = js.ExecuteWrapped(`var e; try { require("broken") } catch (x) { e = x.message } try { require("missing") } catch (x) { e += "|" + (x instanceof Error) } ({ e: e })`)
+	if err != nil || m["e"] != "boom|true" {
+		t.Fatalf("js errors = %v, %v", m, err)
+	}
+
+	// 重新加载持久化文件
+	reloaded := &scriptModuleService{file: s.file, modules: map[string]*entities.ScriptModule{}}
+	reloaded.load()
+	if got, err := reloaded.Get(scriptlib.EngineLua, "strings"); err != nil || got.Version != 3 || len(got.History) != 2 {
+		t.Fatalf("reloaded = %+v, %v", got, err)
+	}
+}
+
+func TestScriptModuleConcurrentCycle(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		s := &scriptModuleService{file: filepath.Join(t.TempDir(), "script-modules.json"), modules: map[string]*entities.ScriptModule{}}
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for j, m := range [][2]string{{"a", `return require("b")`}, {"b", `return require("a")`}} {
+			wg.Add(1)
+			go func(j int, name, code string) {
+				defer wg.Done()
+				_, errs[j] = s.Save(entities.ScriptModule{Name: name, EngineType: scriptlib.EngineLua, Code: code}, "admin")
+			}(j, m[0], m[1])
+		}
+		wg.Wait()
+		// 两个保存不能同时成功，否则形成 a -> b -> a
+		if errs[0] == nil && errs[1] == nil {
+			t.Fatalf("concurrent saves created a cycle: %v", s.Dependencies(scriptlib.EngineLua, `require("a")`))
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"video-crawler/internal/config"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/scriptlib"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ScriptStoreService 站点脚本的键值存储：按站点隔离，支持过期时间，每个站点持久化为数据目录下的一个文件。
+// 修改后延迟 scriptStoreFlushDelay 合并写入，只写有变化的站点。
+// 同时实现 scriptlib.StoreProvider，供脚本中的 store / ctx.store 使用。
+type ScriptStoreService interface {
+	SourceStore(sourceID string) scriptlib.Store
+	// Summaries 列出有数据的站点及其键数量与占用
+	Summaries() []entities.ScriptStoreSummary
+	// Entries 列出站点的所有未过期键值，按键名排序
+	Entries(sourceID string) []entities.ScriptStoreEntry
+	// Clear 删除站点的指定键，key 为空时清空整个站点，返回删除的键数量
+	Clear(sourceID string, key string) (int, error)
+	// Flush 立即写入尚未持久化的修改，用于退出前
+	Flush() error
+}
+
+// 单个站点键值存储的限制
+const (
+	scriptStoreMaxKeys      = 1000
+	scriptStoreMaxKeyLen    = 256
+	scriptStoreMaxValueSize = 64 * 1024
+)
+
+// scriptStoreFlushDelay 修改后延迟写入的时间，期间的多次修改合并为一次写入
+const scriptStoreFlushDelay = time.Second
+
+var ErrScriptStoreFull = fmt.Errorf("键值存储已满（每个站点最多 %d 个键）", scriptStoreMaxKeys)
+
+type scriptStoreItem struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt int64           `json:"expires_at,omitempty"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+func (i *scriptStoreItem) expired(now time.Time) bool {
+	return i.ExpiresAt > 0 && now.Unix() >= i.ExpiresAt
+}
+
+// scriptStoreFile 单个站点的存储文件
+type scriptStoreFile struct {
+	SourceID string                      `json:"source_id"`
+	Items    map[string]*scriptStoreItem `json:"items"`
+}
+
+type scriptStoreService struct {
+	dir string
+	// mutex 保护内存数据与待写入状态，不在持有时读写文件
+	mutex      sync.Mutex
+	sources    map[string]map[string]*scriptStoreItem
+	dirty      map[string]bool
+	flushTimer *time.Timer
+	// flushMutex 串行化写入，避免较早的快照覆盖较新的快照
+	flushMutex sync.Mutex
+}
+
+// NewScriptStoreService 创建键值存储服务并注册为引擎的存储提供者
+func NewScriptStoreService() ScriptStoreService {
+	s := newScriptStoreService(filepath.Join(config.GetDataDir(), "script-store"))
+	s.load()
+	s.migrate(filepath.Join(config.GetDataDir(), "script-store.json"))
+	scriptlib.SetStoreProvider(s)
+	return s
+}
+
+func newScriptStoreService(dir string) *scriptStoreService {
+	return &scriptStoreService{dir: dir, sources: map[string]map[string]*scriptStoreItem{}, dirty: map[string]bool{}}
+}
+
+func (s *scriptStoreService) load() {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Error("Failed to read script store")
+		}
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, f.Name()))
+		if err != nil {
+			logrus.WithError(err).WithField("file", f.Name()).Error("Failed to read script store")
+			continue
+		}
+		var file scriptStoreFile
+		if err := json.Unmarshal(data, &file); err != nil || file.SourceID == "" {
+			logrus.WithError(err).WithField("file", f.Name()).Error("Failed to parse script store")
+			continue
+		}
+		if len(file.Items) > 0 {
+			s.sources[file.SourceID] = file.Items
+		}
+	}
+}
+
+// migrate 将旧版本的单文件存储拆分为按站点的文件，完成后删除旧文件
+func (s *scriptStoreService) migrate(legacyFile string) {
+	data, err := os.ReadFile(legacyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Error("Failed to read legacy script store")
+		}
+		return
+	}
+	var sources map[string]map[string]*scriptStoreItem
+	if err := json.Unmarshal(data, &sources); err != nil {
+		logrus.WithError(err).Error("Failed to parse legacy script store")
+		return
+	}
+	s.mutex.Lock()
+	for sourceID, items := range sources {
+		if _, exists := s.sources[sourceID]; !exists && len(items) > 0 {
+			s.sources[sourceID] = items
+			s.dirty[sourceID] = true
+		}
+	}
+	s.mutex.Unlock()
+	if err := s.Flush(); err != nil {
+		logrus.WithError(err).Error("Failed to migrate script store")
+		return
+	}
+	if err := os.Remove(legacyFile); err != nil {
+		logrus.WithError(err).Error("Failed to remove legacy script store")
+	}
+}
+
+// sourceFile 站点的存储文件，文件名使用站点 ID 的摘要，避免 ID 中的特殊字符
+func (s *scriptStoreService) sourceFile(sourceID string) string {
+	sum := md5.Sum([]byte(sourceID))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// markDirtyLocked 记录站点有修改并安排延迟写入，调用方需持有锁
+func (s *scriptStoreService) markDirtyLocked(sourceID string) {
+	s.dirty[sourceID] = true
+	if s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(scriptStoreFlushDelay, func() {
+			if err := s.Flush(); err != nil {
+				logrus.WithError(err).Error("Failed to save script store")
+			}
+		})
+	}
+}
+
+// Flush 在锁内清理过期数据并序列化有修改的站点，释放锁后再写文件；写入失败的站点等待下次写入
+func (s *scriptStoreService) Flush() error {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.mutex.Lock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	now := time.Now()
+	snapshot := make(map[string][]byte, len(s.dirty))
+	var marshalErr error
+	for sourceID := range s.dirty {
+		items := s.sources[sourceID]
+		for key, item := range items {
+			if item.expired(now) {
+				delete(items, key)
+			}
+		}
+		if len(items) == 0 {
+			delete(s.sources, sourceID)
+			snapshot[sourceID] = nil
+			continue
+		}
+		data, err := json.Marshal(scriptStoreFile{SourceID: sourceID, Items: items})
+		if err != nil {
+			marshalErr = err
+			continue
+		}
+		snapshot[sourceID] = data
+	}
+	s.dirty = map[string]bool{}
+	s.mutex.Unlock()
+	if len(snapshot) == 0 {
+		return marshalErr
+	}
+
+	firstErr := marshalErr
+	var failed []string
+	dirErr := os.MkdirAll(s.dir, 0755)
+	for sourceID, data := range snapshot {
+		err := dirErr
+		if err == nil {
+			err = s.writeSource(sourceID, data)
+		}
+		if err != nil {
+			failed = append(failed, sourceID)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if len(failed) > 0 {
+		s.mutex.Lock()
+		for _, sourceID := range failed {
+			s.markDirtyLocked(sourceID)
+		}
+		s.mutex.Unlock()
+	}
+	return firstErr
+}
+
+// writeSource 写入站点文件，data 为 nil 时删除文件
+func (s *scriptStoreService) writeSource(sourceID string, data []byte) error {
+	file := s.sourceFile(sourceID)
+	if data == nil {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *scriptStoreService) SourceStore(sourceID string) scriptlib.Store {
+	return &sourceScriptStore{service: s, sourceID: sourceID}
+}
+
+func (s *scriptStoreService) get(sourceID, key string) (interface{}, bool) {
+	s.mutex.Lock()
+	item, ok := s.sources[sourceID][key]
+	if ok && item.expired(time.Now()) {
+		delete(s.sources[sourceID], key)
+		ok = false
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+	var value interface{}
+	if err := json.Unmarshal(item.Value, &value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (s *scriptStoreService) set(sourceID, key string, value interface{}, ttl time.Duration) error {
+	if key == "" || len(key) > scriptStoreMaxKeyLen {
+		return fmt.Errorf("键名长度应为 1~%d", scriptStoreMaxKeyLen)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("值无法序列化为 JSON: %w", err)
+	}
+	if len(data) > scriptStoreMaxValueSize {
+		return fmt.Errorf("值过大（%d 字节，上限 %d）", len(data), scriptStoreMaxValueSize)
+	}
+	now := time.Now()
+	item := &scriptStoreItem{Value: data, UpdatedAt: now.Unix()}
+	if ttl > 0 {
+		item.ExpiresAt = now.Add(ttl).Unix()
+		if item.ExpiresAt <= now.Unix() {
+			item.ExpiresAt = now.Unix() + 1
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	items := s.sources[sourceID]
+	if items == nil {
+		items = map[string]*scriptStoreItem{}
+		s.sources[sourceID] = items
+	}
+	if _, exists := items[key]; !exists && len(items) >= scriptStoreMaxKeys {
+		return ErrScriptStoreFull
+	}
+	items[key] = item
+	s.markDirtyLocked(sourceID)
+	return nil
+}
+
+func (s *scriptStoreService) Summaries() []entities.ScriptStoreSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	list := []entities.ScriptStoreSummary{}
+	for sourceID, items := range s.sources {
+		summary := entities.ScriptStoreSummary{SourceID: sourceID}
+		for _, item := range items {
+			if !item.expired(now) {
+				summary.Keys++
+				summary.Size += len(item.Value)
+			}
+		}
+		if summary.Keys > 0 {
+			list = append(list, summary)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SourceID < list[j].SourceID })
+	return list
+}
+
+func (s *scriptStoreService) Entries(sourceID string) []entities.ScriptStoreEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	list := []entities.ScriptStoreEntry{}
+	for key, item := range s.sources[sourceID] {
+		if item.expired(now) {
+			continue
+		}
+		var value interface{}
+		_ = json.Unmarshal(item.Value, &value)
+		list = append(list, entities.ScriptStoreEntry{
+			Key:       key,
+			Value:     value,
+			Size:      len(item.Value),
+			ExpiresAt: item.ExpiresAt,
+			UpdatedAt: item.UpdatedAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+func (s *scriptStoreService) Clear(sourceID string, key string) (int, error) {
+	if sourceID == "" {
+		return 0, errors.New("站点ID不能为空")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	items := s.sources[sourceID]
+	removed := 0
+	now := time.Now()
+	if key == "" {
+		for _, item := range items {
+			if !item.expired(now) {
+				removed++
+			}
+		}
+		delete(s.sources, sourceID)
+	} else if item, ok := items[key]; ok {
+		if !item.expired(now) {
+			removed = 1
+		}
+		delete(items, key)
+	}
+	s.markDirtyLocked(sourceID)
+	return removed, nil
+}
+
+// sourceScriptStore 绑定到单个站点的 scriptlib.Store
+type sourceScriptStore struct {
+	service  *scriptStoreService
+	sourceID string
+}
+
+func (s *sourceScriptStore) Get(key string) (interface{}, bool) {
+	return s.service.get(s.sourceID, key)
+}
+
+func (s *sourceScriptStore) Set(key string, value interface{}, ttl time.Duration) error {
+	return s.service.set(s.sourceID, key, value, ttl)
+}
+
+func (s *sourceScriptStore) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	_, err := s.service.Clear(s.sourceID, key)
+	return err
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"video-crawler/internal/entities"
+	lua "video-crawler/internal/luaengine"
+	"video-crawler/internal/scriptlib"
+)
+
+func TestScriptStoreService(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "script-store")
+	s := newScriptStoreService(dir)
+	scriptlib.SetStoreProvider(s)
+	defer scriptlib.SetStoreProvider(nil)
+
+	// 脚本通过全局 store 与 ctx.store 读写，跨引擎实例保留
+	script := `
+function search_video(k, ctx)
+  local token = store.get("token")
+  if token == nil then
+    token = "t-" .. k
+    assert(ctx.store.set("token", token))
+    assert(store.set("tmp", {1, 2}, 0.001))
+  end
+  return token, nil
+end`
+	for i := 0; i < 2; i++ {
+		cc := scriptlib.NewCallContext("search_video", nil)
+		cc.Store = scriptlib.SourceStore("s1")
+		e := lua.NewLuaEngine(nil)
+		ret, err := e.CallFunction(script, "search_video", "a"+strings.Repeat("b", i), cc)
+		e.Close()
+		if err != nil || ret["data"] != "t-a" {
+			t.Fatalf("call %d = %v, %v", i, ret, err)
+		}
+	}
+
+	// 修改延迟写入，多次修改合并为一次
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("store written before flush delay: %v", err)
+	}
+	s.SourceStore("s2").Set("k", 1, 0)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Fatalf("files = %d, want one per source", len(files))
+	}
+
+	// 过期的键不再返回；重新加载后数据仍在
+	time.Sleep(1100 * time.Millisecond)
+	reloaded := newScriptStoreService(dir)
+	reloaded.load()
+	entries := reloaded.Entries("s1")
+	if len(entries) != 1 || entries[0].Key != "token" || entries[0].Value != "t-a" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if summaries := reloaded.Summaries(); len(summaries) != 2 || summaries[0] != (entities.ScriptStoreSummary{SourceID: "s1", Keys: 1, Size: 5}) {
+		t.Fatalf("summaries = %+v", summaries)
+	}
+
+	if err := reloaded.SourceStore("s1").Set("big", strings.Repeat("x", scriptStoreMaxValueSize), 0); err == nil {
+		t.Fatal("oversized value should be rejected")
+	}
+	if n, err := reloaded.Clear("s1", ""); err != nil || n != 1 || len(reloaded.Entries("s1")) != 0 {
+		t.Fatalf("clear = %d, %v", n, err)
+	}
+	// 清空的站点在写入时删除文件
+	if err := reloaded.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(reloaded.sourceFile("s1")); !os.IsNotExist(err) {
+		t.Fatalf("cleared source file should be removed: %v", err)
+	}
+}
+
+func TestScriptStoreMigrate(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "script-store.json")
+	if err := os.WriteFile(legacy, []byte(`{"s1":{"token":{"value":"t","updated_at":1}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newScriptStoreService(filepath.Join(dir, "script-store"))
+	s.load()
+	s.migrate(legacy)
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Fatalf("legacy file should be removed: %v", err)
+	}
+	reloaded := newScriptStoreService(s.dir)
+	reloaded.load()
+	if v, ok := reloaded.SourceStore("s1").Get("token"); !ok || v != "t" {
+		t.Fatalf("migrated token = %v, %v", v, ok)
+	}
+}
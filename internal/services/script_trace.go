@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"video-crawler/internal/crawler"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/scriptlib"
+)
+
+// CtxKeyTrace 上下文中存放是否记录执行时间线的 key
+const CtxKeyTrace CtxKey = "trace"
+
+// traceEnabled 上下文是否要求记录执行时间线
+func traceEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(CtxKeyTrace).(bool)
+	return enabled
+}
+
+// scriptTrace 收集一次高级调试的执行时间线；请求可能来自异步 fetch 协程，记录加锁串行。
+// 关闭后到达的记录（如事件循环超时后才完成的请求）直接丢弃
+type scriptTrace struct {
+	mu     sync.Mutex
+	start  time.Time
+	events []entities.ScriptTraceEvent
+	sink   func(entities.ScriptTraceEvent) // 非 nil 时每条记录同时推送给它（SSE）
+	closed bool
+}
+
+func newScriptTrace(sink func(entities.ScriptTraceEvent)) *scriptTrace {
+	return &scriptTrace{start: time.Now(), sink: sink}
+}
+
+// attach 为浏览器与引擎设置记录回调；浏览器不支持记录时只记录解析
+func (t *scriptTrace) attach(browser crawler.BrowserRequest, engine interface {
+	SetParseTracer(scriptlib.ParseTracer)
+}) {
+	if tb, ok := browser.(crawler.TraceableBrowser); ok {
+		tb.SetTracer(t.http)
+	}
+	engine.SetParseTracer(t.parse)
+}
+
+func (t *scriptTrace) http(rec crawler.HTTPTrace) {
+	t.add(entities.ScriptTraceEvent{Type: entities.ScriptTraceHTTP, HTTP: &rec})
+}
+
+func (t *scriptTrace) parse(rec scriptlib.ParseTrace) {
+	t.add(entities.ScriptTraceEvent{Type: entities.ScriptTraceParse, Parse: &rec})
+}
+
+func (t *scriptTrace) add(ev entities.ScriptTraceEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	ev.Seq = len(t.events) + 1
+	ev.ElapsedMs = scriptlib.ElapsedMs(t.start)
+	t.events = append(t.events, ev)
+	if t.sink != nil {
+		t.sink(ev)
+	}
+}
+
+// close 停止记录并返回已收集的时间线；未开启记录（nil）时返回 nil
+func (t *scriptTrace) close() []entities.ScriptTraceEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return t.events
+}
+
+// traceEvent 时间线记录的 SSE 事件（trace）
+func traceEvent(ev entities.ScriptTraceEvent) string {
+	b, _ := json.Marshal(ev)
+	return fmt.Sprintf("event: trace\ndata: %s\n\n", string(b))
+}
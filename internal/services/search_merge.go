@@ -0,0 +1,206 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"video-crawler/internal/entities"
+	"video-crawler/internal/utils"
+)
+
+// mergeCluster 同一影片的聚合簇
+type mergeCluster struct {
+	key       string
+	year      string
+	directors map[string]struct{}
+	actors    map[string]struct{}
+	merged    entities.MergedSearchVideoResult
+	seenURL   map[string]struct{}
+	// coverRichness 当前封面所属条目的元数据丰富度，封面等级相同时用于比较
+	coverRichness int
+}
+
+// MergeSearchVideoResults 将多个站点的搜索结果按影片去重合并。
+// 先按规范化后的名称分组，再用年份、导演、演员判断是否为同一部作品；
+// 每部作品返回一条记录，保留所有站点入口，并挑选信息最丰富的封面与简介。
+// 输出顺序与各作品首次出现的顺序一致。
+func MergeSearchVideoResults(items []entities.SourcedSearchVideoResult) []entities.MergedSearchVideoResult {
+	clusters := make([]*mergeCluster, 0, len(items))
+	byKey := make(map[string][]*mergeCluster)
+
+	for _, item := range items {
+		key := utils.NormalizeTitle(item.Result.Name)
+		if key == "" {
+			// 无法识别名称的条目不参与合并，按 URL 单独成组
+			key = "\x00" + item.SourceID + "|" + item.Result.URL
+		}
+		year := utils.ExtractYear(item.Result.ReleaseDate)
+		directors := toSet(utils.SplitPersons(item.Result.Director))
+		actors := toSet(utils.SplitPersons(item.Result.Actor))
+
+		var target *mergeCluster
+		for _, c := range byKey[key] {
+			if c.compatible(year, directors, actors) {
+				target = c
+				break
+			}
+		}
+		if target == nil {
+			target = &mergeCluster{
+				key:           key,
+				directors:     map[string]struct{}{},
+				actors:        map[string]struct{}{},
+				merged:        entities.MergedSearchVideoResult{SearchVideoResult: item.Result},
+				seenURL:       map[string]struct{}{},
+				coverRichness: metadataRichness(item.Result),
+			}
+			target.merged.Alternatives = []entities.SearchVideoAlternative{}
+			byKey[key] = append(byKey[key], target)
+			clusters = append(clusters, target)
+		} else {
+			target.mergeMetadata(item.Result)
+		}
+		target.absorb(item, year, directors, actors)
+	}
+
+	out := make([]entities.MergedSearchVideoResult, 0, len(clusters))
+	for _, c := range clusters {
+		out = append(out, c.merged)
+	}
+	return out
+}
+
+// compatible 判断条目是否可以并入该簇：年份冲突直接排除；
+// 双方都有导演或演员信息时，要求至少有一项存在交集
+func (c *mergeCluster) compatible(year string, directors, actors map[string]struct{}) bool {
+	if c.year != "" && year != "" && !yearsClose(c.year, year) {
+		return false
+	}
+	comparable, overlap := false, false
+	if len(c.directors) > 0 && len(directors) > 0 {
+		comparable = true
+		overlap = overlap || intersects(c.directors, directors)
+	}
+	if len(c.actors) > 0 && len(actors) > 0 {
+		comparable = true
+		overlap = overlap || intersects(c.actors, actors)
+	}
+	return !comparable || overlap
+}
+
+// absorb 记录条目的来源入口与用于后续比较的人员、年份信息
+func (c *mergeCluster) absorb(item entities.SourcedSearchVideoResult, year string, directors, actors map[string]struct{}) {
+	if c.year == "" {
+		c.year = year
+	}
+	for k := range directors {
+		c.directors[k] = struct{}{}
+	}
+	for k := range actors {
+		c.actors[k] = struct{}{}
+	}
+	dedupKey := item.SourceID + "|" + item.Result.URL
+	if _, ok := c.seenURL[dedupKey]; ok {
+		return
+	}
+	c.seenURL[dedupKey] = struct{}{}
+	c.merged.Alternatives = append(c.merged.Alternatives, entities.SearchVideoAlternative{
+		SourceID: item.SourceID,
+		URL:      item.Result.URL,
+	})
+}
+
+// mergeMetadata 合并元数据：封面取最优，简介取最长，其余字段补齐空值。
+// 封面先比较地址等级，等级相同时取元数据最完整的条目的封面
+func (c *mergeCluster) mergeMetadata(r entities.SearchVideoResult) {
+	m := &c.merged.SearchVideoResult
+	rank, curRank := coverRank(r.Cover), coverRank(m.Cover)
+	richness := metadataRichness(r)
+	if rank > curRank || (rank == curRank && rank > 0 && richness > c.coverRichness) {
+		m.Cover = r.Cover
+		c.coverRichness = richness
+	}
+	if len([]rune(strings.TrimSpace(r.Description))) > len([]rune(strings.TrimSpace(m.Description))) {
+		m.Description = r.Description
+	}
+	if len([]rune(r.Actor)) > len([]rune(m.Actor)) {
+		m.Actor = r.Actor
+	}
+	if len([]rune(r.Director)) > len([]rune(m.Director)) {
+		m.Director = r.Director
+	}
+	fillEmpty(&m.Type, r.Type)
+	fillEmpty(&m.ReleaseDate, r.ReleaseDate)
+	fillEmpty(&m.Region, r.Region)
+	fillEmpty(&m.Language, r.Language)
+	fillEmpty(&m.Score, r.Score)
+}
+
+// coverRank 封面优先级：绝对 https > 绝对 http > 协议相对 > 其它非空 > 空。
+// 缺少主机名的地址（如 "https:///a.jpg"）视为其它非空
+func coverRank(cover string) int {
+	cover = strings.TrimSpace(cover)
+	if cover == "" {
+		return 0
+	}
+	u, err := url.Parse(cover)
+	if err != nil || u.Host == "" {
+		return 1
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return 4
+	case "http":
+		return 3
+	case "":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// metadataRichness 条目已填写的元数据字段数
+func metadataRichness(r entities.SearchVideoResult) int {
+	n := 0
+	for _, v := range []string{r.Type, r.Actor, r.Director, r.ReleaseDate, r.Region, r.Language, r.Description, r.Score} {
+		if strings.TrimSpace(v) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func fillEmpty(dst *string, v string) {
+	if strings.TrimSpace(*dst) == "" {
+		*dst = v
+	}
+}
+
+// yearsClose 年份允许 1 年误差（不同站点常以首播/上映年份区分）
+func yearsClose(a, b string) bool {
+	ya, errA := strconv.Atoi(a)
+	yb, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	d := ya - yb
+	return d >= -1 && d <= 1
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, it := range items {
+		set[it] = struct{}{}
+	}
+	return set
+}
+
+func intersects(a, b map[string]struct{}) bool {
+	for k := range a {
+		if _, ok := b[k]; ok {
+			return true
+		}
+	}
+	return false
+}
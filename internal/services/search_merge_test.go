@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"video-crawler/internal/entities"
+)
+
+func TestMergeSearchVideoResults(t *testing.T) {
+	items := []entities.SourcedSearchVideoResult{
+		{SourceID: "a", Result: entities.SearchVideoResult{Name: "狂飙", URL: "https://a/1", ReleaseDate: "2023", Director: "徐纪周", Description: "短"}},
+		{SourceID: "b", Result: entities.SearchVideoResult{Name: "狂飆 ", URL: "https://b/9", Cover: "https://b/c.jpg", ReleaseDate: "2023-01-14", Director: "徐紀周,", Description: "更长的一段剧情简介"}},
+		{SourceID: "c", Result: entities.SearchVideoResult{Name: "狂飙！", URL: "https://c/3", ReleaseDate: "1998", Director: "张三"}},
+		{SourceID: "d", Result: entities.SearchVideoResult{Name: "ＡＢＣ：Ｍｕｒｄｅｒｓ", URL: "https://d/1"}},
+		{SourceID: "e", Result: entities.SearchVideoResult{Name: "abc murders", URL: "https://e/1"}},
+	}
+
+	merged := MergeSearchVideoResults(items)
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 merged results, got %d: %+v", len(merged), merged)
+	}
+
+	first := merged[0]
+	if first.URL != "https://a/1" || len(first.Alternatives) != 2 {
+		t.Fatalf("unexpected first cluster: %+v", first)
+	}
+	if first.Cover != "https://b/c.jpg" || first.Description != "更长的一段剧情简介" {
+		t.Fatalf("metadata not merged: cover=%q description=%q", first.Cover, first.Description)
+	}
+	if merged[1].Alternatives[0].SourceID != "c" || len(merged[1].Alternatives) != 1 {
+		t.Fatalf("different year should not merge: %+v", merged[1])
+	}
+	if len(merged[2].Alternatives) != 2 {
+		t.Fatalf("full-width title should merge with half-width: %+v", merged[2])
+	}
+}
+
+func TestMergeSearchVideoResultsCover(t *testing.T) {
+	items := []entities.SourcedSearchVideoResult{
+		{SourceID: "a", Result: entities.SearchVideoResult{Name: "历史", URL: "https://a/1", Cover: "/img/a.jpg", Type: "纪录片"}},
+		{SourceID: "b", Result: entities.SearchVideoResult{Name: "歷史", URL: "https://b/1", Cover: "http://b/c.jpg"}},
+		{SourceID: "c", Result: entities.SearchVideoResult{Name: "历史", URL: "https://c/1", Cover: "https://c/c.jpg"}},
+		{SourceID: "d", Result: entities.SearchVideoResult{Name: "历史", URL: "https://d/1", Cover: "https://d/c.jpg", Type: "纪录片", Region: "大陆", Score: "9.0"}},
+		{SourceID: "e", Result: entities.SearchVideoResult{Name: "历史", URL: "https://e/1", Cover: "https://e/c.jpg", Type: "纪录片"}},
+		{SourceID: "f", Result: entities.SearchVideoResult{Name: "历史", URL: "https://f/1", Cover: "https:///f.jpg", Type: "纪录片", Region: "大陆", Score: "9.0", Description: "简介"}},
+	}
+
+	merged := MergeSearchVideoResults(items)
+	if len(merged) != 1 || len(merged[0].Alternatives) != len(items) {
+		t.Fatalf("traditional and simplified titles should merge: %+v", merged)
+	}
+	if merged[0].Cover != "https://d/c.jpg" {
+		t.Fatalf("expected https cover from the richest entry, got %q", merged[0].Cover)
+	}
+
+	ranks := []string{"", "/img/a.jpg", "https:///f.jpg", "//cdn/c.jpg", "http://b/c.jpg", "https://c/c.jpg"}
+	for i := 1; i < len(ranks); i++ {
+		if coverRank(ranks[i]) < coverRank(ranks[i-1]) {
+			t.Fatalf("coverRank(%q) should not be lower than coverRank(%q)", ranks[i], ranks[i-1])
+		}
+	}
+}
@@ -18,23 +18,40 @@ import (
 type VideoSourceService interface {
 	List() ([]entities.VideoSourceListResponse, error)
 	Detail(videoSourceId string) (entities.VideoSourceEntity, error)
-	Save(videoSource entities.VideoSourceEntity) error
+	// Save 创建或更新站点，返回保存后的站点；引擎或脚本变化时生成新版本，author 与 comment 记录在版本中。
+	// 版本号与稳定版本由服务端维护，忽略传入的值
+	Save(videoSource entities.VideoSourceEntity, author, comment string) (entities.VideoSourceEntity, error)
 	Delete(videoSourceId string) error
 	UpdateStatus(videoSourceId string, status int) error
+	// UpdateHealthCheck 只更新站点状态与最近一次检查记录，不生成版本，不覆盖检查期间对站点的其他修改
+	UpdateHealthCheck(videoSourceId string, status int, check *entities.VideoSourceHealthCheck) error
 	Import(importData []entities.VideoSourceEntity) (int, error)
+	// Revisions 列出站点的版本（不含脚本内容），按版本号降序
+	Revisions(sourceID string) ([]entities.VideoSourceRevision, error)
+	Revision(sourceID string, revision int) (entities.VideoSourceRevision, error)
+	// DiffRevisions 比较两个版本的脚本，to 为 0 时与当前版本比较
+	DiffRevisions(sourceID string, from, to int) (entities.VideoSourceRevisionDiff, error)
+	// RollbackRevision 以指定版本的引擎与脚本生成一个新版本
+	RollbackRevision(sourceID string, revision int, author, comment string) (entities.VideoSourceEntity, error)
+	// PinStable 固定稳定版本，视频接口执行该版本的脚本；revision 为 0 时取消固定
+	PinStable(sourceID string, revision int, author string) (entities.VideoSourceEntity, error)
 }
 
 type videoSourceService struct {
 	videoSourceMap  *sync.Map
 	videoSourceList []entities.VideoSourceEntity
 	isWriting       bool
+	revisions       *videoSourceRevisionStore
+	store           ScriptStoreService // 站点删除时清理其脚本键值存储，可为 nil
 }
 
-func NewVideoSourceService() VideoSourceService {
+func NewVideoSourceService(store ScriptStoreService) VideoSourceService {
 	videoSourceService := &videoSourceService{
 		videoSourceMap:  &sync.Map{},
 		videoSourceList: []entities.VideoSourceEntity{},
 		isWriting:       false,
+		revisions:       newVideoSourceRevisionStore(),
+		store:           store,
 	}
 
 	// 使用数据目录
@@ -102,7 +119,7 @@ func (s *videoSourceService) List() ([]entities.VideoSourceListResponse, error)
 	videoSourceList := []entities.VideoSourceListResponse{}
 	s.videoSourceMap.Range(func(key, value interface{}) bool {
 		videoSource := value.(entities.VideoSourceEntity)
-		videoSourceList = append(videoSourceList, entities.VideoSourceListResponse{
+		item := entities.VideoSourceListResponse{
 			Id:         videoSource.Id,
 			Name:       videoSource.Name,
 			Domain:     videoSource.Domain,
@@ -112,7 +129,13 @@ func (s *videoSourceService) List() ([]entities.VideoSourceListResponse, error)
 			EngineType: videoSource.EngineType,
 			LuaScript:  videoSource.LuaScript,
 			JsScript:   videoSource.JsScript,
-		})
+			LastCheck:  videoSource.LastCheck,
+			Revision:   videoSource.Revision,
+		}
+		if videoSource.Stable != nil {
+			item.StableRevision = videoSource.Stable.Revision
+		}
+		videoSourceList = append(videoSourceList, item)
 		return true
 	})
 	return videoSourceList, nil
@@ -167,14 +190,30 @@ func (s *videoSourceService) reloadVideoSource() {
 
 }
 
-func (s *videoSourceService) Save(videoSource entities.VideoSourceEntity) error {
+func (s *videoSourceService) Save(videoSource entities.VideoSourceEntity, author, comment string) (entities.VideoSourceEntity, error) {
+	return s.save(videoSource, author, comment, 0)
+}
+
+// save 保存站点，rollbackFrom 不为 0 时表示由回滚生成的版本
+func (s *videoSourceService) save(videoSource entities.VideoSourceEntity, author, comment string, rollbackFrom int) (entities.VideoSourceEntity, error) {
 	// 如果是新站点（ID为空），生成新的UUID
 	if videoSource.Id == "" {
 		videoSource.Id = uuid.New().String()
 	}
 
 	// 检查站点是否已存在
-	_, exists := s.videoSourceMap.Load(videoSource.Id)
+	existing, exists := s.videoSourceMap.Load(videoSource.Id)
+	var previous *entities.VideoSourceEntity
+	videoSource.Revision, videoSource.Stable = 0, nil
+	if exists {
+		prev := existing.(entities.VideoSourceEntity)
+		previous = &prev
+		videoSource.Revision, videoSource.Stable = prev.Revision, prev.Stable
+	}
+	// 先记录版本：站点保存失败时多出的版本不会被当作当前版本
+	if err := s.recordRevision(&videoSource, previous, author, comment, rollbackFrom); err != nil {
+		return entities.VideoSourceEntity{}, err
+	}
 
 	// 更新内存中的数据
 	s.videoSourceMap.Store(videoSource.Id, videoSource)
@@ -183,13 +222,15 @@ func (s *videoSourceService) Save(videoSource entities.VideoSourceEntity) error
 	err := s.saveToFile()
 	if err != nil {
 		// 如果保存失败，回滚内存中的数据
-		if !exists {
+		if exists {
+			s.videoSourceMap.Store(videoSource.Id, *previous)
+		} else {
 			s.videoSourceMap.Delete(videoSource.Id)
 		}
-		return err
+		return entities.VideoSourceEntity{}, err
 	}
 
-	return nil
+	return videoSource, nil
 }
 
 func (s *videoSourceService) Delete(videoSourceId string) error {
@@ -210,6 +251,14 @@ func (s *videoSourceService) Delete(videoSourceId string) error {
 		return err
 	}
 
+	if err := s.revisions.remove(videoSourceId); err != nil {
+		logrus.WithError(err).WithField("source_id", videoSourceId).Error("failed to remove video source revisions")
+	}
+	if s.store != nil {
+		if _, err := s.store.Clear(videoSourceId, ""); err != nil {
+			logrus.WithError(err).WithField("source_id", videoSourceId).Error("failed to clear video source script store")
+		}
+	}
 	return nil
 }
 
@@ -224,6 +273,18 @@ func (s *videoSourceService) UpdateStatus(videoSourceId string, status int) erro
 	return s.saveToFile()
 }
 
+func (s *videoSourceService) UpdateHealthCheck(videoSourceId string, status int, check *entities.VideoSourceHealthCheck) error {
+	val, ok := s.videoSourceMap.Load(videoSourceId)
+	if !ok {
+		return errors.New("video source not found")
+	}
+	vs := val.(entities.VideoSourceEntity)
+	vs.Status = status
+	vs.LastCheck = check
+	s.videoSourceMap.Store(videoSourceId, vs)
+	return s.saveToFile()
+}
+
 func (s *videoSourceService) saveToFile() error {
 	dataDir := config.GetDataDir()
 	videoSourceConfigFilePath := filepath.Join(dataDir, "video-source.json")
@@ -273,6 +334,11 @@ func (s *videoSourceService) Import(importData []entities.VideoSourceEntity) (in
 			videoSource.Id = uuid.New().String()
 		}
 
+		// 导入的脚本记录为一个新版本，导出文件中的稳定版本自带脚本，保持不变
+		if err := s.recordRevision(&videoSource, nil, "", "导入站点", 0); err != nil {
+			return 0, err
+		}
+
 		// 保存到内存中
 		s.videoSourceMap.Store(videoSource.Id, videoSource)
 		importedCount++
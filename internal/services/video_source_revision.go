@@ -0,0 +1,253 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"video-crawler/internal/config"
+	"video-crawler/internal/entities"
+	"video-crawler/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// videoSourceRevisionLimit 每个站点保留的版本数，超过时删除最旧的版本
+const videoSourceRevisionLimit = 50
+
+var ErrVideoSourceRevisionNotFound = errors.New("版本不存在")
+
+// videoSourceRevisionStore 站点脚本的版本历史，与站点配置分开保存，导出站点时不包含历史
+type videoSourceRevisionStore struct {
+	file      string
+	mutex     sync.RWMutex
+	revisions map[string][]entities.VideoSourceRevision // 站点 ID -> 版本，按版本号升序
+}
+
+func newVideoSourceRevisionStore() *videoSourceRevisionStore {
+	s := &videoSourceRevisionStore{
+		file:      filepath.Join(config.GetDataDir(), "video-source-revisions.json"),
+		revisions: map[string][]entities.VideoSourceRevision{},
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithError(err).Error("failed to read video source revisions")
+		}
+		return s
+	}
+	if err := json.Unmarshal(data, &s.revisions); err != nil {
+		logrus.WithError(err).Error("failed to unmarshal video source revisions")
+	}
+	return s
+}
+
+// saveLocked 持久化版本历史，调用方需持有写锁
+func (s *videoSourceRevisionStore) saveLocked() error {
+	data, err := json.Marshal(s.revisions)
+	if err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// add 以站点当前的引擎与脚本生成新版本并持久化，返回新版本
+func (s *videoSourceRevisionStore) add(src entities.VideoSourceEntity, author, comment string, rollbackFrom int) (entities.VideoSourceRevision, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list := s.revisions[src.Id]
+	// 版本号不重复使用：历史被清理或站点从导出文件导入时沿用更大的编号
+	number := src.Revision
+	if len(list) > 0 {
+		number = max(number, list[len(list)-1].Revision)
+	}
+	rev := entities.VideoSourceRevision{
+		SourceID:     src.Id,
+		Revision:     number + 1,
+		EngineType:   src.EngineType,
+		LuaScript:    src.LuaScript,
+		JsScript:     src.JsScript,
+		Comment:      comment,
+		CreatedAt:    time.Now().Unix(),
+		CreatedBy:    author,
+		RollbackFrom: rollbackFrom,
+	}
+	updated := append(append([]entities.VideoSourceRevision(nil), list...), rev)
+	if len(updated) > videoSourceRevisionLimit {
+		updated = updated[len(updated)-videoSourceRevisionLimit:]
+	}
+	s.revisions[src.Id] = updated
+	if err := s.saveLocked(); err != nil {
+		if list == nil {
+			delete(s.revisions, src.Id)
+		} else {
+			s.revisions[src.Id] = list
+		}
+		return entities.VideoSourceRevision{}, err
+	}
+	return rev, nil
+}
+
+// list 站点的版本，按版本号降序
+func (s *videoSourceRevisionStore) list(sourceID string) []entities.VideoSourceRevision {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	list := s.revisions[sourceID]
+	result := make([]entities.VideoSourceRevision, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		result = append(result, list[i])
+	}
+	return result
+}
+
+func (s *videoSourceRevisionStore) get(sourceID string, revision int) (entities.VideoSourceRevision, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, rev := range s.revisions[sourceID] {
+		if rev.Revision == revision {
+			return rev, nil
+		}
+	}
+	return entities.VideoSourceRevision{}, fmt.Errorf("%w: %d", ErrVideoSourceRevisionNotFound, revision)
+}
+
+// remove 删除站点的全部版本
+func (s *videoSourceRevisionStore) remove(sourceID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list, ok := s.revisions[sourceID]
+	if !ok {
+		return nil
+	}
+	delete(s.revisions, sourceID)
+	if err := s.saveLocked(); err != nil {
+		s.revisions[sourceID] = list
+		return err
+	}
+	return nil
+}
+
+// scriptChanged 引擎或任一脚本变化时需要生成新版本
+func scriptChanged(a, b entities.VideoSourceEntity) bool {
+	return a.EngineType != b.EngineType || a.LuaScript != b.LuaScript || a.JsScript != b.JsScript
+}
+
+// recordRevision 脚本变化时生成新版本并更新 videoSource.Revision。
+// 启用版本管理前保存的站点（Revision 为 0）先把原脚本记录为初始版本，修改前的脚本不会丢失
+func (s *videoSourceService) recordRevision(videoSource *entities.VideoSourceEntity, previous *entities.VideoSourceEntity, author, comment string, rollbackFrom int) error {
+	if previous != nil && previous.Revision > 0 && !scriptChanged(*previous, *videoSource) {
+		return nil
+	}
+	if previous != nil && previous.Revision == 0 {
+		if !scriptChanged(*previous, *videoSource) {
+			// 只记录初始版本，不生成重复的版本
+			author, comment = "", "初始版本"
+		} else {
+			base, err := s.revisions.add(*previous, "", "初始版本", 0)
+			if err != nil {
+				return err
+			}
+			videoSource.Revision = base.Revision
+		}
+	}
+	rev, err := s.revisions.add(*videoSource, author, comment, rollbackFrom)
+	if err != nil {
+		return err
+	}
+	videoSource.Revision = rev.Revision
+	return nil
+}
+
+func (s *videoSourceService) Revisions(sourceID string) ([]entities.VideoSourceRevision, error) {
+	if _, err := s.Detail(sourceID); err != nil {
+		return nil, err
+	}
+	list := s.revisions.list(sourceID)
+	// 列表不返回脚本内容，通过 Revision 或 DiffRevisions 查看
+	for i := range list {
+		list[i].LuaScript, list[i].JsScript = "", ""
+	}
+	return list, nil
+}
+
+func (s *videoSourceService) Revision(sourceID string, revision int) (entities.VideoSourceRevision, error) {
+	if _, err := s.Detail(sourceID); err != nil {
+		return entities.VideoSourceRevision{}, err
+	}
+	return s.revisions.get(sourceID, revision)
+}
+
+func (s *videoSourceService) DiffRevisions(sourceID string, from, to int) (entities.VideoSourceRevisionDiff, error) {
+	src, err := s.Detail(sourceID)
+	if err != nil {
+		return entities.VideoSourceRevisionDiff{}, err
+	}
+	if to == 0 {
+		to = src.Revision
+	}
+	fromRev, err := s.revisions.get(sourceID, from)
+	if err != nil {
+		return entities.VideoSourceRevisionDiff{}, err
+	}
+	toRev, err := s.revisions.get(sourceID, to)
+	if err != nil {
+		return entities.VideoSourceRevisionDiff{}, err
+	}
+	result := entities.VideoSourceRevisionDiff{From: fromRev, To: toRev}
+	result.Diff, result.Added, result.Removed = utils.UnifiedDiff(fmt.Sprintf("r%d", from), fmt.Sprintf("r%d", to), fromRev.Script(), toRev.Script())
+	return result, nil
+}
+
+func (s *videoSourceService) RollbackRevision(sourceID string, revision int, author, comment string) (entities.VideoSourceEntity, error) {
+	src, err := s.Detail(sourceID)
+	if err != nil {
+		return entities.VideoSourceEntity{}, err
+	}
+	rev, err := s.revisions.get(sourceID, revision)
+	if err != nil {
+		return entities.VideoSourceEntity{}, err
+	}
+	if comment == "" {
+		comment = fmt.Sprintf("回滚到版本 %d", revision)
+	}
+	src.EngineType, src.LuaScript, src.JsScript = rev.EngineType, rev.LuaScript, rev.JsScript
+	return s.save(src, author, comment, revision)
+}
+
+func (s *videoSourceService) PinStable(sourceID string, revision int, author string) (entities.VideoSourceEntity, error) {
+	src, err := s.Detail(sourceID)
+	if err != nil {
+		return entities.VideoSourceEntity{}, err
+	}
+	previous := src
+	if revision == 0 {
+		src.Stable = nil
+	} else {
+		rev, err := s.revisions.get(sourceID, revision)
+		if err != nil {
+			return entities.VideoSourceEntity{}, err
+		}
+		src.Stable = &entities.VideoSourceStable{
+			Revision:   rev.Revision,
+			EngineType: rev.EngineType,
+			LuaScript:  rev.LuaScript,
+			JsScript:   rev.JsScript,
+			PinnedAt:   time.Now().Unix(),
+			PinnedBy:   author,
+		}
+	}
+	s.videoSourceMap.Store(sourceID, src)
+	if err := s.saveToFile(); err != nil {
+		s.videoSourceMap.Store(sourceID, previous)
+		return entities.VideoSourceEntity{}, err
+	}
+	return src, nil
+}
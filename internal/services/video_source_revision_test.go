@@ -0,0 +1,110 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"video-crawler/internal/entities"
+)
+
+func TestVideoSourceRevisions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", dir)
+	s := &videoSourceService{
+		videoSourceMap: &sync.Map{},
+		revisions:      &videoSourceRevisionStore{file: filepath.Join(dir, "video-source-revisions.json"), revisions: map[string][]entities.VideoSourceRevision{}},
+	}
+
+	src, err := s.Save(entities.VideoSourceEntity{Name: "a", LuaScript: "return 1\n"}, "admin", "创建")
+	if err != nil || src.Revision != 1 {
+		t.Fatalf("create = %+v, %v", src, err)
+	}
+	// 只修改站点信息时不生成版本
+	src.Name = "b"
+	if src, _ = s.Save(src, "admin", ""); src.Revision != 1 {
+		t.Fatalf("revision = %d", src.Revision)
+	}
+	src.LuaScript = "return 2\n"
+	if src, _ = s.Save(src, "editor", "改为 2"); src.Revision != 2 {
+		t.Fatalf("revision = %d", src.Revision)
+	}
+
+	if src, err = s.PinStable(src.Id, 1, "admin"); err != nil || src.Runtime().LuaScript != "return 1\n" {
+		t.Fatalf("pin = %+v, %v", src.Stable, err)
+	}
+	// 客户端保存的站点不带稳定版本，服务端保留
+	src.LuaScript, src.Stable = "return 3\n", nil
+	if src, _ = s.Save(src, "editor", ""); src.Revision != 3 || src.Stable == nil || src.Stable.Revision != 1 {
+		t.Fatalf("save with stable = %+v", src)
+	}
+
+	diff, err := s.DiffRevisions(src.Id, 1, 0)
+	if err != nil || diff.To.Revision != 3 || diff.Added != 1 || diff.Removed != 1 || !strings.Contains(diff.Diff, "-return 1\n+return 3\n") {
+		t.Fatalf("diff = %+v, %v", diff, err)
+	}
+
+	if src, err = s.RollbackRevision(src.Id, 2, "admin", ""); err != nil || src.Revision != 4 || src.LuaScript != "return 2\n" {
+		t.Fatalf("rollback = %+v, %v", src, err)
+	}
+	list, _ := s.Revisions(src.Id)
+	if len(list) != 4 || list[0].RollbackFrom != 2 || list[0].Comment != "回滚到版本 2" || list[0].LuaScript != "" || list[3].CreatedBy != "admin" {
+		t.Fatalf("revisions = %+v", list)
+	}
+	if _, err := s.RollbackRevision(src.Id, 9, "admin", ""); err == nil {
+		t.Fatal("rollback to missing revision should fail")
+	}
+
+	// 站点检查只更新状态与检查记录，不生成版本
+	check := &entities.VideoSourceHealthCheck{Status: 2, Error: "timeout"}
+	if err := s.UpdateHealthCheck(src.Id, 2, check); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Detail(src.Id); got.Status != 2 || got.LastCheck != check || got.Revision != 4 || got.LuaScript != "return 2\n" {
+		t.Fatalf("after health check = %+v", got)
+	}
+
+	// 重新加载后版本历史仍在
+	if got := newVideoSourceRevisionStore().list(src.Id); len(got) != 4 {
+		t.Fatalf("reloaded revisions = %d", len(got))
+	}
+}
+
+func TestVideoSourceDeleteClearsStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("VIDEO_CRAWLER_CONFIG_DIR", dir)
+	store := newScriptStoreService(filepath.Join(dir, "script-store"))
+	s := &videoSourceService{
+		videoSourceMap: &sync.Map{},
+		revisions:      &videoSourceRevisionStore{file: filepath.Join(dir, "video-source-revisions.json"), revisions: map[string][]entities.VideoSourceRevision{}},
+		store:          store,
+	}
+	src, err := s.Save(entities.VideoSourceEntity{Name: "a", LuaScript: "return 1\n"}, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SourceStore(src.Id).Set("token", "t", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.sourceFile(src.Id)); err != nil {
+		t.Fatalf("store file should exist: %v", err)
+	}
+
+	if err := s.Delete(src.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.sourceFile(src.Id)); !os.IsNotExist(err) {
+		t.Fatalf("store file should be removed with the source: %v", err)
+	}
+	if entries := store.Entries(src.Id); len(entries) != 0 {
+		t.Fatalf("entries = %v", entries)
+	}
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中变更前后保留的上下文行数
+const diffContext = 3
+
+// diffMaxEdits 逐行比较的最大编辑距离，超过时剩余部分按整段删除再新增输出，避免超大差异占用过多内存
+const diffMaxEdits = 2000
+
+// diffOp 一行的编辑操作：' ' 相同、'-' 删除、'+' 新增；oldLine / newLine 为该行之前已处理的行数
+type diffOp struct {
+	kind    byte
+	oldLine int
+	newLine int
+	text    string
+}
+
+// UnifiedDiff 按行比较两段文本，返回 unified diff 格式的差异与新增、删除的行数；文本相同时 diff 为空
+func UnifiedDiff(oldName, newName, oldText, newText string) (diff string, added, removed int) {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+	var b strings.Builder
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start, end := max(i-diffContext, 0), i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(ops) && ops[j].kind == ' ' {
+				j++
+			}
+			if j == len(ops) || j-end > 2*diffContext {
+				end = min(end+diffContext, j)
+				break
+			}
+			end = j
+		}
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		writeHunk(&b, ops[start:end])
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+		i = end
+	}
+	return b.String(), added, removed
+}
+
+func writeHunk(b *strings.Builder, ops []diffOp) {
+	oldLen, newLen := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldLen++
+		}
+		if op.kind != '-' {
+			newLen++
+		}
+	}
+	// 没有行时起始行号为之前的行号（unified diff 约定）
+	oldStart, newStart := ops[0].oldLine, ops[0].newLine
+	if oldLen > 0 {
+		oldStart++
+	}
+	if newLen > 0 {
+		newStart++
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+	for _, op := range ops {
+		b.WriteByte(op.kind)
+		b.WriteString(op.text)
+		b.WriteByte('\n')
+	}
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines 使用 Myers 算法计算最短的逐行编辑序列，公共的开头与结尾先行跳过
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', oldLine: i, newLine: i, text: a[i]})
+	}
+	for _, op := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		op.oldLine += prefix
+		op.newLine += prefix
+		ops = append(ops, op)
+	}
+	for i := 0; i < suffix; i++ {
+		x, y := len(a)-suffix+i, len(b)-suffix+i
+		ops = append(ops, diffOp{kind: ' ', oldLine: x, newLine: y, text: a[x]})
+	}
+	return ops
+}
+
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, diffMaxEdits)
+	// trace[d] 为第 d 步后各对角线 k（-d..d）上到达的最远 x，下标为 k+d
+	var trace [][]int
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]):
+				x = trace[d-1][k+1+d-1]
+			default:
+				x = trace[d-1][k-1+d-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				found = true
+			}
+		}
+		trace = append(trace, v)
+	}
+	if !found {
+		return replaceAll(a, b)
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		k := x - y
+		prev := trace[d-1]
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: ' ', oldLine: x, newLine: y, text: a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{kind: '+', oldLine: x, newLine: y, text: b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{kind: '-', oldLine: x, newLine: y, text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{kind: ' ', oldLine: x, newLine: y, text: a[x]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAll 差异过大时按整段删除再新增输出
+func replaceAll(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, diffOp{kind: '-', oldLine: i, text: line})
+	}
+	for i, line := range b {
+		ops = append(ops, diffOp{kind: '+', oldLine: len(a), newLine: i, text: line})
+	}
+	return ops
+}
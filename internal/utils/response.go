@@ -11,12 +11,24 @@ type Response struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data"`
+	// Warnings 数据可用但存在问题时附带的提示，如脚本结果校验中的缺失字段
+	Warnings any `json:"warnings,omitempty"`
 }
 
 func SuccessResponse(ctx *gin.Context, data any) {
 	SendResponse(ctx, consts.ResponseCodeSuccess, "success", data)
 }
 
+// SuccessResponseWithWarnings 成功响应并附带警告，warnings 为空时与 SuccessResponse 相同
+func SuccessResponseWithWarnings(ctx *gin.Context, data any, warnings any) {
+	ctx.JSON(http.StatusOK, Response{
+		Code:     consts.ResponseCodeSuccess,
+		Message:  "success",
+		Data:     data,
+		Warnings: warnings,
+	})
+}
+
 func SendResponse(ctx *gin.Context, code int, msg string, data any) {
 	ctx.JSON(http.StatusOK, Response{
 		Code:    code,
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+var (
+	t2sMap = func() map[rune]rune {
+		pairs := []rune(t2sPairs)
+		m := make(map[rune]rune, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			m[pairs[i]] = pairs[i+1]
+		}
+		return m
+	}()
+	yearRegexp = regexp.MustCompile(`(19|20)\d{2}`)
+	// 人名列表常见分隔符
+	personSeparators = regexp.MustCompile(`[,，/、|;；\s]+`)
+)
+
+// ToSimplifiedChinese 将繁体字逐字转为简体（未收录的字符原样保留）
+func ToSimplifiedChinese(s string) string {
+	return strings.Map(func(r rune) rune {
+		if v, ok := t2sMap[r]; ok {
+			return v
+		}
+		return r
+	}, s)
+}
+
+// NormalizeTitle 规范化影视标题，用于跨站点比较：
+// 全角转半角、繁体转简体、英文小写，并去除所有标点与空白
+func NormalizeTitle(title string) string {
+	folded := ToSimplifiedChinese(width.Fold.String(title))
+	var b strings.Builder
+	for _, r := range strings.ToLower(folded) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ExtractYear 从日期/年份文本中提取四位年份，未找到返回空字符串
+func ExtractYear(s string) string {
+	return yearRegexp.FindString(width.Fold.String(s))
+}
+
+// SplitPersons 将演员/导演文本拆分为规范化后的人名列表
+func SplitPersons(s string) []string {
+	s = ToSimplifiedChinese(width.Fold.String(s))
+	parts := personSeparators.Split(s, -1)
+	names := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if n := NormalizeTitle(p); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
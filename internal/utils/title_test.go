@@ -0,0 +1,22 @@
+package utils
+
+import "testing"
+
+func TestNormalizeTitle(t *testing.T) {
+	cases := []struct{ a, b string }{
+		{"歷史", "历史"},
+		{"後宮·甄嬛傳", "后宫甄嬛传"},
+		{"鬥破蒼穹", "斗破苍穹"},
+		{"臺灣往事", "台湾往事"},
+		{"慶餘年 第二季", "庆余年第二季"},
+		{"ＡＢＣ：Ｍｕｒｄｅｒｓ", "abc murders"},
+	}
+	for _, c := range cases {
+		if NormalizeTitle(c.a) != NormalizeTitle(c.b) {
+			t.Errorf("NormalizeTitle(%q)=%q, NormalizeTitle(%q)=%q", c.a, NormalizeTitle(c.a), c.b, NormalizeTitle(c.b))
+		}
+	}
+	if got := ToSimplifiedChinese("歷史 abc 历史"); got != "历史 abc 历史" {
+		t.Fatalf("ToSimplifiedChinese: %q", got)
+	}
+}
@@ -93,7 +93,9 @@ func (a *App) domReady(ctx context.Context) {
 
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
-	// Perform your teardown here
+	if a.app != nil {
+		a.app.Close()
+	}
 }
 
 // 获取应用配置目录